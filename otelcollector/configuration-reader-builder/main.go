@@ -1,17 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
-	"os/exec"
-	"strings"
-	"time"
+	"path/filepath"
+	"sync"
 
 	"os"
 
+	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/configescape"
 	configmapsettings "github.com/prometheus-collector/shared/configmap/mp"
+	"github.com/prometheus-collector/shared/watcher"
 
 	allocatorconfig "github.com/open-telemetry/opentelemetry-operator/cmd/otel-allocator/config"
 	yaml "gopkg.in/yaml.v2"
@@ -58,168 +61,117 @@ var RESET = "\033[0m"
 var RED = "\033[31m"
 
 var taConfigFilePath = "/ta-configuration/targetallocator.yaml"
-var taConfigUpdated = false
-var taLivenessCounter = 0
-var taLivenessStartTime = time.Time{}
+var terminationLogPath = "/dev/termination-log"
+var configWatcher *watcher.Watcher
+
+// generateCommand is the subcommand that processes the configmaps and writes the TA config once
+const generateCommand = "generate"
+
+// generateMu serializes the generations of the TA config
+var generateMu sync.Mutex
+
+// regenerateMu guards regenerateErr, the error of the last regeneration of the TA config after a configmap change
+var regenerateMu sync.Mutex
+var regenerateErr error
 
 func logFatalError(message string) {
 	// Always log the full message
 	log.Fatalf("%s%s%s", RED, message, RESET)
 }
 
-func updateTAConfigFile(configFilePath string) {
+// updateTAConfigFile writes the TA config for the scrape configs of the otel config at configFilePath. The file is
+// replaced atomically, since the TA reloads it as soon as it changes.
+func updateTAConfigFile(configFilePath string) error {
 	defaultsMergedConfigFileContents, err := os.ReadFile(configFilePath)
 	if err != nil {
-		logFatalError(fmt.Sprintf("config-reader::Unable to read file contents from: %s - %v\n", configFilePath, err))
-		os.Exit(1)
+		return fmt.Errorf("config-reader::Unable to read file contents from: %s - %v", configFilePath, err)
 	}
 	var promScrapeConfig map[string]interface{}
 	var otelConfig OtelConfig
 	err = yaml.Unmarshal([]byte(defaultsMergedConfigFileContents), &otelConfig)
 	if err != nil {
-		logFatalError(fmt.Sprintf("config-reader::Unable to unmarshal merged otel configuration from: %s - %v\n", configFilePath, err))
-		os.Exit(1)
+		return fmt.Errorf("config-reader::Unable to unmarshal merged otel configuration from: %s - %v", configFilePath, err)
 	}
 
 	promScrapeConfig = otelConfig.Receivers.Prometheus.Config
-	// Removing $$ added for regex and replacement in relabel_config and metric_relabel_config added by promconfigvalidator.
-	// The $$ are required by the validator's otel get method, but the TA doesnt do env substitution and hence needs to be removed, else TA crashes.
-	scrapeConfigs := promScrapeConfig["scrape_configs"]
-	if scrapeConfigs != nil {
-		var sc = scrapeConfigs.([]interface{})
-		for _, scrapeConfig := range sc {
-			scrapeConfig := scrapeConfig.(map[interface{}]interface{})
-			if scrapeConfig["relabel_configs"] != nil {
-				relabelConfigs := scrapeConfig["relabel_configs"].([]interface{})
-				for _, relabelConfig := range relabelConfigs {
-					relabelConfig := relabelConfig.(map[interface{}]interface{})
-					//replace $$ with $ for regex field
-					if relabelConfig["regex"] != nil {
-						// Adding this check here since regex can be boolean and the conversion will fail
-						if _, isString := relabelConfig["regex"].(string); isString {
-							regexString := relabelConfig["regex"].(string)
-							modifiedRegexString := strings.ReplaceAll(regexString, "$$", "$")
-							relabelConfig["regex"] = modifiedRegexString
-						}
-					}
-					//replace $$ with $ for replacement field
-					if relabelConfig["replacement"] != nil {
-						replacement := relabelConfig["replacement"].(string)
-						modifiedReplacementString := strings.ReplaceAll(replacement, "$$", "$")
-						relabelConfig["replacement"] = modifiedReplacementString
-					}
-				}
-			}
+	// Removing the $ escaping added to every string by promconfigvalidator. The $$ are required by the otelcollector's
+	// env substitution, but the TA doesnt do env substitution and hence needs them removed, else TA crashes.
+	configescape.UnescapeConfig(promScrapeConfig)
 
-			if scrapeConfig["metric_relabel_configs"] != nil {
-				metricRelabelConfigs := scrapeConfig["metric_relabel_configs"].([]interface{})
-				for _, metricRelabelConfig := range metricRelabelConfigs {
-					metricRelabelConfig := metricRelabelConfig.(map[interface{}]interface{})
-					//replace $$ with $ for regex field
-					if metricRelabelConfig["regex"] != nil {
-						// Adding this check here since regex can be boolean and the conversion will fail
-						if _, isString := metricRelabelConfig["regex"].(string); isString {
-							regexString := metricRelabelConfig["regex"].(string)
-							modifiedRegexString := strings.ReplaceAll(regexString, "$$", "$")
-							metricRelabelConfig["regex"] = modifiedRegexString
-						}
-					}
+	targetAllocatorConfig := loadTASettings(taSettingsMountPath).toTAConfig(promScrapeConfig)
 
-					//replace $$ with $ for replacement field
-					if metricRelabelConfig["replacement"] != nil {
-						replacement := metricRelabelConfig["replacement"].(string)
-						modifiedReplacementString := strings.ReplaceAll(replacement, "$$", "$")
-						metricRelabelConfig["replacement"] = modifiedReplacementString
-					}
-				}
-			}
-		}
+	targetAllocatorConfigYaml, _ := yaml.Marshal(targetAllocatorConfig)
+	// Written next to the TA config and renamed over it, so that the TA never reads a partly written file
+	tmpFile, err := os.CreateTemp(filepath.Dir(taConfigFilePath), ".targetallocator-*.yaml")
+	if err != nil {
+		return fmt.Errorf("config-reader::Unable to write to: %s - %v", taConfigFilePath, err)
 	}
-
-	targetAllocatorConfig := Config{
-		AllocationStrategy: "consistent-hashing",
-		FilterStrategy:     "relabel-config",
-		CollectorSelector: &metav1.LabelSelector{
-			MatchLabels: map[string]string{
-				"rsName":                         "ama-metrics",
-				"kubernetes.azure.com/managedby": "aks",
-			},
-		},
-		Config: promScrapeConfig,
-		PrometheusCR: allocatorconfig.PrometheusCRConfig{
-			ServiceMonitorSelector: &metav1.LabelSelector{},
-			PodMonitorSelector:     &metav1.LabelSelector{},
-		},
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(targetAllocatorConfigYaml)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
 	}
-
-	targetAllocatorConfigYaml, _ := yaml.Marshal(targetAllocatorConfig)
-	if err := os.WriteFile(taConfigFilePath, targetAllocatorConfigYaml, 0644); err != nil {
-		logFatalError(fmt.Sprintf("config-reader::Unable to write to: %s - %v\n", taConfigFilePath, err))
-		os.Exit(1)
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), taConfigFilePath)
+	}
+	if err != nil {
+		return fmt.Errorf("config-reader::Unable to write to: %s - %v", taConfigFilePath, err)
 	}
 
 	log.Println("Updated file - targetallocator.yaml for the TargetAllocator to pick up new config changes")
-	taConfigUpdated = true
-	taLivenessStartTime = time.Now()
+	return nil
 }
 
-func hasConfigChanged(filePath string) bool {
-	if _, err := os.Stat(filePath); err == nil {
-		fileInfo, err := os.Stat(filePath)
-		if err != nil {
-			fmt.Println("Error getting file info:", err)
-			os.Exit(1)
+// writeTAConfig processes the configmaps and writes the TA config for the resulting scrape configs
+func writeTAConfig() error {
+	// The configs of a previous run are not regenerated otherwise
+	configmapsettings.ResetGeneratedConfigs()
+	runtimeSettings := configmapsettings.Configmapparser()
+	if runtimeSettings.UseDefaultPrometheusConfig {
+		if _, err := os.Stat("/opt/microsoft/otelcollector/collector-config-default.yml"); err == nil {
+			return updateTAConfigFile("/opt/microsoft/otelcollector/collector-config-default.yml")
 		}
-
-		return fileInfo.Size() > 0
+	} else if _, err := os.Stat("/opt/microsoft/otelcollector/collector-config.yml"); err == nil {
+		return updateTAConfigFile("/opt/microsoft/otelcollector/collector-config.yml")
+	} else {
+		log.Println("No configs found via configmap, not running config reader")
 	}
-	return false
+	return nil
 }
 
-func taHealthHandler(w http.ResponseWriter, r *http.Request) {
-	status := http.StatusOK
-	message := "\ntargetallocator is running."
-
-	client := &http.Client{Timeout: time.Duration(2) * time.Second}
-
-	req, err := http.NewRequest("GET", "http://localhost:8080/metrics", nil)
-	if err == nil {
-		resp, _ := client.Do(req)
-		if resp != nil && resp.StatusCode == http.StatusOK {
-			if taConfigUpdated {
-				if !taLivenessStartTime.IsZero() {
-					duration := time.Since(taLivenessStartTime)
-					// Serve the response of ServiceUnavailable for 60s and then reset
-					if duration.Seconds() < 60 {
-						status = http.StatusServiceUnavailable
-						message += "targetallocator-config changed"
-					} else {
-						taConfigUpdated = false
-						taLivenessStartTime = time.Time{}
-					}
-				}
-			}
+// generateTAConfig writes the TA config in a new process, so that every generation starts from the environment
+// of the container rather than the one left by the previous generation
+func generateTAConfig() error {
+	generateMu.Lock()
+	defer generateMu.Unlock()
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("config-reader::Unable to find the config reader executable - %v", err)
+	}
+	if err := shared.StartCommandAndWait(executable, generateCommand); err != nil {
+		return fmt.Errorf("config-reader::Unable to generate the TA config - %v", err)
+	}
+	return nil
+}
 
-			if status != http.StatusOK {
-				fmt.Printf(message)
-			}
-			w.WriteHeader(status)
-			fmt.Fprintln(w, message)
-		}
-		if resp != nil && resp.Body != nil {
-			defer resp.Body.Close()
-		}
-	} else {
-		message = "\ncall to get TA metrics failed"
-		status = http.StatusServiceUnavailable
-		fmt.Printf(message)
-		w.WriteHeader(status)
-		fmt.Fprintln(w, message)
+// regenerateTAConfig processes the configmaps again after they changed, the TA picks up the new config without
+// restarting. If it fails, the previous TA config is kept and the liveness probe restarts the container.
+func regenerateTAConfig(ev watcher.Event) {
+	log.Printf("Configuration change detected, regenerating the TA config: %s\n", ev)
+	err := generateTAConfig()
+	if err != nil {
+		log.Printf("%s%v%s\n", RED, err, RESET)
 	}
+	regenerateMu.Lock()
+	regenerateErr = err
+	regenerateMu.Unlock()
 }
 
 func writeTerminationLog(message string) {
-	if err := os.WriteFile("/dev/termination-log", []byte(message), fs.FileMode(0644)); err != nil {
+	if err := os.WriteFile(terminationLogPath, []byte(message), fs.FileMode(0644)); err != nil {
 		log.Printf("Error writing to termination log: %v", err)
 	}
 }
@@ -228,9 +180,12 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusOK
 	message := "\nconfig-reader is running."
 
-	if hasConfigChanged("/opt/inotifyoutput.txt") {
+	regenerateMu.Lock()
+	err := regenerateErr
+	regenerateMu.Unlock()
+	if err != nil {
 		status = http.StatusServiceUnavailable
-		message += "\ninotifyoutput.txt has been updated - config-reader-config changed"
+		message += "\nconfig-reader-config changed and the TA config could not be regenerated - " + err.Error()
 	}
 
 	w.WriteHeader(status)
@@ -242,38 +197,23 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	_, err := os.Create("/opt/inotifyoutput.txt")
-	if err != nil {
-		log.Fatalf("Error creating output file: %v\n", err)
+	if len(os.Args) > 1 && os.Args[1] == generateCommand {
+		if err := writeTAConfig(); err != nil {
+			logFatalError(err.Error() + "\n")
+		}
+		os.Exit(0)
 	}
 
-	// Define the command to start inotify for config reader's liveness probe
-	inotifyCommandCfg := exec.Command(
-		"inotifywait",
-		"/etc/config/settings",
-		"--daemon",
-		"--recursive",
-		"--outfile", "/opt/inotifyoutput.txt",
-		"--event", "create,delete",
-		"--format", "%e : %T",
-		"--timefmt", "+%s",
-	)
-
-	// Start the inotify process
-	err = inotifyCommandCfg.Start()
-	if err != nil {
-		log.Fatalf("Error starting inotify process for config reader's liveness probe: %v\n", err)
+	// Changes to the configmaps are applied by regenerating the TA config, which the TA watches
+	configWatcher = watcher.New("/etc/config/settings")
+	configWatcher.OnChange(regenerateTAConfig)
+	if err := configWatcher.Start(context.Background()); err != nil {
+		log.Fatalf("Error watching /etc/config/settings for config changes: %v\n", err)
 	}
 
-	configmapsettings.Configmapparser()
-	if os.Getenv("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG") == "true" {
-		if _, err = os.Stat("/opt/microsoft/otelcollector/collector-config-default.yml"); err == nil {
-			updateTAConfigFile("/opt/microsoft/otelcollector/collector-config-default.yml")
-		}
-	} else if _, err = os.Stat("/opt/microsoft/otelcollector/collector-config.yml"); err == nil {
-		updateTAConfigFile("/opt/microsoft/otelcollector/collector-config.yml")
-	} else {
-		log.Println("No configs found via configmap, not running config reader")
+	if err := generateTAConfig(); err != nil {
+		logFatalError(err.Error() + "\n")
+		os.Exit(1)
 	}
 
 	http.HandleFunc("/health", healthHandler)
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// useTempPaths points the TA config, TA settings and termination log paths to a temporary directory
+func useTempPaths(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := map[*string]string{
+		&taConfigFilePath:    filepath.Join(dir, "targetallocator.yaml"),
+		&taSettingsMountPath: filepath.Join(dir, "target-allocator-settings"),
+		&terminationLogPath:  filepath.Join(dir, "termination-log"),
+	}
+	for path, value := range paths {
+		defaultValue := *path
+		*path = value
+		t.Cleanup(func() { *path = defaultValue })
+	}
+	return dir
+}
+
+func TestUpdateTAConfigFile(t *testing.T) {
+	dir := useTempPaths(t)
+	otelConfig := filepath.Join(dir, "collector-config.yml")
+	require.NoError(t, os.WriteFile(otelConfig, []byte(`receivers:
+  prometheus:
+    config:
+      scrape_configs:
+      - job_name: app
+        relabel_configs:
+        - source_labels: [__meta_kubernetes_pod_label_app]
+          regex: (.+)
+          replacement: $$1-$${POD}
+          target_label: app
+`), 0644))
+	require.NoError(t, os.WriteFile(taConfigFilePath, []byte("previous"), 0600))
+
+	require.NoError(t, updateTAConfigFile(otelConfig))
+
+	content, err := os.ReadFile(taConfigFilePath)
+	require.NoError(t, err)
+	var taConfig Config
+	require.NoError(t, yaml.Unmarshal(content, &taConfig))
+	assert.Equal(t, "consistent-hashing", taConfig.AllocationStrategy)
+	scrapeConfigs := taConfig.Config["scrape_configs"].([]interface{})
+	relabelConfigs := scrapeConfigs[0].(map[interface{}]interface{})["relabel_configs"].([]interface{})
+	// The TA does no env substitution, so the $ escaping of the otel config is removed
+	assert.Equal(t, "$1-${POD}", relabelConfigs[0].(map[interface{}]interface{})["replacement"])
+
+	// The file was replaced by a rename, which leaves no temporary file and resets the mode
+	info, err := os.Stat(taConfigFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"collector-config.yml", "targetallocator.yaml"}, names)
+}
+
+func TestUpdateTAConfigFileFailureKeepsTheTAConfig(t *testing.T) {
+	dir := useTempPaths(t)
+	require.NoError(t, os.WriteFile(taConfigFilePath, []byte("previous"), 0644))
+
+	err := updateTAConfigFile(filepath.Join(dir, "missing.yml"))
+	assert.ErrorContains(t, err, "Unable to read file contents")
+	invalid := filepath.Join(dir, "invalid.yml")
+	require.NoError(t, os.WriteFile(invalid, []byte("receivers: ["), 0644))
+	err = updateTAConfigFile(invalid)
+	assert.ErrorContains(t, err, "Unable to unmarshal")
+
+	content, err := os.ReadFile(taConfigFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(content))
+}
+
+func TestHealthHandler(t *testing.T) {
+	useTempPaths(t)
+	setRegenerateErr := func(err error) {
+		regenerateMu.Lock()
+		defer regenerateMu.Unlock()
+		regenerateErr = err
+	}
+	t.Cleanup(func() { setRegenerateErr(nil) })
+
+	recorder := httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "config-reader is running.")
+	assert.NoFileExists(t, terminationLogPath)
+
+	setRegenerateErr(errors.New("config-reader::Unable to generate the TA config - exit status 1"))
+	recorder = httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "the TA config could not be regenerated - config-reader::Unable to generate the TA config - exit status 1")
+	content, err := os.ReadFile(terminationLogPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "exit status 1")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var taURL = "http://localhost:8080"
+
+// taCollectorsMetric is the TA metric with the number of collectors it allocates the targets to
+const taCollectorsMetric = "opentelemetry_allocator_collectors_allocatable"
+
+// taCRJobPrefixes are the prefixes of the jobs the TA generates for the ServiceMonitors and PodMonitors, which are
+// not in the TA config
+var taCRJobPrefixes = []string{"serviceMonitor/", "podMonitor/"}
+
+// TAHealth is the body of the TA health check, which is the liveness probe of the TA. The TA is healthy if it
+// answers and has no job that is not in the TA config, i.e. it applied the last TA config. A TA that is not ready
+// or allocates the targets to no collector is reported in the warnings but is healthy, since restarting it does not
+// help while the collectors are starting. The jobs of the TA config without targets are reported too, since a job
+// can legitimately discover no target.
+type TAHealth struct {
+	Healthy            bool     `json:"healthy"`
+	Ready              bool     `json:"ready"`
+	Collectors         int      `json:"collectors"`
+	UnexpectedJobs     []string `json:"unexpectedJobs,omitempty"`
+	JobsWithoutTargets []string `json:"jobsWithoutTargets,omitempty"`
+	Warnings           []string `json:"warnings,omitempty"`
+	Errors             []string `json:"errors,omitempty"`
+}
+
+// taHealthTimeout is the time for all the queries of the TA health check, under the timeout of the liveness probe
+var taHealthTimeout = 4 * time.Second
+
+// taGet sends a GET request for path to the TA
+func taGet(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, taURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// checkTAHealth queries the readiness, the jobs and the collectors of the TA and compares its jobs with the ones
+// of the TA config
+func checkTAHealth(ctx context.Context) TAHealth {
+	var health TAHealth
+	addError := func(format string, a ...interface{}) {
+		health.Errors = append(health.Errors, fmt.Sprintf(format, a...))
+	}
+	addWarning := func(format string, a ...interface{}) {
+		health.Warnings = append(health.Warnings, fmt.Sprintf(format, a...))
+	}
+
+	if resp, err := taGet(ctx, "/readyz"); err != nil {
+		addError("call to get TA readiness failed - %v", err)
+	} else {
+		resp.Body.Close()
+		health.Ready = resp.StatusCode == http.StatusOK
+		if !health.Ready {
+			addWarning("TA is not ready, /readyz returned %s", resp.Status)
+		}
+	}
+
+	collectors, err := getTACollectors(ctx)
+	if err != nil {
+		addError("call to get TA collectors failed - %v", err)
+	} else {
+		health.Collectors = collectors
+		if collectors == 0 {
+			addWarning("no collector is registered in the TA")
+		}
+	}
+
+	taJobs, err := getTAJobs(ctx)
+	if err != nil {
+		addError("call to get TA jobs failed - %v", err)
+	}
+	configJobs, configErr := getTAConfigJobs(taConfigFilePath)
+	if configErr != nil {
+		addError("unable to read the jobs of %s - %v", taConfigFilePath, configErr)
+	}
+	if err == nil && configErr == nil {
+		for job := range taJobs {
+			if !configJobs[job] && !isTACRJob(job) {
+				health.UnexpectedJobs = append(health.UnexpectedJobs, job)
+			}
+		}
+		for job := range configJobs {
+			if _, ok := taJobs[job]; !ok {
+				health.JobsWithoutTargets = append(health.JobsWithoutTargets, job)
+			}
+		}
+		slices.Sort(health.UnexpectedJobs)
+		slices.Sort(health.JobsWithoutTargets)
+		if len(health.UnexpectedJobs) > 0 {
+			addError("the TA has jobs that are not in %s, it did not apply the last config", taConfigFilePath)
+		}
+	}
+
+	health.Healthy = len(health.Errors) == 0
+	return health
+}
+
+// getTAJobs returns the jobs with targets of the TA
+func getTAJobs(ctx context.Context) (map[string]json.RawMessage, error) {
+	resp, err := taGet(ctx, "/jobs")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/jobs returned %s", resp.Status)
+	}
+	jobs := make(map[string]json.RawMessage)
+	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("unable to decode the jobs - %v", err)
+	}
+	return jobs, nil
+}
+
+// getTACollectors returns the number of collectors the TA allocates the targets to, from its metrics
+func getTACollectors(ctx context.Context) (int, error) {
+	resp, err := taGet(ctx, "/metrics")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("/metrics returned %s", resp.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse the metrics - %v", err)
+	}
+	family, ok := families[taCollectorsMetric]
+	if !ok {
+		return 0, nil
+	}
+	collectors := 0
+	for _, metric := range family.GetMetric() {
+		collectors += int(metric.GetGauge().GetValue())
+	}
+	return collectors, nil
+}
+
+// getTAConfigJobs returns the jobs of the TA config at path
+func getTAConfigJobs(path string) (map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var taConfig struct {
+		Config struct {
+			ScrapeConfigs []struct {
+				JobName string `yaml:"job_name"`
+			} `yaml:"scrape_configs"`
+		} `yaml:"config"`
+	}
+	if err := yaml.Unmarshal(content, &taConfig); err != nil {
+		return nil, err
+	}
+	jobs := make(map[string]bool)
+	for _, scrapeConfig := range taConfig.Config.ScrapeConfigs {
+		jobs[scrapeConfig.JobName] = true
+	}
+	return jobs, nil
+}
+
+func isTACRJob(job string) bool {
+	for _, prefix := range taCRJobPrefixes {
+		if strings.HasPrefix(job, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func taHealthHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), taHealthTimeout)
+	defer cancel()
+	health := checkTAHealth(ctx)
+
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+		fmt.Printf("targetallocator is unhealthy - %s\n", strings.Join(health.Errors, ", "))
+	} else if len(health.Warnings) > 0 {
+		fmt.Printf("targetallocator is healthy with warnings - %s\n", strings.Join(health.Warnings, ", "))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
+}
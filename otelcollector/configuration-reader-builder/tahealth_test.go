@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTA answers the TA health check queries like the TA
+type fakeTA struct {
+	readyStatus int
+	collectors  int
+	jobs        []string
+}
+
+func (ta fakeTA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/readyz":
+		w.WriteHeader(ta.readyStatus)
+	case "/metrics":
+		fmt.Fprintf(w, "# TYPE %s gauge\n%s{strategy=\"consistent-hashing\"} %d\n", taCollectorsMetric, taCollectorsMetric, ta.collectors)
+	case "/jobs":
+		jobs := map[string]map[string]string{}
+		for _, job := range ta.jobs {
+			jobs[job] = map[string]string{"_link": "/jobs/" + job + "/targets"}
+		}
+		json.NewEncoder(w).Encode(jobs)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// useFakeTA points the TA health check to ta and writes a TA config with the jobs configJobs
+func useFakeTA(t *testing.T, ta http.Handler, configJobs ...string) {
+	t.Helper()
+	useTempPaths(t)
+	server := httptest.NewServer(ta)
+	t.Cleanup(server.Close)
+	defaultURL := taURL
+	taURL = server.URL
+	t.Cleanup(func() { taURL = defaultURL })
+
+	config := "config:\n  scrape_configs:\n"
+	for _, job := range configJobs {
+		config += fmt.Sprintf("  - job_name: %s\n", job)
+	}
+	require.NoError(t, os.WriteFile(taConfigFilePath, []byte(config), 0644))
+}
+
+func TestCheckTAHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		ta         fakeTA
+		configJobs []string
+		want       TAHealth
+	}{
+		{
+			name:       "healthy",
+			ta:         fakeTA{readyStatus: http.StatusOK, collectors: 2, jobs: []string{"kubelet", "app"}},
+			configJobs: []string{"kubelet", "app"},
+			want:       TAHealth{Healthy: true, Ready: true, Collectors: 2},
+		},
+		{
+			name:       "job without targets",
+			ta:         fakeTA{readyStatus: http.StatusOK, collectors: 1, jobs: []string{"kubelet"}},
+			configJobs: []string{"kubelet", "app"},
+			want:       TAHealth{Healthy: true, Ready: true, Collectors: 1, JobsWithoutTargets: []string{"app"}},
+		},
+		{
+			name:       "ServiceMonitor and PodMonitor jobs",
+			ta:         fakeTA{readyStatus: http.StatusOK, collectors: 1, jobs: []string{"kubelet", "serviceMonitor/default/app/0", "podMonitor/default/app/0"}},
+			configJobs: []string{"kubelet"},
+			want:       TAHealth{Healthy: true, Ready: true, Collectors: 1},
+		},
+		{
+			name:       "zero collectors",
+			ta:         fakeTA{readyStatus: http.StatusOK, jobs: []string{"kubelet"}},
+			configJobs: []string{"kubelet"},
+			want:       TAHealth{Healthy: true, Ready: true, Warnings: []string{"no collector is registered in the TA"}},
+		},
+		{
+			name:       "not ready",
+			ta:         fakeTA{readyStatus: http.StatusServiceUnavailable, collectors: 1, jobs: []string{"kubelet"}},
+			configJobs: []string{"kubelet"},
+			want:       TAHealth{Healthy: true, Collectors: 1, Warnings: []string{"TA is not ready, /readyz returned 503 Service Unavailable"}},
+		},
+		{
+			name:       "mismatched jobs",
+			ta:         fakeTA{readyStatus: http.StatusOK, collectors: 1, jobs: []string{"kubelet", "removed"}},
+			configJobs: []string{"kubelet", "added"},
+			want: TAHealth{
+				Ready:              true,
+				Collectors:         1,
+				UnexpectedJobs:     []string{"removed"},
+				JobsWithoutTargets: []string{"added"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTA(t, tt.ta, tt.configJobs...)
+			got := checkTAHealth(context.Background())
+			if len(tt.want.UnexpectedJobs) > 0 {
+				require.Len(t, got.Errors, 1)
+				assert.Contains(t, got.Errors[0], "it did not apply the last config")
+				got.Errors = nil
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckTAHealthUnreachable(t *testing.T) {
+	useFakeTA(t, fakeTA{}, "kubelet")
+	taURL = "http://127.0.0.1:1"
+
+	got := checkTAHealth(context.Background())
+	assert.False(t, got.Healthy)
+	require.Len(t, got.Errors, 3)
+	assert.Contains(t, got.Errors[0], "call to get TA readiness failed")
+	assert.Contains(t, got.Errors[1], "call to get TA collectors failed")
+	assert.Contains(t, got.Errors[2], "call to get TA jobs failed")
+}
+
+func TestCheckTAHealthWithoutTAConfig(t *testing.T) {
+	useFakeTA(t, fakeTA{readyStatus: http.StatusOK, collectors: 1})
+	require.NoError(t, os.Remove(taConfigFilePath))
+
+	got := checkTAHealth(context.Background())
+	assert.False(t, got.Healthy)
+	require.Len(t, got.Errors, 1)
+	assert.Contains(t, got.Errors[0], "unable to read the jobs of "+taConfigFilePath)
+}
+
+func TestGetTAJobsAndCollectorsErrors(t *testing.T) {
+	useFakeTA(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jobs" {
+			fmt.Fprint(w, "[]")
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	_, err := getTAJobs(context.Background())
+	assert.ErrorContains(t, err, "unable to decode the jobs")
+	_, err = getTACollectors(context.Background())
+	assert.ErrorContains(t, err, "/metrics returned 500 Internal Server Error")
+}
+
+func TestGetTAConfigJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targetallocator.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("allocation_strategy: consistent-hashing\nconfig:\n  scrape_configs:\n  - job_name: a\n  - job_name: b\n"), 0644))
+	jobs, err := getTAConfigJobs(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, jobs)
+
+	require.NoError(t, os.WriteFile(path, []byte("config: ["), 0644))
+	_, err = getTAConfigJobs(path)
+	assert.Error(t, err)
+}
+
+func TestTAHealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		ta         fakeTA
+		wantStatus int
+	}{
+		{"healthy", fakeTA{readyStatus: http.StatusOK, collectors: 1, jobs: []string{"kubelet"}}, http.StatusOK},
+		// The liveness probe does not restart a TA that waits for its collectors
+		{"zero collectors and not ready", fakeTA{readyStatus: http.StatusServiceUnavailable, jobs: []string{"kubelet"}}, http.StatusOK},
+		{"unexpected job", fakeTA{readyStatus: http.StatusOK, collectors: 1, jobs: []string{"removed"}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTA(t, tt.ta, "kubelet")
+			recorder := httptest.NewRecorder()
+			taHealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/health-ta", nil))
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			var health TAHealth
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&health))
+			assert.Equal(t, tt.wantStatus == http.StatusOK, health.Healthy)
+		})
+	}
+}
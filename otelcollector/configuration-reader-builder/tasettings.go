@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/open-telemetry/opentelemetry-operator/cmd/otel-allocator/allocation"
+	allocatorconfig "github.com/open-telemetry/opentelemetry-operator/cmd/otel-allocator/config"
+	"github.com/pelletier/go-toml"
+	yaml "gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var taSettingsMountPath = "/etc/config/settings/target-allocator-settings"
+
+// TASettings are the settings of the TA from the target-allocator-settings section of the settings configmap. They
+// have the fields and the yaml keys of allocatorconfig.Config, the label selectors use the matchlabels and
+// matchexpressions keys like in the TA config. The https settings are left out, as the TA container has no
+// certificates mounted.
+type TASettings struct {
+	AllocationStrategy string                             `yaml:"allocation_strategy,omitempty"`
+	FilterStrategy     string                             `yaml:"filter_strategy,omitempty"`
+	CollectorSelector  *metav1.LabelSelector              `yaml:"collector_selector,omitempty"`
+	PrometheusCR       allocatorconfig.PrometheusCRConfig `yaml:"prometheus_cr,omitempty"`
+}
+
+// defaultTASettings returns the settings used when the section is not mounted or is invalid. The collector selector
+// selects the ama-metrics replicas and the ServiceMonitor and PodMonitor selectors select everything.
+func defaultTASettings() TASettings {
+	return TASettings{
+		AllocationStrategy: allocatorconfig.DefaultAllocationStrategy,
+		FilterStrategy:     allocatorconfig.DefaultFilterStrategy,
+		CollectorSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"rsName":                         "ama-metrics",
+				"kubernetes.azure.com/managedby": "aks",
+			},
+		},
+		PrometheusCR: allocatorconfig.PrometheusCRConfig{
+			ServiceMonitorSelector: &metav1.LabelSelector{},
+			PodMonitorSelector:     &metav1.LabelSelector{},
+		},
+	}
+}
+
+// loadTASettings reads the TA settings from the configmap section at path. The defaults are used if the section is
+// not mounted, and the section is ignored if it is invalid.
+func loadTASettings(path string) TASettings {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("config-reader::Error reading the TA settings from %s, using defaults - %v\n", path, err)
+		}
+		return defaultTASettings()
+	}
+	taSettings, err := parseTASettings(content)
+	if err != nil {
+		log.Printf("%sconfig-reader::Invalid TA settings in the configmap, using defaults, please check the target-allocator-settings section - %v%s\n", RED, err, RESET)
+		return defaultTASettings()
+	}
+	log.Printf("config-reader::Using the TA settings from the configmap: allocation strategy %s, filter strategy %s\n", taSettings.AllocationStrategy, taSettings.FilterStrategy)
+	return taSettings
+}
+
+// parseTASettings parses the TOML of the configmap section and validates it against the TA config. The unset
+// fields get the default values.
+func parseTASettings(content []byte) (TASettings, error) {
+	var taSettings TASettings
+	parsedConfig := make(map[string]interface{})
+	if err := toml.Unmarshal(content, &parsedConfig); err != nil {
+		return taSettings, fmt.Errorf("unable to parse the TOML: %v", err)
+	}
+	if _, ok := parsedConfig["https"]; ok {
+		log.Printf("%sconfig-reader::Ignoring the https settings of the target-allocator-settings section, https is not supported as the TA has no certificates mounted%s\n", RED, RESET)
+		delete(parsedConfig, "https")
+	}
+	// The TOML is converted to yaml, so that the fields are decoded like the TA decodes its config
+	taSettingsYaml, err := yaml.Marshal(parsedConfig)
+	if err != nil {
+		return taSettings, err
+	}
+	if err := yaml.UnmarshalStrict(taSettingsYaml, &taSettings); err != nil {
+		return taSettings, err
+	}
+
+	defaults := defaultTASettings()
+	if taSettings.AllocationStrategy == "" {
+		taSettings.AllocationStrategy = defaults.AllocationStrategy
+	}
+	if taSettings.FilterStrategy == "" {
+		taSettings.FilterStrategy = defaults.FilterStrategy
+	}
+	if taSettings.CollectorSelector == nil {
+		taSettings.CollectorSelector = defaults.CollectorSelector
+	}
+	if taSettings.PrometheusCR.ServiceMonitorSelector == nil {
+		taSettings.PrometheusCR.ServiceMonitorSelector = defaults.PrometheusCR.ServiceMonitorSelector
+	}
+	if taSettings.PrometheusCR.PodMonitorSelector == nil {
+		taSettings.PrometheusCR.PodMonitorSelector = defaults.PrometheusCR.PodMonitorSelector
+	}
+	return taSettings, taSettings.validate()
+}
+
+// validate checks the values the TA would reject or silently ignore
+func (s TASettings) validate() error {
+	strategies := allocation.GetRegisteredAllocatorNames()
+	if !slices.Contains(strategies, s.AllocationStrategy) {
+		slices.Sort(strategies)
+		return fmt.Errorf("unknown allocation_strategy %q, the strategies are %v", s.AllocationStrategy, strategies)
+	}
+	// The TA disables the filtering for an unknown filter strategy, which would scrape the dropped targets
+	if s.FilterStrategy != allocatorconfig.DefaultFilterStrategy {
+		return fmt.Errorf("unknown filter_strategy %q, the only strategy is %s", s.FilterStrategy, allocatorconfig.DefaultFilterStrategy)
+	}
+	selectors := []struct {
+		name     string
+		selector *metav1.LabelSelector
+	}{
+		{"collector_selector", s.CollectorSelector},
+		{"prometheus_cr.service_monitor_selector", s.PrometheusCR.ServiceMonitorSelector},
+		{"prometheus_cr.pod_monitor_selector", s.PrometheusCR.PodMonitorSelector},
+		{"prometheus_cr.service_monitor_namespace_selector", s.PrometheusCR.ServiceMonitorNamespaceSelector},
+		{"prometheus_cr.pod_monitor_namespace_selector", s.PrometheusCR.PodMonitorNamespaceSelector},
+	}
+	for _, sel := range selectors {
+		if _, err := metav1.LabelSelectorAsSelector(sel.selector); err != nil {
+			return fmt.Errorf("invalid %s: %v", sel.name, err)
+		}
+	}
+	if s.PrometheusCR.ScrapeInterval < 0 {
+		return fmt.Errorf("invalid prometheus_cr.scrape_interval %s, it must be positive", s.PrometheusCR.ScrapeInterval)
+	}
+	return nil
+}
+
+// toTAConfig returns the TA config for the scrape configs with these settings
+func (s TASettings) toTAConfig(promScrapeConfig map[string]interface{}) Config {
+	return Config{
+		AllocationStrategy: s.AllocationStrategy,
+		FilterStrategy:     s.FilterStrategy,
+		CollectorSelector:  s.CollectorSelector,
+		Config:             promScrapeConfig,
+		PrometheusCR:       s.PrometheusCR,
+	}
+}
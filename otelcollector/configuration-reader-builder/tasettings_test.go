@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseTASettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    func(s *TASettings)
+		wantErr string
+	}{
+		{name: "empty", content: ""},
+		{
+			name: "settings",
+			content: `allocation_strategy = "per-node"
+[collector_selector.matchlabels]
+app = "collector"
+[prometheus_cr]
+scrape_interval = "1m"
+[prometheus_cr.service_monitor_selector.matchlabels]
+team = "a"
+`,
+			want: func(s *TASettings) {
+				s.AllocationStrategy = "per-node"
+				s.CollectorSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "collector"}}
+				s.PrometheusCR.ScrapeInterval = model.Duration(time.Minute)
+				s.PrometheusCR.ServiceMonitorSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+			},
+		},
+		{name: "invalid TOML", content: `allocation_strategy = `, wantErr: "unable to parse the TOML"},
+		{name: "unknown key", content: `allocation_strategi = "per-node"`, wantErr: "field allocation_strategi not found"},
+		{name: "unknown allocation strategy", content: `allocation_strategy = "round-robin"`, wantErr: `unknown allocation_strategy "round-robin", the strategies are [consistent-hashing least-weighted per-node]`},
+		{name: "unknown filter strategy", content: `filter_strategy = "none"`, wantErr: `unknown filter_strategy "none"`},
+		{
+			name: "invalid selector",
+			content: `[[prometheus_cr.pod_monitor_selector.matchexpressions]]
+key = "team"
+operator = "Equals"
+`,
+			wantErr: "invalid prometheus_cr.pod_monitor_selector",
+		},
+		{
+			name: "negative scrape interval",
+			content: `[prometheus_cr]
+scrape_interval = "-30s"
+`,
+			wantErr: "not a valid duration string",
+		},
+		{
+			// The TA has no certificates mounted, the https settings are ignored and the other settings are kept
+			name: "https",
+			content: `allocation_strategy = "per-node"
+[https]
+enabled = true
+ca_file_path = "/certs/ca.crt"
+tls_cert_file_path = "/certs/tls.crt"
+tls_key_file_path = "/certs/tls.key"
+`,
+			want: func(s *TASettings) {
+				s.AllocationStrategy = "per-node"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTASettings([]byte(tt.content))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := defaultTASettings()
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestLoadTASettings(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, defaultTASettings(), loadTASettings(filepath.Join(dir, "missing")))
+
+	invalid := filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(invalid, []byte(`allocation_strategy = "round-robin"`), 0644))
+	assert.Equal(t, defaultTASettings(), loadTASettings(invalid))
+
+	valid := filepath.Join(dir, "valid")
+	require.NoError(t, os.WriteFile(valid, []byte(`allocation_strategy = "least-weighted"`), 0644))
+	assert.Equal(t, "least-weighted", loadTASettings(valid).AllocationStrategy)
+}
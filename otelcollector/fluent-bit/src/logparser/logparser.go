@@ -0,0 +1,225 @@
+// Package logparser parses the MetricsExtension and otelcollector log records the fluent-bit plugin
+// turns into telemetry. Every parser returns an error, and counts a failure, when a record does not have
+// the fields it expects, so a change in the log format is noticed instead of being reported as zeros.
+package logparser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The formats of the records that can be parsed, used to count the parse failures
+const (
+	FormatMEProcessedCount    = "me_processed_count"
+	FormatMEReceivedCount     = "me_received_count"
+	FormatMEDiagnostics       = "me_diagnostics"
+	FormatMEInfiniteMetric    = "me_infinite_metric"
+	FormatOtelCollectorRecord = "otelcollector_record"
+)
+
+// Formats lists every format that can be parsed
+var Formats = []string{
+	FormatMEProcessedCount,
+	FormatMEReceivedCount,
+	FormatMEDiagnostics,
+	FormatMEInfiniteMetric,
+	FormatOtelCollectorRecord,
+}
+
+// ErrMissingField is returned when a record does not have a field the format requires
+var ErrMissingField = errors.New("missing field")
+
+// fieldPattern matches the Key: value fields in ME messages. Values are either quoted, or run up to the
+// next whitespace or comma.
+var fieldPattern = regexp.MustCompile(`(\w+):\s*("[^"]*"|[^\s,]+)`)
+
+var (
+	failuresMu sync.Mutex
+	failures   = map[string]uint64{}
+)
+
+// Failures returns the number of records that failed to parse for format since the process started
+func Failures(format string) uint64 {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return failures[format]
+}
+
+func fail(format string, err error) error {
+	failuresMu.Lock()
+	failures[format]++
+	failuresMu.Unlock()
+	return fmt.Errorf("%s: %w", format, err)
+}
+
+// MEProcessedCount is the number of timeseries and bytes ME processed and sent to storage for a metrics
+// account in its last reporting period
+type MEProcessedCount struct {
+	MetricsAccount         string
+	ProcessedCount         int64
+	ProcessedBytes         int64
+	SentToPublicationCount int64
+	SentToPublicationBytes int64
+}
+
+// ParseMEProcessedCount parses an ME message with the ProcessedCount fields. The metrics account is the
+// third word of the message.
+func ParseMEProcessedCount(message string) (MEProcessedCount, error) {
+	words := strings.Fields(message)
+	if len(words) < 3 {
+		return MEProcessedCount{}, fail(FormatMEProcessedCount, fmt.Errorf("%w metrics account", ErrMissingField))
+	}
+	f := parseFields(message)
+	p := MEProcessedCount{MetricsAccount: words[2]}
+	var err error
+	if p.ProcessedCount, err = f.int("ProcessedCount"); err != nil {
+		return MEProcessedCount{}, fail(FormatMEProcessedCount, err)
+	}
+	if p.ProcessedBytes, err = f.int("ProcessedBytes"); err != nil {
+		return MEProcessedCount{}, fail(FormatMEProcessedCount, err)
+	}
+	if p.SentToPublicationCount, err = f.int("SentToPublicationCount"); err != nil {
+		return MEProcessedCount{}, fail(FormatMEProcessedCount, err)
+	}
+	if p.SentToPublicationBytes, err = f.int("SentToPublicationBytes"); err != nil {
+		return MEProcessedCount{}, fail(FormatMEProcessedCount, err)
+	}
+	return p, nil
+}
+
+// MEReceivedCount is the number of timeseries ME received in its last reporting period
+type MEReceivedCount struct {
+	EventsProcessedLastPeriod int64
+}
+
+// ParseMEReceivedCount parses an ME message with the EventsProcessedLastPeriod field
+func ParseMEReceivedCount(message string) (MEReceivedCount, error) {
+	n, err := parseFields(message).int("EventsProcessedLastPeriod")
+	if err != nil {
+		return MEReceivedCount{}, fail(FormatMEReceivedCount, err)
+	}
+	return MEReceivedCount{EventsProcessedLastPeriod: n}, nil
+}
+
+// MEDiagnostics is ME's diagnostic heartbeat, with the size of its queue and the timeseries it dropped
+type MEDiagnostics struct {
+	CurrentRawDataQueueSize  int64
+	EtwEventsDropped         int64
+	AggregatedMetricsDropped int64
+}
+
+// ParseMEDiagnostics parses an ME diagnostic heartbeat message
+func ParseMEDiagnostics(message string) (MEDiagnostics, error) {
+	f := parseFields(message)
+	var d MEDiagnostics
+	var err error
+	if d.CurrentRawDataQueueSize, err = f.int("CurrentRawDataQueueSize"); err != nil {
+		return MEDiagnostics{}, fail(FormatMEDiagnostics, err)
+	}
+	if d.EtwEventsDropped, err = f.int("EtwEventsDropped"); err != nil {
+		return MEDiagnostics{}, fail(FormatMEDiagnostics, err)
+	}
+	if d.AggregatedMetricsDropped, err = f.int("AggregatedMetricsDropped"); err != nil {
+		return MEDiagnostics{}, fail(FormatMEDiagnostics, err)
+	}
+	return d, nil
+}
+
+// MEInfiniteMetric is a metric ME dropped because it has too many dimension combinations
+type MEInfiniteMetric struct {
+	Metric               string
+	DimsCount            int64
+	EstimatedSizeInBytes int64
+	Account              string
+}
+
+// ParseMEInfiniteMetric parses an ME message about an infinite metric
+func ParseMEInfiniteMetric(message string) (MEInfiniteMetric, error) {
+	f := parseFields(message)
+	var m MEInfiniteMetric
+	var err error
+	if m.Metric, err = f.quoted("Metric"); err != nil {
+		return MEInfiniteMetric{}, fail(FormatMEInfiniteMetric, err)
+	}
+	if m.DimsCount, err = f.int("DimsCount"); err != nil {
+		return MEInfiniteMetric{}, fail(FormatMEInfiniteMetric, err)
+	}
+	if m.EstimatedSizeInBytes, err = f.int("EstimatedSizeInBytes"); err != nil {
+		return MEInfiniteMetric{}, fail(FormatMEInfiniteMetric, err)
+	}
+	if m.Account, err = f.quoted("Account"); err != nil {
+		return MEInfiniteMetric{}, fail(FormatMEInfiniteMetric, err)
+	}
+	return m, nil
+}
+
+// OtelCollectorRecord is a record of the otelcollector's JSON log
+type OtelCollectorRecord struct {
+	Level   string
+	Caller  string
+	Message string
+}
+
+// ParseOtelCollectorRecord parses the fields of an otelcollector log record. The fields that were found are
+// returned along with the error when the record is missing its message.
+func ParseOtelCollectorRecord(record map[string]string) (OtelCollectorRecord, error) {
+	r := OtelCollectorRecord{
+		Level:   record["level"],
+		Caller:  record["caller"],
+		Message: record["msg"],
+	}
+	if r.Message == "" {
+		return r, fail(FormatOtelCollectorRecord, fmt.Errorf("%w msg", ErrMissingField))
+	}
+	return r, nil
+}
+
+// ExportingFailed reports whether the record is the otelcollector failing to export to ME
+func (r OtelCollectorRecord) ExportingFailed() bool {
+	return strings.Contains(r.Message, "Exporting failed")
+}
+
+func (r OtelCollectorRecord) String() string {
+	return fmt.Sprintf("%s %s", r.Caller, r.Message)
+}
+
+type fields map[string]string
+
+// parseFields returns the Key: value fields of message. The first value is kept when a key repeats.
+func parseFields(message string) fields {
+	f := fields{}
+	for _, match := range fieldPattern.FindAllStringSubmatch(message, -1) {
+		if _, ok := f[match[1]]; !ok {
+			f[match[1]] = match[2]
+		}
+	}
+	return f
+}
+
+func (f fields) int(key string) (int64, error) {
+	value, ok := f[key]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrMissingField, key)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return n, nil
+}
+
+func (f fields) quoted(key string) (string, error) {
+	value, ok := f[key]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrMissingField, key)
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %s: expected a quoted string", key, value)
+	}
+	return unquoted, nil
+}
@@ -0,0 +1,117 @@
+package logparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+type goldenResult struct {
+	Input  string      `json:"input"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// TestGolden parses every line of testdata/<format>.txt and compares the results to testdata/<format>.golden.
+// Run the tests with -update to regenerate the golden files after a deliberate change.
+func TestGolden(t *testing.T) {
+	parsers := map[string]func(line string) (interface{}, error){
+		FormatMEProcessedCount: func(line string) (interface{}, error) { return ParseMEProcessedCount(line) },
+		FormatMEReceivedCount:  func(line string) (interface{}, error) { return ParseMEReceivedCount(line) },
+		FormatMEDiagnostics:    func(line string) (interface{}, error) { return ParseMEDiagnostics(line) },
+		FormatMEInfiniteMetric: func(line string) (interface{}, error) { return ParseMEInfiniteMetric(line) },
+		FormatOtelCollectorRecord: func(line string) (interface{}, error) {
+			var record map[string]string
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("invalid otelcollector record %s: %v", line, err)
+			}
+			return ParseOtelCollectorRecord(record)
+		},
+	}
+
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			parse, ok := parsers[format]
+			if !ok {
+				t.Fatalf("no parser for %s", format)
+			}
+			input, err := os.Open(filepath.Join("testdata", format+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+
+			var results []goldenResult
+			scanner := bufio.NewScanner(input)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if line == "" {
+					continue
+				}
+				result := goldenResult{Input: line}
+				if parsed, err := parse(line); err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Result = parsed
+				}
+				results = append(results, result)
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			goldenFile := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("results differ from %s, run the tests with -update if the change is expected:\n%s", goldenFile, got)
+			}
+		})
+	}
+}
+
+func TestFailures(t *testing.T) {
+	before := Failures(FormatMEReceivedCount)
+	if _, err := ParseMEReceivedCount("EventsProcessedLastPeriod: 10"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseMEReceivedCount("EventsReceivedLastPeriod: 10")
+	if !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected ErrMissingField, got %v", err)
+	}
+	if got := Failures(FormatMEReceivedCount) - before; got != 1 {
+		t.Errorf("expected 1 failure, got %d", got)
+	}
+}
+
+func TestExportingFailed(t *testing.T) {
+	r := OtelCollectorRecord{Caller: "exporterhelper/queued_retry.go:101", Message: "Exporting failed. Will retry the request after interval."}
+	if !r.ExportingFailed() {
+		t.Error("expected the record to be an exporting failure")
+	}
+	if got, want := r.String(), "exporterhelper/queued_retry.go:101 Exporting failed. Will retry the request after interval."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if (OtelCollectorRecord{Message: "Everything is ready."}).ExportingFailed() {
+		t.Error("expected the record not to be an exporting failure")
+	}
+}
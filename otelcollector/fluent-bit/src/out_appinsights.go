@@ -93,6 +93,9 @@ func FLBPluginFlush(data unsafe.Pointer, length C.int, tag *C.char) int {
 
 // FLBPluginExit exits the plugin
 func FLBPluginExit() int {
+	if TelemetryClient != nil {
+		TelemetryClient.Close()
+	}
 	return output.FLB_OK
 }
 
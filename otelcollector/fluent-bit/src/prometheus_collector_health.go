@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 	"os"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
-	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"Docker-Provider/source/plugins/go/src/logparser"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -60,13 +63,14 @@ var (
 		[]string{"computer", "release", "controller_type"},
 	)
 
-	// invalidCustomConfigMetric is true if the config provided failed validation and false otherwise
+	// invalidCustomConfigMetric is true if the config provided failed validation and false otherwise. When only
+	// some scrape jobs were left out of the config in partial acceptance mode, it is set for each of them.
 	invalidCustomConfigMetric = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "invalid_custom_prometheus_config",
 			Help: "If an invalid custom prometheus config was given or not",
 		},
-		[]string{"computer", "release", "controller_type", "error"},
+		[]string{"computer", "release", "controller_type", "error", "job"},
 	)
 
 	// exportingFailedMetric counts the number of times the otelcollector was unable to export to ME
@@ -77,13 +81,167 @@ var (
 		},
 		[]string{"computer", "release", "controller_type"},
 	)
+
+	// meTimeseriesReceivedCounter counts the timeseries received by ME. ME does not log this per metrics account
+	meTimeseriesReceivedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_timeseries_received_total",
+			Help: "Number of timeseries received by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type"},
+	)
+
+	// meTimeseriesProcessedCounter counts the timeseries processed by ME for each metrics account
+	meTimeseriesProcessedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_timeseries_processed_total",
+			Help: "Number of timeseries processed by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type", "metrics_account"},
+	)
+
+	// meBytesProcessedCounter counts the bytes of timeseries processed by ME for each metrics account
+	meBytesProcessedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_bytes_processed_total",
+			Help: "Number of bytes of timeseries processed by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type", "metrics_account"},
+	)
+
+	// meTimeseriesSentCounter counts the timeseries sent to storage by ME for each metrics account
+	meTimeseriesSentCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_timeseries_sent_total",
+			Help: "Number of timeseries sent to storage by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type", "metrics_account"},
+	)
+
+	// meBytesSentCounter counts the bytes of timeseries sent to storage by ME for each metrics account
+	meBytesSentCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_bytes_sent_total",
+			Help: "Number of bytes of timeseries sent to storage by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type", "metrics_account"},
+	)
+
+	// meTimeseriesDroppedCounter counts the timeseries dropped by ME, by the reason ME logs for the drop
+	meTimeseriesDroppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "me_timeseries_dropped_total",
+			Help: "Number of timeseries dropped by MetricsExtension",
+		},
+		[]string{"computer", "release", "controller_type", "reason"},
+	)
+
+	// meTimeseriesSentPerPeriodHistogram is the distribution of the number of timeseries ME sends to storage in
+	// each of its reporting periods, for each metrics account
+	meTimeseriesSentPerPeriodHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "me_timeseries_sent_per_period",
+			Help:    "Number of timeseries sent to storage by MetricsExtension in a reporting period",
+			Buckets: prometheus.ExponentialBuckets(100, 4, 10),
+		},
+		[]string{"computer", "release", "controller_type", "metrics_account"},
+	)
 )
 
 const (
 	prometheusCollectorHealthInterval = 60
-	prometheusCollectorHealthPort = ":2234"
+	envPrometheusCollectorHealthPort  = "PROMETHEUS_COLLECTOR_HEALTH_PORT"
 )
 
+// prometheusCollectorHealthPort returns the port to serve the health metrics on. The entrypoint sets
+// PROMETHEUS_COLLECTOR_HEALTH_PORT to the port the prometheus_collector_health job scrapes.
+func prometheusCollectorHealthPort() (string, error) {
+	port := os.Getenv(envPrometheusCollectorHealthPort)
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("invalid %s %q", envPrometheusCollectorHealthPort, port)
+	}
+	return port, nil
+}
+
+// healthMetricLabels returns the labels common to all the health metrics
+func healthMetricLabels() prometheus.Labels {
+	return prometheus.Labels{"computer": CommonProperties["computer"], "release": CommonProperties["helmreleasename"], "controller_type": CommonProperties["controllertype"]}
+}
+
+// publishTimeseriesVolume sets the per minute gauges from the timeseries and bytes ME logged since they were last
+// set, timePassedInMinutes ago, and starts adding up again
+func publishTimeseriesVolume(timePassedInMinutes float64) {
+	TimeseriesVolumeMutex.Lock()
+	defer TimeseriesVolumeMutex.Unlock()
+	timeseriesReceivedRate := math.Round(TimeseriesReceivedTotal / timePassedInMinutes)
+	timeseriesSentRate := math.Round(TimeseriesSentTotal / timePassedInMinutes)
+	bytesSentRate := math.Round(BytesSentTotal / timePassedInMinutes)
+
+	timeseriesReceivedMetric.With(healthMetricLabels()).Set(timeseriesReceivedRate)
+	timeseriesSentMetric.With(healthMetricLabels()).Set(timeseriesSentRate)
+	bytesSentMetric.With(healthMetricLabels()).Set(bytesSentRate)
+
+	TimeseriesReceivedTotal = 0.0
+	TimeseriesSentTotal = 0.0
+	BytesSentTotal = 0.0
+}
+
+// RecordMEReceivedCount adds the timeseries ME received in the period of its heartbeat to the health counters
+func RecordMEReceivedCount(timeseriesReceived float64) {
+	meTimeseriesReceivedCounter.With(healthMetricLabels()).Add(timeseriesReceived)
+}
+
+// RecordMEProcessedCount adds the timeseries and bytes ME processed and sent for a metrics account in the period
+// of its heartbeat to the health counters
+func RecordMEProcessedCount(metricsAccount string, timeseriesProcessed, bytesProcessed, timeseriesSent, bytesSent float64) {
+	labels := healthMetricLabels()
+	labels["metrics_account"] = metricsAccount
+	meTimeseriesProcessedCounter.With(labels).Add(timeseriesProcessed)
+	meBytesProcessedCounter.With(labels).Add(bytesProcessed)
+	meTimeseriesSentCounter.With(labels).Add(timeseriesSent)
+	meBytesSentCounter.With(labels).Add(bytesSent)
+	meTimeseriesSentPerPeriodHistogram.With(labels).Observe(timeseriesSent)
+}
+
+// RecordMEDroppedCount adds the timeseries ME dropped in the period of its heartbeat to the health counters
+func RecordMEDroppedCount(reason string, timeseriesDropped float64) {
+	labels := healthMetricLabels()
+	labels["reason"] = reason
+	meTimeseriesDroppedCounter.With(labels).Add(timeseriesDropped)
+}
+
+// recordInvalidCustomConfig sets the invalid config metric from the results of the prom config validator. The
+// scrape jobs left out of the config in partial acceptance mode each get their error, otherwise the error of the
+// whole config is set without a job.
+func recordInvalidCustomConfig() {
+	if os.Getenv("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG") != "true" {
+		labels := healthMetricLabels()
+		labels["error"] = ""
+		labels["job"] = ""
+		invalidCustomConfigMetric.With(labels).Set(0)
+		return
+	}
+
+	if rejectedJobs := os.Getenv("INVALID_CONFIG_REJECTED_JOBS"); rejectedJobs != "" {
+		var jobErrors map[string]string
+		if err := json.Unmarshal([]byte(rejectedJobs), &jobErrors); err != nil {
+			Log("Error parsing INVALID_CONFIG_REJECTED_JOBS: %v", err)
+		} else {
+			for job, jobError := range jobErrors {
+				labels := healthMetricLabels()
+				labels["error"] = jobError
+				labels["job"] = job
+				invalidCustomConfigMetric.With(labels).Set(1)
+			}
+			return
+		}
+	}
+
+	labels := healthMetricLabels()
+	labels["error"] = os.Getenv("INVALID_CONFIG_FATAL_ERROR")
+	labels["job"] = ""
+	invalidCustomConfigMetric.With(labels).Set(1)
+}
 
 // Expose Prometheus metrics about the health of the agent
 func ExposePrometheusCollectorHealthMetrics() {
@@ -95,6 +253,28 @@ func ExposePrometheusCollectorHealthMetrics() {
 	r.MustRegister(bytesSentMetric)
 	r.MustRegister(invalidCustomConfigMetric)
 	r.MustRegister(exportingFailedMetric)
+	r.MustRegister(meTimeseriesReceivedCounter)
+	r.MustRegister(meTimeseriesProcessedCounter)
+	r.MustRegister(meBytesProcessedCounter)
+	r.MustRegister(meTimeseriesSentCounter)
+	r.MustRegister(meBytesSentCounter)
+	r.MustRegister(meTimeseriesDroppedCounter)
+	r.MustRegister(meTimeseriesSentPerPeriodHistogram)
+
+	// Counts the ME and otelcollector log records that could not be parsed, a sign that their format changed
+	for _, format := range logparser.Formats {
+		format := format
+		labels := healthMetricLabels()
+		labels["format"] = format
+		r.MustRegister(prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name:        "log_parse_failures_total",
+				Help:        "Number of log records that could not be parsed",
+				ConstLabels: labels,
+			},
+			func() float64 { return float64(logparser.Failures(format)) },
+		))
+	}
 
 	handler := promhttp.HandlerFor(r, promhttp.HandlerOpts{})
 	http.Handle("/metrics", handler)
@@ -107,27 +287,9 @@ func ExposePrometheusCollectorHealthMetrics() {
 			elapsed := time.Since(lastTickerStart)
 			timePassedInMinutes := (float64(elapsed) / float64(time.Second)) / float64(prometheusCollectorHealthInterval)
 
-			TimeseriesVolumeMutex.Lock()
-			timeseriesReceivedRate := math.Round(TimeseriesReceivedTotal / timePassedInMinutes)
-			timeseriesSentRate := math.Round(TimeseriesSentTotal / timePassedInMinutes)
-			bytesSentRate := math.Round(BytesSentTotal / timePassedInMinutes)
+			publishTimeseriesVolume(timePassedInMinutes)
 
-			timeseriesReceivedMetric.With(prometheus.Labels{"computer":CommonProperties["computer"], "release":CommonProperties["helmreleasename"], "controller_type":CommonProperties["controllertype"]}).Set(timeseriesReceivedRate)
-			timeseriesSentMetric.With(prometheus.Labels{"computer":CommonProperties["computer"], "release":CommonProperties["helmreleasename"], "controller_type":CommonProperties["controllertype"]}).Set(timeseriesSentRate)
-			bytesSentMetric.With(prometheus.Labels{"computer":CommonProperties["computer"], "release":CommonProperties["helmreleasename"], "controller_type":CommonProperties["controllertype"]}).Set(bytesSentRate)
-		
-			TimeseriesReceivedTotal = 0.0
-			TimeseriesSentTotal = 0.0
-			BytesSentTotal = 0.0
-			TimeseriesVolumeMutex.Unlock()
-
-			isInvalidCustomConfig := 0
-			invalidConfigErrorString := ""
-			if os.Getenv("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG") == "true" {
-				isInvalidCustomConfig = 1
-				invalidConfigErrorString = os.Getenv("INVALID_CONFIG_FATAL_ERROR")
-			}
-			invalidCustomConfigMetric.With(prometheus.Labels{"computer":CommonProperties["computer"], "release":CommonProperties["helmreleasename"], "controller_type":CommonProperties["controllertype"], "error":invalidConfigErrorString}).Set(float64(isInvalidCustomConfig))
+			recordInvalidCustomConfig()
 		
 			ExportingFailedMutex.Lock()
 			exportingFailedMetric.With(prometheus.Labels{"computer":CommonProperties["computer"], "release":CommonProperties["helmreleasename"], "controller_type":CommonProperties["controllertype"]}).Add(float64(OtelCollectorExportingFailedCount))
@@ -138,10 +300,12 @@ func ExposePrometheusCollectorHealthMetrics() {
 		}
 	}()
 
-	err := http.ListenAndServe(prometheusCollectorHealthPort, nil)
+	port, err := prometheusCollectorHealthPort()
+	if err == nil {
+		err = http.ListenAndServe(":"+port, nil)
+	}
 	if err != nil {
 		Log("Error for Prometheus Collector Health endpoint: %s", err.Error())
-		exception := appinsights.NewExceptionTelemetry(err.Error())
-		TelemetryClient.Track(exception)
+		SendException(err.Error())
 	}
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricValue returns the value of a counter or gauge
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Counter != nil {
+		return m.Counter.GetValue()
+	}
+	return m.Gauge.GetValue()
+}
+
+func TestMEHeartbeatsAreCountedPerPeriod(t *testing.T) {
+	t.Setenv(envPrometheusCollectorHealth, "true")
+	labels := healthMetricLabels()
+	sentLabels := healthMetricLabels()
+	sentLabels["metrics_account"] = "mac_test"
+	processed := []map[interface{}]interface{}{{"message": []byte("Metrics Account: mac_test ProcessedCount: 1000, ProcessedBytes: 50000, SentToPublicationCount: 1000, SentToPublicationBytes: 40000")}}
+	received := []map[interface{}]interface{}{{"message": []byte("EventsProcessedLastPeriod: 1200")}}
+
+	sentBefore := metricValue(t, meTimeseriesSentCounter.With(sentLabels))
+	receivedBefore := metricValue(t, meTimeseriesReceivedCounter.With(labels))
+	// Each heartbeat has the counts of its own period, a steady volume keeps adding up
+	for period := 1; period <= 3; period++ {
+		UpdateMEMetricsProcessedCount(processed)
+		UpdateMEReceivedMetricsCount(received)
+		publishTimeseriesVolume(1)
+
+		if got := metricValue(t, meTimeseriesSentCounter.With(sentLabels)) - sentBefore; got != float64(1000*period) {
+			t.Errorf("period %d: me_timeseries_sent_total increased by %v, want %v", period, got, 1000*period)
+		}
+		if got := metricValue(t, meTimeseriesReceivedCounter.With(labels)) - receivedBefore; got != float64(1200*period) {
+			t.Errorf("period %d: me_timeseries_received_total increased by %v, want %v", period, got, 1200*period)
+		}
+		if got := metricValue(t, timeseriesSentMetric.With(labels)); got != 1000 {
+			t.Errorf("period %d: timeseries_sent_per_minute = %v, want 1000", period, got)
+		}
+		if got := metricValue(t, bytesSentMetric.With(labels)); got != 40000 {
+			t.Errorf("period %d: bytes_sent_per_minute = %v, want 40000", period, got)
+		}
+		if got := metricValue(t, timeseriesReceivedMetric.With(labels)); got != 1200 {
+			t.Errorf("period %d: timeseries_received_per_minute = %v, want 1200", period, got)
+		}
+	}
+}
+
+func TestPrometheusCollectorHealthPort(t *testing.T) {
+	t.Setenv(envPrometheusCollectorHealthPort, "9090")
+	if port, err := prometheusCollectorHealthPort(); err != nil || port != "9090" {
+		t.Errorf("prometheusCollectorHealthPort() = %q, %v, want 9090", port, err)
+	}
+	for _, invalid := range []string{"", "port", "70000"} {
+		t.Setenv(envPrometheusCollectorHealthPort, invalid)
+		if _, err := prometheusCollectorHealthPort(); err == nil {
+			t.Errorf("prometheusCollectorHealthPort() with %q: expected an error", invalid)
+		}
+	}
+}
@@ -4,21 +4,18 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Docker-Provider/source/plugins/go/src/logparser"
 	"github.com/fluent/fluent-bit-go/output"
-	"github.com/microsoft/ApplicationInsights-Go/appinsights"
 	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
 	yaml "gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,8 +37,8 @@ type meMetricsReceivedCount struct {
 var (
 	// CommonProperties indicates the dimensions that are sent with every event/metric
 	CommonProperties map[string]string
-	// TelemetryClient is the client used to send the telemetry
-	TelemetryClient appinsights.TelemetryClient
+	// TelemetryClient is the sink the telemetry is sent to, selected by TELEMETRY_SINK
+	TelemetryClient TelemetrySink
 	// Invalid Prometheus config validation environment variable used for telemetry
 	InvalidCustomPrometheusConfig string
 	// Default Collector config
@@ -165,34 +162,15 @@ func SendException(err interface{}) {
 	}
 }
 
-// InitializeTelemetryClient sets up the telemetry client to send telemetry to the App Insights instance
+// InitializeTelemetryClient sets up the telemetry client to send telemetry to the configured sink
 func InitializeTelemetryClient(agentVersion string) (int, error) {
-	encodedIkey := os.Getenv(envAppInsightsAuth)
-	if encodedIkey == "" {
-		Log("Environment Variable Missing \n")
-		return -1, errors.New("Missing Environment Variable")
-	}
-
-	decIkey, err := base64.StdEncoding.DecodeString(encodedIkey)
+	sink, err := NewTelemetrySink()
 	if err != nil {
-		Log("Decoding Error %s", err.Error())
-		return -1, err
-	}
-
-	appInsightsEndpoint := os.Getenv(envAppInsightsEndpoint)
-	telemetryClientConfig := appinsights.NewTelemetryConfiguration(string(decIkey))
-	// endpoint override required only for sovereign clouds
-	if appInsightsEndpoint != "" {
-		Log("Overriding the default AppInsights EndpointUrl with %s", appInsightsEndpoint)
-		telemetryClientConfig.EndpointUrl = appInsightsEndpoint
-	}
-	TelemetryClient = appinsights.NewTelemetryClientFromConfig(telemetryClientConfig)
-
-	telemetryOffSwitch := os.Getenv(envTelemetryOffSwitch)
-	if strings.Compare(strings.ToLower(telemetryOffSwitch), "true") == 0 {
-		Log("Appinsights telemetry is disabled \n")
-		TelemetryClient.SetIsEnabled(false)
+		// A misconfigured sink drops the telemetry instead of leaving TelemetryClient nil for the plugin to crash on
+		Log("Error creating the telemetry sink, telemetry is dropped: %s", err.Error())
+		sink = &noopSink{}
 	}
+	TelemetryClient = sink
 
 	CommonProperties = make(map[string]string)
 	CommonProperties["cluster"] = os.Getenv(envCluster)
@@ -273,7 +251,7 @@ func InitializeTelemetryClient(agentVersion string) (int, error) {
 		}
 	}
 
-	TelemetryClient.Context().CommonProperties = CommonProperties
+	TelemetryClient.SetCommonProperties(CommonProperties)
 
 	InvalidCustomPrometheusConfig = os.Getenv("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG")
 	DefaultPrometheusConfig = os.Getenv("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG")
@@ -405,15 +383,15 @@ func SendCoreCountToAppInsightsMetrics() {
 		}
 		// Send metric to app insights for node and core capacity
 		cpuCapacityTotal := float64(telemetryProperties[linuxCpuCapacityTelemetryName] + telemetryProperties[windowsCpuCapacityTelemetryName])
-		metricTelemetryItem := appinsights.NewMetricTelemetry(coresAttachedTelemetryName, cpuCapacityTotal)
+		metricProperties := map[string]string{}
 
 		for propertyName, propertyValue := range telemetryProperties {
 			if propertyValue != 0 {
-				metricTelemetryItem.Properties[propertyName] = fmt.Sprintf("%d", propertyValue)
+				metricProperties[propertyName] = fmt.Sprintf("%d", propertyValue)
 			}
 		}
 
-		TelemetryClient.Track(metricTelemetryItem)
+		TelemetryClient.TrackMetric(coresAttachedTelemetryName, cpuCapacityTotal, metricProperties)
 	}
 }
 
@@ -482,15 +460,15 @@ func GetAndSendContainerCPUandMemoryFromCadvisorJSON(container Container, cpuMet
 	cpuUsageNanoCoresLinux := container.Cpu.UsageNanoCores
 	memoryRssBytesLinux := container.Memory.RssBytes
 
-	// Send metric to app insights for Cpu and Memory Usage for Kube state metrics
-	metricTelemetryItem := appinsights.NewMetricTelemetry(cpuMetricName, cpuUsageNanoCoresLinux)
-
-	// Abbreviated properties to save telemetry cost
-	metricTelemetryItem.Properties[memMetricName] = fmt.Sprintf("%d", int(memoryRssBytesLinux))
-	// Adding the actual pod name from Cadvisor output since the podname environment variable points to the pod on which plugin is running
-	metricTelemetryItem.Properties["PodRefName"] = fmt.Sprintf("%s", podRefName)
+	// Send metric for Cpu and Memory Usage for Kube state metrics
+	metricProperties := map[string]string{
+		// Abbreviated properties to save telemetry cost
+		memMetricName: fmt.Sprintf("%d", int(memoryRssBytesLinux)),
+		// Adding the actual pod name from Cadvisor output since the podname environment variable points to the pod on which plugin is running
+		"PodRefName": fmt.Sprintf("%s", podRefName),
+	}
 
-	TelemetryClient.Track(metricTelemetryItem)
+	TelemetryClient.TrackMetric(cpuMetricName, cpuUsageNanoCoresLinux, metricProperties)
 
 	Log(fmt.Sprintf("Sent container CPU and Mem data for %s", cpuMetricName))
 }
@@ -560,7 +538,11 @@ func PushLogErrorsToAppInsightsTraces(records []map[interface{}]interface{}, sev
 
 		// Logs have different parsed formats depending on if they're from otelcollector or container logs
 		if tag == fluentbitOtelCollectorLogsTag {
-			logEntry = fmt.Sprintf("%s %s", ToString(record["caller"]), ToString(record["msg"]))
+			otelCollectorRecord, err := logparser.ParseOtelCollectorRecord(otelCollectorRecordFields(record))
+			if err != nil {
+				Log("Error parsing the otelcollector log record: %v", err)
+			}
+			logEntry = otelCollectorRecord.String()
 		} else {
 			logEntry = ToString(record["log"])
 		}
@@ -569,80 +551,55 @@ func PushLogErrorsToAppInsightsTraces(records []map[interface{}]interface{}, sev
 	}
 
 	traceEntry := strings.Join(logLines, "\n")
-	traceTelemetryItem := appinsights.NewTraceTelemetry(traceEntry, severityLevel)
-	traceTelemetryItem.Properties["tag"] = tag
-	TelemetryClient.Track(traceTelemetryItem)
+	TelemetryClient.TrackTrace(traceEntry, severityLevel, map[string]string{"tag": tag})
 	return output.FLB_OK
 }
 
 func UpdateMEMetricsProcessedCount(records []map[interface{}]interface{}) int {
 	for _, record := range records {
-		var logEntry = ToString(record["message"])
-		var metricScrapeInfoRegex = regexp.MustCompile(`\s*([^\s]+)\s*([^\s]+)\s*([^\s]+).*ProcessedCount: ([\d]+).*ProcessedBytes: ([\d]+).*SentToPublicationCount: ([\d]+).*SentToPublicationBytes: ([\d]+).*`)
-		groupMatches := metricScrapeInfoRegex.FindStringSubmatch(logEntry)
-
-		if len(groupMatches) > 7 {
-			metricsProcessedCount, err := strconv.ParseFloat(groupMatches[4], 64)
-			if err == nil {
-
-				metricsAccountName := groupMatches[3]
-
-				bytesProcessedCount, e := strconv.ParseFloat(groupMatches[5], 64)
-				if e != nil {
-					bytesProcessedCount = 0.0
-				}
+		processed, err := logparser.ParseMEProcessedCount(ToString(record["message"]))
+		if err != nil {
+			Log("Error parsing the ME processed count: %v", err)
+			continue
+		}
 
-				metricsSentToPubCount, e := strconv.ParseFloat(groupMatches[6], 64)
-				if e != nil {
-					metricsSentToPubCount = 0.0
-				}
-				bytesSentToPubCount, e := strconv.ParseFloat(groupMatches[7], 64)
-				if e != nil {
-					bytesSentToPubCount = 0.0
-				}
+		metricsAccountName := processed.MetricsAccount
+		metricsProcessedCount := float64(processed.ProcessedCount)
+		bytesProcessedCount := float64(processed.ProcessedBytes)
+		metricsSentToPubCount := float64(processed.SentToPublicationCount)
+		bytesSentToPubCount := float64(processed.SentToPublicationBytes)
 
-				//update map
-				meMetricsProcessedCountMapMutex.Lock()
+		//update map
+		meMetricsProcessedCountMapMutex.Lock()
 
-				ref, ok := meMetricsProcessedCountMap[metricsAccountName]
+		ref, ok := meMetricsProcessedCountMap[metricsAccountName]
 
-				if ok {
-					ref.DimBytesProcessedCount += bytesProcessedCount
-					ref.DimBytesSentToPubCount += bytesSentToPubCount
-					ref.DimMetricsSentToPubCount += metricsSentToPubCount
-					ref.Value += metricsProcessedCount
+		if ok {
+			ref.DimBytesProcessedCount += bytesProcessedCount
+			ref.DimBytesSentToPubCount += bytesSentToPubCount
+			ref.DimMetricsSentToPubCount += metricsSentToPubCount
+			ref.Value += metricsProcessedCount
 
-				} else {
-					m := &meMetricsProcessedCount{
-						DimBytesProcessedCount:   bytesProcessedCount,
-						DimBytesSentToPubCount:   bytesSentToPubCount,
-						DimMetricsSentToPubCount: metricsSentToPubCount,
-						Value:                    metricsProcessedCount,
-					}
-					meMetricsProcessedCountMap[metricsAccountName] = m
-				}
-				meMetricsProcessedCountMapMutex.Unlock()
+		} else {
+			m := &meMetricsProcessedCount{
+				DimBytesProcessedCount:   bytesProcessedCount,
+				DimBytesSentToPubCount:   bytesSentToPubCount,
+				DimMetricsSentToPubCount: metricsSentToPubCount,
+				Value:                    metricsProcessedCount,
 			}
+			meMetricsProcessedCountMap[metricsAccountName] = m
+		}
+		meMetricsProcessedCountMapMutex.Unlock()
 
-			if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
-				// Add to the total that PublishTimeseriesVolume() uses
-				metricsSentToPubCount, err := strconv.ParseFloat(groupMatches[6], 64)
-				if err == nil {
-					TimeseriesVolumeMutex.Lock()
-					TimeseriesSentTotal += metricsSentToPubCount
-					TimeseriesVolumeMutex.Unlock()
-				}
+		if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
+			RecordMEProcessedCount(metricsAccountName, metricsProcessedCount, bytesProcessedCount, metricsSentToPubCount, bytesSentToPubCount)
 
-				// Add to the total that PublishTimeseriesVolume() uses
-				bytesSentToPubCount, err := strconv.ParseFloat(groupMatches[7], 64)
-				if err == nil {
-					TimeseriesVolumeMutex.Lock()
-					BytesSentTotal += bytesSentToPubCount
-					TimeseriesVolumeMutex.Unlock()
-				}
-			}
+			// Add to the totals that publishTimeseriesVolume() uses
+			TimeseriesVolumeMutex.Lock()
+			TimeseriesSentTotal += metricsSentToPubCount
+			BytesSentTotal += bytesSentToPubCount
+			TimeseriesVolumeMutex.Unlock()
 		}
-
 	}
 	return output.FLB_OK
 }
@@ -656,105 +613,105 @@ func PushMEProcessedAndReceivedCountToAppInsightsMetrics() {
 
 		meMetricsProcessedCountMapMutex.Lock()
 		for k, v := range meMetricsProcessedCountMap {
-			metric := appinsights.NewMetricTelemetry("meMetricsProcessedCount", v.Value)
-			metric.Properties["metricsAccountName"] = k
-			metric.Properties["bytesProcessedCount"] = fmt.Sprintf("%.2f", v.DimBytesProcessedCount)
-			metric.Properties["metricsSentToPubCount"] = fmt.Sprintf("%.2f", v.DimMetricsSentToPubCount)
-			metric.Properties["bytesSentToPubCount"] = fmt.Sprintf("%.2f", v.DimBytesSentToPubCount)
+			metricProperties := map[string]string{}
+			metricProperties["metricsAccountName"] = k
+			metricProperties["bytesProcessedCount"] = fmt.Sprintf("%.2f", v.DimBytesProcessedCount)
+			metricProperties["metricsSentToPubCount"] = fmt.Sprintf("%.2f", v.DimMetricsSentToPubCount)
+			metricProperties["bytesSentToPubCount"] = fmt.Sprintf("%.2f", v.DimBytesSentToPubCount)
 
 			if InvalidCustomPrometheusConfig != "" {
-				metric.Properties["InvalidCustomPrometheusConfig"] = InvalidCustomPrometheusConfig
+				metricProperties["InvalidCustomPrometheusConfig"] = InvalidCustomPrometheusConfig
 			}
 			if DefaultPrometheusConfig != "" {
-				metric.Properties["DefaultPrometheusConfig"] = DefaultPrometheusConfig
+				metricProperties["DefaultPrometheusConfig"] = DefaultPrometheusConfig
 			}
 
 			if os.Getenv(envControllerType) == "ReplicaSet" {
 				if KubeletKeepListRegex != "" {
-					metric.Properties["KubeletKeepListRegex"] = KubeletKeepListRegex
+					metricProperties["KubeletKeepListRegex"] = KubeletKeepListRegex
 				}
 				if CoreDNSKeepListRegex != "" {
-					metric.Properties["CoreDNSKeepListRegex"] = CoreDNSKeepListRegex
+					metricProperties["CoreDNSKeepListRegex"] = CoreDNSKeepListRegex
 				}
 				if CAdvisorKeepListRegex != "" {
-					metric.Properties["CAdvisorKeepListRegex"] = CAdvisorKeepListRegex
+					metricProperties["CAdvisorKeepListRegex"] = CAdvisorKeepListRegex
 				}
 				if KubeProxyKeepListRegex != "" {
-					metric.Properties["KubeProxyKeepListRegex"] = KubeProxyKeepListRegex
+					metricProperties["KubeProxyKeepListRegex"] = KubeProxyKeepListRegex
 				}
 				if ApiServerKeepListRegex != "" {
-					metric.Properties["ApiServerKeepListRegex"] = ApiServerKeepListRegex
+					metricProperties["ApiServerKeepListRegex"] = ApiServerKeepListRegex
 				}
 				if KubeStateKeepListRegex != "" {
-					metric.Properties["KubeStateKeepListRegex"] = KubeStateKeepListRegex
+					metricProperties["KubeStateKeepListRegex"] = KubeStateKeepListRegex
 				}
 				if NodeExporterKeepListRegex != "" {
-					metric.Properties["NodeExporterKeepListRegex"] = NodeExporterKeepListRegex
+					metricProperties["NodeExporterKeepListRegex"] = NodeExporterKeepListRegex
 				}
 				if WinExporterKeepListRegex != "" {
-					metric.Properties["WinExporterKeepListRegex"] = WinExporterKeepListRegex
+					metricProperties["WinExporterKeepListRegex"] = WinExporterKeepListRegex
 				}
 				if WinKubeProxyKeepListRegex != "" {
-					metric.Properties["WinKubeProxyKeepListRegex"] = WinKubeProxyKeepListRegex
+					metricProperties["WinKubeProxyKeepListRegex"] = WinKubeProxyKeepListRegex
 				}
 				if PodannotationKeepListRegex != "" {
-					metric.Properties["PodannotationKeepListRegex"] = PodannotationKeepListRegex
+					metricProperties["PodannotationKeepListRegex"] = PodannotationKeepListRegex
 				}
 				if KappieBasicKeepListRegex != "" {
-					metric.Properties["KappieBasicKeepListRegex"] = KappieBasicKeepListRegex
+					metricProperties["KappieBasicKeepListRegex"] = KappieBasicKeepListRegex
 				}
 				if AcstorCapacityProvisionerKeepListRegex != "" {
-					metric.Properties["AcstorCapacityProvisionerRegex"] = AcstorCapacityProvisionerKeepListRegex
+					metricProperties["AcstorCapacityProvisionerRegex"] = AcstorCapacityProvisionerKeepListRegex
 				}
 				if AcstorMetricsExporterKeepListRegex != "" {
-					metric.Properties["AcstorMetricsExporterRegex"] = AcstorMetricsExporterKeepListRegex
+					metricProperties["AcstorMetricsExporterRegex"] = AcstorMetricsExporterKeepListRegex
 				}
 
 				if KubeletScrapeInterval != "" {
-					metric.Properties["KubeletScrapeInterval"] = KubeletScrapeInterval
+					metricProperties["KubeletScrapeInterval"] = KubeletScrapeInterval
 				}
 				if CoreDNSScrapeInterval != "" {
-					metric.Properties["CoreDNSScrapeInterval"] = CoreDNSScrapeInterval
+					metricProperties["CoreDNSScrapeInterval"] = CoreDNSScrapeInterval
 				}
 				if CAdvisorScrapeInterval != "" {
-					metric.Properties["CAdvisorScrapeInterval"] = CAdvisorScrapeInterval
+					metricProperties["CAdvisorScrapeInterval"] = CAdvisorScrapeInterval
 				}
 				if KubeProxyScrapeInterval != "" {
-					metric.Properties["KubeProxyScrapeInterval"] = KubeProxyScrapeInterval
+					metricProperties["KubeProxyScrapeInterval"] = KubeProxyScrapeInterval
 				}
 				if ApiServerScrapeInterval != "" {
-					metric.Properties["ApiServerScrapeInterval"] = ApiServerScrapeInterval
+					metricProperties["ApiServerScrapeInterval"] = ApiServerScrapeInterval
 				}
 				if KubeStateScrapeInterval != "" {
-					metric.Properties["KubeStateScrapeInterval"] = KubeStateScrapeInterval
+					metricProperties["KubeStateScrapeInterval"] = KubeStateScrapeInterval
 				}
 				if NodeExporterScrapeInterval != "" {
-					metric.Properties["NodeExporterScrapeInterval"] = NodeExporterScrapeInterval
+					metricProperties["NodeExporterScrapeInterval"] = NodeExporterScrapeInterval
 				}
 				if WinExporterScrapeInterval != "" {
-					metric.Properties["WinExporterScrapeInterval"] = WinExporterScrapeInterval
+					metricProperties["WinExporterScrapeInterval"] = WinExporterScrapeInterval
 				}
 				if WinKubeProxyScrapeInterval != "" {
-					metric.Properties["WinKubeProxyScrapeInterval"] = WinKubeProxyScrapeInterval
+					metricProperties["WinKubeProxyScrapeInterval"] = WinKubeProxyScrapeInterval
 				}
 				if PromHealthScrapeInterval != "" {
-					metric.Properties["PromHealthScrapeInterval"] = PromHealthScrapeInterval
+					metricProperties["PromHealthScrapeInterval"] = PromHealthScrapeInterval
 				}
 				if PodAnnotationScrapeInterval != "" {
-					metric.Properties["PodAnnotationScrapeInterval"] = PodAnnotationScrapeInterval
+					metricProperties["PodAnnotationScrapeInterval"] = PodAnnotationScrapeInterval
 				}
 				if KappieBasicScrapeInterval != "" {
-					metric.Properties["KappieBasicScrapeInterval"] = KappieBasicScrapeInterval
+					metricProperties["KappieBasicScrapeInterval"] = KappieBasicScrapeInterval
 				}
 				if AcstorCapacityProvisionerScrapeInterval != "" {
-					metric.Properties["AcstorCapacityProvisionerScrapeInterval"] = AcstorCapacityProvisionerScrapeInterval
+					metricProperties["AcstorCapacityProvisionerScrapeInterval"] = AcstorCapacityProvisionerScrapeInterval
 				}
 				if AcstorMetricsExporterScrapeInterval != "" {
-					metric.Properties["AcstorMetricsExporterScrapeInterval"] = AcstorMetricsExporterScrapeInterval
+					metricProperties["AcstorMetricsExporterScrapeInterval"] = AcstorMetricsExporterScrapeInterval
 				}
 			}
 
-			TelemetryClient.Track(metric)
+			TelemetryClient.TrackMetric("meMetricsProcessedCount", v.Value, metricProperties)
 
 		}
 
@@ -769,8 +726,7 @@ func PushMEProcessedAndReceivedCountToAppInsightsMetrics() {
 		if ok {
 			meMetricsReceivedCountMapMutex.Lock()
 
-			metric := appinsights.NewMetricTelemetry("meMetricsReceivedCount", ref.Value)
-			TelemetryClient.Track(metric)
+			TelemetryClient.TrackMetric("meMetricsReceivedCount", ref.Value, nil)
 			meMetricsReceivedCountMap = make(map[string]*meMetricsReceivedCount)
 
 			meMetricsReceivedCountMapMutex.Unlock()
@@ -781,18 +737,20 @@ func PushMEProcessedAndReceivedCountToAppInsightsMetrics() {
 
 func PushMetricsDroppedCountToAppInsightsMetrics(records []map[interface{}]interface{}) int {
 	for _, record := range records {
-		var logEntry = ToString(record["message"])
-		var metricScrapeInfoRegex = regexp.MustCompile(`.*CurrentRawDataQueueSize: (\d+).*EtwEventsDropped: (\d+).*AggregatedMetricsDropped: (\d+).*`)
-		groupMatches := metricScrapeInfoRegex.FindStringSubmatch(logEntry)
-
-		if len(groupMatches) > 3 {
-			metricsDroppedCount, err := strconv.ParseFloat(groupMatches[2], 64)
-			if err == nil {
-				metric := appinsights.NewMetricTelemetry("meMetricsDroppedCount", metricsDroppedCount)
-				metric.Properties["currentQueueSize"] = groupMatches[1]
-				metric.Properties["aggregatedMetricsDropped"] = groupMatches[3]
-				TelemetryClient.Track(metric)
-			}
+		diagnostics, err := logparser.ParseMEDiagnostics(ToString(record["message"]))
+		if err != nil {
+			Log("Error parsing the ME diagnostic heartbeat: %v", err)
+			continue
+		}
+
+		TelemetryClient.TrackMetric("meMetricsDroppedCount", float64(diagnostics.EtwEventsDropped), map[string]string{
+			"currentQueueSize":         strconv.FormatInt(diagnostics.CurrentRawDataQueueSize, 10),
+			"aggregatedMetricsDropped": strconv.FormatInt(diagnostics.AggregatedMetricsDropped, 10),
+		})
+
+		if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
+			RecordMEDroppedCount("etw_events_dropped", float64(diagnostics.EtwEventsDropped))
+			RecordMEDroppedCount("aggregated_metrics_dropped", float64(diagnostics.AggregatedMetricsDropped))
 		}
 	}
 
@@ -801,39 +759,35 @@ func PushMetricsDroppedCountToAppInsightsMetrics(records []map[interface{}]inter
 
 func UpdateMEReceivedMetricsCount(records []map[interface{}]interface{}) int {
 	for _, record := range records {
-		var logEntry = ToString(record["message"])
-		var metricScrapeInfoRegex = regexp.MustCompile(`.*EventsProcessedLastPeriod: (\d+).*`)
-		groupMatches := metricScrapeInfoRegex.FindStringSubmatch(logEntry)
-
-		if len(groupMatches) > 1 {
-			metricsReceivedCount, err := strconv.ParseFloat(groupMatches[1], 64)
-			if err == nil {
-
-				//update map
-				meMetricsReceivedCountMapMutex.Lock()
-
-				ref, ok := meMetricsReceivedCountMap["na"]
-
-				if ok {
-					ref.Value += metricsReceivedCount
+		received, err := logparser.ParseMEReceivedCount(ToString(record["message"]))
+		if err != nil {
+			Log("Error parsing the ME received count: %v", err)
+			continue
+		}
+		metricsReceivedCount := float64(received.EventsProcessedLastPeriod)
 
-				} else {
-					m := &meMetricsReceivedCount{
-						Value: metricsReceivedCount,
-					}
-					meMetricsReceivedCountMap["na"] = m
-				}
-				meMetricsReceivedCountMapMutex.Unlock()
+		//update map
+		meMetricsReceivedCountMapMutex.Lock()
 
-				// Add to the total that PublishTimeseriesVolume() uses
-				if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
-					TimeseriesVolumeMutex.Lock()
-					TimeseriesReceivedTotal += metricsReceivedCount
-					TimeseriesVolumeMutex.Unlock()
+		ref, ok := meMetricsReceivedCountMap["na"]
 
-				}
+		if ok {
+			ref.Value += metricsReceivedCount
 
+		} else {
+			m := &meMetricsReceivedCount{
+				Value: metricsReceivedCount,
 			}
+			meMetricsReceivedCountMap["na"] = m
+		}
+		meMetricsReceivedCountMapMutex.Unlock()
+
+		// Add to the total that publishTimeseriesVolume() uses
+		if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
+			RecordMEReceivedCount(metricsReceivedCount)
+			TimeseriesVolumeMutex.Lock()
+			TimeseriesReceivedTotal += metricsReceivedCount
+			TimeseriesVolumeMutex.Unlock()
 		}
 	}
 
@@ -842,18 +796,18 @@ func UpdateMEReceivedMetricsCount(records []map[interface{}]interface{}) int {
 
 func PushInfiniteMetricLogToAppInsightsEvents(records []map[interface{}]interface{}) int {
 	for _, record := range records {
-		var logEntry = ToString(record["message"])
-		var metricScrapeInfoRegex = regexp.MustCompile(`.*Metric: "(\w+)".*DimsCount: (\d+).*EstimatedSizeInBytes: (\d+).*Account: "(\w+)".*`)
-		groupMatches := metricScrapeInfoRegex.FindStringSubmatch(logEntry)
-
-		if len(groupMatches) > 4 {
-			event := appinsights.NewEventTelemetry("meInfiniteMetricDropped")
-			event.Properties["metric"] = groupMatches[1]
-			event.Properties["dimsCount"] = groupMatches[2]
-			event.Properties["estimatedBytes"] = groupMatches[3]
-			event.Properties["mdmAccount"] = groupMatches[4]
-			TelemetryClient.Track(event)
+		infiniteMetric, err := logparser.ParseMEInfiniteMetric(ToString(record["message"]))
+		if err != nil {
+			Log("Error parsing the ME infinite metric: %v", err)
+			continue
 		}
+
+		TelemetryClient.TrackEvent("meInfiniteMetricDropped", map[string]string{
+			"metric":         infiniteMetric.Metric,
+			"dimsCount":      strconv.FormatInt(infiniteMetric.DimsCount, 10),
+			"estimatedBytes": strconv.FormatInt(infiniteMetric.EstimatedSizeInBytes, 10),
+			"mdmAccount":     infiniteMetric.Account,
+		})
 	}
 
 	return output.FLB_OK
@@ -861,9 +815,29 @@ func PushInfiniteMetricLogToAppInsightsEvents(records []map[interface{}]interfac
 
 func RecordExportingFailed(records []map[interface{}]interface{}) int {
 	if strings.ToLower(os.Getenv(envPrometheusCollectorHealth)) == "true" {
-		ExportingFailedMutex.Lock()
-		OtelCollectorExportingFailedCount += 1
-		ExportingFailedMutex.Unlock()
+		for _, record := range records {
+			otelCollectorRecord, err := logparser.ParseOtelCollectorRecord(otelCollectorRecordFields(record))
+			if err != nil {
+				Log("Error parsing the otelcollector log record: %v", err)
+				continue
+			}
+			if otelCollectorRecord.ExportingFailed() {
+				ExportingFailedMutex.Lock()
+				OtelCollectorExportingFailedCount += 1
+				ExportingFailedMutex.Unlock()
+			}
+		}
 	}
 	return output.FLB_OK
 }
+
+// otelCollectorRecordFields returns the string fields of a record parsed from the otelcollector's JSON log
+func otelCollectorRecordFields(record map[interface{}]interface{}) map[string]string {
+	fields := make(map[string]string, len(record))
+	for k, v := range record {
+		if key, ok := k.(string); ok {
+			fields[key] = ToString(v)
+		}
+	}
+	return fields
+}
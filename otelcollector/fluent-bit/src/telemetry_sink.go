@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// TelemetrySink is where the agent's self telemetry is sent
+type TelemetrySink interface {
+	TrackMetric(name string, value float64, properties map[string]string)
+	TrackEvent(name string, properties map[string]string)
+	TrackTrace(message string, severity contracts.SeverityLevel, properties map[string]string)
+	TrackException(err interface{})
+	// SetCommonProperties sets the dimensions sent with all the telemetry
+	SetCommonProperties(properties map[string]string)
+	// Close sends the buffered telemetry, it is called when the plugin exits
+	Close()
+}
+
+const (
+	envTelemetrySink         = "TELEMETRY_SINK"
+	envTelemetryOtlpEndpoint = "TELEMETRY_OTLP_ENDPOINT"
+	envTelemetryOtlpHeaders  = "TELEMETRY_OTLP_HEADERS"
+	envTelemetryFilePath     = "TELEMETRY_FILE_PATH"
+
+	telemetrySinkAppInsights = "appinsights"
+	telemetrySinkOtlp        = "otlp"
+	telemetrySinkStdout      = "stdout"
+	telemetrySinkFile        = "file"
+
+	defaultTelemetryFilePath = "/opt/fluent-bit/fluent-bit-out-appinsights-telemetry.log"
+	otlpFlushInterval        = 15 * time.Second
+	otlpMaxBatchSize         = 500
+	otlpScopeName            = "prometheus-collector/fluent-bit"
+	telemetryCloseTimeout    = 10 * time.Second
+)
+
+// NewTelemetrySink creates the sink selected by TELEMETRY_SINK, Application Insights by default
+func NewTelemetrySink() (TelemetrySink, error) {
+	if strings.ToLower(os.Getenv(envTelemetryOffSwitch)) == "true" {
+		Log("Telemetry is disabled \n")
+		return &noopSink{}, nil
+	}
+
+	sink := strings.ToLower(strings.TrimSpace(os.Getenv(envTelemetrySink)))
+	switch sink {
+	case "", telemetrySinkAppInsights:
+		return newAppInsightsSink()
+	case telemetrySinkOtlp:
+		endpoint := os.Getenv(envTelemetryOtlpEndpoint)
+		if endpoint == "" {
+			return nil, fmt.Errorf("%s is required for the %s telemetry sink", envTelemetryOtlpEndpoint, telemetrySinkOtlp)
+		}
+		headers, err := parseOtlpHeaders(os.Getenv(envTelemetryOtlpHeaders))
+		if err != nil {
+			return nil, err
+		}
+		Log("Sending telemetry to the OTLP endpoint %s", endpoint)
+		return newOtlpSink(endpoint, headers), nil
+	case telemetrySinkStdout:
+		return newWriterSink(os.Stdout), nil
+	case telemetrySinkFile:
+		path := os.Getenv(envTelemetryFilePath)
+		if path == "" {
+			path = defaultTelemetryFilePath
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening the telemetry file %s: %v", path, err)
+		}
+		Log("Writing telemetry to %s", path)
+		return newWriterSink(file), nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q, expected one of %s, %s, %s or %s", envTelemetrySink, sink,
+			telemetrySinkAppInsights, telemetrySinkOtlp, telemetrySinkStdout, telemetrySinkFile)
+	}
+}
+
+// appInsightsSink sends telemetry to the Application Insights instance in APPLICATIONINSIGHTS_AUTH
+type appInsightsSink struct {
+	client appinsights.TelemetryClient
+}
+
+func newAppInsightsSink() (*appInsightsSink, error) {
+	encodedIkey := os.Getenv(envAppInsightsAuth)
+	if encodedIkey == "" {
+		Log("Environment Variable Missing \n")
+		return nil, errors.New("Missing Environment Variable")
+	}
+
+	decIkey, err := base64.StdEncoding.DecodeString(encodedIkey)
+	if err != nil {
+		Log("Decoding Error %s", err.Error())
+		return nil, err
+	}
+
+	appInsightsEndpoint := os.Getenv(envAppInsightsEndpoint)
+	telemetryClientConfig := appinsights.NewTelemetryConfiguration(string(decIkey))
+	// endpoint override required only for sovereign clouds
+	if appInsightsEndpoint != "" {
+		Log("Overriding the default AppInsights EndpointUrl with %s", appInsightsEndpoint)
+		telemetryClientConfig.EndpointUrl = appInsightsEndpoint
+	}
+	return &appInsightsSink{client: appinsights.NewTelemetryClientFromConfig(telemetryClientConfig)}, nil
+}
+
+func (s *appInsightsSink) TrackMetric(name string, value float64, properties map[string]string) {
+	metric := appinsights.NewMetricTelemetry(name, value)
+	for k, v := range properties {
+		metric.Properties[k] = v
+	}
+	s.client.Track(metric)
+}
+
+func (s *appInsightsSink) TrackEvent(name string, properties map[string]string) {
+	event := appinsights.NewEventTelemetry(name)
+	for k, v := range properties {
+		event.Properties[k] = v
+	}
+	s.client.Track(event)
+}
+
+func (s *appInsightsSink) TrackTrace(message string, severity contracts.SeverityLevel, properties map[string]string) {
+	trace := appinsights.NewTraceTelemetry(message, severity)
+	for k, v := range properties {
+		trace.Properties[k] = v
+	}
+	s.client.Track(trace)
+}
+
+func (s *appInsightsSink) TrackException(err interface{}) {
+	s.client.TrackException(err)
+}
+
+func (s *appInsightsSink) SetCommonProperties(properties map[string]string) {
+	s.client.Context().CommonProperties = properties
+}
+
+func (s *appInsightsSink) Close() {
+	select {
+	case <-s.client.Channel().Close(telemetryCloseTimeout):
+	case <-time.After(telemetryCloseTimeout):
+		Log("Timed out sending the buffered telemetry to Application Insights")
+	}
+}
+
+// otlpSink batches telemetry and sends it to an OTLP/HTTP endpoint, using the JSON encoding. Metrics are
+// sent as gauges, and events, traces and exceptions as log records.
+type otlpSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	mu       sync.Mutex
+	common   map[string]string
+	metrics  []otlpMetric
+	logs     []otlpLogRecord
+	flushNow chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func newOtlpSink(endpoint string, headers map[string]string) *otlpSink {
+	s := &otlpSink{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		flushNow: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *otlpSink) TrackMetric(name string, value float64, properties map[string]string) {
+	s.mu.Lock()
+	s.metrics = append(s.metrics, otlpMetric{
+		Name: name,
+		Gauge: otlpGauge{DataPoints: []otlpDataPoint{{
+			TimeUnixNano: otlpTime(time.Now()),
+			AsDouble:     value,
+			Attributes:   otlpAttributes(properties),
+		}}},
+	})
+	s.mu.Unlock()
+	s.flushIfFull()
+}
+
+func (s *otlpSink) TrackEvent(name string, properties map[string]string) {
+	attributes := otlpAttributes(properties)
+	attributes = append(attributes, otlpAttribute{Key: "event.name", Value: otlpValue{StringValue: name}})
+	s.trackLog(otlpLogRecord{
+		TimeUnixNano:   otlpTime(time.Now()),
+		SeverityNumber: 9,
+		SeverityText:   "INFO",
+		Body:           otlpValue{StringValue: name},
+		Attributes:     attributes,
+	})
+}
+
+func (s *otlpSink) TrackTrace(message string, severity contracts.SeverityLevel, properties map[string]string) {
+	number, text := otlpSeverity(severity)
+	s.trackLog(otlpLogRecord{
+		TimeUnixNano:   otlpTime(time.Now()),
+		SeverityNumber: number,
+		SeverityText:   text,
+		Body:           otlpValue{StringValue: message},
+		Attributes:     otlpAttributes(properties),
+	})
+}
+
+func (s *otlpSink) TrackException(err interface{}) {
+	message := fmt.Sprint(err)
+	s.trackLog(otlpLogRecord{
+		TimeUnixNano:   otlpTime(time.Now()),
+		SeverityNumber: 17,
+		SeverityText:   "ERROR",
+		Body:           otlpValue{StringValue: message},
+		Attributes:     []otlpAttribute{{Key: "exception.message", Value: otlpValue{StringValue: message}}},
+	})
+}
+
+func (s *otlpSink) SetCommonProperties(properties map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.common = properties
+}
+
+func (s *otlpSink) trackLog(record otlpLogRecord) {
+	s.mu.Lock()
+	s.logs = append(s.logs, record)
+	s.mu.Unlock()
+	s.flushIfFull()
+}
+
+func (s *otlpSink) flushIfFull() {
+	s.mu.Lock()
+	full := len(s.metrics)+len(s.logs) >= otlpMaxBatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *otlpSink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.flushNow:
+		case <-s.done:
+			s.flush()
+			return
+		}
+		s.flush()
+	}
+}
+
+// Close sends the buffered telemetry and stops the periodic flush
+func (s *otlpSink) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.stopped
+}
+
+// flush sends the buffered telemetry. Telemetry that fails to send is dropped, so that an unreachable
+// endpoint does not grow the buffer without bound.
+func (s *otlpSink) flush() {
+	s.mu.Lock()
+	metrics, logs := s.metrics, s.logs
+	s.metrics, s.logs = nil, nil
+	resource := otlpResource{Attributes: otlpAttributes(s.common)}
+	s.mu.Unlock()
+
+	scope := otlpScope{Name: otlpScopeName}
+	if len(metrics) > 0 {
+		request := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+			Resource:     resource,
+			ScopeMetrics: []otlpScopeMetrics{{Scope: scope, Metrics: metrics}},
+		}}}
+		if err := s.post("/v1/metrics", request); err != nil {
+			Log("Error sending %d metrics to the OTLP endpoint: %v", len(metrics), err)
+		}
+	}
+	if len(logs) > 0 {
+		request := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+			Resource:  resource,
+			ScopeLogs: []otlpScopeLogs{{Scope: scope, LogRecords: logs}},
+		}}}
+		if err := s.post("/v1/logs", request); err != nil {
+			Log("Error sending %d log records to the OTLP endpoint: %v", len(logs), err)
+		}
+	}
+}
+
+func (s *otlpSink) post(path string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// parseOtlpHeaders parses headers in the key1=value1,key2=value2 format of OTEL_EXPORTER_OTLP_HEADERS
+func parseOtlpHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid header %q in %s, expected key=value", pair, envTelemetryOtlpHeaders)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers, nil
+}
+
+// otlpSeverity maps an Application Insights severity level to the OTLP severity number and text
+func otlpSeverity(severity contracts.SeverityLevel) (int, string) {
+	switch severity {
+	case contracts.Verbose:
+		return 5, "DEBUG"
+	case contracts.Warning:
+		return 13, "WARN"
+	case contracts.Error:
+		return 17, "ERROR"
+	case contracts.Critical:
+		return 21, "FATAL"
+	default:
+		return 9, "INFO"
+	}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(properties map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attributes = append(attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: properties[k]}})
+	}
+	return attributes
+}
+
+// The OTLP/HTTP JSON encoding of the messages used by otlpSink
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// writerSink writes telemetry as JSON lines, for debugging
+type writerSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	common map[string]string
+}
+
+type telemetryRecord struct {
+	Time       time.Time         `json:"time"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name,omitempty"`
+	Value      *float64          `json:"value,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Severity   string            `json:"severity,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+func newWriterSink(w io.Writer) *writerSink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) TrackMetric(name string, value float64, properties map[string]string) {
+	s.write(telemetryRecord{Kind: "metric", Name: name, Value: &value, Properties: properties})
+}
+
+func (s *writerSink) TrackEvent(name string, properties map[string]string) {
+	s.write(telemetryRecord{Kind: "event", Name: name, Properties: properties})
+}
+
+func (s *writerSink) TrackTrace(message string, severity contracts.SeverityLevel, properties map[string]string) {
+	_, text := otlpSeverity(severity)
+	s.write(telemetryRecord{Kind: "trace", Message: message, Severity: text, Properties: properties})
+}
+
+func (s *writerSink) TrackException(err interface{}) {
+	s.write(telemetryRecord{Kind: "exception", Message: fmt.Sprint(err), Severity: "ERROR"})
+}
+
+func (s *writerSink) SetCommonProperties(properties map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.common = properties
+}
+
+// Close closes the telemetry file. The lines are written unbuffered, so there is nothing to send.
+func (s *writerSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.w.(*os.File); ok && f != os.Stdout {
+		f.Close()
+	}
+}
+
+func (s *writerSink) write(record telemetryRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.Time = time.Now().UTC()
+	if len(s.common) > 0 {
+		properties := make(map[string]string, len(s.common)+len(record.Properties))
+		for k, v := range s.common {
+			properties[k] = v
+		}
+		for k, v := range record.Properties {
+			properties[k] = v
+		}
+		record.Properties = properties
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		Log("Error encoding telemetry: %v", err)
+		return
+	}
+	s.w.Write(append(line, '\n'))
+}
+
+// noopSink drops all the telemetry, used when telemetry is disabled
+type noopSink struct{}
+
+func (*noopSink) TrackMetric(string, float64, map[string]string)                {}
+func (*noopSink) TrackEvent(string, map[string]string)                          {}
+func (*noopSink) TrackTrace(string, contracts.SeverityLevel, map[string]string) {}
+func (*noopSink) TrackException(interface{})                                    {}
+func (*noopSink) SetCommonProperties(map[string]string)                         {}
+func (*noopSink) Close()                                                        {}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+func TestParseOtlpHeaders(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "api-key=secret", want: map[string]string{"api-key": "secret"}},
+		{value: " a = 1 ,, b=x=y, ", want: map[string]string{"a": "1", "b": "x=y"}},
+		{value: "a=", want: map[string]string{"a": ""}},
+		{value: "novalue", wantErr: true},
+		{value: "a=1, =2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOtlpHeaders(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOtlpHeaders(%q): expected an error", tt.value)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOtlpHeaders(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestInitializeTelemetryClientWithMisconfiguredSink(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "otlp without endpoint", env: map[string]string{envTelemetrySink: telemetrySinkOtlp}},
+		{name: "bad otlp headers", env: map[string]string{envTelemetrySink: telemetrySinkOtlp, envTelemetryOtlpEndpoint: "http://localhost:4318", envTelemetryOtlpHeaders: "novalue"}},
+		{name: "unopenable file", env: map[string]string{envTelemetrySink: telemetrySinkFile, envTelemetryFilePath: filepath.Join(t.TempDir(), "missing", "telemetry.log")}},
+		{name: "unknown sink", env: map[string]string{envTelemetrySink: "kafka"}},
+	}
+	defer func() { TelemetryClient = nil }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := NewTelemetrySink(); err == nil {
+				t.Fatal("NewTelemetrySink: expected an error")
+			}
+
+			TelemetryClient = nil
+			InitializeTelemetryClient("test")
+			if _, ok := TelemetryClient.(*noopSink); !ok {
+				t.Fatalf("TelemetryClient = %T, want *noopSink", TelemetryClient)
+			}
+			// The telemetry the plugin sends is dropped
+			SendException("exception")
+			TelemetryClient.TrackEvent("event", nil)
+		})
+	}
+}
+
+// otlpRequest is a request received by otlpServer
+type otlpRequest struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+// otlpServer records the requests it receives and answers them with status
+type otlpServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []otlpRequest
+}
+
+func newOtlpServer(t *testing.T) *otlpServer {
+	s := &otlpServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, otlpRequest{path: r.URL.Path, headers: r.Header, body: string(body)})
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *otlpServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *otlpServer) received() []otlpRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]otlpRequest(nil), s.requests...)
+}
+
+var otlpTimestamp = regexp.MustCompile(`"timeUnixNano":"\d+"`)
+
+func TestOtlpSinkPayload(t *testing.T) {
+	server := newOtlpServer(t)
+	sink := newOtlpSink(server.URL+"/", map[string]string{"Authorization": "Bearer token"})
+	sink.SetCommonProperties(map[string]string{"cluster": "c1"})
+	sink.TrackMetric("meMetricsProcessedCount", 1.5, map[string]string{"b": "2", "a": "1"})
+	sink.TrackEvent("startup", map[string]string{"k": "v"})
+	sink.TrackTrace("scrape failed", contracts.Warning, nil)
+	sink.TrackException(errors.New("boom"))
+	// Close sends the buffered telemetry without waiting for the flush interval
+	sink.Close()
+
+	requests := server.received()
+	if len(requests) != 2 {
+		t.Fatalf("expected a metrics and a logs request, got %+v", requests)
+	}
+	want := map[string]string{
+		"/v1/metrics": `{"resourceMetrics":[{"resource":{"attributes":[{"key":"cluster","value":{"stringValue":"c1"}}]},` +
+			`"scopeMetrics":[{"scope":{"name":"prometheus-collector/fluent-bit"},"metrics":[{"name":"meMetricsProcessedCount",` +
+			`"gauge":{"dataPoints":[{"timeUnixNano":"0","asDouble":1.5,"attributes":[{"key":"a","value":{"stringValue":"1"}},` +
+			`{"key":"b","value":{"stringValue":"2"}}]}]}}]}]}]}`,
+		"/v1/logs": `{"resourceLogs":[{"resource":{"attributes":[{"key":"cluster","value":{"stringValue":"c1"}}]},` +
+			`"scopeLogs":[{"scope":{"name":"prometheus-collector/fluent-bit"},"logRecords":[` +
+			`{"timeUnixNano":"0","severityNumber":9,"severityText":"INFO","body":{"stringValue":"startup"},` +
+			`"attributes":[{"key":"k","value":{"stringValue":"v"}},{"key":"event.name","value":{"stringValue":"startup"}}]},` +
+			`{"timeUnixNano":"0","severityNumber":13,"severityText":"WARN","body":{"stringValue":"scrape failed"}},` +
+			`{"timeUnixNano":"0","severityNumber":17,"severityText":"ERROR","body":{"stringValue":"boom"},` +
+			`"attributes":[{"key":"exception.message","value":{"stringValue":"boom"}}]}]}]}]}`,
+	}
+	for _, r := range requests {
+		if got := r.headers.Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: Content-Type = %q", r.path, got)
+		}
+		if got := r.headers.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("%s: Authorization = %q", r.path, got)
+		}
+		if got := otlpTimestamp.ReplaceAllString(r.body, `"timeUnixNano":"0"`); got != want[r.path] {
+			t.Errorf("%s: payload\n%s\nwant\n%s", r.path, got, want[r.path])
+		}
+	}
+}
+
+func TestOtlpSinkFlushesFullBatch(t *testing.T) {
+	server := newOtlpServer(t)
+	sink := newOtlpSink(server.URL, nil)
+	defer sink.Close()
+	for i := 0; i < otlpMaxBatchSize; i++ {
+		sink.TrackMetric("metric", float64(i), nil)
+	}
+
+	// The full batch is sent right away rather than after the flush interval
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the full batch was not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(server.received()); got != 1 {
+		t.Errorf("expected one request, got %d", got)
+	}
+}
+
+func TestOtlpSinkDropsTelemetryThatFailsToSend(t *testing.T) {
+	server := newOtlpServer(t)
+	server.setStatus(http.StatusServiceUnavailable)
+	sink := newOtlpSink(server.URL, nil)
+	sink.TrackMetric("dropped", 1, nil)
+	sink.flush()
+	if got := len(server.received()); got != 1 {
+		t.Fatalf("expected one request, got %d", got)
+	}
+
+	// The telemetry that failed to send is not retried
+	server.setStatus(http.StatusOK)
+	sink.TrackMetric("sent", 2, nil)
+	sink.Close()
+	requests := server.received()
+	if len(requests) != 2 {
+		t.Fatalf("expected two requests, got %d", len(requests))
+	}
+	if !strings.Contains(requests[1].body, `"name":"sent"`) {
+		t.Errorf("unexpected payload %s", requests[1].body)
+	}
+	if strings.Contains(requests[1].body, `"name":"dropped"`) {
+		t.Errorf("the dropped metric was sent again: %s", requests[1].body)
+	}
+}
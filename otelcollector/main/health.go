@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	shared "github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/supervisor"
+	"github.com/prometheus-collector/shared/watcher"
+)
+
+// noConfigurationTimeout is how long the container may run without a TokenConfig.json before it is
+// restarted, to recover from a missing or late DCR/DCE association.
+const noConfigurationTimeout = 15 * time.Minute
+
+// componentStatus is the health of one component of the container. A component that is not live
+// needs the container to be restarted, a component that is not ready is still starting up or recovering.
+type componentStatus struct {
+	Name               string         `json:"name"`
+	Live               bool           `json:"live"`
+	Ready              bool           `json:"ready"`
+	Message            string         `json:"message,omitempty"`
+	LastError          string         `json:"lastError,omitempty"`
+	LastErrorTime      *time.Time     `json:"lastErrorTime,omitempty"`
+	LastTransitionTime time.Time      `json:"lastTransitionTime"`
+	CheckedAt          time.Time      `json:"checkedAt"`
+	Process            *processStatus `json:"process,omitempty"`
+}
+
+type processStatus struct {
+	State     supervisor.State `json:"state"`
+	PID       int              `json:"pid,omitempty"`
+	Restarts  int              `json:"restarts"`
+	Crashes   int              `json:"consecutiveCrashes"`
+	StartedAt *time.Time       `json:"startedAt,omitempty"`
+	ExitedAt  *time.Time       `json:"exitedAt,omitempty"`
+}
+
+type healthReport struct {
+	Status     string            `json:"status"`
+	Components []componentStatus `json:"components,omitempty"`
+}
+
+// healthChecker evaluates the health of the container components and remembers the previous
+// result of each, to report transition times and the last error after a component recovered.
+type healthChecker struct {
+	sup    *supervisor.Supervisor
+	osType string
+	// configWatcher and tokenConfigWatcher report changes of the configmaps and certificates, and of the
+	// mdsd config. They are only set on linux.
+	configWatcher      *watcher.Watcher
+	tokenConfigWatcher *watcher.Watcher
+
+	mu       sync.Mutex
+	previous map[string]componentStatus
+}
+
+func newHealthChecker(sup *supervisor.Supervisor, osType string, configWatcher, tokenConfigWatcher *watcher.Watcher) *healthChecker {
+	return &healthChecker{
+		sup:                sup,
+		osType:             osType,
+		configWatcher:      configWatcher,
+		tokenConfigWatcher: tokenConfigWatcher,
+		previous:           map[string]componentStatus{},
+	}
+}
+
+// registerHandlers exposes /livez, /readyz and /healthz, plus the legacy /health used by the liveness probe.
+func (h *healthChecker) registerHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/livez", h.handler(func(c componentStatus) bool { return c.Live }))
+	mux.HandleFunc("/readyz", h.handler(func(c componentStatus) bool { return c.Ready }))
+	mux.HandleFunc("/healthz", h.handler(func(c componentStatus) bool { return c.Live && c.Ready }))
+	mux.HandleFunc("/health", h.legacyHandler)
+}
+
+// handler returns a JSON handler that fails if any component does not pass ok. Only the failing
+// components are listed, unless the verbose query parameter is set.
+func (h *healthChecker) handler(ok func(componentStatus) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, verbose := r.URL.Query()["verbose"]
+		components := h.check()
+
+		report := healthReport{Status: "ok"}
+		for _, c := range components {
+			if !ok(c) {
+				report.Status = "failing"
+			}
+			if verbose || !ok(c) {
+				report.Components = append(report.Components, c)
+			}
+		}
+
+		status := http.StatusOK
+		if report.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			fmt.Printf("Error writing health response: %v\n", err)
+		}
+	}
+}
+
+// legacyHandler keeps the plain text /health endpoint, which fails when the container is not live.
+func (h *healthChecker) legacyHandler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	message := "prometheuscollector is running."
+	for _, c := range h.check() {
+		if !c.Live {
+			status = http.StatusServiceUnavailable
+			message = c.Message
+			break
+		}
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprintln(w, message)
+	if status != http.StatusOK {
+		fmt.Printf("Health check failed: %d, Message: %s\n", status, message)
+		shared.WriteTerminationLog(message)
+	}
+}
+
+// check evaluates every component.
+func (h *healthChecker) check() []componentStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	tokenConfig := h.checkTokenConfig(now)
+	components := []componentStatus{tokenConfig}
+	for _, name := range []string{mdsdProcess, meProcess, otelcollectorProcess, fluentBitProcess} {
+		// mdsd and ME are only expected to run once there is a configuration
+		c, ok := h.checkProcess(name, tokenConfig.Ready || (name != mdsdProcess && name != meProcess))
+		if ok {
+			components = append(components, c)
+		}
+	}
+	components = append(components, h.checkConfigChange())
+
+	for i := range components {
+		components[i] = h.track(components[i], now)
+	}
+	return components
+}
+
+// track fills in the timestamps of a component from its previous status.
+func (h *healthChecker) track(c componentStatus, now time.Time) componentStatus {
+	c.CheckedAt = now
+	c.LastTransitionTime = now
+	prev, ok := h.previous[c.Name]
+	if ok && prev.Live == c.Live && prev.Ready == c.Ready {
+		c.LastTransitionTime = prev.LastTransitionTime
+	}
+	switch {
+	case c.LastError != "" && ok && prev.LastError == c.LastError:
+		c.LastErrorTime = prev.LastErrorTime
+	case c.LastError != "":
+		c.LastErrorTime = &now
+	case ok:
+		// keep reporting the last error after the component recovered
+		c.LastError, c.LastErrorTime = prev.LastError, prev.LastErrorTime
+	}
+	h.previous[c.Name] = c
+	return c
+}
+
+func (h *healthChecker) checkTokenConfig(now time.Time) componentStatus {
+	c := componentStatus{Name: "tokenConfig", Live: true, Ready: true, Message: "TokenConfig.json is present"}
+	tokenConfigFileLocation := "/etc/mdsd.d/config-cache/metricsextension/TokenConfig.json"
+	if h.osType == "windows" {
+		tokenConfigFileLocation = "C:\\opt\\genevamonitoringagent\\datadirectory\\mcs\\metricsextension\\TokenConfig.json"
+	}
+	if _, err := os.Stat(tokenConfigFileLocation); !os.IsNotExist(err) {
+		return c
+	}
+
+	c.Ready = false
+	c.Message = "TokenConfig.json does not exist"
+	startTime, err := os.ReadFile("/opt/microsoft/liveness/azmon-container-start-time")
+	if os.IsNotExist(err) {
+		return c
+	}
+	if err != nil {
+		c.Live = false
+		c.Message = "Error reading azmon-container-start-time: " + err.Error()
+		c.LastError = c.Message
+		return c
+	}
+	azmonContainerStartTime, err := strconv.ParseInt(strings.TrimSpace(string(startTime)), 10, 64)
+	if err != nil {
+		c.Live = false
+		c.Message = "Error converting azmon-container-start-time to integer: " + err.Error()
+		c.LastError = c.Message
+		return c
+	}
+	if now.Sub(time.Unix(azmonContainerStartTime, 0)) > noConfigurationTimeout {
+		c.Live = false
+		c.Message = "No configuration present for the AKS resource"
+		c.LastError = c.Message
+	}
+	return c
+}
+
+// checkProcess reports the state of a supervised process. It returns false if the process is not
+// supervised in this container. When expected is false, the process not running is not an error.
+func (h *healthChecker) checkProcess(name string, expected bool) (componentStatus, bool) {
+	status, ok := h.sup.ProcessStatus(name)
+	if !ok {
+		return componentStatus{}, false
+	}
+	c := componentStatus{
+		Name:      name,
+		Live:      true,
+		Ready:     status.State == supervisor.StateRunning,
+		Message:   fmt.Sprintf("%s is %s", name, status.State),
+		LastError: status.LastError,
+		Process: &processStatus{
+			State:    status.State,
+			PID:      status.PID,
+			Restarts: status.Restarts,
+			Crashes:  status.Crashes,
+		},
+	}
+	if !status.StartedAt.IsZero() {
+		c.Process.StartedAt = &status.StartedAt
+	}
+	if !status.ExitedAt.IsZero() {
+		c.Process.ExitedAt = &status.ExitedAt
+	}
+	if !expected {
+		c.Message += " (no configuration)"
+		return c, true
+	}
+	if failed, reason := supervisedProcessFailed(h.sup, name); failed {
+		c.Live = false
+		c.Message = reason
+	}
+	return c, true
+}
+
+// checkConfigChange reports whether the configmaps, certificates or mdsd config changed since the
+// container started. The new configuration is only picked up by restarting the container.
+func (h *healthChecker) checkConfigChange() componentStatus {
+	c := componentStatus{Name: "configChange", Live: true, Ready: true, Message: "configuration unchanged since the container started"}
+	var changed string
+	if h.osType == "linux" {
+		if ev, ok := lastChange(h.tokenConfigWatcher); ok {
+			changed = "mdsd config changed - " + ev.String()
+		} else if ev, ok := lastChange(h.configWatcher); ok {
+			changed = "config changed - " + ev.String()
+		}
+	} else if shared.HasConfigChanged("C:\\opt\\microsoft\\scripts\\filesystemwatcher.txt") {
+		changed = "Config Map Updated or DCR/DCE updated since agent started"
+	}
+	if changed != "" {
+		c.Live = false
+		c.Ready = false
+		c.Message = changed
+	}
+	return c
+}
+
+// lastChange returns the most recent change seen by w, which may be nil.
+func lastChange(w *watcher.Watcher) (watcher.Event, bool) {
+	if w == nil {
+		return watcher.Event{}, false
+	}
+	changes := w.Changes()
+	if len(changes) == 0 {
+		return watcher.Event{}, false
+	}
+	return changes[len(changes)-1], true
+}
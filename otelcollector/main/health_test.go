@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus-collector/shared/supervisor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startCollector supervises an otelcollector process running script and waits for it to reach state
+func startCollector(t *testing.T, script string, maxRestarts int, backoff time.Duration, state supervisor.State) *supervisor.Supervisor {
+	t.Helper()
+	sup := supervisor.New()
+	sup.InitialBackoff, sup.MaxBackoff = backoff, backoff
+	require.NoError(t, sup.Add(supervisor.Process{
+		Name:         otelcollectorProcess,
+		Command:      "/bin/sh",
+		Args:         []string{"-c", script},
+		MaxRestarts:  maxRestarts,
+		DrainTimeout: time.Second,
+	}))
+	require.NoError(t, sup.Start(context.Background()))
+	t.Cleanup(sup.Stop)
+	require.Eventually(t, func() bool {
+		status, _ := sup.ProcessStatus(otelcollectorProcess)
+		return status.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return sup
+}
+
+func getHealth(t *testing.T, h *healthChecker, path string) (int, healthReport) {
+	t.Helper()
+	mux := http.NewServeMux()
+	h.registerHandlers(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report healthReport
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+func findComponent(report healthReport, name string) (componentStatus, bool) {
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return componentStatus{}, false
+}
+
+func TestHealthHandlers(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		maxRestarts int
+		backoff     time.Duration
+		state       supervisor.State
+		wantLive    bool
+		wantReady   bool
+		wantMessage string
+	}{
+		{
+			name:        "healthy",
+			script:      "sleep 10",
+			state:       supervisor.StateRunning,
+			wantLive:    true,
+			wantReady:   true,
+			wantMessage: "otelcollector is running",
+		},
+		{
+			name:        "restarting",
+			script:      "exit 1",
+			backoff:     time.Hour,
+			state:       supervisor.StateBackoff,
+			wantLive:    true,
+			wantMessage: "otelcollector is backoff",
+		},
+		{
+			name:        "failed",
+			script:      "exit 1",
+			maxRestarts: 1,
+			backoff:     time.Millisecond,
+			state:       supervisor.StateFailed,
+			wantMessage: "otelcollector crashed 2 times in a row, last error: exit status 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sup := startCollector(t, tt.script, tt.maxRestarts, tt.backoff, tt.state)
+			h := newHealthChecker(sup, "linux", nil, nil)
+
+			code, report := getHealth(t, h, "/livez?verbose")
+			c, ok := findComponent(report, otelcollectorProcess)
+			require.True(t, ok, "%+v", report)
+			assert.Equal(t, tt.wantLive, c.Live)
+			assert.Equal(t, tt.wantReady, c.Ready)
+			assert.Equal(t, tt.wantMessage, c.Message)
+			require.NotNil(t, c.Process)
+			assert.Equal(t, tt.state, c.Process.State)
+			if tt.wantLive {
+				assert.Equal(t, http.StatusOK, code)
+				assert.Equal(t, "ok", report.Status)
+			} else {
+				assert.Equal(t, http.StatusServiceUnavailable, code)
+				assert.Equal(t, "failing", report.Status)
+			}
+
+			// The collector is only listed by /readyz when it is not ready, like the missing TokenConfig.json
+			code, report = getHealth(t, h, "/readyz")
+			assert.Equal(t, http.StatusServiceUnavailable, code)
+			_, listed := findComponent(report, otelcollectorProcess)
+			assert.Equal(t, !tt.wantReady, listed)
+			_, listed = findComponent(report, "tokenConfig")
+			assert.True(t, listed)
+		})
+	}
+}
+
+func TestHealthLegacyHandler(t *testing.T) {
+	sup := startCollector(t, "sleep 10", 0, time.Second, supervisor.StateRunning)
+	h := newHealthChecker(sup, "linux", nil, nil)
+	rec := httptest.NewRecorder()
+	h.legacyHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "prometheuscollector is running.\n", rec.Body.String())
+}
+
+func TestHealthTrack(t *testing.T) {
+	h := newHealthChecker(nil, "linux", nil, nil)
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	c := h.track(componentStatus{Name: "otelcollector", Live: true, Ready: true}, at(0))
+	assert.Equal(t, at(0), c.LastTransitionTime)
+	assert.Equal(t, at(0), c.CheckedAt)
+	assert.Nil(t, c.LastErrorTime)
+
+	// Unchanged: the transition time is kept
+	c = h.track(componentStatus{Name: "otelcollector", Live: true, Ready: true}, at(time.Second))
+	assert.Equal(t, at(0), c.LastTransitionTime)
+	assert.Equal(t, at(time.Second), c.CheckedAt)
+
+	// Not ready with an error: new transition and error time
+	c = h.track(componentStatus{Name: "otelcollector", Live: true, LastError: "exit status 1"}, at(2*time.Second))
+	assert.Equal(t, at(2*time.Second), c.LastTransitionTime)
+	require.NotNil(t, c.LastErrorTime)
+	assert.Equal(t, at(2*time.Second), *c.LastErrorTime)
+
+	// Same error: the error time is kept
+	c = h.track(componentStatus{Name: "otelcollector", Live: true, LastError: "exit status 1"}, at(3*time.Second))
+	assert.Equal(t, at(2*time.Second), c.LastTransitionTime)
+	assert.Equal(t, at(2*time.Second), *c.LastErrorTime)
+
+	// Another error: new error time
+	c = h.track(componentStatus{Name: "otelcollector", Live: true, LastError: "exit status 2"}, at(4*time.Second))
+	assert.Equal(t, at(4*time.Second), *c.LastErrorTime)
+
+	// Recovered: the last error is still reported
+	c = h.track(componentStatus{Name: "otelcollector", Live: true, Ready: true}, at(5*time.Second))
+	assert.Equal(t, at(5*time.Second), c.LastTransitionTime)
+	assert.Equal(t, "exit status 2", c.LastError)
+	assert.Equal(t, at(4*time.Second), *c.LastErrorTime)
+
+	// Components are tracked separately
+	c = h.track(componentStatus{Name: "fluent-bit", Live: true, Ready: true}, at(6*time.Second))
+	assert.Empty(t, c.LastError)
+	assert.Equal(t, at(6*time.Second), c.LastTransitionTime)
+}
@@ -1,21 +1,47 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
 
 	shared "github.com/prometheus-collector/shared"
 	ccpconfigmapsettings "github.com/prometheus-collector/shared/configmap/ccp"
 	configmapsettings "github.com/prometheus-collector/shared/configmap/mp"
+	"github.com/prometheus-collector/shared/settings"
+	"github.com/prometheus-collector/shared/supervisor"
+	"github.com/prometheus-collector/shared/watcher"
 
-	"strconv"
 	"strings"
 	"time"
 )
 
+// sup owns the long running child processes. It is read by the health handler.
+var sup = supervisor.New()
+
+func addProcess(p supervisor.Process) {
+	if err := sup.Add(p); err != nil {
+		log.Fatalf("Error adding process %s to supervisor: %v\n", p.Name, err)
+	}
+}
+
+// startConfigWatcher watches paths for changes of the configuration the container was started with.
+func startConfigWatcher(ctx context.Context, paths ...string) *watcher.Watcher {
+	w := watcher.New(paths...)
+	w.OnChange(func(ev watcher.Event) {
+		fmt.Printf("Configuration change detected, the container will be restarted: %s\n", ev)
+	})
+	if err := w.Start(ctx); err != nil {
+		log.Fatalf("Error watching %s: %v\n", strings.Join(paths, ", "), err)
+	}
+	return w
+}
+
 func main() {
 	controllerType := shared.GetControllerType()
 	cluster := shared.GetEnv("CLUSTER", "")
@@ -28,11 +54,13 @@ func main() {
 		shared.SetEnvVariablesForWindows()
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Changes to the configmaps and certificates are only picked up by restarting the container
+	var configWatcher, tokenConfigWatcher *watcher.Watcher
 	if osType == "linux" {
-		outputFile := "/opt/inotifyoutput.txt"
-		if err := shared.Inotify(outputFile, "/etc/config/settings", "/etc/prometheus/certs"); err != nil {
-			log.Fatal(err)
-		}
+		configWatcher = startConfigWatcher(ctx, "/etc/config/settings", "/etc/prometheus/certs")
 	} else if osType == "windows" {
 		fmt.Println("Starting filesystemwatcher.ps1")
 		shared.StartCommand("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "C:\\opt\\scripts\\filesystemwatcher.ps1")
@@ -57,14 +85,20 @@ func main() {
 		}
 	}
 
+	var runtimeSettings *settings.Settings
 	if ccpMetricsEnabled == "true" {
-		ccpconfigmapsettings.Configmapparserforccp()
+		runtimeSettings = ccpconfigmapsettings.Configmapparserforccp()
 	} else {
-		configmapsettings.Configmapparser()
+		runtimeSettings = configmapsettings.Configmapparser()
+	}
+
+	// Export the settings for the processes started below
+	if err := runtimeSettings.Export(); err != nil {
+		shared.EchoError(err.Error())
 	}
 
 	if ccpMetricsEnabled != "true" && osType == "linux" {
-		shared.StartCronDaemon()
+		addProcess(newCrondProcess())
 	}
 
 	var meConfigFile string
@@ -74,70 +108,26 @@ func main() {
 	fmt.Println("meConfigFile:", meConfigFile)
 	fmt.Println("fluentBitConfigFile:", fluentBitConfigFile)
 
-	shared.WaitForTokenAdapter(ccpMetricsEnabled)
-
-	if ccpMetricsEnabled != "true" {
-		shared.SetEnvAndSourceBashrcOrPowershell("ME_CONFIG_FILE", meConfigFile, true)
-		shared.SetEnvAndSourceBashrcOrPowershell("customResourceId", cluster, true)
-	} else {
-		os.Setenv("ME_CONFIG_FILE", meConfigFile)
-		os.Setenv("customResourceId", cluster)
-	}
+	shared.SetEnv("ME_CONFIG_FILE", meConfigFile, ccpMetricsEnabled != "true")
+	// fluent-bit serves the health metrics on the port the prometheus_collector_health job scrapes
+	shared.SetEnv("PROMETHEUS_COLLECTOR_HEALTH_PORT", shared.PrometheusCollectorHealthPort(), ccpMetricsEnabled != "true")
+	shared.SetEnv("customResourceId", cluster, ccpMetricsEnabled != "true")
 
 	trimmedRegion := strings.ToLower(strings.ReplaceAll(aksRegion, " ", ""))
-	if ccpMetricsEnabled != "true" {
-		shared.SetEnvAndSourceBashrcOrPowershell("customRegion", trimmedRegion, true)
-	} else {
-		os.Setenv("customRegion", trimmedRegion)
-	}
-
-	fmt.Println("Waiting for 10s for token adapter sidecar to be up and running so that it can start serving IMDS requests")
-	time.Sleep(10 * time.Second)
-
-	if ccpMetricsEnabled != "true" {
-		if osType == "linux" {
-			fmt.Println("Starting MDSD")
-			shared.StartMdsdForOverlay()
-		} else {
-			fmt.Println("Starting MA")
-			shared.StartMA()
-		}
-	} else {
-		shared.StartMdsdForUnderlay()
-	}
-
-	if osType == "linux" {
-		// update this to use color coding
-		shared.PrintMdsdVersion()
-	}
-
-	fmt.Println("Waiting for 30s for MDSD to get the config and put them in place for ME")
-	time.Sleep(30 * time.Second)
+	shared.SetEnv("customRegion", trimmedRegion, ccpMetricsEnabled != "true")
 
-	fmt.Println("Starting Metrics Extension with config overrides")
-	if ccpMetricsEnabled != "true" {
-		if _, err := shared.StartMetricsExtensionForOverlay(meConfigFile); err != nil {
-			log.Fatalf("Error starting MetricsExtension: %v\n", err)
-		}
-	} else {
-		shared.StartMetricsExtensionWithConfigOverridesForUnderlay(meConfigFile)
+	// The supervisor starts the processes in dependency order, each once its readiness gates are met:
+	// token adapter healthy -> mdsd -> TokenConfig.json and ME config present -> MetricsExtension
+	// -> otelcollector -> otelcollector healthy -> fluent-bit, telegraf.
+	mdsdProc, err := newMdsdProcess(ccpMetricsEnabled, osType)
+	if err != nil {
+		log.Fatalf("Error configuring mdsd: %v\n", err)
 	}
-	// ME_PID, err := shared.StartMetricsExtensionForOverlay(meConfigFile)
-	// if err != nil {
-	// 	fmt.Printf("Error starting MetricsExtension: %v\n", err)
-	// 	return
-	// }
-	// fmt.Printf("ME_PID: %d\n", ME_PID)
-
-	// // Modify fluentBitConfigFile using ME_PID
-	// err = shared.ModifyConfigFile(fluentBitConfigFile, ME_PID, "${ME_PID}")
-	// if err != nil {
-	// 	fmt.Printf("Error modifying config file: %v\n", err)
-	// }
+	addProcess(mdsdProc)
+	addProcess(newMetricsExtensionProcess(ccpMetricsEnabled, osType, meConfigFile))
 
 	// Start otelcollector
 	azmonOperatorEnabled := os.Getenv("AZMON_OPERATOR_ENABLED")
-	azmonUseDefaultPrometheusConfig := os.Getenv("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG")
 
 	var collectorConfig string
 
@@ -147,9 +137,9 @@ func main() {
 			collectorConfig = "/opt/microsoft/otelcollector/ccp-collector-config-replicaset.yml"
 		} else {
 			collectorConfig = "/opt/microsoft/otelcollector/collector-config-replicaset.yml"
-			configmapsettings.SetGlobalSettingsInCollectorConfig()
+			configmapsettings.SetGlobalSettingsInCollectorConfig(runtimeSettings)
 		}
-	} else if azmonUseDefaultPrometheusConfig == "true" {
+	} else if runtimeSettings.UseDefaultPrometheusConfig {
 		fmt.Println("Starting otelcollector with only default scrape configs enabled")
 		if ccpMetricsEnabled == "true" {
 			collectorConfig = "/opt/microsoft/otelcollector/ccp-collector-config-default.yml"
@@ -160,208 +150,81 @@ func main() {
 		collectorConfig = "/opt/microsoft/otelcollector/collector-config.yml"
 	}
 
-	fmt.Println("startCommand otelcollector")
-	_, err := shared.StartCommandWithOutputFile("/opt/microsoft/otelcollector/otelcollector", []string{"--config", collectorConfig}, "/opt/microsoft/otelcollector/collector-log.txt")
-	// OTEL_PID, err := shared.StartCommandWithOutputFile("/opt/microsoft/otelcollector/otelcollector", []string{"--config", collectorConfig}, "/opt/microsoft/otelcollector/collector-log.txt")
-	// if err != nil {
-	// 	fmt.Printf("Error starting command: %v\n", err)
-	// 	return
-	// }
-	// fmt.Printf("OTEL_PID: %d\n", OTEL_PID)
-
-	// // Modify fluentBitConfigFile using OTEL_PID
-	// err = shared.ModifyConfigFile(fluentBitConfigFile, OTEL_PID, "${OTEL_PID}")
-	// if err != nil {
-	// 	fmt.Printf("Error modifying config file: %v\n", err)
-	// }
+	addProcess(newOtelcollectorProcess(collectorConfig))
+
+	supervisedTelegraf := osType == "linux" && os.Getenv("TELEMETRY_DISABLED") != "true"
+	if ccpMetricsEnabled != "true" {
+		addProcess(newFluentBitProcess(osType, fluentBitConfigFile))
+		if supervisedTelegraf {
+			addProcess(newTelegrafProcess())
+		}
+	}
+
+	sup.OnGate = reportGate
+	if err := sup.Start(ctx); err != nil {
+		// log.Fatalf exits right away, stop the processes that were started before the failure
+		sup.Stop()
+		log.Fatalf("Error starting processes: %v\n", err)
+	}
 
 	if osType == "linux" {
+		// update this to use color coding
+		shared.PrintMdsdVersion()
 		shared.LogVersionInfo()
 	}
 
 	if ccpMetricsEnabled != "true" {
-		shared.StartFluentBit(fluentBitConfigFile)
 		// Run the command and capture the output
 		if osType == "linux" {
 			cmd := exec.Command("fluent-bit", "--version")
 			fluentBitVersion, err := cmd.Output()
 			if err != nil {
-				log.Fatalf("failed to run command: %v", err)
+				// fluent-bit is already supervised, a missing version is not worth stopping the container for
+				log.Printf("failed to run command: %v", err)
+			} else {
+				shared.EchoVar("FLUENT_BIT_VERSION", string(fluentBitVersion))
 			}
-			shared.EchoVar("FLUENT_BIT_VERSION", string(fluentBitVersion))
 		}
 
-		shared.StartTelegraf()
-
+		if supervisedTelegraf {
+			telegrafVersion, _ := os.ReadFile("/opt/telegrafversion.txt")
+			fmt.Printf("TELEGRAF_VERSION=%s\n", string(telegrafVersion))
+		} else {
+			shared.StartTelegraf()
+		}
 	}
 
 	if osType == "linux" {
-		// Start inotify to watch for changes
-		fmt.Println("Starting inotify for watching mdsd config update")
-
-		// Create an output file for inotify events
-		outputFile := "/opt/inotifyoutput-mdsd-config.txt"
-		_, err = os.Create(outputFile)
-		if err != nil {
-			log.Fatalf("Error creating output file: %v\n", err)
-		}
-
-		// Define the command to start inotify
-		inotifyCommand := exec.Command(
-			"inotifywait",
-			"/etc/mdsd.d/config-cache/metricsextension/TokenConfig.json",
-			"--daemon",
-			"--outfile", outputFile,
-			"--event", "ATTRIB",
-			"--format", "%e : %T",
-			"--timefmt", "+%s",
-		)
-
-		// Start the inotify process
-		err = inotifyCommand.Start()
-		if err != nil {
-			log.Fatalf("Error starting inotify process: %v\n", err)
-		}
+		tokenConfigWatcher = startConfigWatcher(ctx, "/etc/mdsd.d/config-cache/metricsextension/TokenConfig.json")
 	}
 
 	// Setting time at which the container started running
 	epochTimeNow := time.Now().Unix()
 	epochTimeNowReadable := time.Unix(epochTimeNow, 0).Format(time.RFC3339)
 
-	// Writing the epoch time to a file
-	file, err := os.Create("/opt/microsoft/liveness/azmon-container-start-time")
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(fmt.Sprintf("%d", epochTimeNow))
-	if err != nil {
+	// Writing the epoch time to a file. Without it the liveness probe fails and reports the error,
+	// the processes keep running until they are stopped by shutdown
+	if err := os.WriteFile("/opt/microsoft/liveness/azmon-container-start-time", []byte(fmt.Sprintf("%d", epochTimeNow)), 0666); err != nil {
 		fmt.Println("Error writing to file:", err)
-		return
 	}
 
 	// Printing the environment variable and the readable time
 	fmt.Printf("AZMON_CONTAINER_START_TIME=%d\n", epochTimeNow)
 	shared.FmtVar("AZMON_CONTAINER_START_TIME_READABLE", epochTimeNowReadable)
 
-	// Expose a health endpoint for liveness probe
-	http.HandleFunc("/health", healthHandler)
-	http.ListenAndServe(":8080", nil)
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	osType := os.Getenv("OS_TYPE")
-	status := http.StatusOK
-	message := "prometheuscollector is running."
-	processToCheck := ""
-
-	tokenConfigFileLocation := "/etc/mdsd.d/config-cache/metricsextension/TokenConfig.json"
-	if osType == "windows" {
-		tokenConfigFileLocation = "C:\\opt\\genevamonitoringagent\\datadirectory\\mcs\\metricsextension\\TokenConfig.json"
-	}
-
-	// Checking if TokenConfig file exists
-	if _, err := os.Stat(tokenConfigFileLocation); os.IsNotExist(err) {
-		fmt.Println("TokenConfig.json does not exist")
-		if _, err := os.Stat("/opt/microsoft/liveness/azmon-container-start-time"); err == nil {
-			fmt.Println("azmon-container-start-time file exists, reading start time")
-			azmonContainerStartTimeStr, err := os.ReadFile("/opt/microsoft/liveness/azmon-container-start-time")
-			if err != nil {
-				status = http.StatusServiceUnavailable
-				message = "Error reading azmon-container-start-time: " + err.Error()
-				fmt.Println(message)
-				goto response
-			}
-
-			azmonContainerStartTime, err := strconv.Atoi(strings.TrimSpace(string(azmonContainerStartTimeStr)))
-			if err != nil {
-				status = http.StatusServiceUnavailable
-				message = "Error converting azmon-container-start-time to integer: " + err.Error()
-				fmt.Println(message)
-				goto response
-			}
-
-			epochTimeNow := int(time.Now().Unix())
-			duration := epochTimeNow - azmonContainerStartTime
-			durationInMinutes := duration / 60
-			fmt.Printf("Container has been running for %d minutes\n", durationInMinutes)
-
-			if durationInMinutes%5 == 0 {
-				fmt.Printf("%s No configuration present for the AKS resource\n", time.Now().Format("2006-01-02T15:04:05"))
-			}
-
-			if durationInMinutes > 15 {
-				status = http.StatusServiceUnavailable
-				message = "No configuration present for the AKS resource"
-				fmt.Println(message)
-				goto response
-			}
-		} else {
-			fmt.Println("azmon-container-start-time file does not exist")
-		}
-	} else {
-		processToCheck = "/usr/sbin/MetricsExtension"
-		if osType == "windows" {
-			processToCheck = "MetricsExtension.Native.exe"
-		}
-		if !shared.IsProcessRunning(processToCheck) {
-			status = http.StatusServiceUnavailable
-			message = "Metrics Extension is not running (configuration exists)"
-			fmt.Println(message)
-			goto response
-		}
-
-		processToCheck = "/usr/sbin/mdsd"
-		if osType == "windows" {
-			processToCheck = "MonAgentLauncher.exe"
-		}
-		if !shared.IsProcessRunning(processToCheck) {
-			status = http.StatusServiceUnavailable
-			message = "mdsd not running (configuration exists)"
-			fmt.Println(message)
-			goto response
-		}
-	}
-	if osType == "linux" {
-		if shared.HasConfigChanged("/opt/inotifyoutput-mdsd-config.txt") {
-			status = http.StatusServiceUnavailable
-			message = "inotifyoutput-mdsd-config.txt has been updated - mdsd config changed"
-			fmt.Println(message)
-			goto response
-		}
-		if shared.HasConfigChanged("/opt/inotifyoutput.txt") {
-			status = http.StatusServiceUnavailable
-			message = "inotifyoutput.txt has been updated - config changed"
-			fmt.Println(message)
-			goto response
-		}
-	} else {
-		if shared.HasConfigChanged("C:\\opt\\microsoft\\scripts\\filesystemwatcher.txt") {
-			status = http.StatusServiceUnavailable
-			message = "Config Map Updated or DCR/DCE updated since agent started"
-			fmt.Println(message)
-			goto response
-		}
-	}
-
-	processToCheck = "/opt/microsoft/otelcollector/otelcollector"
-	if osType == "windows" {
-		processToCheck = "otelcollector.exe"
-	}
-	if !shared.IsProcessRunning(processToCheck) {
-		status = http.StatusServiceUnavailable
-		message = "OpenTelemetryCollector is not running."
-		fmt.Println(message)
-		goto response
-	}
-
-response:
-	w.WriteHeader(status)
-	fmt.Fprintln(w, message)
-	if status != http.StatusOK {
-		fmt.Printf("Health check failed: %d, Message: %s\n", status, message)
-		shared.WriteTerminationLog(message)
-	}
+	// Expose the health endpoints for the liveness and readiness probes
+	mux := http.NewServeMux()
+	newHealthChecker(sup, osType, configWatcher, tokenConfigWatcher).registerHandlers(mux)
+	server := &http.Server{Addr: ":8080", Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error serving health endpoint: %v\n", err)
+		}
+	}()
+
+	// Stop the children in the order that flushes the metrics in flight before exiting
+	<-ctx.Done()
+	fmt.Println("Received termination signal, stopping processes")
+	shutdown(sup)
+	server.Close()
 }
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	shared "github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/supervisor"
+)
+
+// Names of the supervised processes, used for dependency ordering and in the health endpoint.
+const (
+	crondProcess         = "crond"
+	mdsdProcess          = "mdsd"
+	meProcess            = "MetricsExtension"
+	otelcollectorProcess = "otelcollector"
+	fluentBitProcess     = "fluent-bit"
+	telegrafProcess      = "telegraf"
+)
+
+// maxConsecutiveCrashes is the number of crashes in a row after which a process is given up on
+// and the health endpoint reports the container unhealthy.
+const maxConsecutiveCrashes = 5
+
+// Names of the readiness gates waited for before starting processes.
+const (
+	tokenAdapterGate         = "tokenadapter"
+	tokenConfigGate          = "tokenconfig"
+	meConfigGate             = "meconfig"
+	otelcollectorHealthyGate = "otelcollector"
+)
+
+// gateTelemetryEnv maps each gate to the environment variables telegraf reports its wait time in,
+// when it was met and when it timed out. The token adapter names predate the other gates.
+var gateTelemetryEnv = map[string][2]string{
+	tokenAdapterGate:         {"tokenadapterHealthyAfterSecs", "tokenadapterUnhealthyAfterSecs"},
+	tokenConfigGate:          {"tokenConfigReadyAfterSecs", "tokenConfigTimedOutAfterSecs"},
+	meConfigGate:             {"meConfigReadyAfterSecs", "meConfigTimedOutAfterSecs"},
+	otelcollectorHealthyGate: {"otelcollectorHealthyAfterSecs", "otelcollectorUnhealthyAfterSecs"},
+}
+
+// reportGate exports how long a gate was waited for, so that processes started afterwards,
+// telegraf in particular, pick it up from their environment.
+func reportGate(result supervisor.GateResult) {
+	env, ok := gateTelemetryEnv[result.Gate]
+	if !ok {
+		return
+	}
+	key := env[0]
+	if result.Err != nil {
+		key = env[1]
+	}
+	secs := strconv.Itoa(int(result.Waited.Seconds()))
+	os.Setenv(key, secs)
+	fmt.Printf("export %s=%s\n", key, secs)
+}
+
+// durationFromEnv returns the duration in seconds set by the environment variable envVar, or
+// defaultTimeout if it is not set or invalid. 0 is valid: a gate with a 0 timeout waits without timeout.
+func durationFromEnv(envVar string, defaultTimeout time.Duration) time.Duration {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultTimeout
+	}
+	secs, err := strconv.Atoi(value)
+	if err != nil || secs < 0 {
+		shared.EchoError(fmt.Sprintf("Invalid value %q for %s, using the default of %s", value, envVar, defaultTimeout))
+		return defaultTimeout
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// otelcollectorHealthGate waits for the collector to serve its own telemetry, which fluent-bit and
+// telegraf read from.
+func otelcollectorHealthGate() supervisor.Gate {
+	return supervisor.Gate{
+		Name:    otelcollectorHealthyGate,
+		Check:   supervisor.HTTPOK("http://localhost:8888/metrics"),
+		Timeout: durationFromEnv("OTELCOLLECTOR_HEALTH_WAIT_SECS", 30*time.Second),
+	}
+}
+
+func newCrondProcess() supervisor.Process {
+	return supervisor.Process{
+		Name:        crondProcess,
+		Command:     "/usr/sbin/crond",
+		Args:        []string{"-n", "-s"},
+		MaxRestarts: maxConsecutiveCrashes,
+	}
+}
+
+// newMdsdProcess returns mdsd, or MonAgentLauncher on windows. It is started once the token adapter
+// sidecar is healthy, so that it can serve IMDS requests.
+func newMdsdProcess(ccpMetricsEnabled, osType string) (supervisor.Process, error) {
+	tokenAdapterTimeout := 60 * time.Second
+	if ccpMetricsEnabled == "true" {
+		tokenAdapterTimeout = 20 * time.Second
+	}
+	p := supervisor.Process{
+		Name:        mdsdProcess,
+		MaxRestarts: maxConsecutiveCrashes,
+		Gates: []supervisor.Gate{{
+			Name:    tokenAdapterGate,
+			Check:   supervisor.HTTPOK("http://localhost:9999/healthz"),
+			Timeout: durationFromEnv("TOKEN_ADAPTER_WAIT_SECS", tokenAdapterTimeout),
+		}},
+	}
+	switch {
+	case ccpMetricsEnabled == "true":
+		p.Command = "/usr/sbin/mdsd"
+		p.Args = []string{"-a", "-A", "-D"}
+	case osType == "windows":
+		p.Command = "C:\\opt\\genevamonitoringagent\\genevamonitoringagent\\Monitoring\\Agent\\MonAgentLauncher.exe"
+		p.Args = []string{"-useenv"}
+	default:
+		mdsdLog := os.Getenv("MDSD_LOG")
+		if mdsdLog == "" {
+			return p, fmt.Errorf("MDSD_LOG environment variable is not set")
+		}
+		p.Command = "/usr/sbin/mdsd"
+		p.Args = []string{"-a", "-A", "-e", mdsdLog + "/mdsd.err", "-w", mdsdLog + "/mdsd.warn", "-o", mdsdLog + "/mdsd.info", "-q", mdsdLog + "/mdsd.qos"}
+		// mdsd logs to the files above, its console output is dropped
+		p.Stdout = io.Discard
+		p.Stderr = io.Discard
+	}
+	return p, nil
+}
+
+// newMetricsExtensionProcess returns MetricsExtension, which is started once mdsd has put the token
+// config in place and the ME config is present.
+func newMetricsExtensionProcess(ccpMetricsEnabled, osType, meConfigFile string) supervisor.Process {
+	tokenConfigFile := "/etc/mdsd.d/config-cache/metricsextension/TokenConfig.json"
+	if osType == "windows" {
+		tokenConfigFile = "C:\\opt\\genevamonitoringagent\\datadirectory\\mcs\\metricsextension\\TokenConfig.json"
+	}
+	if ccpMetricsEnabled == "true" {
+		meConfigFile = "/usr/sbin/me.config"
+	}
+	p := supervisor.Process{
+		Name:        meProcess,
+		DependsOn:   []string{mdsdProcess},
+		MaxRestarts: maxConsecutiveCrashes,
+		Gates: []supervisor.Gate{
+			{
+				Name:    tokenConfigGate,
+				Check:   supervisor.FileExists(tokenConfigFile),
+				Timeout: durationFromEnv("TOKEN_CONFIG_WAIT_SECS", 30*time.Second),
+			},
+			{
+				Name:    meConfigGate,
+				Check:   supervisor.FileExists(meConfigFile),
+				Timeout: durationFromEnv("ME_CONFIG_WAIT_SECS", 10*time.Second),
+			},
+		},
+	}
+	switch {
+	case ccpMetricsEnabled == "true":
+		p.Command = "/usr/sbin/MetricsExtension"
+		p.Args = []string{"-Logger", "Console", "-LogLevel", "Error", "-LocalControlChannel", "-TokenSource", "AMCS", "-DataDirectory", "/etc/mdsd.d/config-cache/metricsextension", "-Input", "otlp_grpc_prom", "-ConfigOverridesFilePath", meConfigFile}
+	case osType == "windows":
+		p.Command = "C:\\opt\\metricextension\\MetricsExtension\\MetricsExtension.Native.exe"
+		p.Args = []string{"-Logger", "File", "-LogLevel", "Info", "-LocalControlChannel", "-TokenSource", "AMCS", "-DataDirectory", "C:\\opt\\genevamonitoringagent\\datadirectory\\mcs\\metricsextension\\", "-Input", "otlp_grpc_prom", "-ConfigOverridesFilePath", meConfigFile}
+	default:
+		p.Command = "/usr/sbin/MetricsExtension"
+		p.Args = []string{"-Logger", "File", "-LogLevel", "Info", "-LocalControlChannel", "-TokenSource", "AMCS", "-DataDirectory", "/etc/mdsd.d/config-cache/metricsextension", "-Input", "otlp_grpc_prom", "-ConfigOverridesFilePath", meConfigFile}
+	}
+	return p
+}
+
+func newOtelcollectorProcess(collectorConfig string) supervisor.Process {
+	return supervisor.Process{
+		Name:        otelcollectorProcess,
+		Command:     "/opt/microsoft/otelcollector/otelcollector",
+		Args:        []string{"--config", collectorConfig},
+		OutputFile:  "/opt/microsoft/otelcollector/collector-log.txt",
+		DependsOn:   []string{meProcess},
+		MaxRestarts: maxConsecutiveCrashes,
+		// On SIGTERM the collector stops its receivers, then flushes the batch processor and the
+		// exporter queues to ME
+		DrainTimeout: durationFromEnv("OTELCOLLECTOR_DRAIN_SECS", 10*time.Second),
+	}
+}
+
+func newFluentBitProcess(osType, fluentBitConfigFile string) supervisor.Process {
+	p := supervisor.Process{
+		Name:        fluentBitProcess,
+		DependsOn:   []string{otelcollectorProcess},
+		MaxRestarts: maxConsecutiveCrashes,
+		Gates:       []supervisor.Gate{otelcollectorHealthGate()},
+	}
+	if osType == "windows" {
+		p.Command = "C:\\opt\\fluent-bit\\bin\\fluent-bit.exe"
+		p.Args = []string{"-c", "C:\\opt\\fluent-bit\\fluent-bit-windows.conf", "-e", "C:\\opt\\fluent-bit\\bin\\out_appinsights.so"}
+		return p
+	}
+	p.Command = "fluent-bit"
+	p.Args = []string{"-c", fluentBitConfigFile, "-e", "/opt/fluent-bit/bin/out_appinsights.so"}
+	p.PreStart = func(context.Context) error {
+		if err := os.Mkdir("/opt/microsoft/fluent-bit", 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+		logFile, err := os.Create("/opt/microsoft/fluent-bit/fluent-bit-out-appinsights-runtime.log")
+		if err != nil {
+			return fmt.Errorf("error creating log file: %v", err)
+		}
+		return logFile.Close()
+	}
+	return p
+}
+
+// newTelegrafProcess returns telegraf with the config for the controller type.
+func newTelegrafProcess() supervisor.Process {
+	var telegrafConfig string
+	switch controllerType, azmonOperatorEnabled := os.Getenv("CONTROLLER_TYPE"), os.Getenv("AZMON_OPERATOR_ENABLED"); {
+	case controllerType == "ReplicaSet" && azmonOperatorEnabled == "true":
+		telegrafConfig = "/opt/telegraf/telegraf-prometheus-collector-ta-enabled.conf"
+	case controllerType == "ReplicaSet":
+		telegrafConfig = "/opt/telegraf/telegraf-prometheus-collector.conf"
+	default:
+		telegrafConfig = "/opt/telegraf/telegraf-prometheus-collector-ds.conf"
+	}
+	return supervisor.Process{
+		Name:        telegrafProcess,
+		Command:     "/usr/bin/telegraf",
+		Args:        []string{"--config", telegrafConfig},
+		DependsOn:   []string{otelcollectorProcess},
+		MaxRestarts: maxConsecutiveCrashes,
+		Gates:       []supervisor.Gate{otelcollectorHealthGate()},
+	}
+}
+
+// supervisedProcessFailed reports whether a supervised process has been given up on, along with a
+// message describing why. A process that is being restarted is not considered failed.
+func supervisedProcessFailed(sup *supervisor.Supervisor, name string) (bool, string) {
+	if sup == nil {
+		return false, ""
+	}
+	status, ok := sup.ProcessStatus(name)
+	if !ok {
+		return false, ""
+	}
+	if status.State == supervisor.StateFailed {
+		return true, fmt.Sprintf("%s crashed %d times in a row, last error: %s", name, status.Crashes, status.LastError)
+	}
+	if status.Crashes > 0 {
+		fmt.Printf("%s is recovering, state: %s, consecutive crashes: %d, restarts: %d\n", name, status.State, status.Crashes, status.Restarts)
+	}
+	return false, ""
+}
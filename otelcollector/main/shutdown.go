@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-collector/shared/supervisor"
+)
+
+const (
+	// otelcollectorMetricsURL serves the collector's own telemetry, including its exporter counters.
+	otelcollectorMetricsURL = "http://localhost:8888/metrics"
+	// exporterStatsPollInterval is how often the exporter counters are read while the collector shuts down.
+	exporterStatsPollInterval = 500 * time.Millisecond
+	// defaultShutdownTimeout leaves 5s of the 30s terminationGracePeriodSeconds of the pods for the
+	// kubelet, see shutdown.
+	defaultShutdownTimeout = 25 * time.Second
+)
+
+// exporterStats are the otelcollector exporter counters, summed over all exporters.
+type exporterStats struct {
+	sent   float64
+	failed float64
+	queued float64
+}
+
+// readExporterStats reads the exporter counters from the collector's telemetry endpoint.
+func readExporterStats(client *http.Client) (exporterStats, error) {
+	var stats exporterStats
+	resp, err := client.Get(otelcollectorMetricsURL)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("%s returned %s", otelcollectorMetricsURL, resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name{labels} value [timestamp]
+		name, rest, _ := strings.Cut(line, "{")
+		if i := strings.LastIndexByte(rest, '}'); i >= 0 {
+			rest = rest[i+1:]
+		} else {
+			name, rest, _ = strings.Cut(line, " ")
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSuffix(name, "_total") {
+		case "otelcol_exporter_sent_metric_points":
+			stats.sent += value
+		case "otelcol_exporter_send_failed_metric_points":
+			stats.failed += value
+		case "otelcol_exporter_queue_size":
+			stats.queued += value
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// stopOtelcollector stops the collector, which stops scraping and flushes its batch processor and
+// exporter queues to ME, and logs how many metric points it sent while shutting down. The collector is
+// killed at deadline if it is still flushing.
+func stopOtelcollector(sup *supervisor.Supervisor, deadline time.Time) {
+	client := &http.Client{Timeout: exporterStatsPollInterval}
+	before, err := readExporterStats(client)
+	if err != nil {
+		fmt.Printf("Could not read the otelcollector exporter counters before shutdown: %v\n", err)
+		sup.StopProcessBefore(otelcollectorProcess, deadline)
+		return
+	}
+	fmt.Printf("Stopping otelcollector: %.0f metric points queued in its exporters\n", before.queued)
+
+	// The telemetry endpoint is served until the collector exits, poll it to get the last counters
+	var mu sync.Mutex
+	last := before
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(exporterStatsPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if stats, err := readExporterStats(client); err == nil {
+					mu.Lock()
+					last = stats
+					mu.Unlock()
+				}
+			}
+		}
+	}()
+	result := sup.StopProcessBefore(otelcollectorProcess, deadline)
+	close(done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Printf("otelcollector flushed %.0f metric points in %s, %.0f failed to send, %.0f were still queued when last seen\n",
+		last.sent-before.sent, result.Took.Round(time.Millisecond), last.failed-before.failed, last.queued)
+	if result.Killed {
+		fmt.Println("otelcollector did not finish flushing before its drain timeout, the metric points still queued were lost")
+	}
+}
+
+// shutdown stops the processes so that the metrics in flight reach storage: the otelcollector stops
+// scraping and flushes its exporters to ME, ME gets a drain window to publish what it received, then
+// mdsd, fluent-bit and the remaining processes are stopped. fluent-bit goes last so that it forwards
+// the logs written while shutting down.
+//
+// The processes are stopped one after the other, so their drain timeouts add up. The whole shutdown is
+// bounded by SHUTDOWN_TIMEOUT_SECS, 25s by default, and the processes still running then are killed. It
+// has to stay below the terminationGracePeriodSeconds of the pod, after which the kubelet kills the
+// container without the logs of the shutdown being forwarded. 0 lets every process use its whole drain
+// timeout.
+func shutdown(sup *supervisor.Supervisor) {
+	start := time.Now()
+	var deadline time.Time
+	if timeout := durationFromEnv("SHUTDOWN_TIMEOUT_SECS", defaultShutdownTimeout); timeout > 0 {
+		deadline = start.Add(timeout)
+	}
+	stopOtelcollector(sup, deadline)
+
+	if status, ok := sup.ProcessStatus(meProcess); ok && status.PID != 0 {
+		drainWindow := durationFromEnv("ME_DRAIN_WINDOW_SECS", 5*time.Second)
+		if !deadline.IsZero() {
+			drainWindow = min(drainWindow, max(time.Until(deadline), 0))
+		}
+		fmt.Printf("Waiting %s for MetricsExtension to publish the metrics it received\n", drainWindow)
+		time.Sleep(drainWindow)
+	}
+	for _, name := range []string{meProcess, mdsdProcess, fluentBitProcess} {
+		if result := sup.StopProcessBefore(name, deadline); result.Running {
+			fmt.Printf("%s stopped in %s, killed: %t\n", name, result.Took.Round(time.Millisecond), result.Killed)
+		}
+	}
+	sup.StopBefore(deadline)
+	fmt.Printf("All processes stopped in %s\n", time.Since(start).Round(time.Millisecond))
+}
@@ -44,6 +44,9 @@ Set-Location main
 # Create directories
 New-Item -Path "./shared/configmap/mp/" -ItemType Directory -Force
 New-Item -Path "./shared/configmap/ccp/" -ItemType Directory -Force
+New-Item -Path "./shared/supervisor/" -ItemType Directory -Force
+New-Item -Path "./shared/settings/" -ItemType Directory -Force
+New-Item -Path "./shared/watcher/" -ItemType Directory -Force
 # New-Item -Path "./main/" -ItemType Directory -Force
 
 # Copy shared Go files
@@ -56,6 +59,9 @@ Copy-Item -Path "../shared/configmap/mp/go.sum" -Destination "./shared/configmap
 Copy-Item -Path "../shared/configmap/ccp/*.go" -Destination "./shared/configmap/ccp/"
 Copy-Item -Path "../shared/configmap/ccp/go.mod" -Destination "./shared/configmap/ccp/"
 Copy-Item -Path "../shared/configmap/ccp/go.sum" -Destination "./shared/configmap/ccp/"
+Copy-Item -Path "../shared/supervisor/*.go" -Destination "./shared/supervisor/"
+Copy-Item -Path "../shared/settings/*.go" -Destination "./shared/settings/"
+Copy-Item -Path "../shared/watcher/*.go" -Destination "./shared/watcher/"
 
 # # Copy main Go files
 # Copy-Item -Path "./main/*.go" -Destination "./main/"
@@ -65,7 +71,7 @@ Copy-Item -Path "../shared/configmap/ccp/go.sum" -Destination "./shared/configma
 
 go version
 go mod tidy
-go build -buildmode=pie -o "main.exe" "./main.go"
+go build -buildmode=pie -o "main.exe" .
 
 Write-Output "Build main executable completed"
 
@@ -115,29 +115,40 @@ func LoadFromCLI(target *Config, flagSet *pflag.FlagSet) error {
 		target.PrometheusCR.Enabled = prometheusCREnabled
 	}
 
-	target.HTTPS.Enabled, err = getHttpsEnabled(flagSet)
-	if err != nil {
-		return err
+	// The HTTPS server can be configured in the config file, the flags override it only if they are set
+	if flagSet.Changed(httpsEnabledFlagName) {
+		target.HTTPS.Enabled, err = getHttpsEnabled(flagSet)
+		if err != nil {
+			return err
+		}
 	}
 
-	target.HTTPS.ListenAddr, err = getHttpsListenAddr(flagSet)
-	if err != nil {
-		return err
+	if flagSet.Changed(listenAddrHttpsFlagName) || target.HTTPS.ListenAddr == "" {
+		target.HTTPS.ListenAddr, err = getHttpsListenAddr(flagSet)
+		if err != nil {
+			return err
+		}
 	}
 
-	target.HTTPS.CAFilePath, err = getHttpsCAFilePath(flagSet)
-	if err != nil {
-		return err
+	if flagSet.Changed(httpsCAFilePathFlagName) || target.HTTPS.CAFilePath == "" {
+		target.HTTPS.CAFilePath, err = getHttpsCAFilePath(flagSet)
+		if err != nil {
+			return err
+		}
 	}
 
-	target.HTTPS.TLSCertFilePath, err = getHttpsTLSCertFilePath(flagSet)
-	if err != nil {
-		return err
+	if flagSet.Changed(httpsTLSCertFilePathFlagName) || target.HTTPS.TLSCertFilePath == "" {
+		target.HTTPS.TLSCertFilePath, err = getHttpsTLSCertFilePath(flagSet)
+		if err != nil {
+			return err
+		}
 	}
 
-	target.HTTPS.TLSKeyFilePath, err = getHttpsTLSKeyFilePath(flagSet)
-	if err != nil {
-		return err
+	if flagSet.Changed(httpsTLSKeyFilePathFlagName) || target.HTTPS.TLSKeyFilePath == "" {
+		target.HTTPS.TLSKeyFilePath, err = getHttpsTLSKeyFilePath(flagSet)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -164,13 +175,14 @@ func CreateDefaultConfig() Config {
 	}
 }
 
-func Load() (*Config, error) {
+// Load returns the config loaded from the config file and the command line flags, and the path of the config file.
+func Load() (*Config, string, error) {
 	var err error
 
 	flagSet := getFlagSet(pflag.ExitOnError)
 	err = flagSet.Parse(os.Args)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	config := CreateDefaultConfig()
@@ -178,19 +190,19 @@ func Load() (*Config, error) {
 	// load the config from the config file
 	configFilePath, err := getConfigFilePath(flagSet)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	err = LoadFromFile(configFilePath, &config)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	err = LoadFromCLI(&config, flagSet)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return &config, nil
+	return &config, configFilePath, nil
 }
 
 // ValidateConfig validates the cli and file configs together.
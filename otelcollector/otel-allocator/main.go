@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -56,6 +57,7 @@ func main() {
 		discoveryManager *discovery.Manager
 		collectorWatcher *collector.Watcher
 		promWatcher      allocatorWatcher.Watcher
+		fileWatcher      allocatorWatcher.Watcher
 		targetDiscoverer *target.Discoverer
 
 		discoveryCancel context.CancelFunc
@@ -72,7 +74,7 @@ func main() {
 		setupLog.Info("MICROSOFT SOFTWARE LICENSE TERMS\n\nMICROSOFT Azure Arc-enabled Kubernetes\n\nThis software is licensed to you as part of your or your company's subscription license for Microsoft Azure Services. You may only use the software with Microsoft Azure Services and subject to the terms and conditions of the agreement under which you obtained Microsoft Azure Services. If you do not have an active subscription license for Microsoft Azure Services, you may not use the software. Microsoft Azure Legal Information: https://azure.microsoft.com/en-us/support/legal/")
 	}
 
-	cfg, err := config.Load()
+	cfg, configFilePath, err := config.Load()
 	if err != nil {
 		fmt.Printf("Failed to load config: %v", err)
 		os.Exit(1)
@@ -123,6 +125,26 @@ func main() {
 	signal.Notify(interrupts, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer close(interrupts)
 
+	// The config reader replaces the config file when the scrape configs change
+	fileWatcher, err = allocatorWatcher.NewFileWatcher(setupLog.WithName("file-watcher"), configFilePath)
+	if err != nil {
+		setupLog.Error(err, "Can't start the file watcher")
+		os.Exit(1)
+	}
+	runGroup.Add(
+		func() error {
+			fileWatcherErr := fileWatcher.Watch(eventChan, errChan)
+			setupLog.Info("File watcher exited")
+			return fileWatcherErr
+		},
+		func(_ error) {
+			setupLog.Info("Closing file watcher")
+			fileWatcherErr := fileWatcher.Close()
+			if fileWatcherErr != nil {
+				setupLog.Error(fileWatcherErr, "file watcher failed to close")
+			}
+		})
+
 	if cfg.PrometheusCR.Enabled {
 		promWatcher, err = allocatorWatcher.NewPrometheusCRWatcher(ctx, setupLog.WithName("prometheus-cr-watcher"), *cfg)
 		if err != nil {
@@ -228,6 +250,10 @@ func main() {
 				case event := <-eventChan:
 					eventsMetric.WithLabelValues(event.Source.String()).Inc()
 					loadConfig, err := event.Watcher.LoadConfig(ctx)
+					if errors.Is(err, allocatorWatcher.ErrSettingsChanged) {
+						// Exiting restarts the container with the new settings
+						return err
+					}
 					if err != nil {
 						setupLog.Error(err, "Unable to load configuration")
 						continue
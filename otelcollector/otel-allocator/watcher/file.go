@@ -0,0 +1,138 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	promconfig "github.com/prometheus/prometheus/config"
+
+	"github.com/open-telemetry/opentelemetry-operator/cmd/otel-allocator/config"
+)
+
+// ErrSettingsChanged is returned by LoadConfig when the settings of the config file other than the scrape configs
+// changed. The target allocator only applies them when it starts.
+var ErrSettingsChanged = errors.New("the target allocator settings changed, a restart is required to apply them")
+
+// FileWatcher watches the config file of the target allocator, which the config reader replaces when the
+// scrape configs change, so that they are applied without a restart.
+type FileWatcher struct {
+	logger         logr.Logger
+	configFilePath string
+	watcher        *fsnotify.Watcher
+	closer         chan bool
+	// settings are the settings of the config file when the watcher was created, nil if it could not be loaded
+	settings *config.Config
+}
+
+func NewFileWatcher(logger logr.Logger, configFilePath string) (*FileWatcher, error) {
+	fileWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileWatcher{
+		logger:         logger,
+		configFilePath: filepath.Clean(configFilePath),
+		watcher:        fileWatcher,
+		closer:         make(chan bool),
+	}
+	if cfg, err := f.loadFile(); err == nil {
+		cfg.PromConfig = nil
+		f.settings = cfg
+	}
+	return f, nil
+}
+
+func (f *FileWatcher) loadFile() (*config.Config, error) {
+	cfg := config.CreateDefaultConfig()
+	if err := config.LoadFromFile(f.configFilePath, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (f *FileWatcher) LoadConfig(_ context.Context) (*promconfig.Config, error) {
+	cfg, err := f.loadFile()
+	if err != nil {
+		return nil, err
+	}
+	promConfig := cfg.PromConfig
+	if promConfig == nil {
+		return nil, fmt.Errorf("no prometheus config found in %s", f.configFilePath)
+	}
+	cfg.PromConfig = nil
+	if f.settings != nil && !reflect.DeepEqual(cfg, f.settings) {
+		return nil, ErrSettingsChanged
+	}
+	return promConfig, nil
+}
+
+func (f *FileWatcher) Watch(upstreamEvents chan Event, upstreamErrors chan error) error {
+	// The directory is watched, so that the config file being replaced by a rename or a symlink swap is seen
+	if err := f.watcher.Add(filepath.Dir(f.configFilePath)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-f.closer:
+			return nil
+		case fileEvent, ok := <-f.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !f.isConfigFileEvent(fileEvent) {
+				continue
+			}
+			f.logger.Info("Config file change detected", "file", fileEvent.Name, "event", fileEvent.Op.String())
+			select {
+			case upstreamEvents <- Event{Source: EventSourceConfigMap, Watcher: Watcher(f)}:
+			case <-f.closer:
+				return nil
+			}
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			select {
+			case upstreamErrors <- err:
+			case <-f.closer:
+				return nil
+			}
+		}
+	}
+}
+
+// isConfigFileEvent reports whether an event may have changed the content of the config file: it was written
+// or moved into place, or the ..data symlink of a ConfigMap volume was swapped.
+func (f *FileWatcher) isConfigFileEvent(fileEvent fsnotify.Event) bool {
+	if !fileEvent.Has(fsnotify.Create) && !fileEvent.Has(fsnotify.Write) {
+		return false
+	}
+	name := filepath.Clean(fileEvent.Name)
+	return name == f.configFilePath || filepath.Base(name) == "..data"
+}
+
+func (f *FileWatcher) Close() error {
+	close(f.closer)
+	return f.watcher.Close()
+}
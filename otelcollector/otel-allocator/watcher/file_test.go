@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fileWatcherConfig = `collector_selector:
+  matchlabels:
+    app: collector
+config:
+  scrape_configs:
+  - job_name: %s
+    static_configs:
+    - targets: ["localhost:9090"]
+`
+
+func writeFileWatcherConfig(t *testing.T, path, job string) {
+	t.Helper()
+	// The config reader writes a temporary file and renames it over the config file
+	tmp := filepath.Join(filepath.Dir(path), ".targetallocator.yaml.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(fmt.Sprintf(fileWatcherConfig, job)), 0644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestFileWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targetallocator.yaml")
+	writeFileWatcherConfig(t, path, "first")
+
+	w, err := NewFileWatcher(logr.Discard(), path)
+	require.NoError(t, err)
+	events := make(chan Event)
+	errs := make(chan error)
+	done := make(chan error)
+	go func() { done <- w.Watch(events, errs) }()
+
+	cfg, err := w.LoadConfig(context.Background())
+	require.NoError(t, err)
+	require.Len(t, cfg.ScrapeConfigs, 1)
+	assert.Equal(t, "first", cfg.ScrapeConfigs[0].JobName)
+
+	// Give the watcher time to watch the directory
+	time.Sleep(100 * time.Millisecond)
+	writeFileWatcherConfig(t, path, "second")
+	select {
+	case event := <-events:
+		assert.Equal(t, EventSourceConfigMap, event.Source)
+		cfg, err := event.Watcher.LoadConfig(context.Background())
+		require.NoError(t, err)
+		require.Len(t, cfg.ScrapeConfigs, 1)
+		assert.Equal(t, "second", cfg.ScrapeConfigs[0].JobName)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the config file change")
+	}
+
+	require.NoError(t, w.Close())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the file watcher did not stop")
+	}
+}
+
+func TestFileWatcherLoadConfigWithoutPromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targetallocator.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("allocation_strategy: consistent-hashing\n"), 0644))
+	w, err := NewFileWatcher(logr.Discard(), path)
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.LoadConfig(context.Background())
+	assert.ErrorContains(t, err, "no prometheus config")
+}
+
+func TestFileWatcherSettingsChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targetallocator.yaml")
+	writeFileWatcherConfig(t, path, "first")
+	w, err := NewFileWatcher(logr.Discard(), path)
+	require.NoError(t, err)
+	defer w.Close()
+
+	// A scrape config change is applied without a restart
+	writeFileWatcherConfig(t, path, "second")
+	_, err = w.LoadConfig(context.Background())
+	require.NoError(t, err)
+
+	content := "allocation_strategy: per-node\n" + fmt.Sprintf(fileWatcherConfig, "second")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	_, err = w.LoadConfig(context.Background())
+	assert.ErrorIs(t, err, ErrSettingsChanged)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Severities of the lint warnings
+const (
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// problemLint is the kind of the warnings found by the lint rules
+const problemLint = "lint"
+
+// minScrapeInterval is the scrape interval below which the short-scrape-interval rule warns
+const minScrapeInterval = 10 * time.Second
+
+// ignoreDirective suppresses lint rules for a scrape job when it is in a comment of the job, followed by the
+// rule IDs separated by commas, e.g. "# prom-config-validator:ignore=honor-labels,short-scrape-interval"
+const ignoreDirective = "prom-config-validator:ignore="
+
+// lintScrapeConfig is a scrape config that was decoded without problems, along with where it is in the config
+type lintScrapeConfig struct {
+	file  string
+	node  *yamlv3.Node
+	field string
+	job   string
+	sc    *promconfig.ScrapeConfig
+}
+
+// lintFinding is something a lint rule found in a scrape config. node is where it was found.
+type lintFinding struct {
+	node    *yamlv3.Node
+	field   string
+	message string
+}
+
+// lintRule checks scrape configs for settings that are valid but likely to be a mistake
+type lintRule struct {
+	id          string
+	severity    string
+	description string
+	check       func(l *linter, c lintScrapeConfig) []lintFinding
+}
+
+// lintRules are all the lint rules, run in this order on each scrape config
+var lintRules = []lintRule{
+	{
+		id:          "honor-labels",
+		severity:    severityWarning,
+		description: "honor_labels is set on a job that does not scrape a federation endpoint, so the scraped targets can overwrite the job and instance labels",
+		check:       checkHonorLabels,
+	},
+	{
+		id:          "scrape-timeout-interval",
+		severity:    severityWarning,
+		description: "scrape_timeout is not shorter than scrape_interval, so a slow scrape delays the next one",
+		check:       checkScrapeTimeout,
+	},
+	{
+		id:          "short-scrape-interval",
+		severity:    severityInfo,
+		description: fmt.Sprintf("scrape_interval is shorter than %s, which increases the load on the targets and the ingestion volume", minScrapeInterval),
+		check:       checkShortScrapeInterval,
+	},
+	{
+		id:          "kubernetes-sd-all-namespaces",
+		severity:    severityInfo,
+		description: "kubernetes_sd_configs does not restrict the namespaces, so the objects of every namespace are watched",
+		check:       checkKubernetesSDNamespaces,
+	},
+	{
+		id:          "relabel-matches-all-or-nothing",
+		severity:    severityWarning,
+		description: "a keep or drop relabel config matches everything or nothing, so it keeps or drops everything",
+		check:       checkKeepDropRegex,
+	},
+	{
+		id:          "metric-relabel-drops-name",
+		severity:    severityWarning,
+		description: "a metric relabel config drops the __name__ label, which drops every series",
+		check:       checkMetricRelabelDropsName,
+	},
+	{
+		id:          "duplicate-target",
+		severity:    severityWarning,
+		description: "the same static target is scraped more than once with the same scheme, metrics path and params, which duplicates its series",
+		check:       checkDuplicateTargets,
+	},
+}
+
+// linter runs the lint rules on the scrape configs of a config and collects their warnings
+type linter struct {
+	suppressed map[string]bool
+	warnings   []Problem
+	// targets are the jobs and lines of the static targets seen so far by scrape URL, for the duplicate-target
+	// rule
+	targets map[string]string
+}
+
+// newLinter returns a linter that does not run the rules with the suppressed IDs
+func newLinter(suppressed []string) (*linter, error) {
+	l := &linter{suppressed: map[string]bool{}, targets: map[string]string{}}
+	for _, id := range suppressed {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		if findLintRule(id) == nil {
+			return nil, fmt.Errorf("unknown lint rule %q, the lint rules are: %s", id, strings.Join(lintRuleIDs(), ", "))
+		}
+		l.suppressed[id] = true
+	}
+	return l, nil
+}
+
+func findLintRule(id string) *lintRule {
+	for i := range lintRules {
+		if lintRules[i].id == id {
+			return &lintRules[i]
+		}
+	}
+	return nil
+}
+
+func lintRuleIDs() []string {
+	ids := make([]string, 0, len(lintRules))
+	for _, r := range lintRules {
+		ids = append(ids, r.id)
+	}
+	return ids
+}
+
+// lint runs the lint rules that are not suppressed globally or by a comment in the scrape config
+func (l *linter) lint(c lintScrapeConfig) {
+	ignored := ignoredLintRules(c.node)
+	for _, r := range lintRules {
+		if l.suppressed[r.id] || ignored[r.id] {
+			continue
+		}
+		for _, f := range r.check(l, c) {
+			l.warnings = append(l.warnings, Problem{
+				File:     c.file,
+				Job:      c.job,
+				Field:    f.field,
+				Line:     f.node.Line,
+				Column:   f.node.Column,
+				Kind:     problemLint,
+				Rule:     r.id,
+				Severity: r.severity,
+				Message:  f.message,
+			})
+		}
+	}
+}
+
+// ignoredLintRules returns the lint rules suppressed by the comments of a scrape config
+func ignoredLintRules(node *yamlv3.Node) map[string]bool {
+	ignored := map[string]bool{}
+	var walk func(n *yamlv3.Node)
+	walk = func(n *yamlv3.Node) {
+		for _, comment := range []string{n.HeadComment, n.LineComment, n.FootComment} {
+			for _, line := range strings.Split(comment, "\n") {
+				_, ids, ok := strings.Cut(line, ignoreDirective)
+				if !ok {
+					continue
+				}
+				for _, id := range strings.Split(strings.Fields(ids + " ")[0], ",") {
+					ignored[id] = true
+				}
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(node)
+	return ignored
+}
+
+// findingAt returns a finding at the value of key in the scrape config, or at the scrape config if key is not set
+func (c lintScrapeConfig) findingAt(key, message string) lintFinding {
+	if n := mappingValueNode(c.node, key); n != nil {
+		return lintFinding{node: n, field: joinField(c.field, key), message: message}
+	}
+	return lintFinding{node: c.node, field: c.field, message: message}
+}
+
+func checkHonorLabels(_ *linter, c lintScrapeConfig) []lintFinding {
+	if !c.sc.HonorLabels || strings.HasSuffix(c.sc.MetricsPath, "/federate") {
+		return nil
+	}
+	return []lintFinding{c.findingAt("honor_labels", fmt.Sprintf("honor_labels is true but metrics_path %q is not a federation endpoint", c.sc.MetricsPath))}
+}
+
+func checkScrapeTimeout(_ *linter, c lintScrapeConfig) []lintFinding {
+	// Prometheus lowers the default scrape_timeout to scrape_interval when it is shorter
+	if mappingValueNode(c.node, "scrape_timeout") == nil || c.sc.ScrapeTimeout < c.sc.ScrapeInterval {
+		return nil
+	}
+	return []lintFinding{c.findingAt("scrape_timeout", fmt.Sprintf("scrape_timeout %s is not shorter than scrape_interval %s", c.sc.ScrapeTimeout, c.sc.ScrapeInterval))}
+}
+
+func checkShortScrapeInterval(_ *linter, c lintScrapeConfig) []lintFinding {
+	if time.Duration(c.sc.ScrapeInterval) >= minScrapeInterval {
+		return nil
+	}
+	message := fmt.Sprintf("scrape_interval %s is shorter than %s", c.sc.ScrapeInterval, model.Duration(minScrapeInterval))
+	if mappingValueNode(c.node, "scrape_interval") == nil {
+		message += ", it is inherited from the global scrape_interval"
+	}
+	return []lintFinding{c.findingAt("scrape_interval", message)}
+}
+
+func checkKubernetesSDNamespaces(_ *linter, c lintScrapeConfig) []lintFinding {
+	var findings []lintFinding
+	nodes := mappingValueNode(c.node, "kubernetes_sd_configs")
+	i := 0
+	for _, sd := range c.sc.ServiceDiscoveryConfigs {
+		k8s, ok := sd.(*kubernetes.SDConfig)
+		if !ok {
+			continue
+		}
+		// Nodes are not namespaced
+		if k8s.Role != kubernetes.RoleNode && len(k8s.NamespaceDiscovery.Names) == 0 && !k8s.NamespaceDiscovery.IncludeOwnNamespace {
+			f := lintFinding{node: c.node, field: c.field, message: fmt.Sprintf("kubernetes_sd_configs with role %s watches every namespace, set namespaces.names or namespaces.own_namespace", k8s.Role)}
+			if nodes != nil && i < len(nodes.Content) {
+				f.node, f.field = nodes.Content[i], fmt.Sprintf("%s.kubernetes_sd_configs[%d]", c.field, i)
+			}
+			findings = append(findings, f)
+		}
+		i++
+	}
+	return findings
+}
+
+// regexMatch is what a relabel regex matches regardless of its input
+type regexMatch int
+
+const (
+	matchesSome regexMatch = iota
+	matchesEverything
+	matchesNothing
+)
+
+// relabelRegexMatches returns whether a relabel regex matches every value or no value
+func relabelRegexMatches(re relabel.Regexp) regexMatch {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return matchesSome
+	}
+	parsed = parsed.Simplify()
+	for parsed.Op == syntax.OpCapture && len(parsed.Sub) == 1 {
+		parsed = parsed.Sub[0]
+	}
+	switch {
+	case parsed.Op == syntax.OpNoMatch, parsed.Op == syntax.OpCharClass && len(parsed.Rune) == 0:
+		return matchesNothing
+	case parsed.Op == syntax.OpStar && (parsed.Sub[0].Op == syntax.OpAnyChar || parsed.Sub[0].Op == syntax.OpAnyCharNotNL):
+		return matchesEverything
+	}
+	return matchesSome
+}
+
+func checkKeepDropRegex(_ *linter, c lintScrapeConfig) []lintFinding {
+	var findings []lintFinding
+	check := func(key, what string, configs []*relabel.Config) {
+		nodes := mappingValueNode(c.node, key)
+		for i, rc := range configs {
+			if rc.Action != relabel.Keep && rc.Action != relabel.Drop {
+				continue
+			}
+			match := relabelRegexMatches(rc.Regex)
+			if len(rc.SourceLabels) == 0 {
+				// The value matched is always empty without source labels
+				match = matchesNothing
+				if rc.Regex.MatchString("") {
+					match = matchesEverything
+				}
+			}
+			var result string
+			switch {
+			case match == matchesSome:
+				continue
+			case (rc.Action == relabel.Keep) == (match == matchesEverything):
+				result = "keeps every " + what + ", the relabel config has no effect"
+			default:
+				result = "drops every " + what
+			}
+			f := lintFinding{node: c.node, field: c.field, message: fmt.Sprintf("%s with regex %q %s", rc.Action, rc.Regex.String(), result)}
+			if nodes != nil && i < len(nodes.Content) {
+				f.node, f.field = nodes.Content[i], fmt.Sprintf("%s.%s[%d]", c.field, key, i)
+			}
+			findings = append(findings, f)
+		}
+	}
+	check("relabel_configs", "target", c.sc.RelabelConfigs)
+	check("metric_relabel_configs", "series", c.sc.MetricRelabelConfigs)
+	return findings
+}
+
+func checkMetricRelabelDropsName(_ *linter, c lintScrapeConfig) []lintFinding {
+	var findings []lintFinding
+	nodes := mappingValueNode(c.node, "metric_relabel_configs")
+	for i, rc := range c.sc.MetricRelabelConfigs {
+		var message string
+		switch {
+		case rc.Action == relabel.LabelDrop && rc.Regex.MatchString(model.MetricNameLabel):
+			message = fmt.Sprintf("labeldrop with regex %q drops __name__", rc.Regex.String())
+		case rc.Action == relabel.LabelKeep && !rc.Regex.MatchString(model.MetricNameLabel):
+			message = fmt.Sprintf("labelkeep with regex %q does not keep __name__", rc.Regex.String())
+		case rc.Action == relabel.Replace && rc.TargetLabel == model.MetricNameLabel && rc.Replacement == "":
+			message = "replace sets __name__ to an empty value"
+		default:
+			continue
+		}
+		f := lintFinding{node: c.node, field: c.field, message: message}
+		if nodes != nil && i < len(nodes.Content) {
+			f.node, f.field = nodes.Content[i], fmt.Sprintf("%s.metric_relabel_configs[%d]", c.field, i)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+func checkDuplicateTargets(l *linter, c lintScrapeConfig) []lintFinding {
+	var groups []*targetgroup.Group
+	for _, sd := range c.sc.ServiceDiscoveryConfigs {
+		if static, ok := sd.(discovery.StaticConfig); ok {
+			groups = append(groups, static...)
+		}
+	}
+
+	var findings []lintFinding
+	nodes := mappingValueNode(c.node, "static_configs")
+	for i, group := range groups {
+		var targetNodes *yamlv3.Node
+		if nodes != nil && i < len(nodes.Content) {
+			targetNodes = mappingValueNode(nodes.Content[i], "targets")
+		}
+		for j, target := range group.Targets {
+			scrapeURL := targetURL(c.sc, group.Labels.Merge(target))
+			f := lintFinding{node: c.node, field: c.field}
+			if targetNodes != nil && j < len(targetNodes.Content) {
+				f.node, f.field = targetNodes.Content[j], fmt.Sprintf("%s.static_configs[%d].targets[%d]", c.field, i, j)
+			}
+			if first, ok := l.targets[scrapeURL]; ok {
+				f.message = fmt.Sprintf("target %s is also scraped by %s", scrapeURL, first)
+				findings = append(findings, f)
+				continue
+			}
+			l.targets[scrapeURL] = fmt.Sprintf("job %q at line %d", c.job, f.node.Line)
+			if c.file != "" {
+				l.targets[scrapeURL] += fmt.Sprintf(" of %q", c.file)
+			}
+		}
+	}
+	return findings
+}
+
+// targetURL returns the URL a static target with labels is scraped at before relabeling, from the scheme,
+// metrics path and params of its scrape config or the labels that override them
+func targetURL(sc *promconfig.ScrapeConfig, labels model.LabelSet) string {
+	u := url.URL{Scheme: sc.Scheme, Host: string(labels[model.AddressLabel]), Path: sc.MetricsPath}
+	if scheme, ok := labels[model.SchemeLabel]; ok {
+		u.Scheme = string(scheme)
+	}
+	if path, ok := labels[model.MetricsPathLabel]; ok {
+		u.Path = string(path)
+	}
+	params := url.Values{}
+	for name, values := range sc.Params {
+		params[name] = values
+	}
+	for name, value := range labels {
+		if param, ok := strings.CutPrefix(string(name), model.ParamLabelPrefix); ok {
+			params[param] = []string{string(value)}
+		}
+	}
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// lintRulesHelp returns the lint rules with their severity and description, sorted by ID
+func lintRulesHelp() string {
+	rules := append([]lintRule(nil), lintRules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].id < rules[j].id })
+	var b strings.Builder
+	for _, r := range rules {
+		fmt.Fprintf(&b, "%s (%s): %s\n", r.id, r.severity, r.description)
+	}
+	return b.String()
+}
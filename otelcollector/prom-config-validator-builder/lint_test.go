@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lintConfig(t *testing.T, config string, suppressed ...string) []Problem {
+	l, err := newLinter(suppressed)
+	require.NoError(t, err)
+	require.Empty(t, lintPrometheusConfig([]byte(config), l))
+	return l.warnings
+}
+
+func TestLintRules(t *testing.T) {
+	config := `global:
+  scrape_interval: 5s
+scrape_configs:
+- job_name: app
+  honor_labels: true
+  scrape_interval: 30s
+  scrape_timeout: 30s
+  kubernetes_sd_configs:
+  - role: pod
+  - role: service
+    namespaces:
+      names: [default]
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_pod_label_app]
+    regex: (.*)
+    action: keep
+  - action: drop
+    regex: .+
+  metric_relabel_configs:
+  - action: labeldrop
+    regex: __.*
+  - source_labels: [__name__]
+    regex: '[^\s\S]'
+    action: keep
+- job_name: federate
+  honor_labels: true
+  metrics_path: /federate
+  static_configs:
+  - targets: [localhost:9090, localhost:9091]
+- job_name: fast
+  static_configs:
+  - targets: [localhost:9091]
+`
+	warnings := lintConfig(t, config)
+	got := map[string][]int{}
+	for _, w := range warnings {
+		assert.Equal(t, problemLint, w.Kind)
+		got[w.Rule] = append(got[w.Rule], w.Line)
+	}
+	assert.Equal(t, map[string][]int{
+		"honor-labels":                   {5},
+		"scrape-timeout-interval":        {7},
+		"kubernetes-sd-all-namespaces":   {9},
+		"relabel-matches-all-or-nothing": {14, 17, 22},
+		"metric-relabel-drops-name":      {20},
+		"short-scrape-interval":          {25, 30},
+	}, got)
+
+	for _, w := range warnings {
+		switch w.Rule {
+		case "relabel-matches-all-or-nothing":
+			assert.Contains(t, []string{
+				`keep with regex "(.*)" keeps every target, the relabel config has no effect`,
+				`drop with regex ".+" keeps every target, the relabel config has no effect`,
+				`keep with regex "[^\\s\\S]" drops every series`,
+			}, w.Message)
+		case "short-scrape-interval":
+			assert.Equal(t, severityInfo, w.Severity)
+			assert.Contains(t, w.Message, "inherited from the global scrape_interval")
+		}
+	}
+}
+
+func TestLintDuplicateTargets(t *testing.T) {
+	config := `scrape_configs:
+- job_name: app
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: federate
+  metrics_path: /federate
+  params:
+    match: [app]
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: https
+  scheme: https
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: labels
+  static_configs:
+  - targets: [localhost:9090, localhost:9091]
+    labels:
+      __scheme__: https
+- job_name: param
+  static_configs:
+  - targets: [localhost:9090]
+    labels:
+      __metrics_path__: /federate
+      __param_match: app
+- job_name: other-param
+  metrics_path: /federate
+  params:
+    match: [other]
+  static_configs:
+  - targets: [localhost:9090]
+`
+	warnings := lintConfig(t, config)
+	require.Len(t, warnings, 2, "%v", warnings)
+	assert.Equal(t, "duplicate-target", warnings[0].Rule)
+	assert.Equal(t, "scrape_configs[3].static_configs[0].targets[0]", warnings[0].Field)
+	assert.Equal(t, `target https://localhost:9090/metrics is also scraped by job "https" at line 14`, warnings[0].Message)
+	assert.Equal(t, "scrape_configs[4].static_configs[0].targets[0]", warnings[1].Field)
+	assert.Equal(t, `target http://localhost:9090/federate?match=app is also scraped by job "federate" at line 10`, warnings[1].Message)
+}
+
+func TestLintSuppression(t *testing.T) {
+	config := `scrape_configs:
+# prom-config-validator:ignore=short-scrape-interval,honor-labels
+- job_name: app
+  honor_labels: true
+  scrape_interval: 1s
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: other
+  scrape_interval: 1s # prom-config-validator:ignore=short-scrape-interval
+  honor_labels: true
+  static_configs:
+  - targets: [localhost:9091]
+`
+	warnings := lintConfig(t, config)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "other", warnings[0].Job)
+	assert.Equal(t, "honor-labels", warnings[0].Rule)
+
+	assert.Empty(t, lintConfig(t, config, "honor-labels"))
+
+	_, err := newLinter([]string{"no-such-rule"})
+	assert.ErrorContains(t, err, `unknown lint rule "no-such-rule"`)
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/prometheus-collector/shared/configescape"
+	"github.com/prometheus-collector/shared/settings"
 	"go.opentelemetry.io/collector/confmap"
 	"go.opentelemetry.io/collector/confmap/provider/envprovider"
 	"go.opentelemetry.io/collector/confmap/provider/fileprovider"
@@ -48,18 +52,39 @@ type OtelConfig struct {
 
 var RESET = "\033[0m"
 var RED = "\033[31m"
+var YELLOW = "\033[33m"
+
+var (
+	// resultsPath is the file the results are recorded in for the configmap parser. It is empty when the
+	// validator is run by a user to validate a config outside of the agent.
+	resultsPath string
+	results     settings.ValidatorResults
+	// debugMode adds the prometheus exporter of the debug mode to the generated collector config
+	debugMode bool
+)
+
+// saveResults records the results for the configmap parser
+func saveResults() {
+	if resultsPath == "" {
+		return
+	}
+	if err := results.Save(resultsPath); err != nil {
+		log.Printf("prom-config-validator::Unable to save the results to %s: %v\n", resultsPath, err)
+	}
+}
 
 func logFatalError(message string) {
-	// Do not set env var if customer is running outside of agent to just validate config
-	if os.Getenv("CONFIG_VALIDATOR_RUNNING_IN_AGENT") == "true" {
-		setFatalErrorMessageAsEnvVar(message)
+	// Do not record the error if customer is running outside of agent to just validate config
+	if resultsPath != "" {
+		recordFatalError(message)
+		saveResults()
 	}
 
 	// Always log the full message
 	log.Fatalf("%s%s%s", RED, message, RESET)
 }
 
-func setFatalErrorMessageAsEnvVar(message string) {
+func recordFatalError(message string) {
 	// Truncate to use as a dimension in the invalid config metric for prometheus-collector-health job
 	truncatedMessage := message
 	if len(message) > 1023 {
@@ -70,20 +95,19 @@ func setFatalErrorMessageAsEnvVar(message string) {
 	re := regexp.MustCompile("\\n")
 	truncatedMessage = re.ReplaceAllString(truncatedMessage, "")
 
-	// Write env var to a file so it can be used by other processes
-	file, err := os.OpenFile("/opt/microsoft/prom_config_validator_env_var", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Println("prom-config-validator::Unable to open file - prom_config_validator_env_var")
-	}
-	setEnvVarString := fmt.Sprintf("export INVALID_CONFIG_FATAL_ERROR=\"%s\"\n", truncatedMessage)
-	if os.Getenv("OS_TYPE") != "linux" {
-		setEnvVarString = fmt.Sprintf("INVALID_CONFIG_FATAL_ERROR=%s\n", truncatedMessage)
-	}
-	_, err = file.Write([]byte(setEnvVarString))
+	// Record the error in the results so it can be used by other processes
+	results.InvalidConfigFatalError = truncatedMessage
+}
+
+// recordRejectedJobs records the error of each scrape job left out in partial acceptance mode, for the
+// invalid config metric of the prometheus-collector-health job
+func recordRejectedJobs(rejected map[string]string) {
+	encoded, err := json.Marshal(rejected)
 	if err != nil {
-		log.Println("prom-config-validator::Unable to write to the file prom_config_validator_env_var")
+		log.Printf("prom-config-validator::Unable to encode the rejected scrape jobs: %v\n", err)
+		return
 	}
-	file.Close()
+	results.RejectedScrapeJobs = string(encoded)
 }
 
 func generateOtelConfig(promFilePath string, outputFilePath string, otelConfigTemplatePath string) error {
@@ -109,94 +133,12 @@ func generateOtelConfig(promFilePath string, outputFilePath string, otelConfigTe
 		return err
 	}
 
-	scrapeConfigs := prometheusConfig["scrape_configs"]
-	if scrapeConfigs != nil {
-		var sc = scrapeConfigs.([]interface{})
-		for _, scrapeConfig := range sc {
-			scrapeConfig := scrapeConfig.(map[interface{}]interface{})
-			if scrapeConfig["relabel_configs"] != nil {
-				relabelConfigs := scrapeConfig["relabel_configs"].([]interface{})
-				for _, relabelConfig := range relabelConfigs {
-					relabelConfig := relabelConfig.(map[interface{}]interface{})
-					//replace $ with $$ for regex field
-					if relabelConfig["regex"] != nil {
-						// Adding this check here since regex can be boolean and the conversion will fail
-						if _, isString := relabelConfig["regex"].(string); isString {
-							regexString := relabelConfig["regex"].(string)
-							modifiedRegexString := strings.ReplaceAll(regexString, "$$", "$")
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$", "$$")
-							// Doing the below since we dont want to substitute $ with $$ for env variables NODE_NAME and NODE_IP.
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$$NODE_NAME", "${env:NODE_NAME}")
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$$NODE_IP", "${env:NODE_IP}")
-							relabelConfig["regex"] = modifiedRegexString
-						}
-					}
-					//replace $ with $$ for replacement field
-					if relabelConfig["replacement"] != nil {
-						replacement := relabelConfig["replacement"].(string)
-						modifiedReplacementString := strings.ReplaceAll(replacement, "$$", "$")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$", "$$")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$$NODE_NAME", "${env:NODE_NAME}")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$$NODE_IP", "${env:NODE_IP}")
-						relabelConfig["replacement"] = modifiedReplacementString
-					}
-				}
-			}
-
-			if scrapeConfig["metric_relabel_configs"] != nil {
-				metricRelabelConfigs := scrapeConfig["metric_relabel_configs"].([]interface{})
-				for _, metricRelabelConfig := range metricRelabelConfigs {
-					metricRelabelConfig := metricRelabelConfig.(map[interface{}]interface{})
-					//replace $ with $$ for regex field
-					if metricRelabelConfig["regex"] != nil {
-						// Adding this check here since regex can be boolean and the conversion will fail
-						if _, isString := metricRelabelConfig["regex"].(string); isString {
-							regexString := metricRelabelConfig["regex"].(string)
-							modifiedRegexString := strings.ReplaceAll(regexString, "$$", "$")
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$", "$$")
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$$NODE_NAME", "${env:NODE_NAME}")
-							modifiedRegexString = strings.ReplaceAll(modifiedRegexString, "$$NODE_IP", "${env:NODE_IP}")
-							metricRelabelConfig["regex"] = modifiedRegexString
-						}
-					}
-
-					//replace $ with $$ for replacement field
-					if metricRelabelConfig["replacement"] != nil {
-						replacement := metricRelabelConfig["replacement"].(string)
-						modifiedReplacementString := strings.ReplaceAll(replacement, "$$", "$")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$", "$$")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$$NODE_NAME", "${env:NODE_NAME}")
-						modifiedReplacementString = strings.ReplaceAll(modifiedReplacementString, "$$NODE_IP", "${env:NODE_IP}")
-						metricRelabelConfig["replacement"] = modifiedReplacementString
-					}
-				}
-			}
-
-			if scrapeConfig["static_configs"] != nil {
-				staticConfigs := scrapeConfig["static_configs"].([]interface{})
-				for _, staticConfig := range staticConfigs {
-					staticConfig := staticConfig.(map[interface{}]interface{})
-					if staticConfig["targets"] != nil {
-						targets := staticConfig["targets"].([]interface{})
-						for i, target := range targets {
-							if _, isString := target.(string); isString {
-								targetValue := target.(string)
-								modifiedtargetValue := strings.ReplaceAll(targetValue, "$$NODE_NAME", "$NODE_NAME")
-								modifiedtargetValue = strings.ReplaceAll(modifiedtargetValue, "$$NODE_IP", "$NODE_IP")
-								modifiedtargetValue = strings.ReplaceAll(modifiedtargetValue, "$NODE_NAME", "${env:NODE_NAME}")
-								modifiedtargetValue = strings.ReplaceAll(modifiedtargetValue, "$NODE_IP", "${env:NODE_IP}")
-								staticConfig["targets"].([]interface{})[i] = modifiedtargetValue
-							}
-						}
-					}
-				}
-			}
-		}
-	}
+	// The collector expands env vars in its config, so the literal $ of every string is escaped and the
+	// allow-listed env var placeholders such as $NODE_NAME are converted to the collector syntax
+	configescape.FromEnv().EscapeConfig(prometheusConfig)
 
-	// Need this here even though it is present in the receiver's config validate method since we only do the $ manipulation for regex and replacement fields
-	// in scrape configs sections and the load method which is called before the validate method fails to unmarshal due to single $.
-	// Either approach will fail but the receiver's config load wont return the right error message
+	// Need this here even though it is present in the receiver's config validate method since the load method, which is called before the validate method,
+	// may fail to unmarshal these sections first. Either approach will fail but the receiver's config load wont return the right error message
 	unsupportedFeatures := make([]string, 0, 4)
 
 	if prometheusConfig["remote_write"] != nil {
@@ -221,27 +163,13 @@ func generateOtelConfig(promFilePath string, outputFilePath string, otelConfigTe
 		globalSettings := globalSettingsFromMergedOtelConfig.(map[interface{}]interface{})
 		scrapeInterval := globalSettings["scrape_interval"]
 		if (len(globalSettings) > 1) || (len(globalSettings) == 1 && scrapeInterval != "15s") {
-			setEnvVarString := fmt.Sprintf("AZMON_GLOBAL_SETTINGS_CONFIGURED=true\n")
-			file, err := os.OpenFile("/opt/microsoft/prom_config_validator_env_var", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				log.Println("prom-config-validator::Unable to open file - prom_config_validator_env_var")
-			}
-			_, err = file.Write([]byte(setEnvVarString))
-			if err != nil {
-				log.Println("prom-config-validator::Unable to write to the file prom_config_validator_env_var")
-			}
-			file.Close()
-			if err != nil {
-				log.Println("prom-config-validator::Unable to close file prom_config_validator_env_var", err)
-			} else {
-				log.Printf("prom-config-validator::Successfully set env variables for global config in file prom_config_validator_env_var\n")
-			}
+			results.GlobalSettingsConfigured = true
 		}
 	}
 
 	otelConfig.Receivers.Prometheus.Config = prometheusConfig
 
-	if os.Getenv("DEBUG_MODE_ENABLED") == "true" {
+	if debugMode {
 		otelConfig.Service.Pipelines.Metrics.Exporters = []interface{}{"otlp", "prometheus"}
 	}
 
@@ -270,12 +198,86 @@ func (s *stringArrayValue) String() string {
 	return "[" + strings.Join(s.values, ", ") + "]"
 }
 
+// loadOtelConfig loads and validates the collector config at path the way the otelcollector does
+func loadOtelConfig(path string) error {
+	flags := new(flag.FlagSet)
+	//parserProvider.Flags(flags)
+	configFlagEx := new(stringArrayValue)
+	flags.Var(configFlagEx, "config", "Locations to the config file(s), note that only a"+
+		" single location can be set per flag entry e.g. `-config=file:/path/to/first --config=file:path/to/second`.")
+	configFlag := fmt.Sprintf("--config=%s", path)
+
+	err := flags.Parse([]string{
+		configFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("prom-config-validator::Error parsing flags - %v", err)
+	}
+
+	factories, err := components()
+	if err != nil {
+		return fmt.Errorf("prom-config-validator::Failed to build components: %v", err)
+	}
+
+	fmp := fileprovider.NewFactory()
+	envp := envprovider.NewFactory()
+	providers := []confmap.ProviderFactory{fmp, envp}
+	cp, err := otelcol.NewConfigProvider(
+		otelcol.ConfigProviderSettings{
+			ResolverSettings: confmap.ResolverSettings{
+				URIs:              []string{fmt.Sprintf("file:%s", path)},
+				ProviderFactories: providers,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("prom-config-validator::Cannot load configuration's parser: %w", err)
+	}
+
+	fmt.Printf("prom-config-validator::Loading configuration...\n")
+	cfg, err := cp.Get(context.Background(), factories)
+	if err != nil {
+		return fmt.Errorf("prom-config-validator::Cannot load configuration: %v", err)
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("prom-config-validator::Invalid configuration: %w", err)
+	}
+	return nil
+}
+
 func main() {
 	log.SetFlags(0)
+	if len(os.Args) > 1 && os.Args[1] == relabelCommand {
+		if err := runRelabel(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("%sprom-config-validator::%v%s", RED, err, RESET)
+		}
+		os.Exit(0)
+	}
+	if len(os.Args) > 1 && os.Args[1] == probeCommand {
+		if err := runProbe(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("%sprom-config-validator::%v%s", RED, err, RESET)
+		}
+		os.Exit(0)
+	}
 	configFilePtr := flag.String("config", "", "Config file to validate")
 	outFilePtr := flag.String("output", "", "Output file path for writing collector config")
 	otelTemplatePathPtr := flag.String("otelTemplate", "", "OTel Collector config template file path")
+	reportFilePtr := flag.String("report", "", "Output file path for writing the problems found as JSON")
+	suppressPtr := flag.String("suppress", "", "Comma separated IDs of the lint rules to not run")
+	lintRulesPtr := flag.Bool("lint-rules", false, "Print the lint rules and exit")
+	lintPtr := flag.Bool("lint", true, "Lint the scrape configs")
+	lintConfigPtr := flag.String("lint-config", "", "Config file to lint instead of the validated one, e.g. the custom config before it is merged with the default scrape configs, which keeps its comments")
+	partialPtr := flag.Bool("partial", false, "Leave out the scrape configs with problems instead of failing, as long as some are valid")
+	configDirPtr := flag.String("config-dir", "", "Directory the relative scrape_config_files globs are resolved against, the directory of the config file by default")
+	fileRootPtr := flag.String("file-root", "", "Directory to look up the files referenced by the scrape configs in, to check all of them outside of the agent")
+	flag.StringVar(&resultsPath, "results", "", "Output file path for recording the results for the configmap parser")
+	flag.BoolVar(&debugMode, "debug-mode", false, "Add the prometheus exporter of the debug mode to the collector config")
 	flag.Parse()
+	if *lintRulesPtr {
+		fmt.Print(lintRulesHelp())
+		os.Exit(0)
+	}
 	promFilePath := *configFilePtr
 	otelConfigTemplatePath := *otelTemplatePathPtr
 	if otelConfigTemplatePath == "" {
@@ -290,65 +292,83 @@ func main() {
 			outputFilePath = "merged-otel-config.yaml"
 		}
 
-		err := generateOtelConfig(promFilePath, outputFilePath, otelConfigTemplatePath)
+		// Find all the problems of the prometheus config first, the collector config is only generated and
+		// loaded once there are none since the collector stops at the first one
+		linter, err := newLinter(strings.Split(*suppressPtr, ","))
 		if err != nil {
-			logFatalError(fmt.Sprintf("Generating otel config failed: %v\n", err))
+			logFatalError(fmt.Sprintf("prom-config-validator::%v\n", err))
 			os.Exit(1)
 		}
-
-		flags := new(flag.FlagSet)
-		//parserProvider.Flags(flags)
-		configFlagEx := new(stringArrayValue)
-		flags.Var(configFlagEx, "config", "Locations to the config file(s), note that only a"+
-			" single location can be set per flag entry e.g. `-config=file:/path/to/first --config=file:path/to/second`.")
-		configFlag := fmt.Sprintf("--config=%s", outputFilePath)
-
-		err = flags.Parse([]string{
-			configFlag,
-		})
-		if err != nil {
-			logFatalError(fmt.Sprintf("prom-config-validator::Error parsing flags - %v\n", err))
-			os.Exit(1)
+		files := fileOptions{configDir: *configDirPtr, root: *fileRootPtr}
+		if files.configDir == "" {
+			files.configDir = filepath.Dir(promFilePath)
 		}
-
-		factories, err := components()
-		if err != nil {
-			logFatalError(fmt.Sprintf("prom-config-validator::Failed to build components: %v\n", err))
-			os.Exit(1)
+		var report Report
+		switch {
+		case !*lintPtr:
+			report = Report{Config: promFilePath, Problems: validatePrometheusConfigFile(promFilePath, files, nil)}
+		case *lintConfigPtr != "":
+			report = Report{Config: promFilePath, Problems: validatePrometheusConfigFile(promFilePath, files, nil)}
+			// The problems of the linted config are found again in the validated config it is merged into
+			validatePrometheusConfigFile(*lintConfigPtr, files, linter)
+		default:
+			report = Report{Config: promFilePath, Problems: validatePrometheusConfigFile(promFilePath, files, linter)}
 		}
-
-		fmp := fileprovider.NewFactory()
-		envp := envprovider.NewFactory()
-		providers := []confmap.ProviderFactory{fmp, envp}
-		cp, err := otelcol.NewConfigProvider(
-			otelcol.ConfigProviderSettings{
-				ResolverSettings: confmap.ResolverSettings{
-					URIs:              []string{fmt.Sprintf("file:%s", outputFilePath)},
-					ProviderFactories: providers,
-				},
-			},
-		)
-		if err != nil {
-			logFatalError(fmt.Errorf("prom-config-validator::Cannot load configuration's parser: %w\n", err).Error())
-			os.Exit(1)
+		report.Warnings = linter.warnings
+		// The scrape config files are inlined in the collector config, which has a single prometheus config
+		acceptedFilePath := promFilePath
+		if len(report.Problems) == 0 || *partialPtr {
+			path, rejected, err := writeAcceptedScrapeConfigs(promFilePath, files, report.Problems)
+			switch {
+			case err != nil && len(report.Problems) == 0:
+				report.Problems = append(report.Problems, Problem{Kind: problemInvalidConfig, Message: fmt.Sprintf("Inlining the scrape config files failed: %v", err)})
+			case err != nil:
+				log.Printf("prom-config-validator::Cannot leave out the scrape configs with problems: %v\n", err)
+			case len(report.Problems) == 0:
+				acceptedFilePath = path
+			default:
+				acceptedFilePath = path
+				report.RejectedJobs = rejected
+			}
 		}
-
-		fmt.Printf("prom-config-validator::Loading configuration...\n")
-		cfg, err := cp.Get(context.Background(), factories)
-		if err != nil {
-			logFatalError(fmt.Sprintf("prom-config-validator::Cannot load configuration: %v", err))
-			os.Exit(1)
+		if len(report.Problems) == 0 || report.RejectedJobs != nil {
+			if err := generateOtelConfig(acceptedFilePath, outputFilePath, otelConfigTemplatePath); err != nil {
+				report.Problems = append(report.Problems, Problem{Kind: problemInvalidConfig, Message: fmt.Sprintf("Generating otel config failed: %v", err)})
+				report.RejectedJobs = nil
+			} else if err := loadOtelConfig(outputFilePath); err != nil {
+				report.Problems = append(report.Problems, Problem{Kind: problemInvalidConfig, Message: err.Error()})
+				report.RejectedJobs = nil
+			}
 		}
+		if acceptedFilePath != promFilePath {
+			os.Remove(acceptedFilePath)
+		}
+		report.Valid = len(report.Problems) == 0
 
-		err = cfg.Validate()
-		if err != nil {
-			logFatalError(fmt.Errorf("prom-config-validator::Invalid configuration: %w\n", err).Error())
-			os.Exit(1)
+		for _, w := range report.Warnings {
+			log.Printf("%sprom-config-validator::%s%s\n", YELLOW, w, RESET)
+		}
+		if *reportFilePtr != "" {
+			if err := writeReport(*reportFilePtr, report); err != nil {
+				log.Printf("prom-config-validator::Unable to write the report to %s: %v\n", *reportFilePtr, err)
+			}
+		}
+		if !report.Valid {
+			for _, p := range report.Problems {
+				log.Printf("%sprom-config-validator::%s%s\n", RED, p, RESET)
+			}
+			if report.RejectedJobs == nil {
+				logFatalError(report.summary())
+				os.Exit(1)
+			}
+			recordRejectedJobs(report.RejectedJobs)
+			fmt.Printf("prom-config-validator::Left out %d scrape job(s) with problems, the other scrape jobs are used\n", len(report.RejectedJobs))
 		}
 	} else {
 		logFatalError("prom-config-validator::Please provide a config file using the --config flag to validate\n")
 		os.Exit(1)
 	}
+	saveResults()
 	fmt.Printf("prom-config-validator::Successfully loaded and validated prometheus config\n")
 	os.Exit(0)
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// maxRejectedJobErrorLength is the length the error of a rejected job is truncated to, to use it as a
+// dimension of the invalid config metric like the fatal error
+const maxRejectedJobErrorLength = 1023
+
+// scrapeConfigIndex returns the index of the scrape config a problem was found in
+func scrapeConfigIndex(p Problem) (int, bool) {
+	rest, ok := strings.CutPrefix(p.Field, "scrape_configs[")
+	if !ok {
+		return 0, false
+	}
+	index, _, ok := strings.Cut(rest, "]")
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(index)
+	return i, err == nil
+}
+
+// rejectScrapeConfigs inlines the scrape config files of the prometheus config and removes the scrape configs
+// that have problems from it, for the partial acceptance mode. It returns the remaining config and the error of
+// each rejected job, keyed by job name, or by its scrape_configs index when it has no job name or its job name
+// is kept by another scrape config. It fails if a problem is not about a single scrape config, or if no
+// scrape config is left.
+func rejectScrapeConfigs(content []byte, files fileOptions, problems []Problem) ([]byte, map[string]string, error) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &root); err != nil {
+		return nil, nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, nil, fmt.Errorf("no scrape configs found")
+	}
+	offsets, err := inlineScrapeConfigFiles(root.Content[0], files.configDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rejectedItems := map[int]Problem{}
+	for _, p := range problems {
+		i, ok := scrapeConfigIndex(p)
+		if ok && p.File != "" {
+			// The scrape configs of the file are after the ones before it once inlined
+			var offset int
+			offset, ok = offsets[p.File]
+			i += offset
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("the problem is not in a scrape config: %s", p)
+		}
+		if _, ok := rejectedItems[i]; !ok {
+			rejectedItems[i] = p
+		}
+	}
+	if len(rejectedItems) == 0 {
+		out, err := yamlv3.Marshal(&root)
+		return out, map[string]string{}, err
+	}
+
+	scrapeConfigs := mappingValueNode(root.Content[0], "scrape_configs")
+	if scrapeConfigs == nil || scrapeConfigs.Kind != yamlv3.SequenceNode {
+		return nil, nil, fmt.Errorf("no scrape configs found")
+	}
+
+	accepted := make([]*yamlv3.Node, 0, len(scrapeConfigs.Content))
+	scrapedJobs := map[string]bool{}
+	for i, item := range scrapeConfigs.Content {
+		if _, ok := rejectedItems[i]; !ok {
+			accepted = append(accepted, item)
+			scrapedJobs[mappingValue(item, "job_name")] = true
+		}
+	}
+
+	rejected := map[string]string{}
+	for i := range scrapeConfigs.Content {
+		p, ok := rejectedItems[i]
+		if !ok {
+			continue
+		}
+		job := p.Job
+		message := p.String()
+		if job == "" || scrapedJobs[job] || rejected[job] != "" {
+			// The job name belongs to another scrape config, so only this one is reported as dropped
+			job = fmt.Sprintf("scrape_configs[%d]", i)
+			if p.Kind == problemDuplicateJob && scrapedJobs[p.Job] {
+				message += ", only the first one is scraped"
+			}
+		} else {
+			// The job is the key already
+			p.Job = ""
+			message = p.String()
+		}
+		if len(message) > maxRejectedJobErrorLength {
+			message = message[:maxRejectedJobErrorLength]
+		}
+		rejected[job] = message
+	}
+	if len(accepted) == 0 {
+		return nil, nil, fmt.Errorf("all %d scrape configs have problems", len(scrapeConfigs.Content))
+	}
+	scrapeConfigs.Content = accepted
+
+	out, err := yamlv3.Marshal(&root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, rejected, nil
+}
+
+// writeAcceptedScrapeConfigs writes the prometheus config at path with its scrape config files inlined and
+// without its scrape configs that have problems to a temporary file, and returns the file path and the rejected
+// jobs. The path is returned as is if there is nothing to change.
+func writeAcceptedScrapeConfigs(path string, files fileOptions, problems []Problem) (string, map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(problems) == 0 && !hasScrapeConfigFiles(content) {
+		return path, map[string]string{}, nil
+	}
+	accepted, rejected, err := rejectScrapeConfigs(content, files, problems)
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := os.CreateTemp("", "prom-config-accepted-*.yml")
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	if _, err := file.Write(accepted); err != nil {
+		os.Remove(file.Name())
+		return "", nil, err
+	}
+	return file.Name(), rejected, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRejectScrapeConfigs(t *testing.T) {
+	config := []byte(`global:
+  scrape_interval: 30s
+scrape_configs:
+- job_name: app
+  relabel_configs:
+  - source_labels: [a]
+    regex: (a
+    target_label: x
+- job_name: good
+  static_configs:
+  - targets: [localhost:9090]
+- scrape_intervall: 30s
+- job_name: good
+  static_configs:
+  - targets: [localhost:9091]
+`)
+	problems := validatePrometheusConfig(config)
+	require.Len(t, problems, 3, "%v", problems)
+
+	accepted, rejected, err := rejectScrapeConfigs(config, fileOptions{}, problems)
+	require.NoError(t, err)
+	assert.Len(t, rejected, 3)
+	assert.Contains(t, rejected["app"], "invalid_regex")
+	assert.Contains(t, rejected["scrape_configs[2]"], "field scrape_intervall not found")
+	// Only the dropped duplicate is reported, the first scrape config of the job is scraped
+	assert.NotContains(t, rejected, "good")
+	assert.Contains(t, rejected["scrape_configs[3]"], "duplicate_job")
+	assert.Contains(t, rejected["scrape_configs[3]"], "only the first one is scraped")
+	assert.NotContains(t, rejected["app"], `job "app"`)
+
+	var out struct {
+		Global        map[string]interface{}   `yaml:"global"`
+		ScrapeConfigs []map[string]interface{} `yaml:"scrape_configs"`
+	}
+	require.NoError(t, yaml.Unmarshal(accepted, &out))
+	assert.Equal(t, "30s", out.Global["scrape_interval"])
+	require.Len(t, out.ScrapeConfigs, 1)
+	assert.Equal(t, "good", out.ScrapeConfigs[0]["job_name"])
+	assert.Empty(t, validatePrometheusConfig(accepted))
+}
+
+func TestRejectScrapeConfigsFailures(t *testing.T) {
+	config := []byte("global:\n  scrape_interval: 1x\nscrape_configs:\n- job_name: a\n  static_configs:\n  - targets: [x:1]\n")
+	_, _, err := rejectScrapeConfigs(config, fileOptions{}, validatePrometheusConfig(config))
+	assert.ErrorContains(t, err, "not in a scrape config")
+
+	config = []byte("scrape_configs:\n- job_name: a\n  scrape_intervall: 1s\n")
+	_, _, err = rejectScrapeConfigs(config, fileOptions{}, validatePrometheusConfig(config))
+	assert.ErrorContains(t, err, "all 1 scrape configs have problems")
+}
+
+func TestRejectScrapeConfigsDuplicateOfRejectedJob(t *testing.T) {
+	config := []byte(`scrape_configs:
+- job_name: app
+  scrape_intervall: 30s
+- job_name: app
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: good
+  static_configs:
+  - targets: [localhost:9091]
+`)
+	_, rejected, err := rejectScrapeConfigs(config, fileOptions{}, validatePrometheusConfig(config))
+	require.NoError(t, err)
+	assert.Len(t, rejected, 2)
+	assert.Contains(t, rejected["app"], "scrape_intervall not found")
+	assert.Contains(t, rejected["scrape_configs[1]"], "duplicate_job")
+	assert.NotContains(t, rejected["scrape_configs[1]"], "only the first one is scraped")
+}
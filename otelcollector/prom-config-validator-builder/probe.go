@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	commonconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/scrape"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// probeCommand is the subcommand that scrapes the targets of a config once to check that they can be scraped
+const probeCommand = "probe"
+
+// Where the targets of a probe result come from
+const (
+	targetSourceStatic     = "static"
+	targetSourceFile       = "file"
+	targetSourceDiscovered = "discovered"
+)
+
+// expositionContentTypes are the media types the collector parses the scrape responses of
+var expositionContentTypes = []string{"text/plain", "application/openmetrics-text", "application/vnd.google.protobuf"}
+
+// ProbeResult is the result of the scrape of a target, or of the resolution of the targets of a job when Target is
+// empty
+type ProbeResult struct {
+	Job    string `json:"job"`
+	Target string `json:"target,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Dropped is set for the targets dropped by the relabel configs, which are not scraped
+	Dropped     bool   `json:"dropped,omitempty"`
+	Reachable   bool   `json:"reachable"`
+	Status      string `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Samples     int    `json:"samples"`
+	// SeriesKept is the number of series left after the metric relabel configs
+	SeriesKept int    `json:"series_kept"`
+	Error      string `json:"error,omitempty"`
+}
+
+// failed returns whether the target cannot be scraped or its response cannot be parsed
+func (r ProbeResult) failed() bool {
+	return !r.Dropped && r.Error != ""
+}
+
+// probeOptions are the options of the probe subcommand
+type probeOptions struct {
+	// kubeconfig is the kubeconfig file of the kubernetes_sd_configs without api_server or kubeconfig_file, they
+	// use the in-cluster config if it is empty
+	kubeconfig string
+	// discover is whether the kubernetes_sd_configs are run, it requires access to the cluster
+	discover          bool
+	sample            int
+	discoveryTimeout  time.Duration
+	getenv            func(string) string
+	newDiscoveryGroup func(ctx context.Context, job string, configs discovery.Configs, timeout time.Duration) ([]*targetgroup.Group, error)
+}
+
+// runProbe runs the probe subcommand. It scrapes the static and file-based targets of the jobs once, and a sample
+// of the targets discovered by the kubernetes_sd_configs when the cluster is reachable, and prints the results.
+func runProbe(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(probeCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+	configFile := flags.String("config", "", "Prometheus config file with the scrape configs to probe")
+	jobName := flags.String("job", "", "Name of the scrape job to probe, all of them by default")
+	kubeconfig := flags.String("kubeconfig", "", "Kubeconfig file to discover the targets of the kubernetes_sd_configs with, the in-cluster config is used when running in a cluster")
+	sample := flags.Int("sample", 5, "Number of discovered targets to scrape per job")
+	discoveryTimeout := flags.Duration("discovery-timeout", 30*time.Second, "Time to wait for the targets of the kubernetes_sd_configs")
+	jsonOutput := flags.Bool("json", false, "Print the results as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *configFile == "" {
+		return fmt.Errorf("--config is required")
+	}
+
+	options := probeOptions{
+		kubeconfig:        *kubeconfig,
+		discover:          *kubeconfig != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != "",
+		sample:            *sample,
+		discoveryTimeout:  *discoveryTimeout,
+		getenv:            os.Getenv,
+		newDiscoveryGroup: discoverTargetGroups,
+	}
+	results, err := probeConfig(context.Background(), *configFile, *jobName, options)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printProbeResults(out, results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the %d probes failed", failed, len(results))
+	}
+	return nil
+}
+
+// probeConfig scrapes the targets of the jobs of a prometheus config, or of the job if it is not empty
+func probeConfig(ctx context.Context, path, job string, options probeOptions) ([]ProbeResult, error) {
+	config, err := readRawPrometheusConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []ProbeResult
+	found := false
+	for i, item := range config.ScrapeConfigs {
+		name, _ := item["job_name"].(string)
+		if job != "" && name != job {
+			continue
+		}
+		found = true
+		sc, err := config.scrapeConfig(item, options.getenv)
+		if err != nil {
+			results = append(results, ProbeResult{Job: name, Error: err.Error()})
+			continue
+		}
+		// The files of the scrape config are relative to the file it is in like in Prometheus
+		sc.SetDirectory(config.dirs[i])
+		results = append(results, probeJob(ctx, sc, options)...)
+	}
+	if job != "" && !found {
+		return nil, fmt.Errorf("no scrape config with job name %q found in %s", job, path)
+	}
+	return results, nil
+}
+
+// probeJob resolves the targets of a job and scrapes the ones kept by its relabel configs
+func probeJob(ctx context.Context, sc *promconfig.ScrapeConfig, options probeOptions) []ProbeResult {
+	var results []ProbeResult
+	client, err := commonconfig.NewClientFromConfig(sc.HTTPClientConfig, sc.JobName)
+	if err != nil {
+		return []ProbeResult{{Job: sc.JobName, Error: fmt.Sprintf("error creating the HTTP client: %v", err)}}
+	}
+
+	probeGroups := func(source string, groups []*targetgroup.Group, limit int) {
+		lb := labels.NewBuilder(labels.EmptyLabels())
+		var kept []*scrape.Target
+		for _, tg := range groups {
+			targets, failures := scrape.TargetsFromGroup(tg, sc, false, nil, lb)
+			for _, err := range failures {
+				results = append(results, ProbeResult{Job: sc.JobName, Source: source, Error: err.Error()})
+			}
+			for _, t := range targets {
+				if t.Labels(&labels.ScratchBuilder{}).IsEmpty() {
+					// Only the dropped targets of the static configs and files are listed, there can be many
+					// discovered ones
+					if source != targetSourceDiscovered {
+						results = append(results, ProbeResult{Job: sc.JobName, Target: t.DiscoveredLabels().Get(model.AddressLabel), Source: source, Dropped: true})
+					}
+					continue
+				}
+				kept = append(kept, t)
+			}
+		}
+		sort.Slice(kept, func(i, j int) bool { return kept[i].URL().String() < kept[j].URL().String() })
+		if limit > 0 && len(kept) > limit {
+			kept = kept[:limit]
+		}
+		for _, t := range kept {
+			result := probeTarget(ctx, client, sc, t)
+			result.Source = source
+			results = append(results, result)
+		}
+	}
+
+	var discovered discovery.Configs
+	for _, c := range sc.ServiceDiscoveryConfigs {
+		switch c := c.(type) {
+		case discovery.StaticConfig:
+			probeGroups(targetSourceStatic, c, 0)
+		case *file.SDConfig:
+			groups, err := readFileTargetGroups(c.Files)
+			if err != nil {
+				results = append(results, ProbeResult{Job: sc.JobName, Source: targetSourceFile, Error: err.Error()})
+			}
+			probeGroups(targetSourceFile, groups, 0)
+		case *kubernetes.SDConfig:
+			if c.APIServer.URL == nil && c.KubeConfig == "" {
+				c.KubeConfig = options.kubeconfig
+			}
+			discovered = append(discovered, c)
+		default:
+			results = append(results, ProbeResult{Job: sc.JobName, Source: targetSourceDiscovered, Error: fmt.Sprintf("the targets of the %s service discovery are not resolved", c.Name())})
+		}
+	}
+	if len(discovered) > 0 {
+		if !options.discover {
+			results = append(results, ProbeResult{Job: sc.JobName, Source: targetSourceDiscovered, Error: "the targets of the kubernetes_sd_configs are only discovered with --kubeconfig or in a cluster"})
+		} else if groups, err := options.newDiscoveryGroup(ctx, sc.JobName, discovered, options.discoveryTimeout); err != nil {
+			results = append(results, ProbeResult{Job: sc.JobName, Source: targetSourceDiscovered, Error: err.Error()})
+		} else {
+			probeGroups(targetSourceDiscovered, groups, options.sample)
+		}
+	}
+	return results
+}
+
+// readFileTargetGroups reads the target groups of the files of a file_sd_config like Prometheus does
+func readFileTargetGroups(patterns []string) ([]*targetgroup.Group, error) {
+	var groups []*targetgroup.Group
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return groups, err
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return groups, err
+			}
+			var fileGroups []*targetgroup.Group
+			switch ext := filepath.Ext(path); strings.ToLower(ext) {
+			case ".json":
+				err = json.Unmarshal(content, &fileGroups)
+			case ".yml", ".yaml":
+				err = yaml.UnmarshalStrict(content, &fileGroups)
+			default:
+				err = fmt.Errorf("unsupported file extension %q", ext)
+			}
+			if err != nil {
+				return groups, fmt.Errorf("error reading the targets of %s: %w", path, err)
+			}
+			for i, tg := range fileGroups {
+				if tg == nil {
+					continue
+				}
+				tg.Source = fmt.Sprintf("%s:%d", path, i)
+				if tg.Labels == nil {
+					tg.Labels = model.LabelSet{}
+				}
+				tg.Labels[model.LabelName(model.MetaLabelPrefix+"filepath")] = model.LabelValue(path)
+				groups = append(groups, tg)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// discoveryUpdateInterval is the interval the discovery manager sends the updates of the target groups at
+var discoveryUpdateInterval = time.Second
+
+// discoverTargetGroups runs the service discovery of a job until it discovers targets or the timeout expires. Once
+// targets are discovered, the updates of one more interval are waited for, since the service discovery configs and
+// roles of a job send their first targets in different updates.
+func discoverTargetGroups(ctx context.Context, job string, configs discovery.Configs, timeout time.Duration) ([]*targetgroup.Group, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	sdMetrics, err := discovery.CreateAndRegisterSDMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	manager := discovery.NewManager(ctx, gokitlog.NewNopLogger(), registry, sdMetrics, discovery.Updatert(discoveryUpdateInterval))
+	if manager == nil {
+		return nil, fmt.Errorf("error creating the discovery manager")
+	}
+	if err := manager.ApplyConfig(map[string]discovery.Configs{job: configs}); err != nil {
+		return nil, err
+	}
+	go manager.Run()
+
+	hasTargets := func(groups []*targetgroup.Group) bool {
+		for _, tg := range groups {
+			if len(tg.Targets) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	// Every update has all the target groups of the job, the last one is returned
+	var groups []*targetgroup.Group
+	var lastUpdate <-chan time.Time
+	for {
+		select {
+		case update := <-manager.SyncCh():
+			groups = update[job]
+			if lastUpdate == nil && hasTargets(groups) {
+				// Half an interval more, so that the sync of the next interval is not missed
+				lastUpdate = time.After(discoveryUpdateInterval + discoveryUpdateInterval/2)
+			}
+		case <-lastUpdate:
+			return groups, nil
+		case <-ctx.Done():
+			if lastUpdate != nil {
+				return groups, nil
+			}
+			return nil, fmt.Errorf("no target discovered in %s", timeout)
+		}
+	}
+}
+
+// probeTarget scrapes a target once like the collector does and parses the response
+func probeTarget(ctx context.Context, client *http.Client, sc *promconfig.ScrapeConfig, t *scrape.Target) ProbeResult {
+	result := ProbeResult{Job: sc.JobName, Target: t.URL().String()}
+
+	timeout := time.Duration(sc.ScrapeTimeout)
+	if d, err := model.ParseDuration(t.GetValue(model.ScrapeTimeoutLabel)); err == nil {
+		timeout = time.Duration(d)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.Target, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Add("Accept", scrapeAcceptHeader(sc.ScrapeProtocols))
+	req.Header.Set("User-Agent", scrape.UserAgent)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.Reachable = true
+	result.Status = resp.Status
+	result.ContentType = resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("server returned HTTP status %s", resp.Status)
+		return result
+	}
+
+	var body io.Reader = resp.Body
+	if sc.BodySizeLimit > 0 {
+		body = io.LimitReader(resp.Body, int64(sc.BodySizeLimit)+1)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		result.Error = fmt.Sprintf("error reading the response: %v", err)
+		return result
+	}
+	if sc.BodySizeLimit > 0 && int64(len(content)) > int64(sc.BodySizeLimit) {
+		result.Error = fmt.Sprintf("the response is larger than the body_size_limit %s", sc.BodySizeLimit)
+		return result
+	}
+	if err := checkExpositionContentType(result.ContentType); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	samples, seriesKept, err := countScrapedSeries(content, result.ContentType, sc, t.Labels(&labels.ScratchBuilder{}))
+	result.Samples, result.SeriesKept = samples, seriesKept
+	if err != nil {
+		result.Error = fmt.Sprintf("error parsing the response: %v", err)
+	}
+	return result
+}
+
+// scrapeAcceptHeader returns the Accept header of the scrapes with the scrape protocols of a job, like Prometheus
+func scrapeAcceptHeader(protocols []promconfig.ScrapeProtocol) string {
+	var values []string
+	weight := len(promconfig.ScrapeProtocolsHeaders) + 1
+	for _, protocol := range protocols {
+		values = append(values, fmt.Sprintf("%s;q=0.%d", promconfig.ScrapeProtocolsHeaders[protocol], weight))
+		weight--
+	}
+	values = append(values, fmt.Sprintf("*/*;q=0.%d", weight))
+	return strings.Join(values, ",")
+}
+
+// checkExpositionContentType returns an error if a response is not in an exposition format, e.g. an HTML page.
+// Prometheus parses the responses without a content type as text.
+func checkExpositionContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %v", contentType, err)
+	}
+	for _, t := range expositionContentTypes {
+		if mediaType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("the content type %q is not a Prometheus exposition format", mediaType)
+}
+
+// countScrapedSeries parses a scrape response with the parser of its content type and returns the number of
+// samples and of series left after the metric relabel configs of the job
+func countScrapedSeries(content []byte, contentType string, sc *promconfig.ScrapeConfig, target labels.Labels) (int, int, error) {
+	parser, err := textparse.New(content, contentType, sc.ScrapeClassicHistograms, labels.NewSymbolTable())
+	if err != nil {
+		return 0, 0, err
+	}
+	samples, seriesKept := 0, 0
+	for {
+		entry, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			return samples, seriesKept, nil
+		}
+		if err != nil {
+			return samples, seriesKept, err
+		}
+		if entry != textparse.EntrySeries && entry != textparse.EntryHistogram {
+			continue
+		}
+		samples++
+		var series labels.Labels
+		parser.Metric(&series)
+		if _, keep := relabel.Process(addTargetLabels(sc, target, series), sc.MetricRelabelConfigs...); keep {
+			seriesKept++
+		}
+	}
+}
+
+// printProbeResults prints the probe results as a table
+func printProbeResults(out io.Writer, results []ProbeResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSOURCE\tTARGET\tREACHABLE\tSTATUS\tCONTENT TYPE\tSAMPLES\tSERIES KEPT\tRESULT")
+	for _, r := range results {
+		outcome := "ok"
+		switch {
+		case r.Dropped:
+			outcome = "dropped by relabel_configs"
+		case r.Error != "":
+			outcome = r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\t%d\t%d\t%s\n", r.Job, dash(r.Source), dash(r.Target), r.Reachable, dash(r.Status), dash(r.ContentType), r.Samples, r.SeriesKept, outcome)
+	}
+	w.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
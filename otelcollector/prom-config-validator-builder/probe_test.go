@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const probedMetrics = `# TYPE http_requests_total counter
+http_requests_total{code="200"} 10
+http_requests_total{code="500"} 1
+# TYPE go_goroutines gauge
+go_goroutines 5
+`
+
+// newProbedServer serves the metrics on /metrics with basic auth, and an HTML page on /
+func newProbedServer(t *testing.T) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pa$word" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, probedMetrics)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>metrics</body></html>")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return u.Host
+}
+
+func TestProbeConfig(t *testing.T) {
+	address := newProbedServer(t)
+	dir := t.TempDir()
+	targets := fmt.Sprintf(`[{"targets": [%q], "labels": {"team": "a"}}]`, address)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "targets.json"), []byte(targets), 0600))
+	config := fmt.Sprintf(`scrape_configs:
+- job_name: app
+  basic_auth:
+    username: user
+    password: pa$$word
+  static_configs:
+  - targets: [%[1]s, dropped:9090]
+  relabel_configs:
+  - source_labels: [__address__]
+    regex: dropped.*
+    action: drop
+  metric_relabel_configs:
+  - source_labels: [__name__]
+    regex: go_.*
+    action: drop
+- job_name: unauthorized
+  static_configs:
+  - targets: [%[1]s]
+- job_name: html
+  metrics_path: /
+  file_sd_configs:
+  - files: [targets.json]
+- job_name: pods
+  kubernetes_sd_configs:
+  - role: pod
+`, address)
+	path := filepath.Join(dir, "prometheus.yml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	results, err := probeConfig(context.Background(), path, "", probeOptions{getenv: os.Getenv})
+	require.NoError(t, err)
+	require.Len(t, results, 5, "%+v", results)
+
+	assert.Equal(t, ProbeResult{Job: "app", Target: "dropped:9090", Source: targetSourceStatic, Dropped: true}, results[0])
+
+	app := results[1]
+	assert.Equal(t, "http://"+address+"/metrics", app.Target)
+	assert.Equal(t, targetSourceStatic, app.Source)
+	assert.True(t, app.Reachable)
+	assert.Equal(t, "200 OK", app.Status)
+	assert.Equal(t, "text/plain; version=0.0.4", app.ContentType)
+	assert.Equal(t, 3, app.Samples)
+	assert.Equal(t, 2, app.SeriesKept)
+	assert.Empty(t, app.Error)
+	assert.False(t, app.failed())
+
+	unauthorized := results[2]
+	assert.True(t, unauthorized.Reachable)
+	assert.Equal(t, "401 Unauthorized", unauthorized.Status)
+	assert.Contains(t, unauthorized.Error, "401 Unauthorized")
+	assert.True(t, unauthorized.failed())
+
+	html := results[3]
+	assert.Equal(t, targetSourceFile, html.Source)
+	assert.Equal(t, "http://"+address+"/", html.Target)
+	assert.Equal(t, "text/html; charset=utf-8", html.ContentType)
+	assert.Contains(t, html.Error, `the content type "text/html" is not a Prometheus exposition format`)
+
+	assert.Equal(t, "pods", results[4].Job)
+	assert.Contains(t, results[4].Error, "only discovered with --kubeconfig")
+
+	_, err = probeConfig(context.Background(), path, "missing", probeOptions{getenv: os.Getenv})
+	assert.ErrorContains(t, err, `no scrape config with job name "missing"`)
+}
+
+func TestProbeConfigScrapeConfigFiles(t *testing.T) {
+	address := newProbedServer(t)
+	dir := t.TempDir()
+	jobsDir := filepath.Join(dir, "jobs")
+	require.NoError(t, os.Mkdir(jobsDir, 0700))
+	targets := fmt.Sprintf(`[{"targets": [%q]}]`, address)
+	require.NoError(t, os.WriteFile(filepath.Join(jobsDir, "targets.json"), []byte(targets), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(jobsDir, "html.yml"), []byte(`scrape_configs:
+- job_name: html
+  metrics_path: /
+  file_sd_configs:
+  - files: [targets.json]
+`), 0600))
+	path := filepath.Join(dir, "prometheus.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`scrape_config_files: [jobs/*.yml]
+scrape_configs:
+- job_name: static
+  metrics_path: /
+  static_configs:
+  - targets: [dropped:9090]
+  relabel_configs:
+  - action: drop
+    source_labels: [__address__]
+    regex: dropped.*
+`), 0600))
+
+	results, err := probeConfig(context.Background(), path, "", probeOptions{getenv: os.Getenv})
+	require.NoError(t, err)
+	require.Len(t, results, 2, "%+v", results)
+	assert.Equal(t, "static", results[0].Job)
+	// The files of the scrape configs of a scrape config file are relative to it
+	assert.Equal(t, "html", results[1].Job)
+	assert.Equal(t, targetSourceFile, results[1].Source)
+	assert.Equal(t, "http://"+address+"/", results[1].Target)
+
+	results, err = probeConfig(context.Background(), path, "html", probeOptions{getenv: os.Getenv})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte("scrape_config_files: [jobs/*/*.yml]\n"), 0600))
+	_, err = probeConfig(context.Background(), path, "", probeOptions{getenv: os.Getenv})
+	assert.ErrorContains(t, err, "invalid scrape config file path")
+}
+
+// updatesConfig is a service discovery that sends each of its updates after the delay of the previous one
+type updatesConfig struct {
+	updates [][]*targetgroup.Group
+	delay   time.Duration
+}
+
+func (c updatesConfig) Name() string { return "updates" }
+
+func (c updatesConfig) NewDiscoverer(discovery.DiscovererOptions) (discovery.Discoverer, error) {
+	return c, nil
+}
+
+func (c updatesConfig) NewDiscovererMetrics(prometheus.Registerer, discovery.RefreshMetricsInstantiator) discovery.DiscovererMetrics {
+	return noDiscovererMetrics{}
+}
+
+func (c updatesConfig) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+	for _, update := range c.updates {
+		select {
+		case up <- update:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(c.delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+	<-ctx.Done()
+}
+
+type noDiscovererMetrics struct{}
+
+func (noDiscovererMetrics) Register() error { return nil }
+func (noDiscovererMetrics) Unregister()     {}
+
+func TestDiscoverTargetGroupsWaitsForLaterUpdates(t *testing.T) {
+	defaultInterval := discoveryUpdateInterval
+	discoveryUpdateInterval = 200 * time.Millisecond
+	t.Cleanup(func() { discoveryUpdateInterval = defaultInterval })
+
+	pods := &targetgroup.Group{Source: "pods", Targets: []model.LabelSet{{model.AddressLabel: "pod:8080"}}}
+	services := &targetgroup.Group{Source: "services", Targets: []model.LabelSet{{model.AddressLabel: "service:8080"}}}
+	config := updatesConfig{
+		updates: [][]*targetgroup.Group{{{Source: "pods"}}, {pods}, {services}},
+		// The services are sent after the pods were synced
+		delay: 150 * time.Millisecond,
+	}
+
+	groups, err := discoverTargetGroups(context.Background(), "job", discovery.Configs{config}, 5*time.Second)
+	require.NoError(t, err)
+	// The services sent after the first targets are discovered too
+	var addresses []string
+	for _, tg := range groups {
+		for _, target := range tg.Targets {
+			addresses = append(addresses, string(target[model.AddressLabel]))
+		}
+	}
+	assert.ElementsMatch(t, []string{"pod:8080", "service:8080"}, addresses)
+}
+
+func TestDiscoverTargetGroupsTimeout(t *testing.T) {
+	config := updatesConfig{updates: [][]*targetgroup.Group{{{Source: "pods"}}}}
+	_, err := discoverTargetGroups(context.Background(), "job", discovery.Configs{config}, 300*time.Millisecond)
+	assert.ErrorContains(t, err, "no target discovered in 300ms")
+}
+
+func TestProbeDiscoveredTargets(t *testing.T) {
+	address := newProbedServer(t)
+	path := filepath.Join(t.TempDir(), "prometheus.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`scrape_configs:
+- job_name: pods
+  metrics_path: /
+  kubernetes_sd_configs:
+  - role: pod
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_pod_node_name]
+    regex: $$NODE_NAME
+    action: keep
+`), 0600))
+
+	options := probeOptions{
+		discover: true,
+		sample:   1,
+		getenv:   func(string) string { return "node-1" },
+		newDiscoveryGroup: func(_ context.Context, job string, configs discovery.Configs, _ time.Duration) ([]*targetgroup.Group, error) {
+			assert.Equal(t, "pods", job)
+			assert.Len(t, configs, 1)
+			return []*targetgroup.Group{{Targets: []model.LabelSet{
+				{model.AddressLabel: model.LabelValue(address), "__meta_kubernetes_pod_node_name": "node-1"},
+				{model.AddressLabel: "other:8080", "__meta_kubernetes_pod_node_name": "node-1"},
+				{model.AddressLabel: "dropped:8080", "__meta_kubernetes_pod_node_name": "node-2"},
+			}}}, nil
+		},
+	}
+	results, err := probeConfig(context.Background(), path, "pods", options)
+	require.NoError(t, err)
+	// The dropped discovered targets are not listed and only a sample of the kept ones is scraped
+	require.Len(t, results, 1, "%+v", results)
+	assert.Equal(t, targetSourceDiscovered, results[0].Source)
+	assert.Equal(t, "http://"+address+"/", results[0].Target)
+}
+
+func TestRunProbe(t *testing.T) {
+	address := newProbedServer(t)
+	path := filepath.Join(t.TempDir(), "prometheus.yml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`scrape_configs:
+- job_name: app
+  basic_auth:
+    username: user
+    password: pa$$word
+  static_configs:
+  - targets: [%[1]s]
+- job_name: unauthorized
+  static_configs:
+  - targets: [%[1]s]
+`, address)), 0600))
+
+	var out bytes.Buffer
+	err := runProbe([]string{"--config", path}, &out)
+	assert.EqualError(t, err, "1 of the 2 probes failed")
+	assert.Contains(t, out.String(), "JOB           SOURCE  TARGET")
+	assert.Contains(t, out.String(), "server returned HTTP status 401 Unauthorized")
+
+	out.Reset()
+	require.NoError(t, runProbe([]string{"--config", path, "--job", "app", "--json"}, &out))
+	var results []ProbeResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, 3, results[0].Samples)
+	assert.Equal(t, 3, results[0].SeriesKept)
+
+	assert.Error(t, runProbe(nil, &out))
+}
+
+func TestCountScrapedSeriesOpenMetrics(t *testing.T) {
+	content := []byte("# TYPE requests counter\nrequests_total 1\nrequests_created 1\n# EOF\n")
+	sc, err := (&rawPrometheusConfig{Global: promconfig.DefaultGlobalConfig}).scrapeConfig(map[interface{}]interface{}{"job_name": "app"}, os.Getenv)
+	require.NoError(t, err)
+	samples, kept, err := countScrapedSeries(content, "application/openmetrics-text; version=1.0.0", sc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, samples)
+	assert.Equal(t, 2, kept)
+
+	_, _, err = countScrapedSeries([]byte("requests_total 1\n"), "application/openmetrics-text", sc, nil)
+	assert.ErrorContains(t, err, "EOF")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	promconfig "github.com/prometheus/prometheus/config"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// scrapeConfigFilePattern is the pattern Prometheus accepts for the scrape_config_files globs: the wildcard
+// can only be in the last element of the path
+var scrapeConfigFilePattern = regexp.MustCompile(`^[^*]*(\*[^/]*)?$`)
+
+// resolveScrapeConfigFiles returns the files matched by a scrape_config_files glob, relative to dir if it is
+// not absolute. A glob matching no file is not an error, like in Prometheus.
+func resolveScrapeConfigFiles(pattern, dir string) ([]string, error) {
+	if !scrapeConfigFilePattern.MatchString(pattern) {
+		return nil, fmt.Errorf("invalid scrape config file path %q", pattern)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		// The only error can be a bad pattern
+		return nil, fmt.Errorf("error retrieving scrape config files for %q: %w", pattern, err)
+	}
+	return paths, nil
+}
+
+// hasScrapeConfigFiles returns whether a prometheus config has scrape_config_files globs
+func hasScrapeConfigFiles(content []byte) bool {
+	var config struct {
+		ScrapeConfigFiles []string `yaml:"scrape_config_files"`
+	}
+	return yaml.Unmarshal(content, &config) == nil && len(config.ScrapeConfigFiles) > 0
+}
+
+// validateScrapeConfigFiles validates the scrape configs of the files matched by the scrape_config_files globs
+func (v *configValidator) validateScrapeConfigFiles(seq *yamlv3.Node, global promconfig.GlobalConfig) {
+	if seq.Kind != yamlv3.SequenceNode {
+		if seq.Tag != "!!null" {
+			v.add(Problem{Field: "scrape_config_files", Line: seq.Line, Column: seq.Column, Kind: problemInvalidConfig, Message: "scrape_config_files must be a list"})
+		}
+		return
+	}
+	for i, item := range seq.Content {
+		field := fmt.Sprintf("scrape_config_files[%d]", i)
+		if item.Kind != yamlv3.ScalarNode {
+			v.add(Problem{Field: field, Line: item.Line, Column: item.Column, Kind: problemInvalidConfig, Message: "the scrape config files must be file path globs"})
+			continue
+		}
+		paths, err := resolveScrapeConfigFiles(item.Value, v.files.configDir)
+		if err != nil {
+			v.add(Problem{Field: field, Line: item.Line, Column: item.Column, Kind: problemInvalidConfig, Message: err.Error()})
+			continue
+		}
+		for _, path := range paths {
+			v.validateScrapeConfigFile(path, global)
+		}
+	}
+}
+
+// validateScrapeConfigFile validates the scrape configs of a scrape config file like the ones of the
+// prometheus config. The file can only have scrape configs.
+func (v *configValidator) validateScrapeConfigFile(path string, global promconfig.GlobalConfig) {
+	fv := &configValidator{file: path, linter: v.linter, files: v.files, jobs: v.jobs}
+	defer func() { v.problems = append(v.problems, fv.problems...) }()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fv.add(Problem{Kind: problemMissingFile, Message: err.Error()})
+		return
+	}
+	doc, problem := parseDocument(content, "the scrape config file")
+	if problem != nil {
+		fv.add(*problem)
+		return
+	}
+	if doc == nil {
+		return
+	}
+	fv.lines = splitLines(content)
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		if key.Value != "scrape_configs" {
+			fv.add(Problem{Field: key.Value, Line: key.Line, Column: key.Column, Kind: problemUnknownField, Message: fmt.Sprintf("field %s not found in the scrape config file", key.Value)})
+			continue
+		}
+		fv.validateScrapeConfigs(value, global)
+	}
+}
+
+// inlineScrapeConfigFiles appends the scrape configs of the scrape config files of a prometheus config to its
+// scrape configs and removes its scrape_config_files, since the collector config has a single prometheus config.
+// It returns the index of the first scrape config of each file in the scrape configs, or nil if the config has
+// no scrape config files.
+func inlineScrapeConfigFiles(doc *yamlv3.Node, dir string) (map[string]int, error) {
+	var patterns *yamlv3.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "scrape_config_files" {
+			patterns = doc.Content[i+1]
+			doc.Content = append(doc.Content[:i], doc.Content[i+2:]...)
+			break
+		}
+	}
+	if patterns == nil {
+		return nil, nil
+	}
+
+	scrapeConfigs := mappingValueNode(doc, "scrape_configs")
+	if scrapeConfigs == nil {
+		scrapeConfigs = &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+		doc.Content = append(doc.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: "scrape_configs"}, scrapeConfigs)
+	} else if scrapeConfigs.Kind != yamlv3.SequenceNode {
+		*scrapeConfigs = yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+	}
+
+	offsets := map[string]int{}
+	for _, pattern := range patterns.Content {
+		paths, err := resolveScrapeConfigFiles(pattern.Value, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var file yamlv3.Node
+			if err := yamlv3.Unmarshal(content, &file); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if _, ok := offsets[path]; !ok {
+				offsets[path] = len(scrapeConfigs.Content)
+			}
+			if len(file.Content) == 0 {
+				continue
+			}
+			if items := mappingValueNode(file.Content[0], "scrape_configs"); items != nil && items.Kind == yamlv3.SequenceNode {
+				scrapeConfigs.Content = append(scrapeConfigs.Content, items.Content...)
+			}
+		}
+	}
+	return offsets, nil
+}
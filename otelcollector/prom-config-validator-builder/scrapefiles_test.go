@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// writeScrapeConfigFiles writes the files in a temporary directory and returns it
+func writeScrapeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+const scrapeConfigFilesConfig = `scrape_config_files:
+- jobs/*.yml
+- /does/not/exist/*.yml
+scrape_configs:
+- job_name: main
+  static_configs:
+  - targets: [localhost:9090]
+`
+
+func TestValidateScrapeConfigFiles(t *testing.T) {
+	dir := writeScrapeConfigFiles(t, map[string]string{
+		"prometheus.yml": scrapeConfigFilesConfig,
+		"jobs/a.yml": `scrape_configs:
+- job_name: app
+  static_configs:
+  - targets: [app:8080]
+- job_name: main
+  static_configs:
+  - targets: [localhost:9091]
+`,
+		"jobs/b.yml": `scrape_configs:
+- job_name: broken
+  relabel_configs:
+  - source_labels: [a]
+    regex: (a
+    target_label: x
+global:
+  scrape_interval: 30s
+`,
+	})
+
+	problems := validatePrometheusConfigFile(filepath.Join(dir, "prometheus.yml"), fileOptions{configDir: dir}, nil)
+	require.Len(t, problems, 3, "%v", problems)
+
+	aFile, bFile := filepath.Join(dir, "jobs/a.yml"), filepath.Join(dir, "jobs/b.yml")
+	assert.Equal(t, aFile, problems[0].File)
+	assert.Equal(t, problemDuplicateJob, problems[0].Kind)
+	assert.Equal(t, "scrape_configs[1]", problems[0].Field)
+	assert.Equal(t, 5, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "first found at line 5 of the prometheus config")
+
+	assert.Equal(t, bFile, problems[1].File)
+	assert.Equal(t, problemInvalidRegex, problems[1].Kind)
+	assert.Equal(t, "broken", problems[1].Job)
+	assert.Equal(t, 5, problems[1].Line)
+	assert.Contains(t, problems[1].String(), `file "`+bFile+`", job "broken", line 5`)
+
+	assert.Equal(t, bFile, problems[2].File)
+	assert.Equal(t, problemUnknownField, problems[2].Kind)
+	assert.Equal(t, "global", problems[2].Field)
+	assert.Equal(t, 7, problems[2].Line)
+}
+
+func TestValidateScrapeConfigFilesPatterns(t *testing.T) {
+	dir := writeScrapeConfigFiles(t, map[string]string{
+		"prometheus.yml": "scrape_config_files:\n- jobs/*/a.yml\n- [a]\n",
+	})
+	problems := validatePrometheusConfigFile(filepath.Join(dir, "prometheus.yml"), fileOptions{configDir: dir}, nil)
+	require.Len(t, problems, 2, "%v", problems)
+	assert.Equal(t, "scrape_config_files[0]", problems[0].Field)
+	assert.Contains(t, problems[0].Message, "invalid scrape config file path")
+	assert.Equal(t, "scrape_config_files[1]", problems[1].Field)
+	assert.Equal(t, 3, problems[1].Line)
+}
+
+func TestWriteAcceptedScrapeConfigsInlinesFiles(t *testing.T) {
+	dir := writeScrapeConfigFiles(t, map[string]string{
+		"prometheus.yml": scrapeConfigFilesConfig,
+		"jobs/a.yml":     "scrape_configs:\n- job_name: app\n  static_configs:\n  - targets: [app:8080]\n",
+		"jobs/b.yml":     "scrape_configs:\n- job_name: broken\n  scrape_intervall: 1s\n- job_name: other\n",
+	})
+	path := filepath.Join(dir, "prometheus.yml")
+	files := fileOptions{configDir: dir}
+
+	readJobs := func(path string) []string {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var config struct {
+			ScrapeConfigFiles []string                 `yaml:"scrape_config_files"`
+			ScrapeConfigs     []map[string]interface{} `yaml:"scrape_configs"`
+		}
+		require.NoError(t, yaml.Unmarshal(content, &config))
+		assert.Empty(t, config.ScrapeConfigFiles)
+		var jobs []string
+		for _, sc := range config.ScrapeConfigs {
+			jobs = append(jobs, sc["job_name"].(string))
+		}
+		return jobs
+	}
+
+	problems := validatePrometheusConfigFile(path, files, nil)
+	require.Len(t, problems, 1, "%v", problems)
+	accepted, rejected, err := writeAcceptedScrapeConfigs(path, files, problems)
+	require.NoError(t, err)
+	defer os.Remove(accepted)
+	assert.Equal(t, []string{"main", "app", "other"}, readJobs(accepted))
+	assert.Contains(t, rejected["broken"], "scrape_intervall")
+	assert.Contains(t, rejected["broken"], filepath.Join(dir, "jobs/b.yml"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jobs/b.yml"), []byte("scrape_configs:\n- job_name: other\n"), 0644))
+	accepted, rejected, err = writeAcceptedScrapeConfigs(path, files, nil)
+	require.NoError(t, err)
+	defer os.Remove(accepted)
+	assert.Empty(t, rejected)
+	assert.Equal(t, []string{"main", "app", "other"}, readJobs(accepted))
+	assert.Empty(t, validatePrometheusConfigFile(accepted, files, nil))
+}
+
+func TestWriteAcceptedScrapeConfigsWithoutFiles(t *testing.T) {
+	dir := writeScrapeConfigFiles(t, map[string]string{
+		"prometheus.yml": "scrape_configs:\n- job_name: main\n",
+	})
+	path := filepath.Join(dir, "prometheus.yml")
+	accepted, rejected, err := writeAcceptedScrapeConfigs(path, fileOptions{configDir: dir}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, path, accepted)
+	assert.Empty(t, rejected)
+}
+
+func TestValidateFileReferencesUnderRoot(t *testing.T) {
+	root := writeScrapeConfigFiles(t, map[string]string{
+		"etc/prometheus/token": "token",
+	})
+	config := []byte(`scrape_configs:
+- job_name: secure
+  bearer_token_file: /etc/prometheus/token
+  tls_config:
+    ca_file: /etc/prometheus/ca.crt
+  static_configs:
+  - targets: [localhost:9090]
+`)
+	// Without a root, the files are looked up as is and only the ones the receiver checks are reported
+	problems := checkPrometheusConfig(config, fileOptions{}, nil)
+	require.Len(t, problems, 1, "%v", problems)
+	assert.Equal(t, "scrape_configs[0].bearer_token_file", problems[0].Field)
+
+	problems = checkPrometheusConfig(config, fileOptions{root: root}, nil)
+	require.Len(t, problems, 1, "%v", problems)
+	assert.Equal(t, problemMissingFile, problems[0].Kind)
+	assert.Equal(t, "scrape_configs[0].tls_config.ca_file", problems[0].Field)
+	assert.Equal(t, 5, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "CA file")
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus-collector/shared/configescape"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/scrape"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// relabelCommand is the subcommand that simulates the relabeling of a target and of its series
+const relabelCommand = "relabel"
+
+// shardRegex is replaced by the shard of the target allocator in keep relabel configs, which is always 0
+const shardRegex = "$(SHARD)"
+
+// runRelabel runs the relabel subcommand. It prints the labels after each relabel config of the job for a
+// target, for the series of an exposition text file, or for both.
+func runRelabel(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(relabelCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+	configFile := flags.String("config", "", "Prometheus config file with the scrape config of the job")
+	jobName := flags.String("job", "", "Name of the scrape job to simulate")
+	target := flags.String("target", "", `Labels of the target discovered, as a JSON object such as {"__address__": "10.0.0.1:8080"}`)
+	targetFile := flags.String("target-file", "", "File with the labels of the target discovered, as a JSON object")
+	metricsFile := flags.String("metrics", "", "Exposition text file with the series scraped from the target, for the metric relabel configs")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *configFile == "" || *jobName == "" {
+		return fmt.Errorf("--config and --job are required")
+	}
+	if *target == "" && *targetFile == "" && *metricsFile == "" {
+		return fmt.Errorf("one of --target, --target-file or --metrics is required")
+	}
+
+	sc, err := loadSimulatedScrapeConfig(*configFile, *jobName, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	var targetLabels labels.Labels
+	if *target != "" || *targetFile != "" {
+		content := []byte(*target)
+		if *targetFile != "" {
+			if content, err = os.ReadFile(*targetFile); err != nil {
+				return err
+			}
+		}
+		discovered := map[string]string{}
+		if err := json.Unmarshal(content, &discovered); err != nil {
+			return fmt.Errorf("error parsing the target labels: %w", err)
+		}
+		var kept bool
+		if targetLabels, kept = simulateTargetRelabeling(out, sc, labels.FromMap(discovered)); !kept {
+			return nil
+		}
+	}
+
+	if *metricsFile != "" {
+		f, err := os.Open(*metricsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		series, err := parseExpositionSeries(f)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", *metricsFile, err)
+		}
+		for _, s := range series {
+			simulateMetricRelabeling(out, sc, targetLabels, s)
+		}
+	}
+	return nil
+}
+
+// loadSimulatedScrapeConfig reads the scrape config of a job from a prometheus config, with its strings as the
+// collector sees them once the config went through generateOtelConfig and the collector expanded it: the
+// allow-listed env var placeholders are replaced by the values of the env vars.
+func loadSimulatedScrapeConfig(path, job string, getenv func(string) string) (*promconfig.ScrapeConfig, error) {
+	config, err := readRawPrometheusConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range config.ScrapeConfigs {
+		if name, _ := item["job_name"].(string); name == job {
+			return config.scrapeConfig(item, getenv)
+		}
+	}
+	return nil, fmt.Errorf("no scrape config with job name %q found in %s", job, path)
+}
+
+// rawPrometheusConfig is a prometheus config with its scrape configs not parsed yet, so that each of them is expanded
+// and parsed on its own and a job with an error does not prevent simulating the others
+type rawPrometheusConfig struct {
+	Global            promconfig.GlobalConfig       `yaml:"global"`
+	ScrapeConfigs     []map[interface{}]interface{} `yaml:"scrape_configs"`
+	ScrapeConfigFiles []string                      `yaml:"scrape_config_files"`
+	// dirs are the directories of the files the scrape configs are in, which their files are relative to
+	dirs []string
+}
+
+// readRawPrometheusConfig reads a prometheus config and appends the scrape configs of its scrape config files to
+// its scrape configs like Prometheus does
+func readRawPrometheusConfig(path string) (*rawPrometheusConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &rawPrometheusConfig{Global: promconfig.DefaultGlobalConfig}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	for range config.ScrapeConfigs {
+		config.dirs = append(config.dirs, dir)
+	}
+	for _, pattern := range config.ScrapeConfigFiles {
+		files, err := resolveScrapeConfigFiles(pattern, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var scrapeConfigFile struct {
+				ScrapeConfigs []map[interface{}]interface{} `yaml:"scrape_configs"`
+			}
+			if err := yaml.Unmarshal(content, &scrapeConfigFile); err != nil {
+				return nil, fmt.Errorf("error parsing the scrape config file %s: %w", file, err)
+			}
+			config.ScrapeConfigs = append(config.ScrapeConfigs, scrapeConfigFile.ScrapeConfigs...)
+			for range scrapeConfigFile.ScrapeConfigs {
+				config.dirs = append(config.dirs, filepath.Dir(file))
+			}
+		}
+	}
+	return config, nil
+}
+
+// scrapeConfig expands and parses a scrape config of the prometheus config
+func (c *rawPrometheusConfig) scrapeConfig(item map[interface{}]interface{}, getenv func(string) string) (*promconfig.ScrapeConfig, error) {
+	job, _ := item["job_name"].(string)
+	configescape.FromEnv().ExpandConfig(item, getenv)
+	raw, err := yaml.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	sc := &promconfig.ScrapeConfig{}
+	if err := yaml.UnmarshalStrict(raw, sc); err != nil {
+		return nil, fmt.Errorf("error parsing the scrape config of job %q: %w", job, err)
+	}
+	if err := sc.Validate(c.Global); err != nil {
+		return nil, fmt.Errorf("error validating the scrape config of job %q: %w", job, err)
+	}
+	// The target allocator replaces the shard placeholder, see RelabelConfigTargetFilter
+	for _, rc := range sc.RelabelConfigs {
+		if rc.Regex.String() == shardRegex {
+			rc.Regex = relabel.MustNewRegexp("0")
+		}
+	}
+	return sc, nil
+}
+
+// simulateTargetRelabeling prints the labels of the target after each relabel config, and returns the labels of
+// the target once scraped if it is kept
+func simulateTargetRelabeling(out io.Writer, sc *promconfig.ScrapeConfig, discovered labels.Labels) (labels.Labels, bool) {
+	fmt.Fprintf(out, "Target relabeling of job %q\n", sc.JobName)
+	fmt.Fprintf(out, "  discovered: %s\n", discovered)
+
+	// The labels of the scrape config are added before relabeling, like Prometheus does
+	lb := labels.NewBuilder(discovered)
+	defaults := map[string]string{
+		model.JobLabel:            sc.JobName,
+		model.ScrapeIntervalLabel: sc.ScrapeInterval.String(),
+		model.ScrapeTimeoutLabel:  sc.ScrapeTimeout.String(),
+		model.MetricsPathLabel:    sc.MetricsPath,
+		model.SchemeLabel:         sc.Scheme,
+	}
+	for name, value := range defaults {
+		if lb.Get(name) == "" {
+			lb.Set(name, value)
+		}
+	}
+	for name, values := range sc.Params {
+		if len(values) > 0 {
+			lb.Set(model.ParamLabelPrefix+name, values[0])
+		}
+	}
+	fmt.Fprintf(out, "  before relabeling: %s\n", lb.Labels())
+
+	if !processSteps(out, lb.Labels(), sc.RelabelConfigs) {
+		fmt.Fprintf(out, "  result: target dropped\n")
+		return labels.EmptyLabels(), false
+	}
+
+	final, _, err := scrape.PopulateLabels(labels.NewBuilder(discovered), sc, false)
+	if err != nil {
+		fmt.Fprintf(out, "  result: target dropped: %v\n", err)
+		return labels.EmptyLabels(), false
+	}
+	fmt.Fprintf(out, "  result: target kept: %s\n", final)
+	return final, true
+}
+
+// simulateMetricRelabeling prints the labels of a series after each metric relabel config
+func simulateMetricRelabeling(out io.Writer, sc *promconfig.ScrapeConfig, target, series labels.Labels) {
+	lset := addTargetLabels(sc, target, series)
+	fmt.Fprintf(out, "Metric relabeling of series %s\n", series)
+	fmt.Fprintf(out, "  before relabeling: %s\n", lset)
+	if !processSteps(out, lset, sc.MetricRelabelConfigs) {
+		fmt.Fprintf(out, "  result: series dropped\n")
+		return
+	}
+	final, _ := relabel.Process(lset, sc.MetricRelabelConfigs...)
+	fmt.Fprintf(out, "  result: series kept: %s\n", final)
+}
+
+// addTargetLabels adds the labels of the target to a series scraped from it like Prometheus does before the
+// metric relabeling, see honor_labels
+func addTargetLabels(sc *promconfig.ScrapeConfig, target, series labels.Labels) labels.Labels {
+	lb := labels.NewBuilder(series)
+	target.Range(func(l labels.Label) {
+		if strings.HasPrefix(l.Name, model.ReservedLabelPrefix) {
+			return
+		}
+		existing := series.Get(l.Name)
+		switch {
+		case existing == "":
+			lb.Set(l.Name, l.Value)
+		case !sc.HonorLabels:
+			lb.Set(model.ExportedLabelPrefix+l.Name, existing)
+			lb.Set(l.Name, l.Value)
+		}
+	})
+	return lb.Labels()
+}
+
+// processSteps applies the relabel configs one at a time like relabel.Process does, printing the labels after
+// each of them, and returns whether the labels were kept
+func processSteps(out io.Writer, lset labels.Labels, configs []*relabel.Config) bool {
+	for i, rc := range configs {
+		next, keep := relabel.Process(lset, rc)
+		if !keep {
+			fmt.Fprintf(out, "  [%d] %s: dropped\n", i, describeRelabelConfig(rc))
+			return false
+		}
+		fmt.Fprintf(out, "  [%d] %s: %s\n", i, describeRelabelConfig(rc), next)
+		lset = next
+	}
+	return true
+}
+
+func describeRelabelConfig(rc *relabel.Config) string {
+	parts := []string{string(rc.Action)}
+	if len(rc.SourceLabels) > 0 {
+		parts = append(parts, fmt.Sprintf("source_labels=%s", rc.SourceLabels))
+	}
+	parts = append(parts, fmt.Sprintf("regex=%q", rc.Regex.String()))
+	if rc.TargetLabel != "" {
+		parts = append(parts, fmt.Sprintf("target_label=%q", rc.TargetLabel))
+	}
+	if rc.Action == relabel.Replace {
+		parts = append(parts, fmt.Sprintf("replacement=%q", rc.Replacement))
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseExpositionSeries returns the series of a Prometheus exposition text, sorted
+func parseExpositionSeries(r io.Reader) ([]labels.Labels, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+	var series []labels.Labels
+	add := func(name string, m *dto.Metric, extra ...string) {
+		lb := labels.NewBuilder(labels.EmptyLabels())
+		for _, l := range m.GetLabel() {
+			lb.Set(l.GetName(), l.GetValue())
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			lb.Set(extra[i], extra[i+1])
+		}
+		lb.Set(model.MetricNameLabel, name)
+		series = append(series, lb.Labels())
+	}
+	for name, family := range families {
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					add(name, m, model.QuantileLabel, fmt.Sprint(q.GetQuantile()))
+				}
+				add(name+"_sum", m)
+				add(name+"_count", m)
+			case dto.MetricType_HISTOGRAM:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add(name+"_bucket", m, model.BucketLabel, fmt.Sprint(b.GetUpperBound()))
+				}
+				add(name+"_sum", m)
+				add(name+"_count", m)
+			default:
+				add(name, m)
+			}
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return labels.Compare(series[i], series[j]) < 0 })
+	return series, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const simulatedConfig = `scrape_configs:
+- job_name: app
+  kubernetes_sd_configs:
+  - role: pod
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_pod_annotation_prometheus_io_scrape]
+    regex: "true"
+    action: keep
+  - source_labels: [__address__, __meta_kubernetes_pod_annotation_prometheus_io_port]
+    regex: ([^:]+)(?::\d+)?;(\d+)
+    replacement: $$1:$$2
+    target_label: __address__
+  - source_labels: [__meta_kubernetes_pod_node_name]
+    regex: $$NODE_NAME
+    action: keep
+  - source_labels: [__meta_kubernetes_pod_label_shard]
+    regex: $(SHARD)
+    action: keep
+  metric_relabel_configs:
+  - source_labels: [__name__]
+    regex: go_.*
+    action: drop
+`
+
+const simulatedMetrics = `# TYPE http_requests_total counter
+http_requests_total{code="200",job="inner"} 10
+# TYPE go_goroutines gauge
+go_goroutines 5
+`
+
+func TestLoadSimulatedScrapeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prometheus.yml")
+	require.NoError(t, os.WriteFile(path, []byte(simulatedConfig), 0600))
+
+	sc, err := loadSimulatedScrapeConfig(path, "app", func(name string) string { return map[string]string{"NODE_NAME": "node-1"}[name] })
+	require.NoError(t, err)
+	require.Len(t, sc.RelabelConfigs, 4)
+	assert.Equal(t, "$1:$2", sc.RelabelConfigs[1].Replacement)
+	assert.Equal(t, "node-1", sc.RelabelConfigs[2].Regex.String())
+	assert.Equal(t, "0", sc.RelabelConfigs[3].Regex.String())
+
+	_, err = loadSimulatedScrapeConfig(path, "missing", os.Getenv)
+	assert.ErrorContains(t, err, `no scrape config with job name "missing"`)
+}
+
+func TestRunRelabel(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "prometheus.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(simulatedConfig), 0600))
+	metricsPath := filepath.Join(dir, "metrics.txt")
+	require.NoError(t, os.WriteFile(metricsPath, []byte(simulatedMetrics), 0600))
+	t.Setenv("NODE_NAME", "node-1")
+
+	target := `{"__address__": "10.0.0.1:80", "__meta_kubernetes_pod_annotation_prometheus_io_scrape": "true",
+		"__meta_kubernetes_pod_annotation_prometheus_io_port": "8080", "__meta_kubernetes_pod_node_name": "node-1",
+		"__meta_kubernetes_pod_label_shard": "0"}`
+	var out bytes.Buffer
+	require.NoError(t, runRelabel([]string{"--config", configPath, "--job", "app", "--target", target, "--metrics", metricsPath}, &out))
+	output := out.String()
+	assert.Contains(t, output, `__address__="10.0.0.1:8080"`)
+	assert.Contains(t, output, `result: target kept: {__address__="10.0.0.1:8080", __metrics_path__="/metrics", __scheme__="http", __scrape_interval__="1m", __scrape_timeout__="10s", instance="10.0.0.1:8080", job="app"}`)
+	assert.Contains(t, output, "  [0] drop source_labels=__name__ regex=\"go_.*\": dropped\n  result: series dropped")
+	assert.Contains(t, output, `result: series kept: {__name__="http_requests_total", code="200", exported_job="inner", instance="10.0.0.1:8080", job="app"}`)
+
+	out.Reset()
+	target = strings.Replace(target, `"__meta_kubernetes_pod_node_name": "node-1"`, `"__meta_kubernetes_pod_node_name": "node-2"`, 1)
+	require.NoError(t, runRelabel([]string{"--config", configPath, "--job", "app", "--target", target, "--metrics", metricsPath}, &out))
+	assert.Contains(t, out.String(), "  [2] keep source_labels=__meta_kubernetes_pod_node_name regex=\"node-1\": dropped\n  result: target dropped\n")
+	assert.NotContains(t, out.String(), "Metric relabeling")
+
+	assert.Error(t, runRelabel([]string{"--config", configPath}, &out))
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	commonconfig "github.com/prometheus/common/config"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/prometheus/prometheus/model/relabel"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Kinds of the problems found in a prometheus config
+const (
+	problemSyntax          = "syntax"
+	problemUnknownField    = "unknown_field"
+	problemInvalidRegex    = "invalid_regex"
+	problemInvalidDuration = "invalid_duration"
+	problemMissingFile     = "missing_file"
+	problemDuplicateJob    = "duplicate_job"
+	problemUnsupported     = "unsupported_feature"
+	problemInvalidConfig   = "invalid_config"
+)
+
+// maxProblemsPerSection bounds the retries made to find more problems in a section once one was found
+const maxProblemsPerSection = 50
+
+// unsupportedSections are the prometheus config sections the prometheus receiver does not support
+var unsupportedSections = map[string]bool{"remote_write": true, "remote_read": true, "rule_files": true, "alerting": true}
+
+var (
+	lineNumberPattern   = regexp.MustCompile(`line (\d+)`)
+	unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type`)
+	typeErrorPattern    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	quotedValuePattern  = regexp.MustCompile("\"([^\"]*)\"|`([^`]*)`")
+)
+
+// Problem is a problem found in a prometheus config, attributed to the job and the line it was found at
+type Problem struct {
+	// File is set on the problems found in the scrape_config_files of the config
+	File   string `json:"file,omitempty"`
+	Job    string `json:"job,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Kind   string `json:"kind"`
+	// Rule and Severity are set on the warnings of the lint rules
+	Rule     string `json:"rule,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var location []string
+	if p.File != "" {
+		location = append(location, fmt.Sprintf("file %q", p.File))
+	}
+	if p.Job != "" {
+		location = append(location, fmt.Sprintf("job %q", p.Job))
+	}
+	if p.Line > 0 {
+		location = append(location, fmt.Sprintf("line %d", p.Line))
+	}
+	if p.Column > 0 {
+		location = append(location, fmt.Sprintf("column %d", p.Column))
+	}
+	if p.Field != "" {
+		location = append(location, p.Field)
+	}
+	kind := p.Kind
+	if p.Rule != "" {
+		kind = fmt.Sprintf("%s %s", p.Severity, p.Rule)
+	}
+	if len(location) == 0 {
+		return fmt.Sprintf("%s: %s", kind, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", strings.Join(location, ", "), kind, p.Message)
+}
+
+// Report is the result of validating a prometheus config, written as JSON next to the human readable output
+type Report struct {
+	Config   string    `json:"config"`
+	Valid    bool      `json:"valid"`
+	Problems []Problem `json:"problems"`
+	// Warnings are found by the lint rules, they do not make the config invalid
+	Warnings []Problem `json:"warnings,omitempty"`
+	// RejectedJobs are the scrape jobs left out of the collector config in partial acceptance mode, with
+	// the first problem found in each
+	RejectedJobs map[string]string `json:"rejected_jobs,omitempty"`
+}
+
+// summary returns the problems on a single line, to record them as the fatal error of the validator
+func (r Report) summary() string {
+	messages := make([]string, 0, len(r.Problems))
+	for _, p := range r.Problems {
+		messages = append(messages, p.String())
+	}
+	return fmt.Sprintf("%d problem(s) found in the prometheus config: %s", len(r.Problems), strings.Join(messages, "; "))
+}
+
+func writeReport(path string, report Report) error {
+	if report.Problems == nil {
+		report.Problems = []Problem{}
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// fileOptions say where the files a prometheus config refers to are found
+type fileOptions struct {
+	// configDir is the directory the relative scrape_config_files globs are resolved against
+	configDir string
+	// root is the directory the files referenced by the scrape configs are looked up in instead of /. When it
+	// is set, all the referenced files are checked instead of only the ones the prometheus receiver checks.
+	root string
+}
+
+// validatePrometheusConfigFile reads and validates the prometheus config at path and its scrape config files,
+// and lints its scrape configs with l if it is not nil
+func validatePrometheusConfigFile(path string, files fileOptions, l *linter) []Problem {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []Problem{{Kind: problemInvalidConfig, Message: err.Error()}}
+	}
+	return checkPrometheusConfig(content, files, l)
+}
+
+// validatePrometheusConfig validates the prometheus config without linting it
+func validatePrometheusConfig(content []byte) []Problem {
+	return lintPrometheusConfig(content, nil)
+}
+
+// lintPrometheusConfig validates the prometheus config and lints it with l, with the scrape config files
+// resolved against the working directory
+func lintPrometheusConfig(content []byte, l *linter) []Problem {
+	return checkPrometheusConfig(content, fileOptions{}, l)
+}
+
+// checkPrometheusConfig checks the global section and every scrape config independently, including the ones
+// of the scrape config files, so that all the problems are reported at once instead of only the first one.
+// Each section is decoded strictly like Prometheus does. When a section has a problem, the lines it is on are
+// blanked and the section is decoded again to find the next problem. The scrape configs decoded without
+// problems are linted with l if it is not nil.
+func checkPrometheusConfig(content []byte, files fileOptions, l *linter) []Problem {
+	doc, problem := parseDocument(content, "the prometheus config")
+	if problem != nil {
+		return []Problem{*problem}
+	}
+	if doc == nil {
+		return nil
+	}
+
+	v := &configValidator{lines: splitLines(content), linter: l, files: files, jobs: map[string]jobLocation{}}
+	global := promconfig.DefaultGlobalConfig
+	knownSections := yamlFieldNames(reflect.TypeOf(promconfig.Config{}))
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch {
+		case unsupportedSections[key.Value]:
+			if value.Tag == "!!null" {
+				continue
+			}
+			v.add(Problem{Field: key.Value, Line: key.Line, Column: key.Column, Kind: problemUnsupported, Message: fmt.Sprintf("%s is not supported", key.Value)})
+		case !knownSections[key.Value]:
+			v.add(Problem{Field: key.Value, Line: key.Line, Column: key.Column, Kind: problemUnknownField, Message: fmt.Sprintf("field %s not found in the prometheus config", key.Value)})
+		case key.Value == "global":
+			if out, ok := v.decode(value, "global", "", func() interface{} { return &promconfig.GlobalConfig{} }); ok {
+				global = *out.(*promconfig.GlobalConfig)
+			}
+		}
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if key, value := doc.Content[i], doc.Content[i+1]; key.Value == "scrape_configs" {
+			v.validateScrapeConfigs(value, global)
+		}
+	}
+	// The scrape configs of the files come after the ones of the config, like Prometheus loads them
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if key, value := doc.Content[i], doc.Content[i+1]; key.Value == "scrape_config_files" {
+			v.validateScrapeConfigFiles(value, global)
+		}
+	}
+	return v.problems
+}
+
+// parseDocument returns the root mapping of a YAML document, or the problem found parsing it. It returns
+// neither for an empty document. name is what the document is, for the problems.
+func parseDocument(content []byte, name string) (*yamlv3.Node, *Problem) {
+	// yaml.v2 reports the line of syntax errors closer to where they are than yaml.v3
+	var syntax interface{}
+	if err := yaml.Unmarshal(content, &syntax); err != nil {
+		p := Problem{Kind: problemSyntax, Message: err.Error()}
+		if m := lineNumberPattern.FindStringSubmatch(err.Error()); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+		}
+		return nil, &p
+	}
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &root); err != nil {
+		return nil, &Problem{Kind: problemSyntax, Message: err.Error()}
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	doc := root.Content[0]
+	if doc.Kind != yamlv3.MappingNode {
+		return nil, &Problem{Line: doc.Line, Column: doc.Column, Kind: problemInvalidConfig, Message: name + " must be a mapping"}
+	}
+	return doc, nil
+}
+
+func splitLines(content []byte) []string {
+	return strings.Split(string(content), "\n")
+}
+
+type configValidator struct {
+	// file is the scrape config file validated, empty for the prometheus config
+	file     string
+	lines    []string
+	problems []Problem
+	linter   *linter
+	files    fileOptions
+	// jobs are where the scrape jobs seen so far are, shared with the validators of the scrape config files
+	jobs map[string]jobLocation
+}
+
+// jobLocation is where a scrape job was first found
+type jobLocation struct {
+	file string
+	line int
+}
+
+func (v *configValidator) add(p Problem) {
+	if p.File == "" {
+		p.File = v.file
+	}
+	v.problems = append(v.problems, p)
+}
+
+func (v *configValidator) validateScrapeConfigs(seq *yamlv3.Node, global promconfig.GlobalConfig) {
+	if seq.Kind != yamlv3.SequenceNode {
+		if seq.Tag != "!!null" {
+			v.add(Problem{Field: "scrape_configs", Line: seq.Line, Column: seq.Column, Kind: problemInvalidConfig, Message: "scrape_configs must be a list"})
+		}
+		return
+	}
+	for i, item := range seq.Content {
+		field := fmt.Sprintf("scrape_configs[%d]", i)
+		job := mappingValue(item, "job_name")
+		if job != "" {
+			if first, ok := v.jobs[job]; ok {
+				where := fmt.Sprintf("line %d", first.line)
+				if first.file != v.file {
+					where = fmt.Sprintf("line %d of the prometheus config", first.line)
+					if first.file != "" {
+						where = fmt.Sprintf("line %d of %q", first.line, first.file)
+					}
+				}
+				v.add(Problem{Job: job, Field: field, Line: item.Line, Column: item.Column, Kind: problemDuplicateJob, Message: fmt.Sprintf("found multiple scrape configs with job name %q, first found at %s", job, where)})
+				continue
+			}
+			v.jobs[job] = jobLocation{file: v.file, line: item.Line}
+		}
+
+		out, ok := v.decode(item, field, job, func() interface{} { return &promconfig.ScrapeConfig{} })
+		if !ok {
+			continue
+		}
+		sc := out.(*promconfig.ScrapeConfig)
+		if err := sc.Validate(global); err != nil {
+			p := Problem{Job: job, Field: field, Line: item.Line, Column: item.Column, Kind: problemInvalidConfig, Message: err.Error()}
+			if n := mappingValueNode(item, "scrape_timeout"); n != nil && strings.Contains(err.Error(), "scrape timeout") {
+				p.Field, p.Line, p.Column = field+".scrape_timeout", n.Line, n.Column
+			}
+			v.add(p)
+		}
+		v.checkFiles(item, field, job, sc)
+		if v.linter != nil {
+			v.linter.lint(lintScrapeConfig{file: v.file, node: item, field: field, job: job, sc: sc})
+		}
+	}
+}
+
+// checkFiles reports the credential and TLS files of the scrape config and its kubernetes service discovery
+// configs that do not exist, as the prometheus receiver rejects them. With a root directory, the files are
+// looked up in it and the files the receiver does not check, such as the CA file, are reported too.
+func (v *configValidator) checkFiles(item *yamlv3.Node, field, job string, sc *promconfig.ScrapeConfig) {
+	files := httpClientConfigFiles(sc.HTTPClientConfig)
+	for _, c := range sc.ServiceDiscoveryConfigs {
+		if c, ok := c.(*kubernetes.SDConfig); ok {
+			files = append(files, httpClientConfigFiles(c.HTTPClientConfig)...)
+			if c.KubeConfig != "" {
+				files = append(files, configFile{description: "kubeconfig file", path: c.KubeConfig})
+			}
+		}
+	}
+	for _, file := range files {
+		path := file.path
+		if v.files.root != "" {
+			path = filepath.Join(v.files.root, path)
+		} else if !file.checkedByReceiver {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			p := Problem{Job: job, Field: field, Line: item.Line, Column: item.Column, Kind: problemMissingFile, Message: fmt.Sprintf("error checking %s %q: %v", file.description, file.path, err)}
+			if n, path := findScalar(item, field, func(_ string, n *yamlv3.Node) bool { return n.Value == file.path }); n != nil {
+				p.Field, p.Line, p.Column = path, n.Line, n.Column
+			}
+			v.add(p)
+		}
+	}
+}
+
+// decode decodes the lines of node strictly into the value returned by newOut. Every problem found is added,
+// and the decoded value is returned if there were none.
+func (v *configValidator) decode(node *yamlv3.Node, field, job string, newOut func() interface{}) (interface{}, bool) {
+	snippet := v.snippet(node)
+	blanked := map[int]bool{}
+	found := 0
+	for found < maxProblemsPerSection {
+		out := newOut()
+		err := yaml.UnmarshalStrict([]byte(strings.Join(snippet, "\n")), out)
+		if err == nil {
+			return out, found == 0
+		}
+
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			progress := false
+			for _, msg := range typeErr.Errors {
+				p := Problem{Job: job, Field: field, Line: node.Line, Column: node.Column, Kind: problemInvalidConfig, Message: msg}
+				if m := unknownFieldPattern.FindStringSubmatch(msg); m != nil {
+					p.Kind = problemUnknownField
+					p.Message = fmt.Sprintf("field %s not found", m[2])
+				} else if m := typeErrorPattern.FindStringSubmatch(msg); m != nil {
+					p.Message = m[2]
+				}
+				if m := lineNumberPattern.FindStringSubmatch(msg); m != nil {
+					p.Line, _ = strconv.Atoi(m[1])
+					p.Column = 0
+					if n, path := findKey(node, field, p.Line); n != nil {
+						p.Field, p.Column = path, n.Column
+					}
+					if !blanked[p.Line] {
+						blankBlock(snippet, p.Line, blanked)
+						progress = true
+					}
+				}
+				v.add(p)
+				found++
+			}
+			if !progress {
+				return nil, false
+			}
+			continue
+		}
+
+		p := Problem{Job: job, Field: field, Line: node.Line, Column: node.Column, Kind: classifyError(err), Message: err.Error()}
+		n, path := locateError(node, field, err, blanked)
+		if n == nil {
+			v.add(p)
+			return nil, false
+		}
+		p.Field, p.Line, p.Column = path, n.Line, n.Column
+		v.add(p)
+		found++
+		// Blanking only the value would leave a list item such as a relabel config incomplete
+		blankBlock(snippet, enclosingItemLine(node, n), blanked)
+	}
+	return nil, false
+}
+
+// snippet returns the lines of the config with everything but node blanked, so that the line numbers in the
+// errors returned when decoding it are those of the config. The dash of a sequence item is blanked as well.
+func (v *configValidator) snippet(node *yamlv3.Node) []string {
+	end := lastLine(node)
+	// Multi-line values of the last key end after the line they start on
+	for end < len(v.lines) && (strings.TrimSpace(v.lines[end]) == "" || indentation(v.lines[end]) >= node.Column-1) && node.Column > 1 {
+		end++
+	}
+	snippet := make([]string, len(v.lines))
+	copy(snippet[node.Line-1:end], v.lines[node.Line-1:end])
+	if node.Style&yamlv3.FlowStyle == 0 {
+		line := []byte(snippet[node.Line-1])
+		for i := node.Column - 2; i >= 0 && i < len(line); i-- {
+			if line[i] == '-' {
+				line[i] = ' '
+				break
+			}
+			if line[i] != ' ' {
+				break
+			}
+		}
+		snippet[node.Line-1] = string(line)
+	}
+	return snippet
+}
+
+// lastLine returns the line the last descendant of node starts on
+func lastLine(node *yamlv3.Node) int {
+	last := node.Line
+	for _, c := range node.Content {
+		if l := lastLine(c); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// blankBlock blanks the line and the lines after it that are indented further, which is the value of a key
+// or the rest of a sequence item.
+func blankBlock(lines []string, line int, blanked map[int]bool) {
+	if line < 1 || line > len(lines) {
+		return
+	}
+	indent := indentation(lines[line-1])
+	lines[line-1] = ""
+	blanked[line] = true
+	for i := line; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentation(lines[i]) <= indent {
+			break
+		}
+		lines[i] = ""
+		blanked[i+1] = true
+	}
+}
+
+// classifyError returns the kind of problem of an error returned by a custom YAML unmarshaler
+func classifyError(err error) string {
+	switch msg := err.Error(); {
+	case strings.HasPrefix(msg, "error parsing regexp"):
+		return problemInvalidRegex
+	case strings.Contains(msg, "not a valid duration string"), strings.Contains(msg, "in duration"):
+		return problemInvalidDuration
+	default:
+		return problemInvalidConfig
+	}
+}
+
+// locateError finds the value node an error returned by a custom YAML unmarshaler is about: the first regex
+// that does not compile for regex errors, otherwise the first value quoted in the error message.
+func locateError(node *yamlv3.Node, field string, err error, blanked map[int]bool) (*yamlv3.Node, string) {
+	if classifyError(err) == problemInvalidRegex {
+		return findScalar(node, field, func(key string, n *yamlv3.Node) bool {
+			if key != "regex" || blanked[n.Line] {
+				return false
+			}
+			_, err := relabel.NewRegexp(n.Value)
+			return err != nil
+		})
+	}
+	for _, m := range quotedValuePattern.FindAllStringSubmatch(err.Error(), -1) {
+		value := m[1] + m[2]
+		if n, path := findScalar(node, field, func(_ string, n *yamlv3.Node) bool { return !blanked[n.Line] && n.Value == value }); n != nil {
+			return n, path
+		}
+	}
+	return nil, ""
+}
+
+// findScalar returns the first scalar value under node, in document order, that match accepts, along with
+// its field path.
+func findScalar(node *yamlv3.Node, path string, match func(key string, n *yamlv3.Node) bool) (*yamlv3.Node, string) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinField(path, key.Value)
+			if value.Kind == yamlv3.ScalarNode {
+				if match(key.Value, value) {
+					return value, childPath
+				}
+				continue
+			}
+			if n, p := findScalar(value, childPath, match); n != nil {
+				return n, p
+			}
+		}
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if item.Kind == yamlv3.ScalarNode {
+				if match("", item) {
+					return item, itemPath
+				}
+				continue
+			}
+			if n, p := findScalar(item, itemPath, match); n != nil {
+				return n, p
+			}
+		}
+	}
+	return nil, ""
+}
+
+// enclosingItemLine returns the line of the innermost list item under root that contains target, or the
+// line of target if it is not in a list item.
+func enclosingItemLine(root, target *yamlv3.Node) int {
+	var find func(node *yamlv3.Node, itemLine int) int
+	find = func(node *yamlv3.Node, itemLine int) int {
+		if node == target {
+			return itemLine
+		}
+		for _, c := range node.Content {
+			childLine := itemLine
+			if node.Kind == yamlv3.SequenceNode && c.Style&yamlv3.FlowStyle == 0 && node.Style&yamlv3.FlowStyle == 0 {
+				childLine = c.Line
+			}
+			if line := find(c, childLine); line != 0 {
+				return line
+			}
+		}
+		return 0
+	}
+	if line := find(root, target.Line); line != 0 {
+		return line
+	}
+	return target.Line
+}
+
+// findKey returns the key node under node on the given line, along with its field path
+func findKey(node *yamlv3.Node, path string, line int) (*yamlv3.Node, string) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Line == line {
+				return key, joinField(path, key.Value)
+			}
+			if n, p := findKey(value, joinField(path, key.Value), line); n != nil {
+				return n, p
+			}
+		}
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			if n, p := findKey(item, fmt.Sprintf("%s[%d]", path, i), line); n != nil {
+				return n, p
+			}
+		}
+	}
+	return nil, ""
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mappingValueNode returns the value of key in a mapping node, or nil
+func mappingValueNode(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the scalar value of key in a mapping node, or an empty string
+func mappingValue(node *yamlv3.Node, key string) string {
+	if n := mappingValueNode(node, key); n != nil && n.Kind == yamlv3.ScalarNode {
+		return n.Value
+	}
+	return ""
+}
+
+// yamlFieldNames returns the YAML keys of the fields of a struct type
+func yamlFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+type configFile struct {
+	description       string
+	path              string
+	checkedByReceiver bool
+}
+
+// httpClientConfigFiles returns the files referenced by an HTTP client config. The bearer token file is the
+// authorization credentials file once the config is validated.
+func httpClientConfigFiles(cfg commonconfig.HTTPClientConfig) []configFile {
+	var files []configFile
+	add := func(description, path string, checkedByReceiver bool) {
+		if path != "" {
+			files = append(files, configFile{description, path, checkedByReceiver})
+		}
+	}
+	if cfg.Authorization != nil {
+		add("authorization credentials file", cfg.Authorization.CredentialsFile, true)
+	}
+	add("client cert file", cfg.TLSConfig.CertFile, true)
+	add("client key file", cfg.TLSConfig.KeyFile, true)
+	add("CA file", cfg.TLSConfig.CAFile, false)
+	if cfg.BasicAuth != nil {
+		add("basic auth username file", cfg.BasicAuth.UsernameFile, false)
+		add("basic auth password file", cfg.BasicAuth.PasswordFile, false)
+	}
+	if cfg.OAuth2 != nil {
+		add("oauth2 client secret file", cfg.OAuth2.ClientSecretFile, false)
+	}
+	return files
+}
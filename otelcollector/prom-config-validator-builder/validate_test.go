@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePrometheusConfigCollectsEveryProblem(t *testing.T) {
+	config := `global:
+  scrape_interval: 1x
+scrape_configs:
+- job_name: app
+  scrape_interval: 30s
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_pod_label_app]
+    regex: (a
+    action: keep
+  - source_labels: [__meta_kubernetes_pod_label_team]
+    regex: b[
+    action: keep
+- job_name: other
+  scrape_intervall: 30s
+  static_configs:
+  - targets: [localhost:9090]
+- job_name: app
+  static_configs:
+  - targets: [localhost:9091]
+`
+	problems := validatePrometheusConfig([]byte(config))
+	require.Len(t, problems, 5, "%v", problems)
+
+	assert.Equal(t, problemInvalidDuration, problems[0].Kind)
+	assert.Equal(t, "global.scrape_interval", problems[0].Field)
+	assert.Equal(t, 2, problems[0].Line)
+
+	assert.Equal(t, Problem{Job: "app", Field: "scrape_configs[0].relabel_configs[0].regex", Line: 8, Column: 12, Kind: problemInvalidRegex, Message: problems[1].Message}, problems[1])
+	assert.Equal(t, Problem{Job: "app", Field: "scrape_configs[0].relabel_configs[1].regex", Line: 11, Column: 12, Kind: problemInvalidRegex, Message: problems[2].Message}, problems[2])
+
+	assert.Equal(t, Problem{Job: "other", Field: "scrape_configs[1].scrape_intervall", Line: 14, Column: 3, Kind: problemUnknownField, Message: "field scrape_intervall not found"}, problems[3])
+
+	assert.Equal(t, problemDuplicateJob, problems[4].Kind)
+	assert.Equal(t, "app", problems[4].Job)
+	assert.Equal(t, 17, problems[4].Line)
+}
+
+func TestValidatePrometheusConfigMissingFiles(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte("cert"), 0600))
+	config := `scrape_configs:
+- job_name: secure
+  authorization:
+    credentials_file: /does/not/exist/token
+  tls_config:
+    cert_file: ` + certFile + `
+    key_file: /does/not/exist/key.pem
+  static_configs:
+  - targets: [localhost:9090]
+`
+	problems := validatePrometheusConfig([]byte(config))
+	require.Len(t, problems, 2, "%v", problems)
+	assert.Equal(t, problemMissingFile, problems[0].Kind)
+	assert.Equal(t, "secure", problems[0].Job)
+	assert.Equal(t, "scrape_configs[0].authorization.credentials_file", problems[0].Field)
+	assert.Equal(t, 4, problems[0].Line)
+	assert.Equal(t, "scrape_configs[0].tls_config.key_file", problems[1].Field)
+	assert.Equal(t, 7, problems[1].Line)
+}
+
+func TestValidatePrometheusConfigSections(t *testing.T) {
+	problems := validatePrometheusConfig([]byte("scrape_configs:\n- job_name: a\n  static_configs:\n  - targets: [x:1]\n   bad: indent\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, problemSyntax, problems[0].Kind)
+	assert.Equal(t, 4, problems[0].Line)
+
+	problems = validatePrometheusConfig([]byte("remote_write:\n- url: http://x\nscrape_config:\n- job_name: a\n"))
+	require.Len(t, problems, 2)
+	assert.Equal(t, Problem{Field: "remote_write", Line: 1, Column: 1, Kind: problemUnsupported, Message: "remote_write is not supported"}, problems[0])
+	assert.Equal(t, problemUnknownField, problems[1].Kind)
+	assert.Equal(t, 3, problems[1].Line)
+
+	problems = validatePrometheusConfig([]byte("scrape_configs:\n- job_name: a\n  scrape_interval: 10s\n  scrape_timeout: 20s\n  static_configs:\n  - targets: [x:1]\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, "scrape_configs[0].scrape_timeout", problems[0].Field)
+	assert.Equal(t, 4, problems[0].Line)
+
+	assert.Empty(t, validatePrometheusConfig([]byte("scrape_configs:\n- job_name: a\n  static_configs:\n  - targets: [x:1]\n")))
+}
@@ -0,0 +1,56 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver"
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal/metadata"
+)
+
+// startCardinalityReport creates the cardinality tracker, computes its report periodically and serves it on the
+// configured endpoint until ctx is done.
+func (r *pReceiver) startCardinalityReport(ctx context.Context, host component.Host) error {
+	telemetryBuilder, err := metadata.NewTelemetryBuilder(r.settings.TelemetrySettings)
+	if err != nil {
+		return err
+	}
+	r.cardinality = internal.NewCardinalityTracker(r.cfg.Cardinality, gcInterval(r.cfg.PrometheusConfig), r.settings.Logger, telemetryBuilder)
+	r.cardinality.Start(ctx)
+
+	endpoint := r.cfg.Cardinality.Endpoint
+	if endpoint == "" {
+		endpoint = internal.DefaultCardinalityEndpoint
+	}
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(internal.CardinalityPath, r.cardinality)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Minute * readTimeoutMinutes,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	go func() {
+		r.settings.Logger.Info("Serving cardinality report", zap.String("endpoint", listener.Addr().String()), zap.String("path", internal.CardinalityPath))
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			r.settings.Logger.Error("Cardinality report server failed", zap.Error(err))
+			componentstatus.ReportStatus(host, componentstatus.NewFatalErrorEvent(err))
+		}
+	}()
+	return nil
+}
@@ -13,9 +13,11 @@ import (
 	commonconfig "github.com/prometheus/common/config"
 	promconfig "github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/confmap"
 	"gopkg.in/yaml.v2"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/targetallocator"
 )
 
@@ -36,6 +38,64 @@ type Config struct {
 	ReportExtraScrapeMetrics bool `mapstructure:"report_extra_scrape_metrics"`
 
 	TargetAllocator *targetallocator.Config `mapstructure:"target_allocator"`
+
+	// Exemplars controls forwarding of the exemplars returned by scrapes, globally and per job.
+	Exemplars internal.ExemplarsConfig `mapstructure:"exemplars"`
+
+	// Web configures the embedded Prometheus web handler serving the UI and API.
+	Web WebConfig `mapstructure:"web"`
+
+	// LabelsFile adds labels read from a file on disk to every series or resource.
+	// The file is watched, changes apply to the next scrape.
+	LabelsFile *internal.LabelsFileConfig `mapstructure:"labels_file"`
+
+	// Cardinality enables a periodic report of the metric names and jobs with the most series,
+	// served as JSON on a debug endpoint and recorded as receiver telemetry.
+	Cardinality *internal.CardinalityConfig `mapstructure:"cardinality"`
+}
+
+// WebConfig configures the embedded Prometheus web handler.
+type WebConfig struct {
+	// Disabled stops the receiver from serving the Prometheus web UI and API.
+	Disabled bool `mapstructure:"disabled"`
+	// Endpoint is the address the web handler listens on. Defaults to ":9090".
+	Endpoint string `mapstructure:"endpoint"`
+	// RoutePrefix is the path prefix of all web routes. Defaults to "/".
+	RoutePrefix string `mapstructure:"route_prefix"`
+	// MaxConnections is the maximum number of simultaneous connections. Defaults to 512.
+	MaxConnections int `mapstructure:"max_connections"`
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+	// BasicAuthUsers maps user names to bcrypt hashed passwords. When set, every request
+	// must authenticate as one of the users.
+	BasicAuthUsers map[string]configopaque.String `mapstructure:"basic_auth_users"`
+	// APIOnly serves only the /api/v1/targets and /api/v1/status/config endpoints instead of
+	// the full UI and API.
+	APIOnly bool `mapstructure:"api_only"`
+}
+
+// Validate checks the web handler configuration is valid.
+func (cfg *WebConfig) Validate() error {
+	if cfg.Disabled {
+		return nil
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return errors.New("web: tls_cert_file and tls_key_file must be set together")
+	}
+	if err := checkFile(cfg.TLSCertFile); err != nil {
+		return fmt.Errorf("web: error checking tls cert file %q: %w", cfg.TLSCertFile, err)
+	}
+	if err := checkFile(cfg.TLSKeyFile); err != nil {
+		return fmt.Errorf("web: error checking tls key file %q: %w", cfg.TLSKeyFile, err)
+	}
+	if cfg.RoutePrefix != "" && !strings.HasPrefix(cfg.RoutePrefix, "/") {
+		return fmt.Errorf("web: route_prefix %q must start with \"/\"", cfg.RoutePrefix)
+	}
+	if cfg.MaxConnections < 0 {
+		return fmt.Errorf("web: max_connections must not be negative, got %d", cfg.MaxConnections)
+	}
+	return nil
 }
 
 // Validate checks the receiver configuration is valid.
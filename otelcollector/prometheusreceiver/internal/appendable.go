@@ -24,9 +24,13 @@ type appendable struct {
 	trimSuffixes           bool
 	startTimeMetricRegex   *regexp.Regexp
 	externalLabels         labels.Labels
+	exemplars              ExemplarsConfig
+	fileLabels             *FileLabels
+	cardinality            *CardinalityTracker
 
-	settings receiver.Settings
-	obsrecv  *receiverhelper.ObsReport
+	settings         receiver.Settings
+	obsrecv          *receiverhelper.ObsReport
+	telemetryBuilder *receiverTelemetry
 }
 
 // NewAppendable returns a storage.Appendable instance that emits metrics to the sink.
@@ -39,7 +43,10 @@ func NewAppendable(
 	useCreatedMetric bool,
 	enableNativeHistograms bool,
 	externalLabels labels.Labels,
-	trimSuffixes bool) (storage.Appendable, error) {
+	trimSuffixes bool,
+	exemplars ExemplarsConfig,
+	fileLabels *FileLabels,
+	cardinality *CardinalityTracker) (storage.Appendable, error) {
 	var metricAdjuster MetricsAdjuster
 	if !useStartTimeMetric {
 		metricAdjuster = NewInitialPointAdjuster(set.Logger, gcInterval, useCreatedMetric)
@@ -52,6 +59,11 @@ func NewAppendable(
 		return nil, err
 	}
 
+	telemetryBuilder, err := newReceiverTelemetry(set.TelemetrySettings)
+	if err != nil {
+		return nil, err
+	}
+
 	return &appendable{
 		sink:                   sink,
 		settings:               set,
@@ -62,9 +74,18 @@ func NewAppendable(
 		externalLabels:         externalLabels,
 		obsrecv:                obsrecv,
 		trimSuffixes:           trimSuffixes,
+		exemplars:              exemplars,
+		fileLabels:             fileLabels,
+		cardinality:            cardinality,
+		telemetryBuilder:       telemetryBuilder,
 	}, nil
 }
 
 func (o *appendable) Appender(ctx context.Context) storage.Appender {
-	return newTransaction(ctx, o.metricAdjuster, o.sink, o.externalLabels, o.settings, o.obsrecv, o.trimSuffixes, o.enableNativeHistograms)
+	// File labels are read once per scrape so that all series of a scrape share them.
+	var fileLabels fileLabelSet
+	if o.fileLabels != nil {
+		fileLabels = fileLabelSet{labels: o.fileLabels.Labels(), toResource: o.fileLabels.toResource}
+	}
+	return newTransaction(ctx, o.metricAdjuster, o.sink, o.externalLabels, fileLabels, o.settings, o.obsrecv, o.trimSuffixes, o.enableNativeHistograms, o.exemplars, o.telemetryBuilder, o.cardinality)
 }
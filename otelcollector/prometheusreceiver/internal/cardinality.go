@@ -0,0 +1,237 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	mdata "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal/metadata"
+)
+
+const (
+	defaultCardinalityTopN     = 20
+	defaultCardinalityInterval = time.Minute
+	// DefaultCardinalityEndpoint is the address the cardinality report is served on when no endpoint is configured.
+	DefaultCardinalityEndpoint = "localhost:9095"
+	// CardinalityPath is the path of the cardinality report on the debug endpoint.
+	CardinalityPath = "/debug/cardinality"
+)
+
+// CardinalityConfig configures the periodic report of the metric names and jobs with the most series.
+type CardinalityConfig struct {
+	// TopN is the number of metric names and jobs included in the report. Defaults to 20.
+	TopN int `mapstructure:"top_n"`
+	// Interval is how often the report is computed. Defaults to 1m.
+	Interval time.Duration `mapstructure:"interval"`
+	// Endpoint is the address the report is served on as JSON. Defaults to localhost:9095.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Validate checks the cardinality configuration is valid.
+func (cfg *CardinalityConfig) Validate() error {
+	if cfg.TopN < 0 {
+		return fmt.Errorf("cardinality: top_n must not be negative, got %d", cfg.TopN)
+	}
+	if cfg.Interval < 0 {
+		return fmt.Errorf("cardinality: interval must not be negative, got %s", cfg.Interval)
+	}
+	return nil
+}
+
+// CardinalityEntry is the number of series of a metric name or a job.
+type CardinalityEntry struct {
+	Name   string `json:"name"`
+	Series int    `json:"series"`
+}
+
+// CardinalityReport lists the metric names and jobs with the most series, as emitted on the last scrape of every target.
+type CardinalityReport struct {
+	Time        time.Time          `json:"time"`
+	TotalSeries int                `json:"total_series"`
+	Targets     int                `json:"targets"`
+	Metrics     []CardinalityEntry `json:"metrics"`
+	Jobs        []CardinalityEntry `json:"jobs"`
+}
+
+type targetSeries struct {
+	job     string
+	series  map[string]int
+	updated time.Time
+}
+
+// CardinalityTracker keeps the number of series per metric name emitted on the last scrape of every target,
+// and periodically reports the metric names and jobs with the most series.
+type CardinalityTracker struct {
+	topN             int
+	interval         time.Duration
+	staleAfter       time.Duration
+	logger           *zap.Logger
+	telemetryBuilder *mdata.TelemetryBuilder
+	now              func() time.Time
+
+	mu      sync.Mutex
+	targets map[resourceKey]*targetSeries
+	report  *CardinalityReport
+	// reported holds the metric names and jobs recorded in the self-metrics by the last report,
+	// so those that leave the top N are reset to zero.
+	reportedMetrics map[string]bool
+	reportedJobs    map[string]bool
+}
+
+// NewCardinalityTracker creates a tracker. Targets that were not scraped within staleAfter are left out of the report.
+func NewCardinalityTracker(cfg *CardinalityConfig, staleAfter time.Duration, logger *zap.Logger, telemetryBuilder *mdata.TelemetryBuilder) *CardinalityTracker {
+	topN := cfg.TopN
+	if topN == 0 {
+		topN = defaultCardinalityTopN
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultCardinalityInterval
+	}
+	return &CardinalityTracker{
+		topN:             topN,
+		interval:         interval,
+		staleAfter:       staleAfter,
+		logger:           logger,
+		telemetryBuilder: telemetryBuilder,
+		now:              time.Now,
+		targets:          map[resourceKey]*targetSeries{},
+		reportedMetrics:  map[string]bool{},
+		reportedJobs:     map[string]bool{},
+	}
+}
+
+// Start computes a report every interval until ctx is done.
+func (c *CardinalityTracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Update(ctx)
+			}
+		}
+	}()
+}
+
+// record replaces the series per metric name of a target with those of its latest scrape.
+func (c *CardinalityTracker) record(key resourceKey, job string, series map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets[key] = &targetSeries{job: job, series: series, updated: c.now()}
+}
+
+// Update computes a new report and records it in the self-metrics.
+func (c *CardinalityTracker) Update(ctx context.Context) CardinalityReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	report := CardinalityReport{Time: now}
+	metrics, jobs := map[string]int{}, map[string]int{}
+	for key, target := range c.targets {
+		if now.Sub(target.updated) > c.staleAfter {
+			delete(c.targets, key)
+			continue
+		}
+		report.Targets++
+		for name, n := range target.series {
+			metrics[name] += n
+			jobs[target.job] += n
+			report.TotalSeries += n
+		}
+	}
+	report.Metrics = topCardinalityEntries(metrics, c.topN)
+	report.Jobs = topCardinalityEntries(jobs, c.topN)
+	c.report = &report
+
+	if c.telemetryBuilder != nil {
+		c.telemetryBuilder.ReceiverPrometheusActiveSeries.Record(ctx, int64(report.TotalSeries))
+		c.reportedMetrics = recordTopSeries(ctx, c.telemetryBuilder.ReceiverPrometheusTopMetricSeries, "metric_name", report.Metrics, c.reportedMetrics)
+		c.reportedJobs = recordTopSeries(ctx, c.telemetryBuilder.ReceiverPrometheusTopJobSeries, "job", report.Jobs, c.reportedJobs)
+	}
+	c.logger.Debug("Computed cardinality report",
+		zap.Int("total_series", report.TotalSeries),
+		zap.Int("targets", report.Targets))
+	return report
+}
+
+// Report returns the last report, computing one if none was computed yet.
+func (c *CardinalityTracker) Report(ctx context.Context) CardinalityReport {
+	c.mu.Lock()
+	report := c.report
+	c.mu.Unlock()
+	if report == nil {
+		return c.Update(ctx)
+	}
+	return *report
+}
+
+// ServeHTTP serves the last report as JSON. The optional limit query parameter returns fewer entries.
+func (c *CardinalityTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	report := c.Report(req.Context())
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", limit), http.StatusBadRequest)
+			return
+		}
+		report.Metrics = report.Metrics[:min(n, len(report.Metrics))]
+		report.Jobs = report.Jobs[:min(n, len(report.Jobs))]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		c.logger.Warn("Failed to write cardinality report", zap.Error(err))
+	}
+}
+
+// topCardinalityEntries returns the n entries with the most series, ties ordered by name.
+func topCardinalityEntries(series map[string]int, n int) []CardinalityEntry {
+	entries := make([]CardinalityEntry, 0, len(series))
+	for name, count := range series {
+		entries = append(entries, CardinalityEntry{Name: name, Series: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Series != entries[j].Series {
+			return entries[i].Series > entries[j].Series
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// recordTopSeries records the entries on gauge and resets those of the previous report that are no longer
+// in the top N to zero. It returns the names recorded with a non-zero value.
+func recordTopSeries(ctx context.Context, gauge metric.Int64Gauge, attr string, entries []CardinalityEntry, previous map[string]bool) map[string]bool {
+	current := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		gauge.Record(ctx, int64(e.Series), metric.WithAttributes(attribute.String(attr, e.Name)))
+		current[e.Name] = true
+	}
+	for name := range previous {
+		if !current[name] {
+			gauge.Record(ctx, 0, metric.WithAttributes(attribute.String(attr, name)))
+		}
+	}
+	return current
+}
@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+
+	mdata "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal/metadata"
+)
+
+func TestCardinalityConfigValidate(t *testing.T) {
+	assert.NoError(t, (&CardinalityConfig{}).Validate())
+	assert.NoError(t, (&CardinalityConfig{TopN: 5, Interval: time.Minute}).Validate())
+	assert.Error(t, (&CardinalityConfig{TopN: -1}).Validate())
+	assert.Error(t, (&CardinalityConfig{Interval: -time.Second}).Validate())
+}
+
+func TestCardinalityTrackerFromTransaction(t *testing.T) {
+	tracker := NewCardinalityTracker(&CardinalityConfig{}, time.Minute, zap.NewNop(), nil)
+	tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, consumertest.NewNop(), labels.EmptyLabels(), fileLabelSet{}, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, nopTelemetryBuilder(t), tracker)
+
+	target := []string{model.JobLabel, "job", model.InstanceLabel, "instance"}
+	samples := []labels.Labels{
+		labels.FromStrings(append([]string{model.MetricNameLabel, "counter_test", "foo", "bar"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "counter_test", "foo", "baz"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "counter_test", "foo", "qux"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "gauge_test"}, target...)...),
+	}
+	for _, ls := range samples {
+		_, err := tr.Append(0, ls, ts, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tr.Commit())
+
+	report := tracker.Update(context.Background())
+	assert.Equal(t, 4, report.TotalSeries)
+	assert.Equal(t, 1, report.Targets)
+	assert.Equal(t, []CardinalityEntry{{Name: "counter_test", Series: 3}, {Name: "gauge_test", Series: 1}}, report.Metrics)
+	assert.Equal(t, []CardinalityEntry{{Name: "job", Series: 4}}, report.Jobs)
+}
+
+func TestCardinalityTrackerReport(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { require.NoError(t, provider.Shutdown(context.Background())) }()
+	set := componenttest.NewNopTelemetrySettings()
+	set.LeveledMeterProvider = func(configtelemetry.Level) metric.MeterProvider { return provider }
+	telemetryBuilder, err := mdata.NewTelemetryBuilder(set)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	tracker := NewCardinalityTracker(&CardinalityConfig{TopN: 2}, time.Minute, zap.NewNop(), telemetryBuilder)
+	tracker.now = func() time.Time { return now }
+
+	tracker.record(resourceKey{job: "kubelet", instance: "node-1"}, "kubelet", map[string]int{"a": 10, "b": 5})
+	tracker.record(resourceKey{job: "kubelet", instance: "node-2"}, "kubelet", map[string]int{"a": 10, "c": 1})
+	tracker.record(resourceKey{job: "app", instance: "pod-1"}, "app", map[string]int{"d": 30})
+	tracker.record(resourceKey{job: "other", instance: "pod-2"}, "other", map[string]int{"e": 1})
+
+	report := tracker.Update(context.Background())
+	assert.Equal(t, 57, report.TotalSeries)
+	assert.Equal(t, 4, report.Targets)
+	assert.Equal(t, []CardinalityEntry{{Name: "d", Series: 30}, {Name: "a", Series: 20}}, report.Metrics)
+	assert.Equal(t, []CardinalityEntry{{Name: "app", Series: 30}, {Name: "kubelet", Series: 26}}, report.Jobs)
+
+	// the app target stops being scraped and is left out once stale
+	now = now.Add(2 * time.Minute)
+	tracker.record(resourceKey{job: "kubelet", instance: "node-1"}, "kubelet", map[string]int{"a": 10, "b": 5})
+	tracker.record(resourceKey{job: "kubelet", instance: "node-2"}, "kubelet", map[string]int{"a": 10, "c": 1})
+	tracker.record(resourceKey{job: "other", instance: "pod-2"}, "other", map[string]int{"e": 1})
+	report = tracker.Update(context.Background())
+	assert.Equal(t, 27, report.TotalSeries)
+	assert.Equal(t, []CardinalityEntry{{Name: "a", Series: 20}, {Name: "b", Series: 5}}, report.Metrics)
+	assert.Equal(t, []CardinalityEntry{{Name: "kubelet", Series: 26}, {Name: "other", Series: 1}}, report.Jobs)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	got := map[string]map[string]int64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		values := map[string]int64{}
+		for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+			var name string
+			for _, kv := range dp.Attributes.ToSlice() {
+				name = kv.Value.AsString()
+			}
+			values[name] = dp.Value
+		}
+		got[m.Name] = values
+	}
+	assert.Equal(t, map[string]int64{"": 27}, got["otelcol_receiver_prometheus_active_series"])
+	// d left the top N and is reset to zero
+	assert.Equal(t, map[string]int64{"a": 20, "b": 5, "d": 0}, got["otelcol_receiver_prometheus_top_metric_series"])
+	assert.Equal(t, map[string]int64{"kubelet": 26, "other": 1, "app": 0}, got["otelcol_receiver_prometheus_top_job_series"])
+}
+
+func TestCardinalityTrackerServeHTTP(t *testing.T) {
+	tracker := NewCardinalityTracker(&CardinalityConfig{}, time.Minute, zap.NewNop(), nil)
+	tracker.record(resourceKey{job: "kubelet", instance: "node-1"}, "kubelet", map[string]int{"a": 10, "b": 5})
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CardinalityPath+"?limit=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report CardinalityReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 15, report.TotalSeries)
+	assert.Equal(t, []CardinalityEntry{{Name: "a", Series: 10}}, report.Metrics)
+	assert.Equal(t, []CardinalityEntry{{Name: "kubelet", Series: 15}}, report.Jobs)
+
+	rec = httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CardinalityPath+"?limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestTopCardinalityEntries(t *testing.T) {
+	got := topCardinalityEntries(map[string]int{"b": 2, "a": 2, "c": 3}, 5)
+	assert.Equal(t, []CardinalityEntry{{Name: "c", Series: 3}, {Name: "a", Series: 2}, {Name: "b", Series: 2}}, got)
+	assert.Empty(t, topCardinalityEntries(nil, 5))
+	assert.Len(t, topCardinalityEntries(map[string]int{"a": 1, "b": 1}, 1), 1)
+}
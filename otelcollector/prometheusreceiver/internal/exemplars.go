@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheus"
+)
+
+const (
+	// traceIDHexLen and spanIDHexLen are the hex encoded lengths of W3C trace and span IDs.
+	traceIDHexLen = 32
+	spanIDHexLen  = 16
+
+	exemplarDropReasonLimit          = "limit"
+	exemplarDropReasonInvalidTraceID = "invalid_trace_id"
+	exemplarDropReasonInvalidSpanID  = "invalid_span_id"
+)
+
+// ExemplarsConfig controls which exemplars returned by a scrape are forwarded.
+type ExemplarsConfig struct {
+	// Disabled drops all exemplars for jobs that have no entry in Jobs.
+	Disabled bool `mapstructure:"disabled"`
+	// MaxPerSeries caps the number of exemplars kept for a single series on each scrape.
+	// Zero means no limit.
+	MaxPerSeries int `mapstructure:"max_per_series"`
+	// ValidateTraceIDs drops exemplars whose trace_id or span_id label is not a
+	// hex string of exactly 32 or 16 characters respectively.
+	ValidateTraceIDs bool `mapstructure:"validate_trace_ids"`
+	// Jobs overrides Disabled and MaxPerSeries for individual scrape jobs, keyed by job_name.
+	Jobs map[string]ExemplarsJobConfig `mapstructure:"jobs"`
+}
+
+// ExemplarsJobConfig holds the exemplar settings of a single scrape job.
+type ExemplarsJobConfig struct {
+	Disabled     bool `mapstructure:"disabled"`
+	MaxPerSeries int  `mapstructure:"max_per_series"`
+}
+
+// Validate checks the exemplar configuration is valid.
+func (cfg *ExemplarsConfig) Validate() error {
+	var errs error
+	if cfg.MaxPerSeries < 0 {
+		errs = errors.Join(errs, fmt.Errorf("exemplars: max_per_series must not be negative, got %d", cfg.MaxPerSeries))
+	}
+	for job, jobCfg := range cfg.Jobs {
+		if jobCfg.MaxPerSeries < 0 {
+			errs = errors.Join(errs, fmt.Errorf("exemplars: max_per_series for job %q must not be negative, got %d", job, jobCfg.MaxPerSeries))
+		}
+	}
+	return errs
+}
+
+// forJob returns whether exemplars are forwarded for the given job and how many are kept per series.
+func (cfg *ExemplarsConfig) forJob(job string) (bool, int) {
+	if jobCfg, ok := cfg.Jobs[job]; ok {
+		return !jobCfg.Disabled, jobCfg.MaxPerSeries
+	}
+	return !cfg.Disabled, cfg.MaxPerSeries
+}
+
+// invalidTraceContextReason returns the drop reason for an exemplar whose trace_id or
+// span_id label is malformed, or an empty string if both are absent or well-formed.
+func invalidTraceContextReason(e exemplar.Exemplar) string {
+	var reason string
+	e.Labels.Range(func(lb labels.Label) {
+		if reason != "" || lb.Value == "" {
+			return
+		}
+		switch strings.ToLower(lb.Name) {
+		case prometheus.ExemplarTraceIDKey:
+			if !isHexOfLen(lb.Value, traceIDHexLen) {
+				reason = exemplarDropReasonInvalidTraceID
+			}
+		case prometheus.ExemplarSpanIDKey:
+			if !isHexOfLen(lb.Value, spanIDHexLen) {
+				reason = exemplarDropReasonInvalidSpanID
+			}
+		}
+	})
+	return reason
+}
+
+func isHexOfLen(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
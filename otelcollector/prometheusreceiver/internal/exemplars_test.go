@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+func TestExemplarsConfigForJob(t *testing.T) {
+	cfg := ExemplarsConfig{
+		MaxPerSeries: 5,
+		Jobs: map[string]ExemplarsJobConfig{
+			"noisy":  {Disabled: true},
+			"capped": {MaxPerSeries: 1},
+		},
+	}
+
+	enabled, limit := cfg.forJob("other")
+	assert.True(t, enabled)
+	assert.Equal(t, 5, limit)
+
+	enabled, _ = cfg.forJob("noisy")
+	assert.False(t, enabled)
+
+	enabled, limit = cfg.forJob("capped")
+	assert.True(t, enabled)
+	assert.Equal(t, 1, limit)
+
+	cfg.Disabled = true
+	enabled, _ = cfg.forJob("other")
+	assert.False(t, enabled)
+	enabled, _ = cfg.forJob("capped")
+	assert.True(t, enabled)
+}
+
+func TestExemplarsConfigValidate(t *testing.T) {
+	assert.NoError(t, (&ExemplarsConfig{MaxPerSeries: 1}).Validate())
+	assert.Error(t, (&ExemplarsConfig{MaxPerSeries: -1}).Validate())
+	assert.Error(t, (&ExemplarsConfig{Jobs: map[string]ExemplarsJobConfig{"job": {MaxPerSeries: -1}}}).Validate())
+}
+
+func TestInvalidTraceContextReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels labels.Labels
+		want   string
+	}{
+		{
+			name:   "no trace context",
+			labels: labels.FromStrings("foo", "bar"),
+		},
+		{
+			name:   "empty trace context",
+			labels: labels.FromStrings("trace_id", "", "span_id", ""),
+		},
+		{
+			name:   "valid",
+			labels: labels.FromStrings("trace_id", "10a47365b8aa04e08291fab9deca84db", "span_id", "719cee4a669fd7d1"),
+		},
+		{
+			name:   "valid upper case label names",
+			labels: labels.FromStrings("Trace_ID", "10A47365B8AA04E08291FAB9DECA84DB", "SPAN_ID", "719CEE4A669FD7D1"),
+		},
+		{
+			name:   "short trace id",
+			labels: labels.FromStrings("trace_id", "174137cab66dc880", "span_id", "719cee4a669fd7d1"),
+			want:   exemplarDropReasonInvalidTraceID,
+		},
+		{
+			name:   "non hex trace id",
+			labels: labels.FromStrings("trace_id", "not-a-trace-id-not-a-trace-id-xx"),
+			want:   exemplarDropReasonInvalidTraceID,
+		},
+		{
+			name:   "long span id",
+			labels: labels.FromStrings("trace_id", "10a47365b8aa04e08291fab9deca84db", "span_id", "719cee4a669fd7d109ff"),
+			want:   exemplarDropReasonInvalidSpanID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, invalidTraceContextReason(exemplar.Exemplar{Labels: tt.labels}))
+		})
+	}
+}
+
+func TestTransactionExemplarFiltering(t *testing.T) {
+	validTraceContext := labels.FromStrings("trace_id", "10a47365b8aa04e08291fab9deca84db", "span_id", "719cee4a669fd7d1")
+	invalidTraceContext := labels.FromStrings("trace_id", "garbage")
+
+	tests := []struct {
+		name      string
+		cfg       ExemplarsConfig
+		exemplars []exemplar.Exemplar
+		want      int
+	}{
+		{
+			name:      "forward all by default",
+			exemplars: []exemplar.Exemplar{{Labels: validTraceContext}, {Labels: invalidTraceContext}, {Labels: validTraceContext}},
+			want:      3,
+		},
+		{
+			name:      "disabled",
+			cfg:       ExemplarsConfig{Disabled: true},
+			exemplars: []exemplar.Exemplar{{Labels: validTraceContext}},
+			want:      0,
+		},
+		{
+			name:      "disabled for job",
+			cfg:       ExemplarsConfig{Jobs: map[string]ExemplarsJobConfig{"job": {Disabled: true}}},
+			exemplars: []exemplar.Exemplar{{Labels: validTraceContext}},
+			want:      0,
+		},
+		{
+			name:      "capped per series",
+			cfg:       ExemplarsConfig{MaxPerSeries: 2},
+			exemplars: []exemplar.Exemplar{{Labels: validTraceContext}, {Labels: validTraceContext}, {Labels: validTraceContext}},
+			want:      2,
+		},
+		{
+			name:      "invalid trace ids dropped",
+			cfg:       ExemplarsConfig{ValidateTraceIDs: true},
+			exemplars: []exemplar.Exemplar{{Labels: validTraceContext}, {Labels: invalidTraceContext}},
+			want:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := new(consumertest.MetricsSink)
+			tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, sink, labels.EmptyLabels(), fileLabelSet{}, receivertest.NewNopSettings(), nopObsRecv(t), false, false, tt.cfg, nopTelemetryBuilder(t), nil)
+
+			ls := labels.FromStrings(model.MetricNameLabel, "counter_test", model.JobLabel, "job", model.InstanceLabel, "instance")
+			_, err := tr.Append(0, ls, ts, 1)
+			require.NoError(t, err)
+			for _, e := range tt.exemplars {
+				_, err = tr.AppendExemplar(0, ls, e)
+				require.NoError(t, err)
+			}
+			require.NoError(t, tr.Commit())
+
+			mds := sink.AllMetrics()
+			require.Len(t, mds, 1)
+			dps := mds[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+			require.Equal(t, 1, dps.Len())
+			assert.Equal(t, tt.want, dps.At(0).Exemplars().Len())
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+const (
+	// LabelsFileAttachToSeries adds the file labels to every scraped series.
+	LabelsFileAttachToSeries = "series"
+	// LabelsFileAttachToResource adds the file labels as attributes of every resource.
+	LabelsFileAttachToResource = "resource"
+)
+
+// LabelsFileConfig configures labels read from a file on disk, such as a downward API volume.
+type LabelsFileConfig struct {
+	// Path of the file. Each line holds one key=value pair, the value may be double quoted.
+	// Empty lines and lines starting with # are ignored.
+	Path string `mapstructure:"path"`
+	// AttachTo is either "series" or "resource". Defaults to "series".
+	AttachTo string `mapstructure:"attach_to"`
+}
+
+// Validate checks the labels file configuration is valid.
+func (cfg *LabelsFileConfig) Validate() error {
+	if cfg.Path == "" {
+		return fmt.Errorf("labels_file: path must be set")
+	}
+	switch cfg.AttachTo {
+	case "", LabelsFileAttachToSeries, LabelsFileAttachToResource:
+		return nil
+	default:
+		return fmt.Errorf("labels_file: attach_to must be %q or %q, got %q", LabelsFileAttachToSeries, LabelsFileAttachToResource, cfg.AttachTo)
+	}
+}
+
+// FileLabels holds the labels read from a file and reloads them whenever the file changes,
+// so that they apply to the next scrape without a configuration reload.
+type FileLabels struct {
+	path       string
+	toResource bool
+	logger     *zap.Logger
+	watcher    *fsnotify.Watcher
+	done       chan struct{}
+
+	mu     sync.RWMutex
+	labels labels.Labels
+}
+
+// NewFileLabels reads the labels file and starts watching it for changes.
+func NewFileLabels(cfg *LabelsFileConfig, logger *zap.Logger) (*FileLabels, error) {
+	fl := &FileLabels{
+		path:       cfg.Path,
+		toResource: cfg.AttachTo == LabelsFileAttachToResource,
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+	if err := fl.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("labels_file: failed to create watcher: %w", err)
+	}
+	// Watch the directory rather than the file: mounted ConfigMaps and downward API
+	// volumes update files by swapping symlinks, which a file watch does not follow.
+	if err = watcher.Add(filepath.Dir(fl.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("labels_file: failed to watch %q: %w", fl.path, err)
+	}
+	fl.watcher = watcher
+	go fl.watch()
+	return fl, nil
+}
+
+// Labels returns the current labels of the file.
+func (fl *FileLabels) Labels() labels.Labels {
+	fl.mu.RLock()
+	defer fl.mu.RUnlock()
+	return fl.labels
+}
+
+// Close stops watching the file.
+func (fl *FileLabels) Close() error {
+	if fl == nil || fl.watcher == nil {
+		return nil
+	}
+	err := fl.watcher.Close()
+	<-fl.done
+	return err
+}
+
+func (fl *FileLabels) watch() {
+	defer close(fl.done)
+	for {
+		select {
+		case _, ok := <-fl.watcher.Events:
+			if !ok {
+				return
+			}
+			if err := fl.reload(); err != nil {
+				// Keep the last good labels, the file may be in the middle of an update.
+				fl.logger.Warn("Failed to reload labels file, keeping previous labels", zap.String("path", fl.path), zap.Error(err))
+			}
+		case err, ok := <-fl.watcher.Errors:
+			if !ok {
+				return
+			}
+			fl.logger.Warn("Error watching labels file", zap.String("path", fl.path), zap.Error(err))
+		}
+	}
+}
+
+func (fl *FileLabels) reload() error {
+	content, err := os.ReadFile(fl.path)
+	if err != nil {
+		return fmt.Errorf("labels_file: failed to read %q: %w", fl.path, err)
+	}
+	ls, err := parseLabelsFile(content)
+	if err != nil {
+		return fmt.Errorf("labels_file: failed to parse %q: %w", fl.path, err)
+	}
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if !labels.Equal(fl.labels, ls) {
+		fl.logger.Info("Loaded labels file", zap.String("path", fl.path), zap.Stringer("labels", ls))
+	}
+	fl.labels = ls
+	return nil
+}
+
+// parseLabelsFile parses key=value lines, the format used by downward API label files.
+func parseLabelsFile(content []byte) (labels.Labels, error) {
+	b := labels.NewScratchBuilder(0)
+	seen := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return labels.EmptyLabels(), fmt.Errorf("line %d: expected key=value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !model.LabelName(key).IsValid() {
+			return labels.EmptyLabels(), fmt.Errorf("line %d: invalid label name %q", lineNo, key)
+		}
+		if isReservedFileLabel(key) {
+			return labels.EmptyLabels(), fmt.Errorf("line %d: reserved label name %q", lineNo, key)
+		}
+		if _, ok := seen[key]; ok {
+			return labels.EmptyLabels(), fmt.Errorf("line %d: duplicate label name %q", lineNo, key)
+		}
+		seen[key] = struct{}{}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return labels.EmptyLabels(), fmt.Errorf("line %d: invalid quoted value %s", lineNo, value)
+			}
+			value = unquoted
+		}
+		b.Add(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return labels.EmptyLabels(), err
+	}
+	b.Sort()
+	return b.Labels(), nil
+}
+
+// fileLabelSet holds the labels of the labels file for one scrape. They are only added where the series or the
+// resource does not already have the label or attribute.
+type fileLabelSet struct {
+	labels     labels.Labels
+	toResource bool
+}
+
+func (fs fileLabelSet) toSeries() bool {
+	return !fs.toResource && fs.labels.Len() != 0
+}
+
+// addToResource adds the file labels as the attributes the resource does not have yet.
+func (fs fileLabelSet) addToResource(attrs pcommon.Map) {
+	if !fs.toResource {
+		return
+	}
+	fs.labels.Range(func(l labels.Label) {
+		if _, ok := attrs.Get(l.Name); !ok {
+			attrs.PutStr(l.Name, l.Value)
+		}
+	})
+}
+
+// isReservedFileLabel reports whether a label name identifies the target, which the labels file must not set.
+func isReservedFileLabel(name string) bool {
+	return name == model.JobLabel || name == model.InstanceLabel || strings.HasPrefix(name, model.ReservedLabelPrefix)
+}
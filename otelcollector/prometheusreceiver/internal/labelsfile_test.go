@@ -0,0 +1,175 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.uber.org/zap"
+)
+
+func TestParseLabelsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    labels.Labels
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    labels.EmptyLabels(),
+		},
+		{
+			name:    "downward api format",
+			content: "zone=\"eastus-1\"\nregion=\"eastus\"\n",
+			want:    labels.FromStrings("region", "eastus", "zone", "eastus-1"),
+		},
+		{
+			name:    "unquoted values, comments and blank lines",
+			content: "# node labels\n\nnodepool = system\nenvironment=prod=blue\n",
+			want:    labels.FromStrings("environment", "prod=blue", "nodepool", "system"),
+		},
+		{
+			name:    "missing separator",
+			content: "zone\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid label name",
+			content: "topology.kubernetes.io/zone=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate label name",
+			content: "zone=1\nzone=2\n",
+			wantErr: true,
+		},
+		{
+			name:    "reserved job label",
+			content: "job=from-file\n",
+			wantErr: true,
+		},
+		{
+			name:    "reserved instance label",
+			content: "instance=from-file\n",
+			wantErr: true,
+		},
+		{
+			name:    "reserved prefix",
+			content: "__address__=from-file\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad quoting",
+			content: "zone=\"1\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabelsFile([]byte(tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTransactionSeriesFileLabels(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	fileLabels := fileLabelSet{labels: labels.FromStrings("cluster", "from-file", "team", "from-file", "zone", "1")}
+	tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, sink, labels.FromStrings("cluster", "configured"), fileLabels, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, nopTelemetryBuilder(t), nil)
+
+	// The scraped series sets team, which the file also sets
+	_, err := tr.Append(0, labels.FromStrings(model.MetricNameLabel, "gauge_test", model.JobLabel, "job", model.InstanceLabel, "instance", "team", "scraped"), ts, 1)
+	require.NoError(t, err)
+	require.NoError(t, tr.Commit())
+
+	mds := sink.AllMetrics()
+	require.Len(t, mds, 1)
+	attrs := mds[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes().AsRaw()
+	assert.Equal(t, map[string]any{"cluster": "configured", "team": "scraped", "zone": "1"}, attrs)
+}
+
+func TestTransactionSeriesFileLabelsWithExemplar(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	fileLabels := fileLabelSet{labels: labels.FromStrings("zone", "1")}
+	tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, sink, labels.EmptyLabels(), fileLabels, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, nopTelemetryBuilder(t), nil)
+
+	// The exemplar belongs to the series with the file labels, not to a series of its own
+	ls := labels.FromStrings(model.MetricNameLabel, "counter_test", model.JobLabel, "job", model.InstanceLabel, "instance")
+	_, err := tr.Append(0, ls, ts, 1)
+	require.NoError(t, err)
+	_, err = tr.AppendExemplar(0, ls, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "10a47365b8aa04e08291fab9deca84db"), Value: 1, Ts: ts, HasTs: true})
+	require.NoError(t, err)
+	require.NoError(t, tr.Commit())
+
+	mds := sink.AllMetrics()
+	require.Len(t, mds, 1)
+	dps := mds[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+	require.Equal(t, 1, dps.Len())
+	assert.Equal(t, map[string]any{"zone": "1"}, dps.At(0).Attributes().AsRaw())
+	assert.Equal(t, 1, dps.At(0).Exemplars().Len())
+}
+
+func TestLabelsFileConfigValidate(t *testing.T) {
+	assert.NoError(t, (&LabelsFileConfig{Path: "labels"}).Validate())
+	assert.NoError(t, (&LabelsFileConfig{Path: "labels", AttachTo: LabelsFileAttachToResource}).Validate())
+	assert.Error(t, (&LabelsFileConfig{}).Validate())
+	assert.Error(t, (&LabelsFileConfig{Path: "labels", AttachTo: "scope"}).Validate())
+}
+
+func TestFileLabelsReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels")
+	require.NoError(t, os.WriteFile(path, []byte("zone=1\n"), 0o600))
+
+	fl, err := NewFileLabels(&LabelsFileConfig{Path: path}, zap.NewNop())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fl.Close()) }()
+	assert.Equal(t, labels.FromStrings("zone", "1"), fl.Labels())
+
+	require.NoError(t, os.WriteFile(path, []byte("zone=2\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return labels.Equal(labels.FromStrings("zone", "2"), fl.Labels())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// An invalid update keeps the previous labels.
+	require.NoError(t, os.WriteFile(path, []byte("zone\n"), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, labels.FromStrings("zone", "2"), fl.Labels())
+}
+
+func TestNewFileLabelsMissingFile(t *testing.T) {
+	_, err := NewFileLabels(&LabelsFileConfig{Path: filepath.Join(t.TempDir(), "missing")}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestTransactionResourceLabels(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, sink, labels.EmptyLabels(), fileLabelSet{labels: labels.FromStrings("zone", "1"), toResource: true}, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, nopTelemetryBuilder(t), nil)
+
+	_, err := tr.Append(0, labels.FromStrings(model.MetricNameLabel, "gauge_test", model.JobLabel, "job", model.InstanceLabel, "instance"), ts, 1)
+	require.NoError(t, err)
+	require.NoError(t, tr.Commit())
+
+	mds := sink.AllMetrics()
+	require.Len(t, mds, 1)
+	zone, ok := mds[0].ResourceMetrics().At(0).Resource().Attributes().Get("zone")
+	require.True(t, ok)
+	assert.Equal(t, "1", zone.Str())
+}
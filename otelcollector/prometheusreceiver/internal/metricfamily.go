@@ -384,7 +384,7 @@ func (mf *metricFamily) loadMetricGroupOrCreate(groupKey uint64, ls labels.Label
 func (mf *metricFamily) addSeries(seriesRef uint64, metricName string, ls labels.Labels, t int64, v float64) error {
 	mg := mf.loadMetricGroupOrCreate(seriesRef, ls, t)
 	if mg.ts != t {
-		return fmt.Errorf("inconsistent timestamps on metric points for metric %v", metricName)
+		return fmt.Errorf("%w for metric %v", errInconsistentTimestamps, metricName)
 	}
 	switch mf.mtype {
 	case pmetric.MetricTypeHistogram, pmetric.MetricTypeSummary:
@@ -428,7 +428,7 @@ func (mf *metricFamily) addSeries(seriesRef uint64, metricName string, ls labels
 func (mf *metricFamily) addExponentialHistogramSeries(seriesRef uint64, metricName string, ls labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) error {
 	mg := mf.loadMetricGroupOrCreate(seriesRef, ls, t)
 	if mg.ts != t {
-		return fmt.Errorf("inconsistent timestamps on metric points for metric %v", metricName)
+		return fmt.Errorf("%w for metric %v", errInconsistentTimestamps, metricName)
 	}
 	if mg.mtype != pmetric.MetricTypeExponentialHistogram {
 		return fmt.Errorf("metric type mismatch for exponential histogram metric %v type %s", metricName, mg.mtype.String())
@@ -525,13 +525,19 @@ func (mf *metricFamily) appendMetric(metrics pmetric.MetricSlice, trimSuffixes b
 	metric.MoveTo(metrics.AppendEmpty())
 }
 
-func (mf *metricFamily) addExemplar(seriesRef uint64, e exemplar.Exemplar) {
+// addExemplar adds the exemplar to the series it belongs to. It returns false if the
+// series already holds maxPerSeries exemplars, a maxPerSeries of zero means no limit.
+func (mf *metricFamily) addExemplar(seriesRef uint64, e exemplar.Exemplar, maxPerSeries int) bool {
 	mg := mf.groups[seriesRef]
 	if mg == nil {
-		return
+		return true
 	}
 	es := mg.exemplars
+	if maxPerSeries > 0 && es.Len() >= maxPerSeries {
+		return false
+	}
 	convertExemplar(e, es.AppendEmpty())
+	return true
 }
 
 func convertExemplar(pe exemplar.Exemplar, e pmetric.Exemplar) {
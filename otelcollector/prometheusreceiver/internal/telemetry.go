@@ -0,0 +1,169 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal"
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	mdata "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver/internal/metadata"
+)
+
+// Reasons reported by the conversion errors metric. All of them except
+// conversionErrorInvalidUpValue mean the sample was dropped.
+const (
+	conversionErrorDuplicateLabels       = "duplicate_labels"
+	conversionErrorMissingMetricName     = "missing_metric_name"
+	conversionErrorMissingLe             = "missing_le"
+	conversionErrorMissingQuantile       = "missing_quantile"
+	conversionErrorInvalidBoundary       = "invalid_boundary"
+	conversionErrorInconsistentTimestamp = "inconsistent_timestamp"
+	conversionErrorInvalidUpValue        = "invalid_up_value"
+	conversionErrorOther                 = "other"
+)
+
+// conversionErrorReason maps an error returned while adding a sample to a metric family
+// to the reason reported by the conversion errors metric.
+func conversionErrorReason(err error) string {
+	var numErr *strconv.NumError
+	switch {
+	case errors.Is(err, errEmptyLeLabel):
+		return conversionErrorMissingLe
+	case errors.Is(err, errEmptyQuantileLabel):
+		return conversionErrorMissingQuantile
+	case errors.As(err, &numErr):
+		return conversionErrorInvalidBoundary
+	case errors.Is(err, errInconsistentTimestamps):
+		return conversionErrorInconsistentTimestamp
+	default:
+		return conversionErrorOther
+	}
+}
+
+// receiverTelemetry holds the self-metrics of the receiver. The target series gauge is optional in metadata.yaml
+// and created here instead of with the generated InitReceiverPrometheusTargetSeries, which observes a single value:
+// its series must be removed when their target goes away, which needs a callback over all the targets.
+type receiverTelemetry struct {
+	*mdata.TelemetryBuilder
+	targetSeries *targetSeriesGauge
+}
+
+func newReceiverTelemetry(set component.TelemetrySettings) (*receiverTelemetry, error) {
+	telemetryBuilder, err := mdata.NewTelemetryBuilder(set)
+	if err != nil {
+		return nil, err
+	}
+	targetSeries, err := newTargetSeriesGauge(set, telemetryBuilder)
+	if err != nil {
+		return nil, err
+	}
+	return &receiverTelemetry{TelemetryBuilder: telemetryBuilder, targetSeries: targetSeries}, nil
+}
+
+type targetSeriesCount struct {
+	job    string
+	series int64
+}
+
+// targetSeriesGauge reports the number of series emitted for every target on its last scrape.
+type targetSeriesGauge struct {
+	mu      sync.Mutex
+	targets map[resourceKey]targetSeriesCount
+}
+
+// newTargetSeriesGauge creates the ReceiverPrometheusTargetSeries gauge of the telemetry builder.
+func newTargetSeriesGauge(set component.TelemetrySettings, builder *mdata.TelemetryBuilder) (*targetSeriesGauge, error) {
+	g := &targetSeriesGauge{targets: map[resourceKey]targetSeriesCount{}}
+	meter := mdata.LeveledMeter(set, configtelemetry.LevelBasic)
+	gauge, err := meter.Int64ObservableGauge(
+		"otelcol_receiver_prometheus_target_series",
+		metric.WithDescription("Number of series emitted for a scrape target on its last scrape, by job and instance"),
+		metric.WithUnit("{series}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	builder.ReceiverPrometheusTargetSeries = gauge
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		for key, count := range g.targets {
+			o.ObserveInt64(gauge, count.series, metric.WithAttributes(
+				attribute.String("job", count.job),
+				attribute.String("instance", key.instance),
+			))
+		}
+		return nil
+	}, gauge)
+	return g, err
+}
+
+func (g *targetSeriesGauge) record(key resourceKey, job string, series int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.targets[key] = targetSeriesCount{job: job, series: int64(series)}
+}
+
+// remove deletes the series of a target, once its scrape loop stopped.
+func (g *targetSeriesGauge) remove(key resourceKey) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.targets, key)
+}
+
+func (t *transaction) recordConversionError(key resourceKey, reason string) {
+	t.telemetryBuilder.ReceiverPrometheusConversionErrors.Add(t.ctx, 1, metric.WithAttributes(
+		attribute.String("job", t.scrapeJobName(key)),
+		attribute.String("reason", reason),
+	))
+}
+
+// recordEmitted reports the number of metric families and series emitted for a target. The series of a target
+// whose scrape loop stopped are removed instead, the scrape only carried its staleness markers.
+func (t *transaction) recordEmitted(key resourceKey, families, series int) {
+	job := t.scrapeJobName(key)
+	t.telemetryBuilder.ReceiverPrometheusMetricFamilies.Add(t.ctx, int64(families), metric.WithAttributes(
+		attribute.String("job", job),
+	))
+	if t.stoppedTargets[key] {
+		t.telemetryBuilder.targetSeries.remove(key)
+		return
+	}
+	t.telemetryBuilder.targetSeries.record(key, job, series)
+}
+
+// dataPointCount returns the number of data points, and thus series, in the metrics.
+func dataPointCount(metrics pmetric.MetricSlice) int {
+	var count int
+	for i := 0; i < metrics.Len(); i++ {
+		count += metricDataPointCount(metrics.At(i))
+	}
+	return count
+}
+
+// metricDataPointCount returns the number of data points, and thus series, of a metric.
+func metricDataPointCount(m pmetric.Metric) int {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return m.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		return m.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		return m.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		return m.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		return m.Summary().DataPoints().Len()
+	case pmetric.MetricTypeEmpty:
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConversionErrorReason(t *testing.T) {
+	_, parseErr := strconv.ParseFloat("x", 64)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: errEmptyLeLabel, want: conversionErrorMissingLe},
+		{err: errEmptyQuantileLabel, want: conversionErrorMissingQuantile},
+		{err: parseErr, want: conversionErrorInvalidBoundary},
+		{err: fmt.Errorf("%w for metric %v", errInconsistentTimestamps, "foo"), want: conversionErrorInconsistentTimestamp},
+		{err: errors.New("boom"), want: conversionErrorOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, conversionErrorReason(tt.err), tt.err.Error())
+	}
+}
+
+func TestTransactionSelfTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { require.NoError(t, provider.Shutdown(context.Background())) }()
+	set := componenttest.NewNopTelemetrySettings()
+	set.LeveledMeterProvider = func(configtelemetry.Level) metric.MeterProvider { return provider }
+	telemetryBuilder, err := newReceiverTelemetry(set)
+	require.NoError(t, err)
+
+	tr := newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, consumertest.NewNop(), labels.EmptyLabels(), fileLabelSet{}, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, telemetryBuilder, nil)
+
+	target := []string{model.JobLabel, "job", model.InstanceLabel, "instance"}
+	samples := []labels.Labels{
+		labels.FromStrings(append([]string{model.MetricNameLabel, "counter_test", "foo", "bar"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "counter_test", "foo", "baz"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "gauge_test"}, target...)...),
+		// histogram bucket without an le label
+		labels.FromStrings(append([]string{model.MetricNameLabel, "hist_test_bucket"}, target...)...),
+		labels.FromStrings(append([]string{model.MetricNameLabel, "up"}, target...)...),
+	}
+	for i, ls := range samples {
+		val := 1.0
+		if i == len(samples)-1 {
+			val = 2
+		}
+		_, err = tr.Append(0, ls, ts, val)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tr.Commit())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	got := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	conversionErrors := got["otelcol_receiver_prometheus_conversion_errors"].(metricdata.Sum[int64])
+	reasons := map[string]int64{}
+	for _, dp := range conversionErrors.DataPoints {
+		job, _ := dp.Attributes.Value(attribute.Key("job"))
+		assert.Equal(t, "job", job.AsString())
+		reason, _ := dp.Attributes.Value(attribute.Key("reason"))
+		reasons[reason.AsString()] = dp.Value
+	}
+	assert.Equal(t, map[string]int64{conversionErrorMissingLe: 1, conversionErrorInvalidUpValue: 1}, reasons)
+
+	families := got["otelcol_receiver_prometheus_metric_families"].(metricdata.Sum[int64])
+	require.Len(t, families.DataPoints, 1)
+	assert.Equal(t, int64(3), families.DataPoints[0].Value)
+
+	series := got["otelcol_receiver_prometheus_target_series"].(metricdata.Gauge[int64])
+	require.Len(t, series.DataPoints, 1)
+	instance, _ := series.DataPoints[0].Attributes.Value(attribute.Key("instance"))
+	assert.Equal(t, "instance", instance.AsString())
+	assert.Equal(t, int64(4), series.DataPoints[0].Value)
+
+	// The scrape loop of the target stopped and appended the end of run staleness markers
+	tr = newTransaction(scrapeCtx, &startTimeAdjuster{startTime: startTimestamp}, consumertest.NewNop(), labels.EmptyLabels(), fileLabelSet{}, receivertest.NewNopSettings(), nopObsRecv(t), false, false, ExemplarsConfig{}, telemetryBuilder, nil)
+	for _, ls := range []labels.Labels{samples[2], samples[4]} {
+		_, err = tr.Append(0, ls, ts+interval, math.Float64frombits(value.StaleNaN))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tr.Commit())
+
+	rm = metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == "otelcol_receiver_prometheus_target_series" {
+			assert.Empty(t, m.Data.(metricdata.Gauge[int64]).DataPoints)
+		}
+	}
+}
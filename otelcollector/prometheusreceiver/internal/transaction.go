@@ -23,6 +23,8 @@ import (
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/receiver"
 	"go.opentelemetry.io/collector/receiver/receiverhelper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheus"
@@ -42,12 +44,18 @@ type transaction struct {
 	mc                     scrape.MetricMetadataStore
 	sink                   consumer.Metrics
 	externalLabels         labels.Labels
+	fileLabels             fileLabelSet
 	nodeResources          map[resourceKey]pcommon.Resource
 	scopeAttributes        map[resourceKey]map[scopeID]pcommon.Map
 	logger                 *zap.Logger
 	buildInfo              component.BuildInfo
 	metricAdjuster         MetricsAdjuster
 	obsrecv                *receiverhelper.ObsReport
+	exemplars              ExemplarsConfig
+	telemetryBuilder       *receiverTelemetry
+	cardinality            *CardinalityTracker
+	// stoppedTargets are the targets whose scrape loop stopped, which append a stale up sample.
+	stoppedTargets map[resourceKey]bool
 	// Used as buffer to calculate series ref hash.
 	bufBytes []byte
 }
@@ -64,10 +72,14 @@ func newTransaction(
 	metricAdjuster MetricsAdjuster,
 	sink consumer.Metrics,
 	externalLabels labels.Labels,
+	fileLabels fileLabelSet,
 	settings receiver.Settings,
 	obsrecv *receiverhelper.ObsReport,
 	trimSuffixes bool,
-	enableNativeHistograms bool) *transaction {
+	enableNativeHistograms bool,
+	exemplars ExemplarsConfig,
+	telemetryBuilder *receiverTelemetry,
+	cardinality *CardinalityTracker) *transaction {
 	return &transaction{
 		ctx:                    ctx,
 		families:               make(map[resourceKey]map[scopeID]map[string]*metricFamily),
@@ -77,15 +89,38 @@ func newTransaction(
 		sink:                   sink,
 		metricAdjuster:         metricAdjuster,
 		externalLabels:         externalLabels,
+		fileLabels:             fileLabels,
 		logger:                 settings.Logger,
 		buildInfo:              settings.BuildInfo,
 		obsrecv:                obsrecv,
+		exemplars:              exemplars,
+		telemetryBuilder:       telemetryBuilder,
+		cardinality:            cardinality,
 		bufBytes:               make([]byte, 0, 1024),
 		scopeAttributes:        make(map[resourceKey]map[scopeID]pcommon.Map),
 		nodeResources:          map[resourceKey]pcommon.Resource{},
 	}
 }
 
+// addLabels sets the external labels on a scraped series and adds the file labels it does not have.
+func (t *transaction) addLabels(ls labels.Labels) labels.Labels {
+	if t.externalLabels.Len() == 0 && !t.fileLabels.toSeries() {
+		return ls
+	}
+	b := labels.NewBuilder(ls)
+	t.externalLabels.Range(func(l labels.Label) {
+		b.Set(l.Name, l.Value)
+	})
+	if t.fileLabels.toSeries() {
+		t.fileLabels.labels.Range(func(l labels.Label) {
+			if b.Get(l.Name) == "" {
+				b.Set(l.Name, l.Value)
+			}
+		})
+	}
+	return b.Labels()
+}
+
 // Append always returns 0 to disable label caching.
 func (t *transaction) Append(_ storage.SeriesRef, ls labels.Labels, atMs int64, val float64) (storage.SeriesRef, error) {
 	select {
@@ -94,13 +129,7 @@ func (t *transaction) Append(_ storage.SeriesRef, ls labels.Labels, atMs int64,
 	default:
 	}
 
-	if t.externalLabels.Len() != 0 {
-		b := labels.NewBuilder(ls)
-		t.externalLabels.Range(func(l labels.Label) {
-			b.Set(l.Name, l.Value)
-		})
-		ls = b.Labels()
-	}
+	ls = t.addLabels(ls)
 
 	rKey, err := t.initTransaction(ls)
 	if err != nil {
@@ -112,23 +141,31 @@ func (t *transaction) Append(_ storage.SeriesRef, ls labels.Labels, atMs int64,
 	// * https://github.com/open-telemetry/opentelemetry-collector/issues/3407
 	// as Prometheus rejects such too as of version 2.16.0, released on 2020-02-13.
 	if dupLabel, hasDup := ls.HasDuplicateLabelNames(); hasDup {
+		t.recordConversionError(*rKey, conversionErrorDuplicateLabels)
 		return 0, fmt.Errorf("invalid sample: non-unique label names: %q", dupLabel)
 	}
 
 	metricName := ls.Get(model.MetricNameLabel)
 	if metricName == "" {
+		t.recordConversionError(*rKey, conversionErrorMissingMetricName)
 		return 0, errMetricNameNotFound
 	}
 
 	// See https://www.prometheus.io/docs/concepts/jobs_instances/#automatically-generated-labels-and-time-series
 	// up: 1 if the instance is healthy, i.e. reachable, or 0 if the scrape failed.
 	// But it can also be a staleNaN, which is inserted when the target goes away.
-	if metricName == scrapeUpMetricName && val != 1.0 && !value.IsStaleNaN(val) {
+	if metricName == scrapeUpMetricName && value.IsStaleNaN(val) {
+		if t.stoppedTargets == nil {
+			t.stoppedTargets = map[resourceKey]bool{}
+		}
+		t.stoppedTargets[*rKey] = true
+	} else if metricName == scrapeUpMetricName && val != 1.0 {
 		if val == 0.0 {
 			t.logger.Warn("Failed to scrape Prometheus endpoint",
 				zap.Int64("scrape_timestamp", atMs),
 				zap.Stringer("target_labels", ls))
 		} else {
+			t.recordConversionError(*rKey, conversionErrorInvalidUpValue)
 			t.logger.Warn("The 'up' metric contains invalid value",
 				zap.Float64("value", val),
 				zap.Int64("scrape_timestamp", atMs),
@@ -174,6 +211,7 @@ func (t *transaction) Append(_ storage.SeriesRef, ls labels.Labels, atMs int64,
 			_ = curMF.addExponentialHistogramSeries(seriesRef, metricName, ls, atMs, &histogram.Histogram{Sum: math.Float64frombits(value.StaleNaN)}, nil)
 			// ignore errors here, this is best effort.
 		} else {
+			t.recordConversionError(*rKey, conversionErrorReason(err))
 			t.logger.Warn("failed to add datapoint", zap.Error(err), zap.String("metric_name", metricName), zap.Any("labels", ls))
 		}
 	}
@@ -215,6 +253,8 @@ func (t *transaction) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exe
 	default:
 	}
 
+	l = t.addLabels(l)
+
 	rKey, err := t.initTransaction(l)
 	if err != nil {
 		return 0, err
@@ -231,12 +271,46 @@ func (t *transaction) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exe
 		return 0, errMetricNameNotFound
 	}
 
+	job := t.scrapeJobName(*rKey)
+	enabled, maxPerSeries := t.exemplars.forJob(job)
+	if !enabled {
+		return 0, nil
+	}
+
+	if t.exemplars.ValidateTraceIDs {
+		if reason := invalidTraceContextReason(e); reason != "" {
+			t.recordDroppedExemplar(job, reason)
+			return 0, nil
+		}
+	}
+
 	mf, _ := t.getOrCreateMetricFamily(*rKey, getScopeID(l), mn)
-	mf.addExemplar(t.getSeriesRef(l, mf.mtype), e)
+	if !mf.addExemplar(t.getSeriesRef(l, mf.mtype), e, maxPerSeries) {
+		t.recordDroppedExemplar(job, exemplarDropReasonLimit)
+	}
 
 	return 0, nil
 }
 
+// scrapeJobName returns the job_name of the scrape config the transaction belongs to.
+// The job label of a series can be changed by relabeling, so the discovered labels of the
+// target are preferred.
+func (t *transaction) scrapeJobName(key resourceKey) string {
+	if target, ok := scrape.TargetFromContext(t.ctx); ok {
+		if job := target.DiscoveredLabels().Get(model.JobLabel); job != "" {
+			return job
+		}
+	}
+	return key.job
+}
+
+func (t *transaction) recordDroppedExemplar(job, reason string) {
+	t.telemetryBuilder.ReceiverPrometheusExemplarsDropped.Add(t.ctx, 1, metric.WithAttributes(
+		attribute.String("job", job),
+		attribute.String("reason", reason),
+	))
+}
+
 func (t *transaction) AppendHistogram(_ storage.SeriesRef, ls labels.Labels, atMs int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
 	if !t.enableNativeHistograms {
 		return 0, nil
@@ -248,13 +322,7 @@ func (t *transaction) AppendHistogram(_ storage.SeriesRef, ls labels.Labels, atM
 	default:
 	}
 
-	if t.externalLabels.Len() != 0 {
-		b := labels.NewBuilder(ls)
-		t.externalLabels.Range(func(l labels.Label) {
-			b.Set(l.Name, l.Value)
-		})
-		ls = b.Labels()
-	}
+	ls = t.addLabels(ls)
 
 	rKey, err := t.initTransaction(ls)
 	if err != nil {
@@ -266,11 +334,13 @@ func (t *transaction) AppendHistogram(_ storage.SeriesRef, ls labels.Labels, atM
 	// * https://github.com/open-telemetry/opentelemetry-collector/issues/3407
 	// as Prometheus rejects such too as of version 2.16.0, released on 2020-02-13.
 	if dupLabel, hasDup := ls.HasDuplicateLabelNames(); hasDup {
+		t.recordConversionError(*rKey, conversionErrorDuplicateLabels)
 		return 0, fmt.Errorf("invalid sample: non-unique label names: %q", dupLabel)
 	}
 
 	metricName := ls.Get(model.MetricNameLabel)
 	if metricName == "" {
+		t.recordConversionError(*rKey, conversionErrorMissingMetricName)
 		return 0, errMetricNameNotFound
 	}
 
@@ -291,6 +361,7 @@ func (t *transaction) AppendHistogram(_ storage.SeriesRef, ls labels.Labels, atM
 
 	err = curMF.addExponentialHistogramSeries(t.getSeriesRef(ls, curMF.mtype), metricName, ls, atMs, h, fh)
 	if err != nil {
+		t.recordConversionError(*rKey, conversionErrorReason(err))
 		t.logger.Warn("failed to add histogram datapoint", zap.Error(err), zap.String("metric_name", metricName), zap.Any("labels", ls))
 	}
 
@@ -328,6 +399,12 @@ func (t *transaction) getMetrics() (pmetric.Metrics, error) {
 		rms := md.ResourceMetrics().AppendEmpty()
 		resource.CopyTo(rms.Resource())
 
+		var familyCount, seriesCount int
+		var seriesPerMetric map[string]int
+		if t.cardinality != nil {
+			seriesPerMetric = map[string]int{}
+		}
+
 		for scope, mfs := range families {
 			ils := rms.ScopeMetrics().AppendEmpty()
 			// If metrics don't include otel_scope_name or otel_scope_version
@@ -351,6 +428,17 @@ func (t *transaction) getMetrics() (pmetric.Metrics, error) {
 			for _, mf := range mfs {
 				mf.appendMetric(metrics, t.trimSuffixes)
 			}
+			familyCount += metrics.Len()
+			seriesCount += dataPointCount(metrics)
+			if seriesPerMetric != nil {
+				for i := 0; i < metrics.Len(); i++ {
+					seriesPerMetric[metrics.At(i).Name()] += metricDataPointCount(metrics.At(i))
+				}
+			}
+		}
+		t.recordEmitted(rKey, familyCount, seriesCount)
+		if seriesPerMetric != nil {
+			t.cardinality.record(rKey, t.scrapeJobName(rKey), seriesPerMetric)
 		}
 	}
 	// remove the resource if no metrics were added to avoid returning resources with empty data points
@@ -384,7 +472,7 @@ func getScopeID(ls labels.Labels) scopeID {
 	return scope
 }
 
-func (t *transaction) initTransaction(labels labels.Labels) (*resourceKey, error) {
+func (t *transaction) initTransaction(ls labels.Labels) (*resourceKey, error) {
 	target, ok := scrape.TargetFromContext(t.ctx)
 	if !ok {
 		return nil, errors.New("unable to find target in context")
@@ -394,12 +482,15 @@ func (t *transaction) initTransaction(labels labels.Labels) (*resourceKey, error
 		return nil, errors.New("unable to find MetricMetadataStore in context")
 	}
 
-	rKey, err := t.getJobAndInstance(labels)
+	rKey, err := t.getJobAndInstance(ls)
 	if err != nil {
 		return nil, err
 	}
 	if _, ok := t.nodeResources[*rKey]; !ok {
-		t.nodeResources[*rKey] = CreateResource(rKey.job, rKey.instance, target.DiscoveredLabels())
+		resource := CreateResource(rKey.job, rKey.instance, target.DiscoveredLabels())
+		attrs := resource.Attributes()
+		t.fileLabels.addToResource(attrs)
+		t.nodeResources[*rKey] = resource
 	}
 
 	t.isNew = false
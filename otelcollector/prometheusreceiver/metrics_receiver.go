@@ -7,7 +7,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
 	"os"
 	"reflect"
 	"regexp"
@@ -18,11 +17,9 @@ import (
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	commonconfig "github.com/prometheus/common/config"
-	"github.com/prometheus/common/version"
 	promconfig "github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/scrape"
-	"github.com/prometheus/prometheus/web"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componentstatus"
 	"go.opentelemetry.io/collector/consumer"
@@ -57,7 +54,10 @@ type pReceiver struct {
 	registerer             prometheus.Registerer
 	unregisterMetrics      func()
 	skipOffsetting         bool // for testing only
-	webHandler             *web.Handler
+	webHandler             targetallocator.ConfigApplier
+	webConfigFile          string
+	fileLabels             *internal.FileLabels
+	cardinality            *internal.CardinalityTracker
 }
 
 // New creates a new prometheus.Receiver reference.
@@ -139,6 +139,19 @@ func (r *pReceiver) initPrometheusComponents(ctx context.Context, logger log.Log
 		}
 	}
 
+	if r.cfg.LabelsFile != nil {
+		r.fileLabels, err = internal.NewFileLabels(r.cfg.LabelsFile, r.settings.Logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.cfg.Cardinality != nil {
+		if err = r.startCardinalityReport(ctx, host); err != nil {
+			return err
+		}
+	}
+
 	store, err := internal.NewAppendable(
 		r.consumer,
 		r.settings,
@@ -149,6 +162,9 @@ func (r *pReceiver) initPrometheusComponents(ctx context.Context, logger log.Log
 		enableNativeHistogramsGate.IsEnabled(),
 		r.cfg.PrometheusConfig.GlobalConfig.ExternalLabels,
 		r.cfg.TrimMetricSuffixes,
+		r.cfg.Exemplars,
+		r.fileLabels,
+		r.cardinality,
 	)
 	if err != nil {
 		return err
@@ -200,51 +216,11 @@ func (r *pReceiver) initPrometheusComponents(ctx context.Context, logger log.Log
 		}
 	}()
 
-	// Setup settings and logger and create Prometheus web handler
-	webOptions := web.Options{
-		ScrapeManager: r.scrapeManager,
-		Context:       ctx,
-		ListenAddress: ":9090",
-		ExternalURL: &url.URL{
-			Scheme: "http",
-			Host:   "localhost:9090",
-			Path:   "",
-		},
-		RoutePrefix: "/",
-		ReadTimeout: time.Minute * readTimeoutMinutes,
-		PageTitle:   "Prometheus Receiver",
-		Version: &web.PrometheusVersion{
-			Version:   version.Version,
-			Revision:  version.Revision,
-			Branch:    version.Branch,
-			BuildUser: version.BuildUser,
-			BuildDate: version.BuildDate,
-			GoVersion: version.GoVersion,
-		},
-		Flags:          make(map[string]string),
-		MaxConnections: maxConnections,
-		IsAgent:        true,
-		Gatherer:       prometheus.DefaultGatherer,
+	if r.cfg.Web.Disabled {
+		r.settings.Logger.Info("Prometheus web handler is disabled")
+		return nil
 	}
-	go_kit_logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-	r.webHandler = web.New(go_kit_logger, &webOptions)
-	listener, err := r.webHandler.Listener()
-	if err != nil {
-		return err
-	}
-	// Pass config and let the web handler know the config is ready.
-	// These are needed because Prometheus allows reloading the config without restarting.
-	r.webHandler.ApplyConfig((*promconfig.Config)(r.cfg.PrometheusConfig))
-	r.webHandler.SetReady(true)
-	// Uses the same context as the discovery and scrape managers for shutting down
-	go func() {
-		if err := r.webHandler.Run(ctx, listener, ""); err != nil {
-			r.settings.Logger.Error("Web handler failed", zap.Error(err))
-			componentstatus.ReportStatus(host, componentstatus.NewFatalErrorEvent(err))
-		}
-	}()
-
-	return nil
+	return r.startWebHandler(ctx, host)
 }
 
 // gcInterval returns the longest scrape interval used by a scrape config,
@@ -277,5 +253,11 @@ func (r *pReceiver) Shutdown(context.Context) error {
 	if r.unregisterMetrics != nil {
 		r.unregisterMetrics()
 	}
+	if err := r.fileLabels.Close(); err != nil {
+		r.settings.Logger.Warn("Failed to stop watching labels file", zap.Error(err))
+	}
+	if r.webConfigFile != "" {
+		_ = os.Remove(r.webConfigFile)
+	}
 	return nil
 }
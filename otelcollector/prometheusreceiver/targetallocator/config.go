@@ -24,6 +24,12 @@ type Config struct {
 	CollectorID             string                `mapstructure:"collector_id"`
 	HTTPSDConfig            *PromHTTPSDConfig     `mapstructure:"http_sd_config"`
 	HTTPScrapeConfig        *PromHTTPClientConfig `mapstructure:"http_scrape_config"`
+	// MaxBackoff caps the exponential backoff between retries while the target allocator is failing.
+	// Defaults to 5 minutes, and is never shorter than Interval.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// CacheFile is the path the last successful /scrape_configs response is persisted to. When set,
+	// the cached jobs are applied at startup if the target allocator is unreachable.
+	CacheFile string `mapstructure:"cache_file"`
 }
 
 // PromHTTPSDConfig is a redeclaration of promHTTP.SDConfig because we need custom unmarshaling
@@ -39,6 +45,9 @@ func (cfg *Config) Validate() error {
 	if cfg.CollectorID == "" || strings.Contains(cfg.CollectorID, "${") {
 		return fmt.Errorf("CollectorID is not a valid ID")
 	}
+	if cfg.MaxBackoff < 0 {
+		return fmt.Errorf("TargetAllocator max_backoff must not be negative: %s", cfg.MaxBackoff)
+	}
 
 	return nil
 }
@@ -15,19 +15,26 @@ import (
 	"sort"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	commonconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	promconfig "github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
 	promHTTP "github.com/prometheus/prometheus/discovery/http"
 	"github.com/prometheus/prometheus/scrape"
-	"github.com/prometheus/prometheus/web"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/receiver"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
 
+const defaultMaxBackoff = 5 * time.Minute
+
+// ConfigApplier is notified of the scrape config received from the target allocator, like the web handler.
+type ConfigApplier interface {
+	ApplyConfig(*promconfig.Config) error
+}
+
 type Manager struct {
 	settings               receiver.Settings
 	shutdown               chan struct{}
@@ -35,7 +42,7 @@ type Manager struct {
 	promCfg                *promconfig.Config
 	scrapeManager          *scrape.Manager
 	discoveryManager       *discovery.Manager
-	webHandler             *web.Handler
+	webHandler             ConfigApplier
 	enableNativeHistograms bool
 }
 
@@ -49,7 +56,7 @@ func NewManager(set receiver.Settings, cfg *Config, promCfg *promconfig.Config,
 	}
 }
 
-func (m *Manager) Start(ctx context.Context, host component.Host, sm *scrape.Manager, dm *discovery.Manager, wh *web.Handler) error {
+func (m *Manager) Start(ctx context.Context, host component.Host, sm *scrape.Manager, dm *discovery.Manager, wh ConfigApplier) error {
 	m.scrapeManager = sm
 	m.discoveryManager = dm
 	m.webHandler = wh
@@ -71,21 +78,33 @@ func (m *Manager) Start(ctx context.Context, host component.Host, sm *scrape.Man
 	// immediately sync jobs, not waiting for the first tick
 	savedHash, err := m.sync(uint64(0), httpClient)
 	if err != nil {
-		return err
+		// Keep the collector running: fall back to the last known good jobs, if any,
+		// and keep retrying the target allocator in the background.
+		m.settings.Logger.Warn("Failed to retrieve jobs from target allocator at startup", zap.Error(err))
+		savedHash = m.loadCache()
+	}
+	bo := newSyncBackOff(m.cfg)
+	next := m.cfg.Interval
+	if err != nil {
+		next = bo.NextBackOff()
 	}
 	go func() {
-		targetAllocatorIntervalTicker := time.NewTicker(m.cfg.Interval)
+		timer := time.NewTimer(next)
+		defer timer.Stop()
 		for {
 			select {
-			case <-targetAllocatorIntervalTicker.C:
+			case <-timer.C:
 				hash, newErr := m.sync(savedHash, httpClient)
 				if newErr != nil {
-					m.settings.Logger.Error(newErr.Error())
-					continue
+					next = bo.NextBackOff()
+					m.settings.Logger.Error("Failed to sync target allocator jobs, retrying", zap.Duration("retry_in", next), zap.Error(newErr))
+				} else {
+					bo.Reset()
+					next = m.cfg.Interval
+					savedHash = hash
 				}
-				savedHash = hash
+				timer.Reset(next)
 			case <-m.shutdown:
-				targetAllocatorIntervalTicker.Stop()
 				m.settings.Logger.Info("Stopping target allocator")
 				return
 			}
@@ -94,6 +113,25 @@ func (m *Manager) Start(ctx context.Context, host component.Host, sm *scrape.Man
 	return nil
 }
 
+// newSyncBackOff returns the exponential backoff with jitter used between failed syncs.
+// It starts at the sync interval and grows up to the configured maximum.
+func newSyncBackOff(cfg *Config) *backoff.ExponentialBackOff {
+	maxBackoff := cfg.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if maxBackoff < cfg.Interval {
+		maxBackoff = cfg.Interval
+	}
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = cfg.Interval
+	bo.MaxInterval = maxBackoff
+	// never give up, the allocator is expected to come back eventually
+	bo.MaxElapsedTime = 0
+	bo.Reset()
+	return bo
+}
+
 func (m *Manager) Shutdown() {
 	close(m.shutdown)
 }
@@ -102,12 +140,31 @@ func (m *Manager) Shutdown() {
 // baseDiscoveryCfg can be used to provide additional ScrapeConfigs which will be added to the retrieved jobs.
 func (m *Manager) sync(compareHash uint64, httpClient *http.Client) (uint64, error) {
 	m.settings.Logger.Debug("Syncing target allocator jobs")
-	scrapeConfigsResponse, err := getScrapeConfigsResponse(httpClient, m.cfg.Endpoint)
+	body, err := getScrapeConfigsResponse(httpClient, m.cfg.Endpoint)
 	if err != nil {
 		m.settings.Logger.Error("Failed to retrieve job list", zap.Error(err))
 		return 0, err
 	}
 
+	hash, err := m.applyScrapeConfigsResponse(compareHash, body)
+	if err != nil {
+		return 0, err
+	}
+	if hash != compareHash {
+		m.saveCache(body)
+	}
+	return hash, nil
+}
+
+// applyScrapeConfigsResponse parses a /scrape_configs response body and applies its jobs,
+// if the response does not match the provided compareHash.
+func (m *Manager) applyScrapeConfigsResponse(compareHash uint64, body []byte) (uint64, error) {
+	scrapeConfigsResponse, err := parseScrapeConfigsResponse(body)
+	if err != nil {
+		m.settings.Logger.Error("Failed to parse job list", zap.Error(err))
+		return 0, err
+	}
+
 	hash, err := getScrapeConfigHash(scrapeConfigsResponse)
 	if err != nil {
 		m.settings.Logger.Error("Failed to hash job list", zap.Error(err))
@@ -160,6 +217,44 @@ func (m *Manager) sync(compareHash uint64, httpClient *http.Client) (uint64, err
 	return hash, nil
 }
 
+// loadCache applies the last known good /scrape_configs response persisted in the cache file
+// and returns its hash, or 0 if there is none.
+func (m *Manager) loadCache() uint64 {
+	if m.cfg.CacheFile == "" {
+		return 0
+	}
+	body, err := os.ReadFile(m.cfg.CacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.settings.Logger.Warn("Failed to read target allocator cache", zap.String("path", m.cfg.CacheFile), zap.Error(err))
+		}
+		return 0
+	}
+	hash, err := m.applyScrapeConfigsResponse(0, body)
+	if err != nil {
+		m.settings.Logger.Warn("Failed to apply target allocator cache", zap.String("path", m.cfg.CacheFile), zap.Error(err))
+		return 0
+	}
+	m.settings.Logger.Info("Applied last known good jobs from target allocator cache", zap.String("path", m.cfg.CacheFile), zap.Int("jobs", len(m.promCfg.ScrapeConfigs)))
+	return hash
+}
+
+// saveCache persists a /scrape_configs response body to the cache file. The file is replaced
+// atomically so that a crash never leaves a partial response behind.
+func (m *Manager) saveCache(body []byte) {
+	if m.cfg.CacheFile == "" {
+		return
+	}
+	tmp := m.cfg.CacheFile + ".tmp"
+	err := os.WriteFile(tmp, body, 0o600)
+	if err == nil {
+		err = os.Rename(tmp, m.cfg.CacheFile)
+	}
+	if err != nil {
+		m.settings.Logger.Warn("Failed to write target allocator cache", zap.String("path", m.cfg.CacheFile), zap.Error(err))
+	}
+}
+
 func (m *Manager) applyCfg() error {
 	if !m.enableNativeHistograms {
 		// Enforce scraping classic histograms to avoid dropping them.
@@ -172,8 +267,11 @@ func (m *Manager) applyCfg() error {
 		return err
 	}
 
-	if err := m.webHandler.ApplyConfig(m.promCfg); err != nil {
-		return err
+	// The web handler is nil when the receiver's web UI is disabled.
+	if m.webHandler != nil {
+		if err := m.webHandler.ApplyConfig(m.promCfg); err != nil {
+			return err
+		}
 	}
 
 	discoveryCfg := make(map[string]discovery.Configs)
@@ -184,7 +282,8 @@ func (m *Manager) applyCfg() error {
 	return m.discoveryManager.ApplyConfig(discoveryCfg)
 }
 
-func getScrapeConfigsResponse(httpClient *http.Client, baseURL string) (map[string]*promconfig.ScrapeConfig, error) {
+// getScrapeConfigsResponse returns the raw /scrape_configs response body of the target allocator.
+func getScrapeConfigsResponse(httpClient *http.Client, baseURL string) ([]byte, error) {
 	scrapeConfigsURL := fmt.Sprintf("%s/scrape_configs", baseURL)
 	_, err := url.Parse(scrapeConfigsURL) // check if valid
 	if err != nil {
@@ -195,19 +294,23 @@ func getScrapeConfigsResponse(httpClient *http.Client, baseURL string) (map[stri
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	// Anything but a 200 is an error page, which must not be parsed as a (possibly empty) job list.
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, scrapeConfigsURL)
+	}
+	return body, nil
+}
 
+func parseScrapeConfigsResponse(body []byte) (map[string]*promconfig.ScrapeConfig, error) {
 	jobToScrapeConfig := map[string]*promconfig.ScrapeConfig{}
 	envReplacedBody := instantiateShard(body)
-	err = yaml.Unmarshal(envReplacedBody, &jobToScrapeConfig)
-	if err != nil {
-		return nil, err
-	}
-	err = resp.Body.Close()
+	err := yaml.Unmarshal(envReplacedBody, &jobToScrapeConfig)
 	if err != nil {
 		return nil, err
 	}
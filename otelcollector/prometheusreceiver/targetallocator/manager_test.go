@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,6 +32,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/config/configtls"
 	"go.opentelemetry.io/collector/receiver/receivertest"
@@ -696,7 +699,7 @@ func TestTargetAllocatorJobRetrieval(t *testing.T) {
 
 			baseCfg := promconfig.Config{GlobalConfig: promconfig.DefaultGlobalConfig}
 			manager := NewManager(receivertest.NewNopSettings(), tc.cfg, &baseCfg, false)
-			require.NoError(t, manager.Start(ctx, componenttest.NewNopHost(), scrapeManager, discoveryManager))
+			require.NoError(t, manager.Start(ctx, componenttest.NewNopHost(), scrapeManager, discoveryManager, nil))
 
 			allocator.wg.Wait()
 
@@ -811,3 +814,89 @@ func initPrometheusManagers(ctx context.Context, t *testing.T) (*scrape.Manager,
 	require.NoError(t, err)
 	return scrapeManager, discoveryManager
 }
+
+func TestGetScrapeConfigsResponseStatusCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = rw.Write([]byte("<html>internal error</html>"))
+	}))
+	defer srv.Close()
+
+	_, err := getScrapeConfigsResponse(srv.Client(), srv.URL)
+	require.ErrorContains(t, err, "unexpected status code 500")
+}
+
+func TestSyncBackOff(t *testing.T) {
+	bo := newSyncBackOff(&Config{Interval: time.Second, MaxBackoff: 4 * time.Second})
+	prev := time.Duration(0)
+	for i := 0; i < 10; i++ {
+		next := bo.NextBackOff()
+		// jitter is applied around the current interval, which never exceeds the maximum
+		assert.LessOrEqual(t, next, 6*time.Second)
+		assert.Greater(t, next, time.Duration(0))
+		prev = next
+	}
+	assert.Greater(t, prev, 2*time.Second)
+
+	bo.Reset()
+	assert.LessOrEqual(t, bo.NextBackOff(), 1500*time.Millisecond)
+
+	// the maximum is never shorter than the sync interval
+	bo = newSyncBackOff(&Config{Interval: time.Minute})
+	assert.Equal(t, defaultMaxBackoff, bo.MaxInterval)
+	bo = newSyncBackOff(&Config{Interval: time.Minute, MaxBackoff: time.Second})
+	assert.Equal(t, time.Minute, bo.MaxInterval)
+}
+
+func TestTargetAllocatorLastKnownGoodCache(t *testing.T) {
+	ctx := context.Background()
+	cacheFile := filepath.Join(t.TempDir(), "scrape_configs.yaml")
+	scrapeConfigs := map[string]map[string]any{
+		"job1": {
+			"job_name":        "job1",
+			"scrape_interval": "30s",
+			"scrape_timeout":  "30s",
+			"metrics_path":    "/metrics",
+			"scheme":          "http",
+		},
+	}
+	body, err := json.Marshal(scrapeConfigs)
+	require.NoError(t, err)
+
+	var up atomic.Bool
+	up.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		if !up.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write(body)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{
+		ClientConfig: confighttp.ClientConfig{Endpoint: srv.URL},
+		Interval:     time.Hour,
+		CollectorID:  "collector-1",
+		CacheFile:    cacheFile,
+	}
+
+	// a successful sync persists the response
+	scrapeManager, discoveryManager := initPrometheusManagers(ctx, t)
+	manager := NewManager(receivertest.NewNopSettings(), cfg, &promconfig.Config{GlobalConfig: promconfig.DefaultGlobalConfig}, false)
+	require.NoError(t, manager.Start(ctx, componenttest.NewNopHost(), scrapeManager, discoveryManager, nil))
+	manager.Shutdown()
+	cached, err := os.ReadFile(cacheFile)
+	require.NoError(t, err)
+	assert.Equal(t, body, cached)
+
+	// an unreachable allocator at startup falls back to the cached jobs
+	up.Store(false)
+	scrapeManager, discoveryManager = initPrometheusManagers(ctx, t)
+	manager = NewManager(receivertest.NewNopSettings(), cfg, &promconfig.Config{GlobalConfig: promconfig.DefaultGlobalConfig}, false)
+	require.NoError(t, manager.Start(ctx, componenttest.NewNopHost(), scrapeManager, discoveryManager, nil))
+	defer manager.Shutdown()
+	require.Len(t, manager.promCfg.ScrapeConfigs, 1)
+	assert.Equal(t, "job1", manager.promCfg.ScrapeConfigs[0].JobName)
+	assert.Len(t, discoveryManager.Providers(), 1)
+}
@@ -0,0 +1,273 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/prometheusreceiver"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/route"
+	"github.com/prometheus/common/version"
+	toolkitweb "github.com/prometheus/exporter-toolkit/web"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/web"
+	apiv1 "github.com/prometheus/prometheus/web/api/v1"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+	"golang.org/x/net/netutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultWebEndpoint    = ":9090"
+	defaultWebRoutePrefix = "/"
+)
+
+// apiOnlyPaths are the only paths served by the web handler in API only mode.
+var apiOnlyPaths = []string{"/api/v1/targets", "/api/v1/status/config"}
+
+// toolkitWebConfig is the exporter-toolkit web configuration file format used to
+// enable TLS and basic authentication on the web handler.
+type toolkitWebConfig struct {
+	TLSServerConfig *toolkitTLSServerConfig `yaml:"tls_server_config,omitempty"`
+	BasicAuthUsers  map[string]string       `yaml:"basic_auth_users,omitempty"`
+}
+
+type toolkitTLSServerConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// startWebHandler creates the Prometheus web handler and serves it according to the web config.
+func (r *pReceiver) startWebHandler(ctx context.Context, host component.Host) error {
+	cfg := r.cfg.Web
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultWebEndpoint
+	}
+	routePrefix := cfg.RoutePrefix
+	if routePrefix == "" {
+		routePrefix = defaultWebRoutePrefix
+	}
+	maxConns := cfg.MaxConnections
+	if maxConns == 0 {
+		maxConns = maxConnections
+	}
+	externalURL, err := webExternalURL(endpoint, routePrefix, cfg.TLSCertFile != "")
+	if err != nil {
+		return err
+	}
+
+	webConfigFile, err := writeWebConfigFile(&cfg)
+	if err != nil {
+		return err
+	}
+	r.webConfigFile = webConfigFile
+	goKitLogger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	promVersion := &web.PrometheusVersion{
+		Version:   version.Version,
+		Revision:  version.Revision,
+		Branch:    version.Branch,
+		BuildUser: version.BuildUser,
+		BuildDate: version.BuildDate,
+		GoVersion: version.GoVersion,
+	}
+
+	if cfg.APIOnly {
+		return r.startAPIOnlyHandler(ctx, host, endpoint, routePrefix, externalURL, maxConns, promVersion, goKitLogger)
+	}
+
+	// Setup settings and logger and create Prometheus web handler
+	webOptions := web.Options{
+		ScrapeManager:  r.scrapeManager,
+		Context:        ctx,
+		ListenAddress:  endpoint,
+		ExternalURL:    externalURL,
+		RoutePrefix:    routePrefix,
+		ReadTimeout:    time.Minute * readTimeoutMinutes,
+		PageTitle:      "Prometheus Receiver",
+		Version:        promVersion,
+		Flags:          make(map[string]string),
+		MaxConnections: maxConns,
+		IsAgent:        true,
+		Gatherer:       prometheus.DefaultGatherer,
+	}
+	webHandler := web.New(goKitLogger, &webOptions)
+	r.webHandler = webHandler
+
+	listener, err := webHandler.Listener()
+	if err != nil {
+		return err
+	}
+
+	// Pass config and let the web handler know the config is ready.
+	// These are needed because Prometheus allows reloading the config without restarting.
+	if err = webHandler.ApplyConfig((*promconfig.Config)(r.cfg.PrometheusConfig)); err != nil {
+		return err
+	}
+	webHandler.SetReady(true)
+
+	// Uses the same context as the discovery and scrape managers for shutting down
+	go func() {
+		if err := webHandler.Run(ctx, listener, webConfigFile); err != nil {
+			r.settings.Logger.Error("Web handler failed", zap.Error(err))
+			componentstatus.ReportStatus(host, componentstatus.NewFatalErrorEvent(err))
+		}
+	}()
+	return nil
+}
+
+// apiOnlyHandler serves the apiOnlyPaths of the Prometheus API, without the UI and the other endpoints
+// of the web handler.
+type apiOnlyHandler struct {
+	mu     sync.RWMutex
+	config promconfig.Config
+	mux    *http.ServeMux
+}
+
+// ApplyConfig updates the config served on /api/v1/status/config.
+func (h *apiOnlyHandler) ApplyConfig(cfg *promconfig.Config) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config = *cfg
+	return nil
+}
+
+func (h *apiOnlyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// newAPIOnlyHandler creates the Prometheus API like the web handler does in agent mode, and routes only
+// the apiOnlyPaths to it.
+func newAPIOnlyHandler(scrapeManager *scrape.Manager, endpoint, routePrefix string, externalURL *url.URL, promVersion *web.PrometheusVersion, logger log.Logger) *apiOnlyHandler {
+	h := &apiOnlyHandler{mux: http.NewServeMux()}
+	api := apiv1.NewAPI(nil, nil, nil, nil,
+		func(context.Context) apiv1.ScrapePoolsRetriever { return scrapeManager },
+		func(context.Context) apiv1.TargetRetriever { return scrapeManager },
+		nil,
+		func() promconfig.Config {
+			h.mu.RLock()
+			defer h.mu.RUnlock()
+			return h.config
+		},
+		map[string]string{},
+		apiv1.GlobalURLOptions{
+			ListenAddress: endpoint,
+			Host:          externalURL.Host,
+			Scheme:        externalURL.Scheme,
+		},
+		func(f http.HandlerFunc) http.HandlerFunc { return f },
+		nil, "", false, logger, nil, 0, 0, 0,
+		true, nil, nil, promVersion, prometheus.DefaultGatherer, nil, nil,
+		false, nil, false,
+	)
+	router := route.New()
+	api.Register(router)
+
+	apiPath := path.Join(routePrefix, "/api/v1")
+	for _, p := range apiOnlyPaths {
+		h.mux.Handle(path.Join(routePrefix, p), http.StripPrefix(apiPath, router))
+	}
+	return h
+}
+
+// startAPIOnlyHandler serves the apiOnlyPaths on the endpoint. The web handler is not created, so the UI
+// is not served on any address.
+func (r *pReceiver) startAPIOnlyHandler(ctx context.Context, host component.Host, endpoint, routePrefix string, externalURL *url.URL, maxConns int, promVersion *web.PrometheusVersion, logger log.Logger) error {
+	handler := newAPIOnlyHandler(r.scrapeManager, endpoint, routePrefix, externalURL, promVersion, logger)
+	if err := handler.ApplyConfig((*promconfig.Config)(r.cfg.PrometheusConfig)); err != nil {
+		return err
+	}
+	r.webHandler = handler
+
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	listener = netutil.LimitListener(listener, maxConns)
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       time.Minute * readTimeoutMinutes,
+		ReadHeaderTimeout: time.Minute * readTimeoutMinutes,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	go func() {
+		r.settings.Logger.Info("Serving Prometheus API only", zap.String("endpoint", endpoint), zap.Strings("paths", apiOnlyPaths))
+		err := toolkitweb.Serve(listener, srv, &toolkitweb.FlagConfig{WebConfigFile: &r.webConfigFile}, logger)
+		if err != nil && err != http.ErrServerClosed {
+			r.settings.Logger.Error("Web API server failed", zap.Error(err))
+			componentstatus.ReportStatus(host, componentstatus.NewFatalErrorEvent(err))
+		}
+	}()
+	return nil
+}
+
+// webExternalURL returns the URL the web handler is reachable under, used to build links in the UI.
+func webExternalURL(endpoint, routePrefix string, tls bool) (*url.URL, error) {
+	_, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("web: invalid endpoint %q: %w", endpoint, err)
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	return &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort("localhost", port),
+		Path:   strings.TrimSuffix(routePrefix, "/"),
+	}, nil
+}
+
+// writeWebConfigFile writes the TLS and basic auth settings to a temporary exporter-toolkit
+// web configuration file and returns its path, or an empty path if neither is configured.
+func writeWebConfigFile(cfg *WebConfig) (string, error) {
+	if cfg.TLSCertFile == "" && len(cfg.BasicAuthUsers) == 0 {
+		return "", nil
+	}
+
+	webCfg := toolkitWebConfig{}
+	if cfg.TLSCertFile != "" {
+		webCfg.TLSServerConfig = &toolkitTLSServerConfig{
+			CertFile: cfg.TLSCertFile,
+			KeyFile:  cfg.TLSKeyFile,
+		}
+	}
+	if len(cfg.BasicAuthUsers) != 0 {
+		webCfg.BasicAuthUsers = make(map[string]string, len(cfg.BasicAuthUsers))
+		for user, hash := range cfg.BasicAuthUsers {
+			webCfg.BasicAuthUsers[user] = string(hash)
+		}
+	}
+	out, err := yaml.Marshal(webCfg)
+	if err != nil {
+		return "", fmt.Errorf("web: failed to marshal web config: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "prometheusreceiver-web-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("web: failed to create web config file: %w", err)
+	}
+	defer f.Close()
+	if _, err = f.Write(out); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("web: failed to write web config file: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusreceiver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promconfig "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWebConfigValidate(t *testing.T) {
+	assert.NoError(t, (&WebConfig{}).Validate())
+	assert.NoError(t, (&WebConfig{Disabled: true, TLSCertFile: "missing.crt"}).Validate())
+	assert.Error(t, (&WebConfig{TLSCertFile: "server.crt"}).Validate())
+	assert.Error(t, (&WebConfig{TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"}).Validate())
+	assert.Error(t, (&WebConfig{RoutePrefix: "prom"}).Validate())
+	assert.Error(t, (&WebConfig{MaxConnections: -1}).Validate())
+}
+
+func TestWebExternalURL(t *testing.T) {
+	u, err := webExternalURL(":9090", "/", false)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9090", u.String())
+
+	u, err = webExternalURL("0.0.0.0:9443", "/prometheus/", true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://localhost:9443/prometheus", u.String())
+
+	_, err = webExternalURL("9090", "/", false)
+	assert.Error(t, err)
+}
+
+func TestWriteWebConfigFile(t *testing.T) {
+	file, err := writeWebConfigFile(&WebConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, file)
+
+	file, err = writeWebConfigFile(&WebConfig{
+		TLSCertFile:    "server.crt",
+		TLSKeyFile:     "server.key",
+		BasicAuthUsers: map[string]configopaque.String{"admin": "$2y$10$hash"},
+	})
+	require.NoError(t, err)
+	defer os.Remove(file)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var got toolkitWebConfig
+	require.NoError(t, yaml.Unmarshal(content, &got))
+	assert.Equal(t, toolkitWebConfig{
+		TLSServerConfig: &toolkitTLSServerConfig{CertFile: "server.crt", KeyFile: "server.key"},
+		BasicAuthUsers:  map[string]string{"admin": "$2y$10$hash"},
+	}, got)
+}
+
+func TestWebHandlerAPIOnly(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	endpoint := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Web = WebConfig{Endpoint: endpoint, APIOnly: true}
+	r := newPrometheusReceiver(receivertest.NewNopSettings(), cfg, nil)
+	r.scrapeManager, err = scrape.NewManager(&scrape.Options{}, nil, nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, r.startWebHandler(ctx, componenttest.NewNopHost()))
+
+	// The full web handler is not created, so the UI is not served on any address
+	_, isAPIOnly := r.webHandler.(*apiOnlyHandler)
+	assert.True(t, isAPIOnly)
+
+	get := func(path string) (int, string) {
+		var resp *http.Response
+		require.Eventually(t, func() bool {
+			resp, err = http.Get(fmt.Sprintf("http://%s%s", endpoint, path))
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/api/v1/status/config")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"status":"success"`)
+	status, _ = get("/api/v1/targets")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = get("/api/v1/status/flags")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = get("/graph")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// The config applied from the target allocator is served
+	applied := promconfig.DefaultConfig
+	applied.ScrapeConfigs = []*promconfig.ScrapeConfig{{JobName: "from-target-allocator"}}
+	require.NoError(t, r.webHandler.ApplyConfig(&applied))
+	_, body = get("/api/v1/status/config")
+	assert.Contains(t, body, "from-target-allocator")
+}
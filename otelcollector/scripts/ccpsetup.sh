@@ -20,11 +20,8 @@ gem install tomlrb
 gem install deep_merge
 gem install re2
 
-#download inotify tools for watching configmap changes
-echo "Installing inotify..."
 sudo tdnf check-update
 sudo tdnf repolist --refresh
-sudo tdnf install inotify-tools -y
 
 echo "Installing mdsd..."
 sudo tdnf install -y azure-mdsd-1.30.3
@@ -19,11 +19,8 @@ chmod 544 $TMPDIR/microsoft/configmapparser/*.rb
 
 chmod 744 /usr/sbin/
 
-#download inotify tools for watching configmap changes
-echo "Installing inotify..."
 sudo tdnf check-update
 sudo tdnf repolist --refresh
-sudo tdnf install inotify-tools -y
 
 echo "Installing packages for re2 gem install..."
 sudo tdnf install -y build-essential re2-devel
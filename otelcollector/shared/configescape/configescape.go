@@ -0,0 +1,202 @@
+// Package configescape converts the strings of a prometheus config between the form users write them in,
+// the form the otelcollector config needs for its confmap env var expansion, and the form the target
+// allocator reads, which does no expansion.
+//
+// The grammar of a string in a prometheus config, as written by users, is:
+//
+//	string      = { literal | placeholder | dollar } ;
+//	placeholder = ( "$$" | "$" ) ( name | "{" name "}" ) ;
+//	dollar      = "$$" | "$" ;
+//	literal     = any character but "$" ;
+//
+// where name is an allow-listed env var name. A name without braces is the longest identifier
+// ([A-Za-z_][A-Za-z0-9_]*) following the dollars, so "$NODE_NAMESPACE" is not the NODE_NAME placeholder.
+// A dollar that does not start a placeholder is a literal "$", whether it is written "$$" or "$", so "$1" and
+// "$$1" are both the capture group reference of a relabel replacement.
+//
+// Escape converts a string to the otelcollector form: every literal "$" becomes "$$" and every placeholder
+// becomes "${env:NAME}", which the collector replaces with the value of the env var when it loads its config.
+// Unescape converts the otelcollector form back for the target allocator: "$$" becomes "$" and "${env:NAME}"
+// is kept as is, since the allocator does not run on the node the placeholders refer to. Expand converts a
+// string to the value the collector ends up with.
+package configescape
+
+import (
+	"os"
+	"strings"
+)
+
+// AllowlistEnv is the env var with the comma separated names of the env vars that can be used as placeholders
+// in addition to DefaultPlaceholders
+const AllowlistEnv = "AZMON_PROMETHEUS_CONFIG_ENV_VARS"
+
+// DefaultPlaceholders are the env vars that can always be used as placeholders
+var DefaultPlaceholders = []string{"NODE_NAME", "NODE_IP", "POD_NAMESPACE"}
+
+// Escaper converts the strings of a prometheus config with a set of allowed placeholders
+type Escaper struct {
+	allowed map[string]bool
+}
+
+// New returns an Escaper that allows DefaultPlaceholders and names as placeholders
+func New(names ...string) *Escaper {
+	e := &Escaper{allowed: map[string]bool{}}
+	for _, name := range append(append([]string{}, DefaultPlaceholders...), names...) {
+		if name = strings.TrimSpace(name); isIdentifier(name) {
+			e.allowed[name] = true
+		}
+	}
+	return e
+}
+
+// FromEnv returns an Escaper that allows DefaultPlaceholders and the names listed in AllowlistEnv
+func FromEnv() *Escaper {
+	return New(strings.Split(os.Getenv(AllowlistEnv), ",")...)
+}
+
+// token is a part of a string in the users' form: a literal, or a placeholder if name is set
+type token struct {
+	literal string
+	name    string
+}
+
+// tokenize splits a string in the users' form into literals and placeholders
+func (e *Escaper) tokenize(s string) []token {
+	var tokens []token
+	var literal strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '$' {
+			literal.WriteByte(s[i])
+			i++
+			continue
+		}
+		start := i + 1
+		if start < len(s) && s[start] == '$' {
+			start++
+		}
+		if name, end, ok := e.placeholder(s, start); ok {
+			if literal.Len() > 0 {
+				tokens = append(tokens, token{literal: literal.String()})
+				literal.Reset()
+			}
+			tokens = append(tokens, token{name: name})
+			i = end
+			continue
+		}
+		literal.WriteByte('$')
+		i = start
+	}
+	if literal.Len() > 0 {
+		tokens = append(tokens, token{literal: literal.String()})
+	}
+	return tokens
+}
+
+// placeholder returns the allowed placeholder name at s[start:] and where it ends
+func (e *Escaper) placeholder(s string, start int) (string, int, bool) {
+	if start < len(s) && s[start] == '{' {
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", 0, false
+		}
+		name := s[start+1 : start+end]
+		return name, start + end + 1, e.allowed[name]
+	}
+	end := start
+	for end < len(s) && isIdentifierByte(s[end], end == start) {
+		end++
+	}
+	name := s[start:end]
+	return name, end, e.allowed[name]
+}
+
+// Escape converts a string from the users' form to the otelcollector form
+func (e *Escaper) Escape(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for _, t := range e.tokenize(s) {
+		if t.name != "" {
+			b.WriteString("${env:" + t.name + "}")
+		} else {
+			b.WriteString(strings.ReplaceAll(t.literal, "$", "$$"))
+		}
+	}
+	return b.String()
+}
+
+// Expand converts a string from the users' form to its value, with the placeholders replaced by getenv
+func (e *Escaper) Expand(s string, getenv func(string) string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for _, t := range e.tokenize(s) {
+		if t.name != "" {
+			b.WriteString(getenv(t.name))
+		} else {
+			b.WriteString(t.literal)
+		}
+	}
+	return b.String()
+}
+
+// Unescape converts a string from the otelcollector form to the target allocator form: "$$" becomes "$" and
+// the "${env:NAME}" placeholders are kept
+func Unescape(s string) string {
+	return strings.ReplaceAll(s, "$$", "$")
+}
+
+// EscapeConfig escapes every string value of a config decoded from YAML, and returns the escaped config. The
+// maps and slices of the config are updated in place, the keys of the maps are not changed.
+func (e *Escaper) EscapeConfig(config interface{}) interface{} {
+	return walkStrings(config, e.Escape)
+}
+
+// ExpandConfig expands every string value of a config decoded from YAML like EscapeConfig
+func (e *Escaper) ExpandConfig(config interface{}, getenv func(string) string) interface{} {
+	return walkStrings(config, func(s string) string { return e.Expand(s, getenv) })
+}
+
+// UnescapeConfig unescapes every string value of a config decoded from YAML like EscapeConfig
+func UnescapeConfig(config interface{}) interface{} {
+	return walkStrings(config, Unescape)
+}
+
+// walkStrings replaces every string value in the maps and slices of v by f of it
+func walkStrings(v interface{}, f func(string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[interface{}]interface{}:
+		for key, value := range v {
+			v[key] = walkStrings(value, f)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = walkStrings(value, f)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = walkStrings(value, f)
+		}
+	}
+	return v
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentifierByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifierByte(c byte, first bool) bool {
+	return c == '_' || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || (!first && '0' <= c && c <= '9')
+}
@@ -0,0 +1,166 @@
+package configescape
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var testEnv = map[string]string{
+	"NODE_NAME":     "node-1",
+	"NODE_IP":       "10.0.0.4",
+	"POD_NAMESPACE": "kube-system",
+	"CLUSTER":       "cluster-1",
+}
+
+func getenv(name string) string {
+	return testEnv[name]
+}
+
+func TestEscape(t *testing.T) {
+	e := New("CLUSTER")
+	tests := []struct {
+		name     string
+		in       string
+		escaped  string
+		expanded string
+	}{
+		{"empty", "", "", ""},
+		{"no dollar", "kube-state-metrics", "kube-state-metrics", "kube-state-metrics"},
+		{"single dollar at the end", "foo$", "foo$$", "foo$"},
+		{"double dollar at the end", "foo$$", "foo$$", "foo$"},
+		{"regex anchor", "^(.*)$", "^(.*)$$", "^(.*)$"},
+		{"capture group", "$1", "$$1", "$1"},
+		{"escaped capture group", "$$1", "$$1", "$1"},
+		{"named capture group", "${1}:${port}", "$${1}:$${port}", "${1}:${port}"},
+		{"escaped named capture group", "$${1}", "$${1}", "${1}"},
+		{"two capture groups", "$1:$2", "$$1:$$2", "$1:$2"},
+		{"four dollars", "$$$$", "$$$$", "$$"},
+		{"three dollars", "$$$", "$$$$", "$$"},
+		{"placeholder", "$NODE_NAME", "${env:NODE_NAME}", "node-1"},
+		{"escaped placeholder", "$$NODE_NAME", "${env:NODE_NAME}", "node-1"},
+		{"braced placeholder", "${NODE_IP}", "${env:NODE_IP}", "10.0.0.4"},
+		{"escaped braced placeholder", "$${NODE_IP}", "${env:NODE_IP}", "10.0.0.4"},
+		{"placeholder in a target", "$NODE_IP:9100", "${env:NODE_IP}:9100", "10.0.0.4:9100"},
+		{"placeholder followed by a dollar", "$NODE_NAME$", "${env:NODE_NAME}$$", "node-1$"},
+		{"placeholder after a literal dollar", "$$$NODE_NAME", "$$${env:NODE_NAME}", "$node-1"},
+		{"two placeholders", "$NODE_NAME/$$NODE_IP", "${env:NODE_NAME}/${env:NODE_IP}", "node-1/10.0.0.4"},
+		{"pod namespace", "$$POD_NAMESPACE", "${env:POD_NAMESPACE}", "kube-system"},
+		{"allow-listed env var", "$CLUSTER-$NODE_NAME", "${env:CLUSTER}-${env:NODE_NAME}", "cluster-1-node-1"},
+		{"allow-listed env var braced", "${CLUSTER}", "${env:CLUSTER}", "cluster-1"},
+		{"longer identifier", "$NODE_NAMESPACE", "$$NODE_NAMESPACE", "$NODE_NAMESPACE"},
+		{"identifier with a digit", "$NODE_IP2", "$$NODE_IP2", "$NODE_IP2"},
+		{"unknown env var", "$HOME", "$$HOME", "$HOME"},
+		{"unknown braced env var", "${HOME}", "$${HOME}", "${HOME}"},
+		{"unclosed brace", "${NODE_NAME", "$${NODE_NAME", "${NODE_NAME"},
+		{"lowercase is not a placeholder", "$node_name", "$$node_name", "$node_name"},
+		{"shard placeholder of the target allocator", "$(SHARD)", "$$(SHARD)", "$(SHARD)"},
+		{"collector syntax is a literal", "${env:NODE_NAME}", "$${env:NODE_NAME}", "${env:NODE_NAME}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.escaped, e.Escape(tt.in))
+			assert.Equal(t, tt.expanded, e.Expand(tt.in, getenv))
+		})
+	}
+}
+
+// TestRoundTrip checks that the target allocator gets the value of a string without the placeholders replaced
+func TestRoundTrip(t *testing.T) {
+	e := New()
+	tests := []struct {
+		in        string
+		allocator string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"^(.*)$", "^(.*)$"},
+		{"$$1", "$1"},
+		{"$1", "$1"},
+		{"$$$$", "$$"},
+		{"$(SHARD)", "$(SHARD)"},
+		{"$NODE_NAME", "${env:NODE_NAME}"},
+		{"$$$NODE_NAME$", "$${env:NODE_NAME}$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.allocator, Unescape(e.Escape(tt.in)))
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(AllowlistEnv, " CLUSTER , ,not valid,1ABC")
+	e := FromEnv()
+	assert.Equal(t, "${env:CLUSTER}", e.Escape("$CLUSTER"))
+	assert.Equal(t, "${env:NODE_NAME}", e.Escape("$NODE_NAME"))
+	assert.Equal(t, "$${1ABC}", e.Escape("${1ABC}"))
+
+	t.Setenv(AllowlistEnv, "")
+	assert.Equal(t, "$$CLUSTER", FromEnv().Escape("$CLUSTER"))
+}
+
+func TestEscapeConfig(t *testing.T) {
+	content := `
+global:
+  external_labels:
+    node: $NODE_NAME
+scrape_configs:
+- job_name: node
+  honor_labels: true
+  basic_auth:
+    password: pa$word
+  static_configs:
+  - targets: [$NODE_IP:9100, localhost:9100]
+    labels:
+      namespace: $$POD_NAMESPACE
+  relabel_configs:
+  - source_labels: [__address__]
+    regex: (.*):\d+$
+    replacement: $$1
+    target_label: instance
+  metric_relabel_configs:
+  - regex: true
+`
+	var config map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal([]byte(content), &config))
+	New().EscapeConfig(config)
+
+	out, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	var escaped map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(out, &escaped))
+
+	global := escaped["global"].(map[interface{}]interface{})
+	assert.Equal(t, "${env:NODE_NAME}", global["external_labels"].(map[interface{}]interface{})["node"])
+	job := escaped["scrape_configs"].([]interface{})[0].(map[interface{}]interface{})
+	assert.Equal(t, true, job["honor_labels"])
+	assert.Equal(t, "pa$$word", job["basic_auth"].(map[interface{}]interface{})["password"])
+	static := job["static_configs"].([]interface{})[0].(map[interface{}]interface{})
+	assert.Equal(t, []interface{}{"${env:NODE_IP}:9100", "localhost:9100"}, static["targets"])
+	assert.Equal(t, "${env:POD_NAMESPACE}", static["labels"].(map[interface{}]interface{})["namespace"])
+	relabel := job["relabel_configs"].([]interface{})[0].(map[interface{}]interface{})
+	assert.Equal(t, `(.*):\d+$$`, relabel["regex"])
+	assert.Equal(t, "$$1", relabel["replacement"])
+	assert.Equal(t, []interface{}{"__address__"}, relabel["source_labels"])
+	assert.Equal(t, true, job["metric_relabel_configs"].([]interface{})[0].(map[interface{}]interface{})["regex"])
+
+	UnescapeConfig(escaped)
+	relabel = escaped["scrape_configs"].([]interface{})[0].(map[interface{}]interface{})["relabel_configs"].([]interface{})[0].(map[interface{}]interface{})
+	assert.Equal(t, `(.*):\d+$`, relabel["regex"])
+	assert.Equal(t, "$1", relabel["replacement"])
+}
+
+func TestExpandConfig(t *testing.T) {
+	config := []interface{}{
+		map[interface{}]interface{}{"regex": "$$NODE_NAME", "replacement": "$$1"},
+		"${NODE_IP}",
+	}
+	New().ExpandConfig(config, getenv)
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"regex": "node-1", "replacement": "$1"},
+		"10.0.0.4",
+	}, config)
+	assert.Equal(t, "node-1", New().ExpandConfig("$NODE_NAME", getenv))
+}
@@ -2,30 +2,35 @@ package ccpconfigmapsettings
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	// "prometheus-collector/shared"
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 )
 
-func Configmapparserforccp() {
+// Configmapparserforccp parses the settings configmaps, generates the control plane collector config and
+// returns the settings, which it also saves for the other processes.
+func Configmapparserforccp() *settings.Settings {
 	fmt.Printf("in configmapparserforccp")
 	configVersionPath := "/etc/config/settings/config-version"
 	configSchemaPath := "/etc/config/settings/schema-version"
+	s := &settings.Settings{}
 	// Set agent config schema version
 	if shared.ExistsAndNotEmpty(configSchemaPath) {
 		configVersion, err := shared.ReadAndTrim(configVersionPath)
 		if err != nil {
 			fmt.Println("Error reading config version file:", err)
-			return
+			return s
 		}
 		// Remove all spaces and take the first 10 characters
 		configVersion = strings.ReplaceAll(configVersion, " ", "")
 		if len(configVersion) >= 10 {
 			configVersion = configVersion[:10]
 		}
-		// Set the environment variable
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_FILE_VERSION", configVersion, true)
+		s.ConfigFileVersion = configVersion
+		shared.EchoVar("AZMON_AGENT_CFG_FILE_VERSION", configVersion)
 	}
 
 	// Set agent config file version
@@ -33,44 +38,36 @@ func Configmapparserforccp() {
 		configSchemaVersion, err := shared.ReadAndTrim(configSchemaPath)
 		if err != nil {
 			fmt.Println("Error reading config schema version file:", err)
-			return
+			return s
 		}
 		// Remove all spaces and take the first 10 characters
 		configSchemaVersion = strings.ReplaceAll(configSchemaVersion, " ", "")
 		if len(configSchemaVersion) >= 10 {
 			configSchemaVersion = configSchemaVersion[:10]
 		}
-		// Set the environment variable
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_SCHEMA_VERSION", configSchemaVersion, true)
+		s.ConfigSchemaVersion = configSchemaVersion
+		shared.EchoVar("AZMON_AGENT_CFG_SCHEMA_VERSION", configSchemaVersion)
 	}
 
-	// Parse the configmap to set the right environment variables for prometheus collector settings
-	parseConfigAndSetEnvInFile()
-	filename := "/opt/microsoft/configmapparser/config_prometheus_collector_settings_env_var"
-	err := shared.SetEnvVarsFromFile(filename)
-	if err != nil {
-		fmt.Printf("Error when settinng env for /opt/microsoft/configmapparser/config_prometheus_collector_settings_env_var: %v\n", err)
-	}
+	// Parse the configmap for the prometheus collector settings
+	parseCollectorSettings(s)
 
 	// Parse the settings for default scrape configs
-	tomlparserCCPDefaultScrapeSettings()
-	filename = "/opt/microsoft/configmapparser/config_default_scrape_settings_env_var"
-	err = shared.SetEnvVarsFromFile(filename)
-	if err != nil {
-		fmt.Printf("Error when settinng env for /opt/microsoft/configmapparser/config_default_scrape_settings_env_var: %v\n", err)
-	}
+	tomlparserCCPDefaultScrapeSettings(s)
 
 	// Parse the settings for default targets metrics keep list config
-	tomlparserCCPTargetsMetricsKeepList()
-
-	prometheusCcpConfigMerger()
+	tomlparserCCPTargetsMetricsKeepList(s)
 
-	shared.SetEnvAndSourceBashrcOrPowershell("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG", "false", true)
-	shared.SetEnvAndSourceBashrcOrPowershell("CONFIG_VALIDATOR_RUNNING_IN_AGENT", "true", true)
+	prometheusCcpConfigMerger(s)
 
 	// No need to merge custom prometheus config, only merging in the default configs
-	shared.SetEnvAndSourceBashrcOrPowershell("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG", "true", true)
-	shared.StartCommandAndWait("/opt/promconfigvalidator", "--config", "/opt/defaultsMergedConfig.yml", "--output", "/opt/ccp-collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/ccp-collector-config-template.yml")
+	s.UseDefaultPrometheusConfig = true
+
+	if err := os.Remove(settings.ValidatorResultsPath); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error removing the prom config validator results: %v\n", err)
+	}
+	// The default scrape configs are not linted
+	shared.StartCommandAndWait("/opt/promconfigvalidator", "--config", "/opt/defaultsMergedConfig.yml", "--output", "/opt/ccp-collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/ccp-collector-config-template.yml", "--results", settings.ValidatorResultsPath, "--lint=false")
 	if !shared.Exists("/opt/ccp-collector-config-with-defaults.yml") {
 		fmt.Printf("prom-config-validator::Prometheus default scrape config validation failed. No scrape configs will be used")
 	} else {
@@ -83,4 +80,15 @@ func Configmapparserforccp() {
 			fmt.Println("File copied successfully.")
 		}
 	}
+
+	results, err := settings.LoadValidatorResults(settings.ValidatorResultsPath)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error reading the prom config validator results: %v\n", err)
+	}
+	s.ValidatorResults = results
+
+	if err := s.Save(settings.DefaultPath); err != nil {
+		fmt.Printf("Error saving settings: %v\n", err)
+	}
+	return s
 }
@@ -1,5 +1,7 @@
 package ccpconfigmapsettings
 
+import "github.com/prometheus-collector/shared/settings"
+
 type RegexValues struct {
 	ControlplaneKubeControllerManager string
 	ControlplaneKubeScheduler         string
@@ -37,15 +39,9 @@ type ConfigParser interface {
 
 // Configurator is responsible for configuring the application.
 type Configurator struct {
-	ConfigLoader   *FilesystemConfigLoader
-	ConfigParser   *ConfigProcessor
-	ConfigWriter   *FileConfigWriter
-	ConfigFilePath string
-}
-
-type FileConfigWriter struct {
-	ConfigProcessor *ConfigProcessor
-	Config          map[string]string
+	ConfigLoader *FilesystemConfigLoader
+	ConfigParser *ConfigProcessor
+	Settings     *settings.Settings
 }
 
 // ConfigLoader is an interface for loading configurations.
@@ -53,8 +49,3 @@ type ConfigLoader interface {
 	ParseConfigMapForDefaultScrapeSettings() (map[string]string, error)
 	SetDefaultScrapeSettings() (map[string]string, error)
 }
-
-// ConfigWriter is an interface for writing configurations to a file.
-type ConfigWriter interface {
-	WriteDefaultScrapeSettingsToFile(filename string) error
-}
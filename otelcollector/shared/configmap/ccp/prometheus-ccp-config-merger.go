@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -87,13 +88,13 @@ func appendMetricRelabelConfig(yamlConfigFile, keepListRegex string) {
 	}
 }
 
-func populateDefaultPrometheusConfig() {
+func populateDefaultPrometheusConfig(s *settings.Settings) {
 	loadRegexHash()
 
 	defaultConfigs := []string{}
 	currentControllerType := strings.TrimSpace(strings.ToLower(os.Getenv("CONTROLLER_TYPE")))
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_CONTROLLER_MANAGER_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.ControlPlaneScrape.KubeControllerManager && currentControllerType == replicasetControllerType {
 		fmt.Println("Kube Controller Manager enabled.")
 		kubeControllerManagerMetricsKeepListRegex, exists := regexHash["CONTROLPLANE_KUBE_CONTROLLER_MANAGER_KEEP_LIST_REGEX"]
 		if exists && kubeControllerManagerMetricsKeepListRegex != "" {
@@ -108,7 +109,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, controlplaneKubeControllerManagerFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_SCHEDULER_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.ControlPlaneScrape.KubeScheduler && currentControllerType == replicasetControllerType {
 		controlplaneKubeSchedulerKeepListRegex, exists := regexHash["CONTROLPLANE_KUBE_SCHEDULER_KEEP_LIST_REGEX"]
 		if exists && controlplaneKubeSchedulerKeepListRegex != "" {
 			appendMetricRelabelConfig(controlplaneKubeSchedulerDefaultFile, controlplaneKubeSchedulerKeepListRegex)
@@ -121,7 +122,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, controlplaneKubeSchedulerDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CONTROLPLANE_APISERVER_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.ControlPlaneScrape.APIServer && currentControllerType == replicasetControllerType {
 		controlplaneApiserverKeepListRegex, exists := regexHash["CONTROLPLANE_APISERVER_KEEP_LIST_REGEX"]
 		if exists && controlplaneApiserverKeepListRegex != "" {
 			appendMetricRelabelConfig(controlplaneApiserverDefaultFile, controlplaneApiserverKeepListRegex)
@@ -134,7 +135,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, controlplaneApiserverDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CONTROLPLANE_CLUSTER_AUTOSCALER_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.ControlPlaneScrape.ClusterAutoscaler && currentControllerType == replicasetControllerType {
 		controlplaneClusterAutoscalerKeepListRegex, exists := regexHash["CONTROLPLANE_CLUSTER_AUTOSCALER_KEEP_LIST_REGEX"]
 		if exists && controlplaneClusterAutoscalerKeepListRegex != "" {
 			appendMetricRelabelConfig(controlplaneClusterAutoscalerFile, controlplaneClusterAutoscalerKeepListRegex)
@@ -147,7 +148,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, controlplaneClusterAutoscalerFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CONTROLPLANE_ETCD_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.ControlPlaneScrape.Etcd && currentControllerType == replicasetControllerType {
 		controlplaneEtcdKeepListRegex, exists := regexHash["CONTROLPLANE_ETCD_KEEP_LIST_REGEX"]
 		if exists && controlplaneEtcdKeepListRegex != "" {
 			appendMetricRelabelConfig(controlplaneEtcdDefaultFile, controlplaneEtcdKeepListRegex)
@@ -235,12 +236,11 @@ func deepMerge(target, source map[interface{}]interface{}) map[interface{}]inter
 	return target
 }
 
-func writeDefaultScrapeTargetsFile() {
+func writeDefaultScrapeTargetsFile(s *settings.Settings) {
 	fmt.Printf("Start Updating Default Prometheus Config\n")
-	noDefaultScrapingEnabled := os.Getenv("AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED")
-	if noDefaultScrapingEnabled != "" && strings.ToLower(noDefaultScrapingEnabled) == "false" {
+	if s.ControlPlaneScrape != nil && !s.NoDefaultScrapingEnabled {
 		loadRegexHash()
-		populateDefaultPrometheusConfig()
+		populateDefaultPrometheusConfig(s)
 		if mergedDefaultConfigs != nil && len(mergedDefaultConfigs) > 0 {
 			fmt.Printf("Starting to merge default prometheus config values in collector template as backup\n")
 			mergedDefaultConfigYaml, err := yaml.Marshal(mergedDefaultConfigs)
@@ -281,9 +281,9 @@ func setDefaultFileScrapeInterval(scrapeInterval string) {
 	}
 }
 
-func prometheusCcpConfigMerger() {
+func prometheusCcpConfigMerger(s *settings.Settings) {
 	mergedDefaultConfigs = make(map[interface{}]interface{}) // Initialize mergedDefaultConfigs
 	setDefaultFileScrapeInterval("30s")
-	writeDefaultScrapeTargetsFile()
+	writeDefaultScrapeTargetsFile(s)
 	fmt.Printf("Done creating default targets file\n")
 }
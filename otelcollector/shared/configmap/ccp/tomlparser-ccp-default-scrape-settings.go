@@ -5,6 +5,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/prometheus-collector/shared/settings"
 )
 
 func (fcl *FilesystemConfigLoader) SetDefaultScrapeSettings() (map[string]string, error) {
@@ -92,25 +94,21 @@ func (cp *ConfigProcessor) PopulateSettingValues(parsedConfig map[string]string)
 	}
 }
 
-func (fcw *FileConfigWriter) WriteDefaultScrapeSettingsToFile(filename string, cp *ConfigProcessor) error {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("exception while opening file for writing prometheus-collector config environment variables: %s", err)
-	}
-	defer file.Close()
-
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_CONTROLLER_MANAGER_ENABLED=%v\n", cp.ControlplaneKubeControllerManager))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_SCHEDULER_ENABLED=%v\n", cp.ControlplaneKubeScheduler))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CONTROLPLANE_APISERVER_ENABLED=%v\n", cp.ControlplaneApiserver))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CONTROLPLANE_CLUSTER_AUTOSCALER_ENABLED=%v\n", cp.ControlplaneClusterAutoscaler))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CONTROLPLANE_ETCD_ENABLED=%v\n", cp.ControlplaneEtcd))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED=%v\n", cp.NoDefaultsEnabled))
-
-	return nil
+// setControlPlaneScrapeSettings records the control plane targets enabled in s
+func (cp *ConfigProcessor) setControlPlaneScrapeSettings(s *settings.Settings) {
+	enabled := func(value string) bool { return strings.ToLower(value) == "true" }
+	s.ControlPlaneScrape = &settings.ControlPlaneScrapeSettings{
+		KubeControllerManager: enabled(cp.ControlplaneKubeControllerManager),
+		KubeScheduler:         enabled(cp.ControlplaneKubeScheduler),
+		APIServer:             enabled(cp.ControlplaneApiserver),
+		ClusterAutoscaler:     enabled(cp.ControlplaneClusterAutoscaler),
+		Etcd:                  enabled(cp.ControlplaneEtcd),
+	}
+	s.NoDefaultScrapingEnabled = cp.NoDefaultsEnabled
 }
 
 func (c *Configurator) ConfigureDefaultScrapeSettings() {
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+	configSchemaVersion := c.Settings.ConfigSchemaVersion
 
 	fmt.Printf("Start prometheus-collector-settings Processing\n")
 
@@ -141,21 +139,16 @@ func (c *Configurator) ConfigureDefaultScrapeSettings() {
 		fmt.Printf("After replacing non-alpha-numeric characters with '_': %s\n", c.ConfigParser.ClusterAlias)
 	}
 
-	err := c.ConfigWriter.WriteDefaultScrapeSettingsToFile(c.ConfigFilePath, c.ConfigParser)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		return
-	}
+	c.ConfigParser.setControlPlaneScrapeSettings(c.Settings)
 
 	fmt.Printf("End prometheus-collector-settings Processing\n")
 }
 
-func tomlparserCCPDefaultScrapeSettings() {
+func tomlparserCCPDefaultScrapeSettings(s *settings.Settings) {
 	configurator := &Configurator{
-		ConfigLoader:   &FilesystemConfigLoader{ConfigMapMountPath: "/etc/config/settings/default-scrape-settings-enabled"},
-		ConfigWriter:   &FileConfigWriter{Config: map[string]string{}},
-		ConfigFilePath: "/opt/microsoft/configmapparser/config_default_scrape_settings_env_var",
-		ConfigParser:   &ConfigProcessor{},
+		ConfigLoader: &FilesystemConfigLoader{ConfigMapMountPath: "/etc/config/settings/default-scrape-settings-enabled"},
+		ConfigParser: &ConfigProcessor{},
+		Settings:     s,
 	}
 
 	fmt.Println("Start ccp-default-scrape-settings Processing")
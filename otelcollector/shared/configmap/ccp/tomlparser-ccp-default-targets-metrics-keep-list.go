@@ -7,6 +7,7 @@ import (
 
 	// "prometheus-collector/shared"
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 
 	"strings"
 
@@ -132,8 +133,8 @@ func populateRegexValuesWithMinimalIngestionProfile(regexValues RegexValues) {
 	}
 }
 
-func tomlparserCCPTargetsMetricsKeepList() {
-	configSchemaVersion = os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+func tomlparserCCPTargetsMetricsKeepList(s *settings.Settings) {
+	configSchemaVersion = s.ConfigSchemaVersion
 	fmt.Println("Start default-targets-metrics-keep-list Processing")
 
 	var regexValues RegexValues
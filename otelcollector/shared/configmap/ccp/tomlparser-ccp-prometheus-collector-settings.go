@@ -5,6 +5,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/prometheus-collector/shared/settings"
 )
 
 func (fcl *FilesystemConfigLoader) ParseConfigMap() (map[string]string, error) {
@@ -64,25 +66,19 @@ func (cp *ConfigProcessor) PopulateSettingValuesFromConfigMap(parsedConfig map[s
 	}
 }
 
-func (fcw *FileConfigWriter) WriteConfigToFile(filename string, configParser *ConfigProcessor) error {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("exception while opening file for writing prometheus-collector config environment variables: %s", err)
-	}
-	defer file.Close()
-	file.WriteString(fmt.Sprintf("AZMON_DEFAULT_METRIC_ACCOUNT_NAME=%s\n", configParser.DefaultMetricAccountName))
-	file.WriteString(fmt.Sprintf("AZMON_CLUSTER_LABEL=%s\n", configParser.ClusterLabel))
-	file.WriteString(fmt.Sprintf("AZMON_CLUSTER_ALIAS=%s\n", configParser.ClusterAlias))
-	file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED_CHART_SETTING=%t\n", configParser.IsOperatorEnabledChartSetting))
-	if configParser.IsOperatorEnabled {
-		file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED=%t\n", configParser.IsOperatorEnabled))
-		file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING=%t\n", configParser.IsOperatorEnabled))
+// setCollectorSettings records the prometheus collector settings in s
+func (cp *ConfigProcessor) setCollectorSettings(s *settings.Settings) {
+	s.Collector = settings.CollectorSettings{
+		DefaultMetricAccountName:    cp.DefaultMetricAccountName,
+		ClusterLabel:                cp.ClusterLabel,
+		ClusterAlias:                cp.ClusterAlias,
+		OperatorEnabledChartSetting: cp.IsOperatorEnabledChartSetting,
+		OperatorEnabled:             cp.IsOperatorEnabled,
 	}
-	return nil
 }
 
 func (c *Configurator) Configure() {
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+	configSchemaVersion := c.Settings.ConfigSchemaVersion
 
 	fmt.Printf("Start prometheus-collector-settings Processing\n")
 
@@ -112,21 +108,16 @@ func (c *Configurator) Configure() {
 	fmt.Printf("AZMON_CLUSTER_ALIAS: '%s'\n", c.ConfigParser.ClusterAlias)
 	fmt.Printf("AZMON_CLUSTER_LABEL: %s\n", c.ConfigParser.ClusterLabel)
 
-	err := c.ConfigWriter.WriteConfigToFile(c.ConfigFilePath, c.ConfigParser)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		return
-	}
+	c.ConfigParser.setCollectorSettings(c.Settings)
 
 	fmt.Printf("End prometheus-collector-settings Processing\n")
 }
 
-func parseConfigAndSetEnvInFile() {
+func parseCollectorSettings(s *settings.Settings) {
 	configurator := &Configurator{
-		ConfigLoader:   &FilesystemConfigLoader{ConfigMapMountPath: "/etc/config/settings/prometheus-collector-settings"},
-		ConfigParser:   &ConfigProcessor{},
-		ConfigWriter:   &FileConfigWriter{ConfigProcessor: &ConfigProcessor{}},
-		ConfigFilePath: "/opt/microsoft/configmapparser/config_prometheus_collector_settings_env_var",
+		ConfigLoader: &FilesystemConfigLoader{ConfigMapMountPath: "/etc/config/settings/prometheus-collector-settings"},
+		ConfigParser: &ConfigProcessor{},
+		Settings:     s,
 	}
 
 	configurator.Configure()
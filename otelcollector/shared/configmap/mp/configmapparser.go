@@ -1,13 +1,13 @@
 package configmapsettings
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 )
 
 const (
@@ -15,181 +15,177 @@ const (
 	defaultConfigFileVersion   = "ver1"
 )
 
-func setConfigSchemaVersionEnv() {
-	fileInfo, err := os.Stat(schemaVersionFile)
+// readVersionFile returns the trimmed first 10 characters of the version file at path, or defaultVersion
+// when it is missing or empty
+func readVersionFile(path, defaultVersion string) string {
+	fileInfo, err := os.Stat(path)
 	if err != nil || fileInfo.Size() == 0 {
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_SCHEMA_VERSION", defaultConfigSchemaVersion, true)
-		return
+		return defaultVersion
 	}
-	content, err := os.ReadFile(schemaVersionFile)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		shared.EchoError("Error reading schema version file:" + err.Error())
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_SCHEMA_VERSION", defaultConfigSchemaVersion, true)
-		return
+		shared.EchoError("Error reading version file " + path + ":" + err.Error())
+		return defaultVersion
 	}
-	trimmedContent := strings.TrimSpace(string(content))
-	configSchemaVersion := strings.ReplaceAll(trimmedContent, " ", "")
-	if len(configSchemaVersion) > 10 {
-		configSchemaVersion = configSchemaVersion[:10]
+	version := strings.ReplaceAll(strings.TrimSpace(string(content)), " ", "")
+	if len(version) > 10 {
+		version = version[:10]
 	}
-	shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_SCHEMA_VERSION", configSchemaVersion, true)
+	return version
 }
 
-func setConfigFileVersionEnv() {
-	fileInfo, err := os.Stat(configVersionFile)
-	if err != nil || fileInfo.Size() == 0 {
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_FILE_VERSION", defaultConfigFileVersion, true)
-		return
-	}
-	content, err := os.ReadFile(configVersionFile)
-	if err != nil {
-		shared.EchoError("Error reading config version file:" + err.Error())
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_FILE_VERSION", defaultConfigFileVersion, true)
-		return
-	}
-	trimmedContent := strings.TrimSpace(string(content))
-	configFileVersion := strings.ReplaceAll(trimmedContent, " ", "")
-	if len(configFileVersion) > 10 {
-		configFileVersion = configFileVersion[:10]
-	}
-	shared.SetEnvAndSourceBashrcOrPowershell("AZMON_AGENT_CFG_FILE_VERSION", configFileVersion, true)
-}
-
-func parseSettingsForPodAnnotations() {
+func parseSettingsForPodAnnotations(s *settings.Settings) {
 	shared.EchoSectionDivider("Start Processing - parseSettingsForPodAnnotations")
-	if err := configurePodAnnotationSettings(); err != nil {
+	if err := configurePodAnnotationSettings(s.DefaultScrape); err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	handlePodAnnotationsFile(podAnnotationEnvVarPath)
 	shared.EchoSectionDivider("End Processing - parseSettingsForPodAnnotations")
 }
 
-func handlePodAnnotationsFile(filename string) {
-	// Check if the file exists
-	_, e := os.Stat(filename)
-	if os.IsNotExist(e) {
-		fmt.Printf("File does not exist: %s\n", filename)
-		return
-	}
-
-	// Open the file for reading
-	file, err := os.Open(filename)
-	if err != nil {
-		fmt.Printf("Error opening file: %s\n", err)
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		index := strings.Index(line, "=")
-		if index == -1 {
-			fmt.Printf("Skipping invalid line: %s\n", line)
-			continue
-		}
-
-		key := line[:index]
-		value := line[index+1:]
-
-		if key == "AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX" {
-			shared.SetEnvAndSourceBashrcOrPowershell(key, value, false)
-		} else {
-			shared.SetEnvAndSourceBashrcOrPowershell(key, value, false)
-		}
-
-	}
-
-	if err := scanner.Err(); err != nil {
-		fmt.Printf("Error reading file: %s\n", err)
-	}
-}
-
-func parsePrometheusCollectorConfig() {
+func parsePrometheusCollectorConfig(s *settings.Settings) {
 	shared.EchoSectionDivider("Start Processing - parsePrometheusCollectorConfig")
-	parseConfigAndSetEnvInFile()
-	handleEnvFileError(collectorSettingsEnvVarPath)
+	parseCollectorSettings(s)
 	shared.EchoSectionDivider("End Processing - parsePrometheusCollectorConfig")
 }
 
-func parseDefaultScrapeSettings() {
+func parseDefaultScrapeSettings(s *settings.Settings) {
 	shared.EchoSectionDivider("Start Processing - parseDefaultScrapeSettings")
-	tomlparserDefaultScrapeSettings()
-	handleEnvFileError(defaultSettingsEnvVarPath)
+	tomlparserDefaultScrapeSettings(s)
 	shared.EchoSectionDivider("End Processing - parseDefaultScrapeSettings")
 }
 
-func parseDebugModeSettings() {
+func parseDebugModeSettings(s *settings.Settings) {
 	shared.EchoSectionDivider("Start Processing - parseDebugModeSettings")
-	if err := ConfigureDebugModeSettings(); err != nil {
+	if err := ConfigureDebugModeSettings(s); err != nil {
 		shared.EchoError(err.Error())
 		return
 	}
-	handleEnvFileError(debugModeEnvVarPath)
 	shared.EchoSectionDivider("End Processing - parseDebugModeSettings")
 }
 
-func handleEnvFileError(filename string) {
-	err := shared.SetEnvVarsFromFile(filename)
-	if err != nil {
-		fmt.Printf("Error when setting env for %s: %v\n", filename, err)
+// generatedConfigPaths are the configs written by Configmapparser
+var generatedConfigPaths = []string{
+	promMergedConfigPath,
+	mergedDefaultConfigPath,
+	"/opt/collector-config-with-defaults.yml",
+	"/opt/microsoft/otelcollector/collector-config.yml",
+	"/opt/microsoft/otelcollector/collector-config-default.yml",
+}
+
+// ResetGeneratedConfigs removes the configs written by a previous run of Configmapparser and the results the
+// validator recorded, so that running it again after the configmaps changed regenerates them.
+func ResetGeneratedConfigs() {
+	for _, path := range append(generatedConfigPaths, validatorResultsPath) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			shared.EchoError("Error removing " + path + ":" + err.Error())
+		}
 	}
 }
 
-func Configmapparser() {
-	setConfigFileVersionEnv()
-	setConfigSchemaVersionEnv()
-	parseSettingsForPodAnnotations()
-	parsePrometheusCollectorConfig()
-	parseDefaultScrapeSettings()
-	parseDebugModeSettings()
+// runValidator runs the prom config validator with args. When recordResults is set, the validator records
+// its results for the invalid config metrics, and they are read back into s.
+func runValidator(s *settings.Settings, recordResults bool, args ...string) error {
+	if recordResults {
+		args = append(args, "--results", validatorResultsPath)
+	}
+	if s.DebugModeEnabled {
+		args = append(args, "--debug-mode")
+	}
+	err := shared.StartCommandAndWait("/opt/promconfigvalidator", args...)
+	if recordResults {
+		results, loadErr := settings.LoadValidatorResults(validatorResultsPath)
+		switch {
+		case loadErr == nil:
+			s.ValidatorResults = results
+			// Scrape jobs were rejected from a custom config that was otherwise accepted
+			if results.RejectedScrapeJobs != "" {
+				s.InvalidCustomPrometheusConfig = true
+			}
+		case !os.IsNotExist(loadErr):
+			shared.EchoError("Error reading the prom config validator results:" + loadErr.Error())
+		}
+	}
+	return err
+}
 
-	tomlparserTargetsMetricsKeepList()
-	tomlparserScrapeInterval()
+// Configmapparser parses the settings configmaps, generates the collector configs and returns the settings,
+// which it also saves for the other containers and processes.
+func Configmapparser() *settings.Settings {
+	s := &settings.Settings{DefaultScrape: &settings.DefaultScrapeSettings{}}
+	s.ConfigFileVersion = readVersionFile(configVersionFile, defaultConfigFileVersion)
+	shared.EchoVar("AZMON_AGENT_CFG_FILE_VERSION", s.ConfigFileVersion)
+	s.ConfigSchemaVersion = readVersionFile(schemaVersionFile, defaultConfigSchemaVersion)
+	shared.EchoVar("AZMON_AGENT_CFG_SCHEMA_VERSION", s.ConfigSchemaVersion)
+	parseSettingsForPodAnnotations(s)
+	parsePrometheusCollectorConfig(s)
+	parseDefaultScrapeSettings(s)
+	parseDebugModeSettings(s)
+
+	tomlparserTargetsMetricsKeepList(s)
+	tomlparserScrapeInterval(s)
 
 	azmonOperatorEnabled := os.Getenv("AZMON_OPERATOR_ENABLED")
 	containerType := os.Getenv("CONTAINER_TYPE")
 
 	if azmonOperatorEnabled == "true" || containerType == "ConfigReaderSidecar" {
-		prometheusConfigMerger(true)
+		prometheusConfigMerger(s, true)
 	} else {
-		prometheusConfigMerger(false)
+		prometheusConfigMerger(s, false)
 	}
 
-	shared.SetEnvAndSourceBashrcOrPowershell("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG", "false", true)
-	shared.SetEnvAndSourceBashrcOrPowershell("CONFIG_VALIDATOR_RUNNING_IN_AGENT", "true", true)
+	// The results of a previous run must not be read back if the validator does not record any
+	if err := os.Remove(validatorResultsPath); err != nil && !os.IsNotExist(err) {
+		shared.EchoError("Error removing " + validatorResultsPath + ":" + err.Error())
+	}
 
 	// Running promconfigvalidator if promMergedConfig.yml exists
 	if shared.FileExists("/opt/promMergedConfig.yml") {
 		if !shared.FileExists("/opt/microsoft/otelcollector/collector-config.yml") {
-			err := shared.StartCommandAndWait("/opt/promconfigvalidator",
+			args := []string{
 				"--config", "/opt/promMergedConfig.yml",
 				"--output", "/opt/microsoft/otelcollector/collector-config.yml",
 				"--otelTemplate", "/opt/microsoft/otelcollector/collector-config-template.yml",
-			)
+				"--report", "/opt/microsoft/otelcollector/prom-config-validator-report.json",
+				// The scrape_config_files globs are relative to where the custom config is mounted
+				"--config-dir", filepath.Dir(configMapMountPath),
+			}
+			// Only the scrape jobs with problems are left out instead of the whole custom config
+			if s.Collector.PartialConfigAcceptance {
+				args = append(args, "--partial")
+			}
+			// Only the custom config is linted, before it is merged since merging drops its comments, which can
+			// suppress lint rules. The default scrape configs are not linted.
+			if shared.FileExists(configMapMountPath) {
+				args = append(args, "--lint-config", configMapMountPath)
+				if s.Collector.SuppressedLintRules != "" {
+					args = append(args, "--suppress", s.Collector.SuppressedLintRules)
+				}
+			} else {
+				args = append(args, "--lint=false")
+			}
+			err := runValidator(s, true, args...)
 			if err != nil {
 				fmt.Println("prom-config-validator::Prometheus custom config validation failed. The custom config will not be used")
 				fmt.Printf("Command execution failed: %v\n", err)
-				shared.SetEnvAndSourceBashrcOrPowershell("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG", "true", true)
+				s.InvalidCustomPrometheusConfig = true
 				if shared.FileExists(mergedDefaultConfigPath) {
 					fmt.Println("prom-config-validator::Running validator on just default scrape configs")
-					shared.StartCommandAndWait("/opt/promconfigvalidator", "--config", mergedDefaultConfigPath, "--output", "/opt/collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/collector-config-template.yml")
+					runValidator(s, false, "--config", mergedDefaultConfigPath, "--output", "/opt/collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/collector-config-template.yml", "--lint=false")
 					if !shared.FileExists("/opt/collector-config-with-defaults.yml") {
 						fmt.Println("prom-config-validator::Prometheus default scrape config validation failed. No scrape configs will be used")
 					} else {
 						shared.CopyFile("/opt/collector-config-with-defaults.yml", "/opt/microsoft/otelcollector/collector-config-default.yml")
 					}
 				}
-				shared.SetEnvAndSourceBashrcOrPowershell("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG", "true", true)
+				s.UseDefaultPrometheusConfig = true
 			} else {
-				shared.SetEnvAndSourceBashrcOrPowershell("AZMON_SET_GLOBAL_SETTINGS", "true", true)
+				s.SetGlobalSettings = true
 			}
 		}
 	} else if _, err := os.Stat(mergedDefaultConfigPath); err == nil {
 		fmt.Println("prom-config-validator::No custom prometheus config found. Only using default scrape configs")
-		err := shared.StartCommandAndWait("/opt/promconfigvalidator", "--config", mergedDefaultConfigPath, "--output", "/opt/collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/collector-config-template.yml")
+		err := runValidator(s, true, "--config", mergedDefaultConfigPath, "--output", "/opt/collector-config-with-defaults.yml", "--otelTemplate", "/opt/microsoft/otelcollector/collector-config-template.yml", "--lint=false")
 		if err != nil {
 			fmt.Println("prom-config-validator::Prometheus default scrape config validation failed. No scrape configs will be used")
 			fmt.Printf("Command execution failed: %v\n", err)
@@ -197,61 +193,17 @@ func Configmapparser() {
 			fmt.Println("prom-config-validator::Prometheus default scrape config validation succeeded, using this as collector config")
 			shared.CopyFile("/opt/collector-config-with-defaults.yml", "/opt/microsoft/otelcollector/collector-config-default.yml")
 		}
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG", "true", true)
+		s.UseDefaultPrometheusConfig = true
 	} else {
 		// This else block is needed, when there is no custom config mounted as config map or default configs enabled
 		fmt.Println("prom-config-validator::No custom config via configmap or default scrape configs enabled.")
-		shared.SetEnvAndSourceBashrcOrPowershell("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG", "true", true)
+		s.UseDefaultPrometheusConfig = true
 	}
 
-	if _, err := os.Stat("/opt/microsoft/prom_config_validator_env_var"); err == nil {
-		file, err := os.Open("/opt/microsoft/prom_config_validator_env_var")
-		if err != nil {
-			shared.EchoError("Error opening file:" + err.Error())
-			return
-		}
-		defer file.Close()
-
-		// Create or truncate envvars.env file
-		envFile, err := os.Create("/opt/envvars.env")
-		if err != nil {
-			shared.EchoError("Error creating env file:" + err.Error())
-			return
-		}
-		defer envFile.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			parts := strings.Split(line, "=")
-			if len(parts) == 2 {
-				key := parts[0]
-				value := parts[1]
-				shared.SetEnvAndSourceBashrcOrPowershell(key, value, true)
-
-				// Write to envvars.env
-				fmt.Fprintf(envFile, "%s=%s\n", key, value)
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			shared.EchoError("Error reading file:" + err.Error())
-			return
-		}
-
-		// Source prom_config_validator_env_var
-		cmd := exec.Command("bash", "-c", "source /opt/microsoft/prom_config_validator_env_var && env")
-		if err := cmd.Run(); err != nil {
-			shared.EchoError("Error sourcing env file:" + err.Error())
-			return
-		}
-
-		// Source envvars.env
-		cmd = exec.Command("bash", "-c", "source /opt/envvars.env && env")
-		if err := cmd.Run(); err != nil {
-			shared.EchoError("Error sourcing envvars.env:" + err.Error())
-			return
-		}
+	if err := s.Save(settingsPath); err != nil {
+		shared.EchoError("Error saving settings:" + err.Error())
 	}
 
-	fmt.Printf("prom-config-validator::Use default prometheus config: %s\n", os.Getenv("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG"))
+	fmt.Printf("prom-config-validator::Use default prometheus config: %t\n", s.UseDefaultPrometheusConfig)
+	return s
 }
@@ -7,12 +7,13 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
 /*
  * For each type of ama-metrics pod, (Linux ReplicaSet, Linux DaemonSet, Windows DaemonSet):
- * 1) Test that the settings from the configmaps are correctly parsed and set to environment variables.
+ * 1) Test that the settings from the configmaps are correctly parsed and recorded in the settings.
  * 2) Test that the Prometheus config created is as expected for the settings given.
  */
 var _ = Describe("Configmapparser", Ordered, func() {
@@ -41,7 +42,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 
 			expectedContentsFilePath := "./testdata/default-linux-rs.yaml"
 
-			Configmapparser()
+			s := Configmapparser()
 
 			envVars := map[string]string {
 				"AZMON_AGENT_CFG_SCHEMA_VERSION": "v1",
@@ -51,7 +52,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_CLUSTER_LABEL":                              "",
 				"AZMON_CLUSTER_ALIAS":                              "",
 				"AZMON_OPERATOR_ENABLED_CHART_SETTING":              "false",
-				"AZMON_OPERATOR_ENABLED":                            "true",
+				"AZMON_PARTIAL_CONFIG_ACCEPTANCE":                   "false",
+				"AZMON_OPERATOR_ENABLED":                            "",
 				"AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING":            "",
 				"AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED":         "true",
 				"AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED":         "false",
@@ -61,7 +63,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED":       "true",
 				"AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED":    "true",
 				"AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED": "false",
-				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "",
+				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "false",
 				"AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED":  "false",
 				"AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED": "false",
 				"AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED":      "true",
@@ -69,12 +71,11 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED": "true",
 				"AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED": "true",
 				"AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED": "false",
-				"DEBUG_MODE_ENABLED": "",
+				"DEBUG_MODE_ENABLED": "false",
 				"AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG": "false",
-				"CONFIG_VALIDATOR_RUNNING_IN_AGENT": "true",
 				"AZMON_USE_DEFAULT_PROMETHEUS_CONFIG": "true",
 			}
-			err := checkEnvVars(envVars)
+			err := checkEnvVars(s.Environ(), envVars)
 			Expect(err).NotTo(HaveOccurred())
 
 			checkHashMaps(configMapKeepListEnvVarPath, map[string]string {
@@ -91,7 +92,9 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"KAPPIEBASIC_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",kappiebasicRegex_minimal_mac),
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityRetinaRegex_minimal_mac),
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityHubbleRegex_minimal_mac),
-				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "",
+				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityCiliumRegex_minimal_mac),
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorCapacityProvisionerRegex_minimal_mac),
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorMetricsExporter_minimal_mac),
 			})
 
 			checkHashMaps(scrapeIntervalEnvVarPath, map[string]string {
@@ -110,6 +113,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL": "30s",
+				"ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL": "30s",
+				"ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL": "30s",
 			})
 
 			mergedFileContents, err := ioutil.ReadFile(mergedDefaultConfigPath)
@@ -134,7 +139,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 			setupProcessedFiles()
 			expectedContentsFilePath := "./testdata/default-linux-ds.yaml"
 
-			Configmapparser()
+			s := Configmapparser()
 
 			envVars := map[string]string {
 				"AZMON_AGENT_CFG_SCHEMA_VERSION": "v1",
@@ -144,7 +149,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_CLUSTER_LABEL":                              "",
 				"AZMON_CLUSTER_ALIAS":                              "",
 				"AZMON_OPERATOR_ENABLED_CHART_SETTING":              "false",
-				"AZMON_OPERATOR_ENABLED":                            "true",
+				"AZMON_PARTIAL_CONFIG_ACCEPTANCE":                   "false",
+				"AZMON_OPERATOR_ENABLED":                            "",
 				"AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING":            "",
 				"AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED":         "true",
 				"AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED":         "false",
@@ -154,7 +160,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED":       "true",
 				"AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED":    "true",
 				"AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED": "false",
-				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "",
+				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "false",
 				"AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED":  "false",
 				"AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED": "false",
 				"AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED":      "true",
@@ -162,12 +168,11 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED": "true",
 				"AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED": "true",
 				"AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED": "false",
-				"DEBUG_MODE_ENABLED": "",
+				"DEBUG_MODE_ENABLED": "false",
 				"AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG": "false",
-				"CONFIG_VALIDATOR_RUNNING_IN_AGENT": "true",
 				"AZMON_USE_DEFAULT_PROMETHEUS_CONFIG": "true",
 			}
-			err := checkEnvVars(envVars)
+			err := checkEnvVars(s.Environ(), envVars)
 			Expect(err).NotTo(HaveOccurred())
 
 			checkHashMaps(configMapKeepListEnvVarPath, map[string]string {
@@ -184,7 +189,9 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"KAPPIEBASIC_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",kappiebasicRegex_minimal_mac),
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityRetinaRegex_minimal_mac),
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityHubbleRegex_minimal_mac),
-				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "",
+				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityCiliumRegex_minimal_mac),
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorCapacityProvisionerRegex_minimal_mac),
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorMetricsExporter_minimal_mac),
 			})
 
 			checkHashMaps(scrapeIntervalEnvVarPath, map[string]string {
@@ -203,6 +210,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL": "30s",
+				"ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL": "30s",
+				"ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL": "30s",
 			})
 
 			mergedFileContents, err := ioutil.ReadFile(mergedDefaultConfigPath)
@@ -238,7 +247,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 
 			fmt.Println("testing replicaset defaults empty")
 
-			Configmapparser()
+			s := Configmapparser()
 
 			envVars := map[string]string {
 				"AZMON_AGENT_CFG_SCHEMA_VERSION": "v1",
@@ -248,7 +257,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_CLUSTER_LABEL":                              "",
 				"AZMON_CLUSTER_ALIAS":                              "",
 				"AZMON_OPERATOR_ENABLED_CHART_SETTING":              "false",
-				"AZMON_OPERATOR_ENABLED":                            "true",
+				"AZMON_PARTIAL_CONFIG_ACCEPTANCE":                   "false",
+				"AZMON_OPERATOR_ENABLED":                            "",
 				"AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING":            "",
 				"AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED":         "true",
 				"AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED":         "false",
@@ -258,7 +268,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED":       "true",
 				"AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED":    "true",
 				"AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED": "false",
-				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "",
+				"AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED":   "false",
 				"AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED":  "false",
 				"AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED": "false",
 				"AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED":      "true",
@@ -268,10 +278,9 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED": "false",
 				"DEBUG_MODE_ENABLED": "false",
 				"AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG": "false",
-				"CONFIG_VALIDATOR_RUNNING_IN_AGENT": "true",
 				"AZMON_USE_DEFAULT_PROMETHEUS_CONFIG": "true",
 			}
-			err := checkEnvVars(envVars)
+			err := checkEnvVars(s.Environ(), envVars)
 			Expect(err).NotTo(HaveOccurred())
 			
 			checkHashMaps(configMapKeepListEnvVarPath, map[string]string {
@@ -288,7 +297,9 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"KAPPIEBASIC_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",kappiebasicRegex_minimal_mac),
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityRetinaRegex_minimal_mac),
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityHubbleRegex_minimal_mac),
-				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "",
+				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("|%s",networkobservabilityCiliumRegex_minimal_mac),
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorCapacityProvisionerRegex_minimal_mac),
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorMetricsExporter_minimal_mac),
 			})
 
 			checkHashMaps(scrapeIntervalEnvVarPath, map[string]string {
@@ -307,6 +318,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL": "30s",
 				"NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL": "30s",
+				"ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL": "30s",
+				"ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL": "30s",
 			})
 
 			mergedFileContents, err := ioutil.ReadFile(mergedDefaultConfigPath)
@@ -344,7 +357,7 @@ var _ = Describe("Configmapparser", Ordered, func() {
 			schemaVersionFile = createTempFile("schema-version", "v1")
 			configVersionFile = createTempFile("config-version", "ver1")
 			configMapMountPathForPodAnnotation = createTempFile("podannotation", `podannotationnamespaceregex = ".*|value"`)
-			collectorSettingsMountPath = createTempFile("collector-settings", `cluster_alias = "alias"`)
+			collectorSettingsMountPath = createTempFile("collector-settings", "cluster_alias = \"alias\"\npartial_config_acceptance = true\nsuppressed_lint_rules = \"honor-labels,short-scrape-interval\"")
 			defaultSettingsMountPath = createTempFile("default-settings", `
 				kubelet = true
 				coredns = true
@@ -399,17 +412,18 @@ var _ = Describe("Configmapparser", Ordered, func() {
 
 			setupProcessedFiles()
 
-			Configmapparser()
+			s := Configmapparser()
 
 			envVars := map[string]string {
 				"AZMON_AGENT_CFG_SCHEMA_VERSION": "v1",
 				"AZMON_AGENT_CFG_FILE_VERSION":   "ver1",
-			  "AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX": "'.*|value'",
+			  "AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX": ".*|value",
 				"AZMON_DEFAULT_METRIC_ACCOUNT_NAME":                "",
 				"AZMON_CLUSTER_LABEL":                              "alias",
 				"AZMON_CLUSTER_ALIAS":                              "alias",
 				"AZMON_OPERATOR_ENABLED_CHART_SETTING":              "true",
-				"AZMON_OPERATOR_ENABLED":                            "true",
+				"AZMON_PARTIAL_CONFIG_ACCEPTANCE":                   "true",
+				"AZMON_OPERATOR_ENABLED":                            "",
 				"AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING":            "",
 				"AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED":         "true",
 				"AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED":         "true",
@@ -429,10 +443,10 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED": "false",
 				//"DEBUG_MODE_ENABLED": "true",
 				"AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG": "false",
-				"CONFIG_VALIDATOR_RUNNING_IN_AGENT": "true",
 				"AZMON_USE_DEFAULT_PROMETHEUS_CONFIG": "true",
 			}
-			err := checkEnvVars(envVars)
+			Expect(s.Collector.SuppressedLintRules).To(Equal("honor-labels,short-scrape-interval"))
+			err := checkEnvVars(s.Environ(), envVars)
 			Expect(err).NotTo(HaveOccurred())
 
 			checkHashMaps(configMapKeepListEnvVarPath, map[string]string {
@@ -449,7 +463,9 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"KAPPIEBASIC_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("test.*|test2|%s",kappiebasicRegex_minimal_mac),
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("test.*|test2|%s",networkobservabilityRetinaRegex_minimal_mac),
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("test.*|test2|%s",networkobservabilityHubbleRegex_minimal_mac),
-				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "test.*|test2",
+				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": fmt.Sprintf("test.*|test2|%s",networkobservabilityCiliumRegex_minimal_mac),
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorCapacityProvisionerRegex_minimal_mac),
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": fmt.Sprintf("|%s",acstorMetricsExporter_minimal_mac),
 			})
 
 			checkHashMaps(scrapeIntervalEnvVarPath, map[string]string {
@@ -468,6 +484,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL": "15s",
 				"NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL": "15s",
 				"NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL": "15s",
+				"ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL": "30s",
+				"ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL": "30s",
 			})
 		})
 
@@ -541,6 +559,8 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": "test.*|test2",
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": "test.*|test2",
 				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "test.*|test2",
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": "",
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": "",
 			})
 		})
 
@@ -567,12 +587,10 @@ var _ = Describe("Configmapparser", Ordered, func() {
 			`)
 			configMapScrapeIntervalMountPath = createTempFile("scrape-interval", ``)
 
-			podAnnotationEnvVarPath = createTempFile("podannotation-envvar", "")
-			collectorSettingsEnvVarPath = createTempFile("collector-settings-envvar", "")
-			defaultSettingsEnvVarPath = createTempFile("default-settings-envvar", "")
-			debugModeEnvVarPath = createTempFile("debug-mode-envvar", "")
 			configMapKeepListEnvVarPath = createTempFile("keep-list-envvar", "")
 			scrapeIntervalEnvVarPath = createTempFile("scrape-interval-envvar", "")
+			settingsPath = createTempFile("settings", "")
+			validatorResultsPath = createTempFile("validator-results", "")
 
 			Configmapparser()
 
@@ -591,11 +609,46 @@ var _ = Describe("Configmapparser", Ordered, func() {
 				"NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX": "",
 				"NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX": "",
 				"NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX": "",
+				"ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX": "",
+				"ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX": "",
 			})
 		})
 	})	
 })
 
+var _ = Describe("Configmapparser settings store", func() {
+	AfterEach(func() {
+		cleanupEnvVars()
+	})
+
+	It("should save the settings it parsed for the processes that read them", func() {
+		setEnvVars(map[string]string {
+			"AZMON_OPERATOR_ENABLED": "true",
+			"CONTAINER_TYPE": "",
+			"CONTROLLER_TYPE": "DaemonSet",
+			"OS_TYPE": "linux",
+			"MODE": "advanced",
+			"KUBE_STATE_NAME": "ama-metrics-ksm",
+			"POD_NAMESPACE": "kube-system",
+			"MAC": "true",
+		})
+		setupConfigFiles(true)
+		setupProcessedFiles()
+
+		s := Configmapparser()
+
+		saved, err := settings.Load(settingsPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved.ConfigSchemaVersion).To(Equal("v1"))
+		Expect(saved.ConfigFileVersion).To(Equal("ver1"))
+		Expect(saved.UseDefaultPrometheusConfig).To(BeTrue())
+		Expect(saved.InvalidCustomPrometheusConfig).To(BeFalse())
+		Expect(saved.DefaultScrape.Kubelet).To(BeTrue())
+		Expect(saved.DefaultScrape.CoreDNS).To(BeFalse())
+		Expect(saved).To(Equal(s))
+	})
+})
+
 func createTempFile(name string, content string) string {
 	tempFile, err := ioutil.TempFile("", name)
 	Expect(err).NotTo(HaveOccurred())
@@ -604,10 +657,10 @@ func createTempFile(name string, content string) string {
 	return tempFile.Name()
 }
 
-func checkEnvVars(envVars map[string]string) error {
+func checkEnvVars(environ map[string]string, envVars map[string]string) error {
 	for key, value := range envVars {
-		if os.Getenv(key) != value {
-			return fmt.Errorf("Expected %s to be %s, but got %s", key, value, os.Getenv(key))
+		if environ[key] != value {
+			return fmt.Errorf("Expected %s to be %s, but got %s", key, value, environ[key])
 		}
 	}
 	return nil
@@ -653,12 +706,10 @@ func setupConfigFiles(defaultPath bool) {
 }
 
 func setupProcessedFiles() {
-	podAnnotationEnvVarPath = createTempFile("podannotation-envvar", "")
-	collectorSettingsEnvVarPath = createTempFile("collector-settings-envvar", "")
-	defaultSettingsEnvVarPath = createTempFile("default-settings-envvar", "")
-	debugModeEnvVarPath = createTempFile("debug-mode-envvar", "")
 	configMapKeepListEnvVarPath = createTempFile("keep-list-envvar", "")
 	scrapeIntervalEnvVarPath = createTempFile("scrape-interval-envvar", "")
+	settingsPath = createTempFile("settings", "")
+	validatorResultsPath = createTempFile("validator-results", "")
 
 	defaultPromConfigPathPrefix = "../../../configmapparser/default-prom-configs/"
 	mergedDefaultConfigPath = createTempFile("merged-default-config", "")
@@ -682,6 +733,7 @@ func cleanupEnvVars() {
 		"AZMON_CLUSTER_LABEL",
 		"AZMON_CLUSTER_ALIAS",
 		"AZMON_OPERATOR_ENABLED_CHART_SETTING",
+		"AZMON_PARTIAL_CONFIG_ACCEPTANCE",
 		"AZMON_OPERATOR_ENABLED",
 		"AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING",
 		"AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED",
@@ -702,7 +754,6 @@ func cleanupEnvVars() {
 		"AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED",
 		"DEBUG_MODE_ENABLED",
 		"AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG",
-		"CONFIG_VALIDATOR_RUNNING_IN_AGENT",
 		"AZMON_USE_DEFAULT_PROMETHEUS_CONFIG",
 	}
 	for _, envVar := range allEnvVars {
@@ -1,17 +1,17 @@
 package configmapsettings
 
+import "github.com/prometheus-collector/shared/settings"
+
 var (
+	settingsPath                                 = settings.DefaultPath
+	validatorResultsPath                         = settings.ValidatorResultsPath
 	schemaVersionFile                            = "/etc/config/settings/schema-version"
 	configVersionFile                            = "/etc/config/settings/config-version"
 	configMapDebugMountPath                      = "/etc/config/settings/debug-mode"
 	replicaSetCollectorConfig                    = "/opt/microsoft/otelcollector/collector-config-replicaset.yml"
-	debugModeEnvVarPath                          = "/opt/microsoft/configmapparser/config_debug_mode_env_var"
 	defaultSettingsMountPath                     = "/etc/config/settings/default-scrape-settings-enabled"
-	defaultSettingsEnvVarPath                    = "/opt/microsoft/configmapparser/config_default_scrape_settings_env_var"
 	configMapMountPathForPodAnnotation           = "/etc/config/settings/pod-annotation-based-scraping"
-	podAnnotationEnvVarPath                      = "/opt/microsoft/configmapparser/config_def_pod_annotation_based_scraping"
 	collectorSettingsMountPath                   = "/etc/config/settings/prometheus-collector-settings"
-	collectorSettingsEnvVarPath                  = "/opt/microsoft/configmapparser/config_prometheus_collector_settings_env_var"
 	configMapKeepListMountPath                   = "/etc/config/settings/default-targets-metrics-keep-list"
 	configMapKeepListEnvVarPath                  = "/opt/microsoft/configmapparser/config_def_targets_metrics_keep_list_hash"
 	configMapScrapeIntervalMountPath             = "/etc/config/settings/default-targets-scrape-interval-settings"
@@ -81,6 +81,8 @@ type ConfigProcessor struct {
 	ClusterLabel                      string
 	IsOperatorEnabled                 bool
 	IsOperatorEnabledChartSetting     bool
+	PartialConfigAcceptance           bool
+	SuppressedLintRules               string
 	ControlplaneKubeControllerManager string
 	ControlplaneKubeScheduler         string
 	ControlplaneApiserver             string
@@ -115,15 +117,10 @@ type ConfigParser interface {
 
 // Configurator is responsible for configuring the application.
 type Configurator struct {
-	ConfigLoader   *FilesystemConfigLoader
-	ConfigParser   *ConfigProcessor
-	ConfigWriter   *FileConfigWriter
-	ConfigFilePath string
-}
-
-type FileConfigWriter struct {
-	ConfigProcessor *ConfigProcessor
-	Config          map[string]string
+	ConfigLoader *FilesystemConfigLoader
+	ConfigParser *ConfigProcessor
+	// Settings receive the values parsed from the configmap
+	Settings *settings.Settings
 }
 
 // ConfigLoader is an interface for loading configurations.
@@ -131,8 +128,3 @@ type ConfigLoader interface {
 	ParseConfigMapForDefaultScrapeSettings() (map[string]string, error)
 	SetDefaultScrapeSettings() (map[string]string, error)
 }
-
-// ConfigWriter is an interface for writing configurations to a file.
-type ConfigWriter interface {
-	WriteDefaultScrapeSettingsToFile(filename string) error
-}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -89,6 +90,21 @@ func isConfigReaderSidecar() bool {
 	return false
 }
 
+// setPrometheusCollectorHealthPort points the prometheus_collector_health job at the port the fluent-bit
+// plugin serves the health metrics on, set by PROMETHEUS_COLLECTOR_HEALTH_PORT.
+func setPrometheusCollectorHealthPort(yamlConfigFile string) {
+	port := shared.PrometheusCollectorHealthPort()
+	contents, err := os.ReadFile(yamlConfigFile)
+	if err != nil {
+		fmt.Printf("Error reading config file %s: %v. The prometheus collector health port will not be updated\n", yamlConfigFile, err)
+		return
+	}
+	contents = []byte(strings.ReplaceAll(string(contents), "$$PROMETHEUS_COLLECTOR_HEALTH_PORT$$", port))
+	if err := os.WriteFile(yamlConfigFile, contents, fs.FileMode(0644)); err != nil {
+		fmt.Printf("Error writing config file %s: %v\n", yamlConfigFile, err)
+	}
+}
+
 func UpdateScrapeIntervalConfig(yamlConfigFile string, scrapeIntervalSetting string) {
 	fmt.Printf("Updating scrape interval config for %s\n", yamlConfigFile)
 
@@ -244,7 +260,7 @@ func AppendRelabelConfig(yamlConfigFile string, relabelConfig []map[string]inter
 	}
 }
 
-func populateDefaultPrometheusConfig() {
+func populateDefaultPrometheusConfig(s *settings.Settings) {
 	defaultConfigs := []string{}
 	currentControllerType := strings.TrimSpace(strings.ToLower(os.Getenv("CONTROLLER_TYPE")))
 
@@ -264,7 +280,7 @@ func populateDefaultPrometheusConfig() {
 		windowsDaemonset = true
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.Kubelet {
 		kubeletMetricsKeepListRegex, exists := regexHash["KUBELET_METRICS_KEEP_LIST_REGEX"]
 		kubeletScrapeInterval := intervalHash["KUBELET_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -302,7 +318,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.CoreDNS && currentControllerType == replicasetControllerType {
 		corednsMetricsKeepListRegex, exists := regexHash["COREDNS_METRICS_KEEP_LIST_REGEX"]
 		corednsScrapeInterval, intervalExists := intervalHash["COREDNS_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -314,7 +330,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, coreDNSDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CADVISOR_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.Cadvisor {
 		cadvisorMetricsKeepListRegex, exists := regexHash["CADVISOR_METRICS_KEEP_LIST_REGEX"]
 		cadvisorScrapeInterval, intervalExists := intervalHash["CADVISOR_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -349,7 +365,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBEPROXY_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.KubeProxy && currentControllerType == replicasetControllerType {
 		kubeproxyMetricsKeepListRegex, exists := regexHash["KUBEPROXY_METRICS_KEEP_LIST_REGEX"]
 		kubeproxyScrapeInterval, intervalExists := intervalHash["KUBEPROXY_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -361,7 +377,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, kubeProxyDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_APISERVER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.APIServer && currentControllerType == replicasetControllerType {
 		apiserverMetricsKeepListRegex, exists := regexHash["APISERVER_METRICS_KEEP_LIST_REGEX"]
 		apiserverScrapeInterval, intervalExists := intervalHash["APISERVER_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -373,7 +389,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, apiserverDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.KubeState && currentControllerType == replicasetControllerType {
 		kubestateMetricsKeepListRegex, exists := regexHash["KUBESTATE_METRICS_KEEP_LIST_REGEX"]
 		kubestateScrapeInterval, intervalExists := intervalHash["KUBESTATE_SCRAPE_INTERVAL"]
 		log.Printf("path %s: %s\n", "kubeStateDefaultFile", kubeStateDefaultFile)
@@ -395,7 +411,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NodeExporter {
 		nodeexporterMetricsKeepListRegex, exists := regexHash["NODEEXPORTER_METRICS_KEEP_LIST_REGEX"]
 		nodeexporterScrapeInterval := intervalHash["NODEEXPORTER_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -445,7 +461,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.KappieBasic {
 		kappiebasicMetricsKeepListRegex, exists := regexHash["KAPPIEBASIC_METRICS_KEEP_LIST_REGEX"]
 		kappiebasicScrapeInterval := intervalHash["KAPPIEBASIC_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -470,7 +486,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYRETINA_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityRetina {
 		networkobservabilityRetinaMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityRetinaScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -497,7 +513,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityHubble {
 		networkobservabilityHubbleMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityHubbleScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -524,7 +540,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityCilium {
 		networkobservabilityCiliumMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityCiliumScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType {
@@ -551,15 +567,16 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.CollectorHealth {
 		prometheusCollectorHealthInterval, intervalExists := intervalHash["PROMETHEUS_COLLECTOR_HEALTH_SCRAPE_INTERVAL"]
 		if intervalExists {
 			UpdateScrapeIntervalConfig(prometheusCollectorHealthDefaultFile, prometheusCollectorHealthInterval)
 		}
+		setPrometheusCollectorHealthPort(prometheusCollectorHealthDefaultFile)
 		defaultConfigs = append(defaultConfigs, prometheusCollectorHealthDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.WindowsExporter {
 		winexporterMetricsKeepListRegex, exists := regexHash["WINDOWSEXPORTER_METRICS_KEEP_LIST_REGEX"]
 		windowsexporterScrapeInterval, intervalExists := intervalHash["WINDOWSEXPORTER_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType && !advancedMode && strings.ToLower(os.Getenv("OS_TYPE")) == "linux" {
@@ -597,7 +614,7 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.WindowsKubeProxy {
 		winkubeproxyMetricsKeepListRegex, exists := regexHash["WINDOWSKUBEPROXY_METRICS_KEEP_LIST_REGEX"]
 		windowskubeproxyScrapeInterval, intervalExists := intervalHash["WINDOWSKUBEPROXY_SCRAPE_INTERVAL"]
 		if currentControllerType == replicasetControllerType && !advancedMode && strings.ToLower(os.Getenv("OS_TYPE")) == "linux" {
@@ -635,35 +652,27 @@ func populateDefaultPrometheusConfig() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
-		if podannotationNamespacesRegex, exists := os.LookupEnv("AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX"); exists {
-			podannotationMetricsKeepListRegex := regexHash["POD_ANNOTATION_METRICS_KEEP_LIST_REGEX"]
-			podannotationScrapeInterval, intervalExists := intervalHash["POD_ANNOTATION_SCRAPE_INTERVAL"]
+	if s.DefaultScrape.PodAnnotations && currentControllerType == replicasetControllerType {
+		podannotationNamespacesRegex := s.DefaultScrape.PodAnnotationNamespacesRegex
+		podannotationMetricsKeepListRegex := regexHash["POD_ANNOTATION_METRICS_KEEP_LIST_REGEX"]
+		podannotationScrapeInterval, intervalExists := intervalHash["POD_ANNOTATION_SCRAPE_INTERVAL"]
 
-			if intervalExists {
-				UpdateScrapeIntervalConfig(podAnnotationsDefaultFile, podannotationScrapeInterval)
-			}
-			if podannotationMetricsKeepListRegex != "" {
-				AppendMetricRelabelConfig(podAnnotationsDefaultFile, podannotationMetricsKeepListRegex)
-			}
-			// Trim the first and last escaped quotes if they exist
-			if len(podannotationNamespacesRegex) > 1 && podannotationNamespacesRegex[0] == '"' && podannotationNamespacesRegex[len(podannotationNamespacesRegex)-1] == '"' {
-				podannotationNamespacesRegex = podannotationNamespacesRegex[1 : len(podannotationNamespacesRegex)-1]
-			}
-			// Additional trim to remove single quotes if present
-			podannotationNamespacesRegex = strings.Trim(podannotationNamespacesRegex, "'")
-
-			if podannotationNamespacesRegex != "" {
-				relabelConfig := []map[string]interface{}{
-					{"source_labels": []string{"__meta_kubernetes_namespace"}, "action": "keep", "regex": podannotationNamespacesRegex},
-				}
-				AppendRelabelConfig(podAnnotationsDefaultFile, relabelConfig, podannotationNamespacesRegex)
+		if intervalExists {
+			UpdateScrapeIntervalConfig(podAnnotationsDefaultFile, podannotationScrapeInterval)
+		}
+		if podannotationMetricsKeepListRegex != "" {
+			AppendMetricRelabelConfig(podAnnotationsDefaultFile, podannotationMetricsKeepListRegex)
+		}
+		if podannotationNamespacesRegex != "" {
+			relabelConfig := []map[string]interface{}{
+				{"source_labels": []string{"__meta_kubernetes_namespace"}, "action": "keep", "regex": podannotationNamespacesRegex},
 			}
-			defaultConfigs = append(defaultConfigs, podAnnotationsDefaultFile)
+			AppendRelabelConfig(podAnnotationsDefaultFile, relabelConfig, podannotationNamespacesRegex)
 		}
+		defaultConfigs = append(defaultConfigs, podAnnotationsDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_ACSTORCAPACITYPROVISIONER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.AcstorCapacityProvisioner && currentControllerType == replicasetControllerType {
 		acstorCapacityProvisionerKeepListRegex, exists := regexHash["ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX"]
 		acstorCapacityProvisionerScrapeInterval, intervalExists := intervalHash["ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -675,7 +684,7 @@ func populateDefaultPrometheusConfig() {
 		defaultConfigs = append(defaultConfigs, acstorCapacityProvisionerDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_ACSTORMETRICSEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && currentControllerType == replicasetControllerType {
+	if s.DefaultScrape.AcstorMetricsExporter && currentControllerType == replicasetControllerType {
 		acstorMetricsExporterKeepListRegex, exists := regexHash["ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX"]
 		acstorMetricsExporterScrapeInterval, intervalExists := intervalHash["ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -693,7 +702,7 @@ func populateDefaultPrometheusConfig() {
 	// }
 }
 
-func populateDefaultPrometheusConfigWithOperator() {
+func populateDefaultPrometheusConfigWithOperator(s *settings.Settings) {
 	defaultConfigs := []string{}
 
 	envControllerType := os.Getenv("CONTROLLER_TYPE")
@@ -724,7 +733,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		windowsDaemonset = true
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.Kubelet {
 		kubeletMetricsKeepListRegex, exists := regexHash["KUBELET_METRICS_KEEP_LIST_REGEX"]
 		kubeletScrapeInterval := intervalHash["KUBELET_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -762,7 +771,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.CoreDNS && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		corednsMetricsKeepListRegex, exists := regexHash["COREDNS_METRICS_KEEP_LIST_REGEX"]
 		corednsScrapeInterval, intervalExists := intervalHash["COREDNS_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -774,7 +783,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		defaultConfigs = append(defaultConfigs, coreDNSDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_CADVISOR_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.Cadvisor {
 		cadvisorMetricsKeepListRegex, exists := regexHash["CADVISOR_METRICS_KEEP_LIST_REGEX"]
 		cadvisorScrapeInterval, intervalExists := intervalHash["CADVISOR_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -809,7 +818,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBEPROXY_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.KubeProxy && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		kubeproxyMetricsKeepListRegex, exists := regexHash["KUBEPROXY_METRICS_KEEP_LIST_REGEX"]
 		kubeproxyScrapeInterval, intervalExists := intervalHash["KUBEPROXY_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -821,7 +830,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		defaultConfigs = append(defaultConfigs, kubeProxyDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_APISERVER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.APIServer && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		apiserverMetricsKeepListRegex, exists := regexHash["APISERVER_METRICS_KEEP_LIST_REGEX"]
 		apiserverScrapeInterval, intervalExists := intervalHash["APISERVER_SCRAPE_INTERVAL"]
 		if intervalExists {
@@ -833,7 +842,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		defaultConfigs = append(defaultConfigs, apiserverDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.KubeState && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		kubestateMetricsKeepListRegex, exists := regexHash["KUBESTATE_METRICS_KEEP_LIST_REGEX"]
 		kubestateScrapeInterval, intervalExists := intervalHash["KUBESTATE_SCRAPE_INTERVAL"]
 
@@ -854,7 +863,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NodeExporter {
 		nodeexporterMetricsKeepListRegex, exists := regexHash["NODEEXPORTER_METRICS_KEEP_LIST_REGEX"]
 		nodeexporterScrapeInterval := intervalHash["NODEEXPORTER_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -904,7 +913,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.KappieBasic {
 		kappiebasicMetricsKeepListRegex, exists := regexHash["KAPPIEBASIC_METRICS_KEEP_LIST_REGEX"]
 		kappiebasicScrapeInterval := intervalHash["KAPPIEBASIC_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -929,7 +938,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYRETINA_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityRetina {
 		networkobservabilityRetinaMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYRETINA_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityRetinaScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYRETINA_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -956,7 +965,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityHubble {
 		networkobservabilityHubbleMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYHUBBLE_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityHubbleScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYHUBBLE_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -983,7 +992,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.NetworkObservabilityCilium {
 		networkobservabilityCiliumMetricsKeepListRegex, exists := regexHash["NETWORKOBSERVABILITYCILIUM_METRICS_KEEP_LIST_REGEX"]
 		networkobservabilityCiliumScrapeInterval, intervalExists := intervalHash["NETWORKOBSERVABILITYCILIUM_SCRAPE_INTERVAL"]
 		if isConfigReaderSidecar() || currentControllerType == replicasetControllerType {
@@ -1010,15 +1019,16 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.CollectorHealth {
 		prometheusCollectorHealthInterval, intervalExists := intervalHash["PROMETHEUS_COLLECTOR_HEALTH_SCRAPE_INTERVAL"]
 		if intervalExists {
 			UpdateScrapeIntervalConfig(prometheusCollectorHealthDefaultFile, prometheusCollectorHealthInterval)
 		}
+		setPrometheusCollectorHealthPort(prometheusCollectorHealthDefaultFile)
 		defaultConfigs = append(defaultConfigs, prometheusCollectorHealthDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.WindowsExporter {
 		winexporterMetricsKeepListRegex, exists := regexHash["WINDOWSEXPORTER_METRICS_KEEP_LIST_REGEX"]
 		windowsexporterScrapeInterval, intervalExists := intervalHash["WINDOWSEXPORTER_SCRAPE_INTERVAL"]
 		// Not adding the isConfigReaderSidecar check instead of replicaset check since this is legacy 1P chart path and not relevant anymore.
@@ -1057,7 +1067,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" {
+	if s.DefaultScrape.WindowsKubeProxy {
 		winkubeproxyMetricsKeepListRegex, exists := regexHash["WINDOWSKUBEPROXY_METRICS_KEEP_LIST_REGEX"]
 		windowskubeproxyScrapeInterval, intervalExists := intervalHash["WINDOWSKUBEPROXY_SCRAPE_INTERVAL"]
 		// Not adding the isConfigReaderSidecar check instead of replicaset check since this is legacy 1P chart path and not relevant anymore.
@@ -1096,35 +1106,27 @@ func populateDefaultPrometheusConfigWithOperator() {
 		}
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
-		if podannotationNamespacesRegex, exists := os.LookupEnv("AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX"); exists {
-			podannotationMetricsKeepListRegex := regexHash["POD_ANNOTATION_METRICS_KEEP_LIST_REGEX"]
-			podannotationScrapeInterval, intervalExists := intervalHash["POD_ANNOTATION_SCRAPE_INTERVAL"]
-
-			if intervalExists {
-				UpdateScrapeIntervalConfig(podAnnotationsDefaultFile, podannotationScrapeInterval)
-			}
-			if podannotationMetricsKeepListRegex != "" {
-				AppendMetricRelabelConfig(podAnnotationsDefaultFile, podannotationMetricsKeepListRegex)
-			}
-			// Trim the first and last escaped quotes if they exist
-			if len(podannotationNamespacesRegex) > 1 && podannotationNamespacesRegex[0] == '"' && podannotationNamespacesRegex[len(podannotationNamespacesRegex)-1] == '"' {
-				podannotationNamespacesRegex = podannotationNamespacesRegex[1 : len(podannotationNamespacesRegex)-1]
-			}
-			// Additional trim to remove single quotes if present
-			podannotationNamespacesRegex = strings.Trim(podannotationNamespacesRegex, "'")
+	if s.DefaultScrape.PodAnnotations && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+		podannotationNamespacesRegex := s.DefaultScrape.PodAnnotationNamespacesRegex
+		podannotationMetricsKeepListRegex := regexHash["POD_ANNOTATION_METRICS_KEEP_LIST_REGEX"]
+		podannotationScrapeInterval, intervalExists := intervalHash["POD_ANNOTATION_SCRAPE_INTERVAL"]
 
-			if podannotationNamespacesRegex != "" {
-				relabelConfig := []map[string]interface{}{
-					{"source_labels": []string{"__meta_kubernetes_namespace"}, "action": "keep", "regex": podannotationNamespacesRegex},
-				}
-				AppendRelabelConfig(podAnnotationsDefaultFile, relabelConfig, podannotationNamespacesRegex)
+		if intervalExists {
+			UpdateScrapeIntervalConfig(podAnnotationsDefaultFile, podannotationScrapeInterval)
+		}
+		if podannotationMetricsKeepListRegex != "" {
+			AppendMetricRelabelConfig(podAnnotationsDefaultFile, podannotationMetricsKeepListRegex)
+		}
+		if podannotationNamespacesRegex != "" {
+			relabelConfig := []map[string]interface{}{
+				{"source_labels": []string{"__meta_kubernetes_namespace"}, "action": "keep", "regex": podannotationNamespacesRegex},
 			}
-			defaultConfigs = append(defaultConfigs, podAnnotationsDefaultFile)
+			AppendRelabelConfig(podAnnotationsDefaultFile, relabelConfig, podannotationNamespacesRegex)
 		}
+		defaultConfigs = append(defaultConfigs, podAnnotationsDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_ACSTORCAPACITYPROVISIONER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.AcstorCapacityProvisioner && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		acstorCapacityProvisionerKeepListRegex, exists := regexHash["ACSTORCAPACITYPROVISONER_KEEP_LIST_REGEX"]
 		acstorCapacityProvisionerScrapeInterval, intervalExists := intervalHash["ACSTORCAPACITYPROVISIONER_SCRAPE_INTERVAL"]
 		log.Printf("path %s: %s\n", "acstorCapacityProvisionerDefaultFile", acstorCapacityProvisionerDefaultFile)
@@ -1137,7 +1139,7 @@ func populateDefaultPrometheusConfigWithOperator() {
 		defaultConfigs = append(defaultConfigs, acstorCapacityProvisionerDefaultFile)
 	}
 
-	if enabled, exists := os.LookupEnv("AZMON_PROMETHEUS_ACSTORMETRICSEXPORTER_SCRAPING_ENABLED"); exists && strings.ToLower(enabled) == "true" && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
+	if s.DefaultScrape.AcstorMetricsExporter && (isConfigReaderSidecar() || currentControllerType == replicasetControllerType) {
 		acstorMetricsExporterKeepListRegex, exists := regexHash["ACSTORMETRICSEXPORTER_KEEP_LIST_REGEX"]
 		acstorMetricsExporterScrapeInterval, intervalExists := intervalHash["ACSTORMETRICSEXPORTER_SCRAPE_INTERVAL"]
 		log.Printf("path %s: %s\n", "acstorMetricsExporterDefaultFile", acstorMetricsExporterDefaultFile)
@@ -1225,15 +1227,14 @@ func deepMerge(target, source map[interface{}]interface{}) map[interface{}]inter
 	return target
 }
 
-func writeDefaultScrapeTargetsFile(operatorEnabled bool) map[interface{}]interface{} {
-	noDefaultScrapingEnabled := os.Getenv("AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED")
-	if noDefaultScrapingEnabled != "" && strings.ToLower(noDefaultScrapingEnabled) == "false" {
+func writeDefaultScrapeTargetsFile(s *settings.Settings, operatorEnabled bool) map[interface{}]interface{} {
+	if !s.NoDefaultScrapingEnabled {
 		loadRegexHash()
 		loadIntervalHash()
 		if operatorEnabled {
-			populateDefaultPrometheusConfigWithOperator()
+			populateDefaultPrometheusConfigWithOperator(s)
 		} else {
-			populateDefaultPrometheusConfig()
+			populateDefaultPrometheusConfig(s)
 		}
 		if mergedDefaultConfigs != nil && len(mergedDefaultConfigs) > 0 {
 			fmt.Printf("Starting to merge default prometheus config values in collector template as backup\n")
@@ -1392,21 +1393,21 @@ func setGlobalScrapeConfigInDefaultFilesIfExists(configString string) string {
 	return string(updatedConfig)
 }
 
-func prometheusConfigMerger(operatorEnabled bool) {
+func prometheusConfigMerger(s *settings.Settings, operatorEnabled bool) {
 	shared.EchoSectionDivider("Start Processing - prometheusConfigMerger")
 	mergedDefaultConfigs = make(map[interface{}]interface{}) // Initialize mergedDefaultConfigs
 	prometheusConfigMap := parseConfigMap()
 
 	if len(prometheusConfigMap) > 0 {
 		modifiedPrometheusConfigString := setGlobalScrapeConfigInDefaultFilesIfExists(prometheusConfigMap)
-		writeDefaultScrapeTargetsFile(operatorEnabled)
+		writeDefaultScrapeTargetsFile(s, operatorEnabled)
 		// Set label limits for every custom scrape job, before merging the default & custom config
 		labellimitedconfigString := setLabelLimitsPerScrape(modifiedPrometheusConfigString)
 		mergeDefaultAndCustomScrapeConfigs(labellimitedconfigString, mergedDefaultConfigs)
 		shared.EchoSectionDivider("End Processing - prometheusConfigMerger, Done Merging Default and Custom Prometheus Config")
 	} else {
 		setDefaultFileScrapeInterval("30s")
-		writeDefaultScrapeTargetsFile(operatorEnabled)
+		writeDefaultScrapeTargetsFile(s, operatorEnabled)
 		shared.EchoSectionDivider("End Processing - prometheusConfigMerger, Done Writing Default Prometheus Config")
 	}
 
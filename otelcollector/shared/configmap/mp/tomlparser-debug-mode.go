@@ -8,6 +8,7 @@ import (
 	"io/fs"
 
 	"github.com/pelletier/go-toml"
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -16,35 +17,24 @@ const (
 )
 
 // ConfigureDebugModeSettings reads debug mode settings from a config map,
-// sets default values if necessary, writes environment variables to a file,
+// sets default values if necessary, records them in s,
 // and modifies a YAML configuration file based on debug mode settings.
-func ConfigureDebugModeSettings() error {
+func ConfigureDebugModeSettings(s *settings.Settings) error {
 	configMapSettings, err := parseConfigMapForDebugSettings()
 	if err != nil || configMapSettings == nil {
 		return fmt.Errorf("Error: %v", err)
 	}
 	enabled := populateSettingValuesFromConfigMap(configMapSettings)
 
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+	configSchemaVersion := s.ConfigSchemaVersion
 	if configSchemaVersion != "" && strings.TrimSpace(configSchemaVersion) == "v1" {
 		if _, err := os.Stat(configMapDebugMountPath); os.IsNotExist(err) {
 			fmt.Printf("Unsupported/missing config schema version - '%s', using defaults, please use supported schema version\n", configSchemaVersion)
 		}
 	}
 
-	file, err := os.Create(debugModeEnvVarPath)
-	if err != nil {
-		return fmt.Errorf("Exception while opening file for writing prometheus-collector config environment variables: %v\n", err)
-	}
-	defer file.Close()
-
-	//if os.Getenv("OS_TYPE") != "" && strings.ToLower(os.Getenv("OS_TYPE")) == "linux" {
-		//file.WriteString(fmt.Sprintf("export DEBUG_MODE_ENABLED=%v\n", defaultEnabled))
-	//} else {
-		file.WriteString(fmt.Sprintf("DEBUG_MODE_ENABLED=%v\n", enabled))
-
-		fmt.Printf("Setting debug mode environment variable: %v\n", enabled)
-	//}
+	s.DebugModeEnabled = enabled
+	fmt.Printf("Setting debug mode: %v\n", enabled)
 
 	if enabled {
 		controllerType := os.Getenv("CONTROLLER_TYPE")
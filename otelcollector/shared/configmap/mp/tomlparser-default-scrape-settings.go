@@ -5,6 +5,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/prometheus-collector/shared/settings"
 )
 
 func (fcl *FilesystemConfigLoader) SetDefaultScrapeSettings() (map[string]string, error) {
@@ -175,36 +177,31 @@ func (cp *ConfigProcessor) PopulateSettingValues(parsedConfig map[string]string)
 	}
 }
 
-func (fcw *FileConfigWriter) WriteDefaultScrapeSettingsToFile(filename string, cp *ConfigProcessor) error {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("Exception while opening file for writing prometheus-collector config environment variables: %s", err)
-	}
-	defer file.Close()
-
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED=%v\n", cp.Kubelet))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED=%v\n", cp.Coredns))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_CADVISOR_SCRAPING_ENABLED=%v\n", cp.Cadvisor))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_KUBEPROXY_SCRAPING_ENABLED=%v\n", cp.Kubeproxy))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_APISERVER_SCRAPING_ENABLED=%v\n", cp.Apiserver))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED=%v\n", cp.Kubestate))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED=%v\n", cp.NodeExporter))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED=%v\n", cp.PrometheusCollectorHealth))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED=%v\n", cp.Windowsexporter))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED=%v\n", cp.Windowskubeproxy))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED=%v\n", cp.Kappiebasic))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NETWORKOBSERVABILITYRETINA_SCRAPING_ENABLED=%v\n", cp.NetworkObservabilityRetina))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED=%v\n", cp.NetworkObservabilityHubble))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED=%v\n", cp.NetworkObservabilityCilium))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED=%v\n", cp.NoDefaultsEnabled))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_ACSTORCAPACITYPROVISIONER_SCRAPING_ENABLED=%v\n", cp.AcstorCapacityProvisioner))
-	file.WriteString(fmt.Sprintf("AZMON_PROMETHEUS_ACSTORMETRICSEXPORTER_SCRAPING_ENABLED=%v\n", cp.AcstorMetricsExporter))
-
-	return nil
+// setDefaultScrapeSettings records the default scrape targets enabled in s
+func (cp *ConfigProcessor) setDefaultScrapeSettings(s *settings.Settings) {
+	enabled := func(value string) bool { return strings.ToLower(value) == "true" }
+	d := s.DefaultScrape
+	d.Kubelet = enabled(cp.Kubelet)
+	d.CoreDNS = enabled(cp.Coredns)
+	d.Cadvisor = enabled(cp.Cadvisor)
+	d.KubeProxy = enabled(cp.Kubeproxy)
+	d.APIServer = enabled(cp.Apiserver)
+	d.KubeState = enabled(cp.Kubestate)
+	d.NodeExporter = enabled(cp.NodeExporter)
+	d.CollectorHealth = enabled(cp.PrometheusCollectorHealth)
+	d.WindowsExporter = enabled(cp.Windowsexporter)
+	d.WindowsKubeProxy = enabled(cp.Windowskubeproxy)
+	d.KappieBasic = enabled(cp.Kappiebasic)
+	d.NetworkObservabilityRetina = enabled(cp.NetworkObservabilityRetina)
+	d.NetworkObservabilityHubble = enabled(cp.NetworkObservabilityHubble)
+	d.NetworkObservabilityCilium = enabled(cp.NetworkObservabilityCilium)
+	d.AcstorCapacityProvisioner = enabled(cp.AcstorCapacityProvisioner)
+	d.AcstorMetricsExporter = enabled(cp.AcstorMetricsExporter)
+	s.NoDefaultScrapingEnabled = cp.NoDefaultsEnabled
 }
 
 func (c *Configurator) ConfigureDefaultScrapeSettings() {
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+	configSchemaVersion := c.Settings.ConfigSchemaVersion
 
 	fmt.Printf("Start prometheus-collector-settings Processing\n")
 
@@ -219,6 +216,7 @@ func (c *Configurator) ConfigureDefaultScrapeSettings() {
 
 	if err != nil {
 		fmt.Printf("Error loading default settings: %v\n", err)
+		c.Settings.NoDefaultScrapingEnabled = true
 		return
 	}
 
@@ -239,22 +237,16 @@ func (c *Configurator) ConfigureDefaultScrapeSettings() {
 		fmt.Printf("After replacing non-alpha-numeric characters with '_': %s\n", c.ConfigParser.ClusterAlias)
 	}
 
-	// Write default scrape settings to file
-	err = c.ConfigWriter.WriteDefaultScrapeSettingsToFile(c.ConfigFilePath, c.ConfigParser)
-	if err != nil {
-		fmt.Printf("Error writing default scrape settings to file: %v\n", err)
-		return
-	}
+	c.ConfigParser.setDefaultScrapeSettings(c.Settings)
 
 	fmt.Printf("End prometheus-collector-settings Processing\n")
 }
 
-func tomlparserDefaultScrapeSettings() {
+func tomlparserDefaultScrapeSettings(s *settings.Settings) {
 	configurator := &Configurator{
-		ConfigLoader:   &FilesystemConfigLoader{ConfigMapMountPath: defaultSettingsMountPath},
-		ConfigWriter:   &FileConfigWriter{},
-		ConfigFilePath: defaultSettingsEnvVarPath,
-		ConfigParser:   &ConfigProcessor{},
+		ConfigLoader: &FilesystemConfigLoader{ConfigMapMountPath: defaultSettingsMountPath},
+		ConfigParser: &ConfigProcessor{},
+		Settings:     s,
 	}
 
 	configurator.ConfigureDefaultScrapeSettings()
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pelletier/go-toml"
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -237,8 +238,8 @@ func populateRegexValuesWithMinimalIngestionProfile(regexValues RegexValues) {
 	}
 }
 
-func tomlparserTargetsMetricsKeepList() {
-	configSchemaVersion = os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+func tomlparserTargetsMetricsKeepList(s *settings.Settings) {
+	configSchemaVersion = s.ConfigSchemaVersion
 	shared.EchoSectionDivider("Start Processing - tomlparserTargetsMetricsKeepList")
 
 	var regexValues RegexValues
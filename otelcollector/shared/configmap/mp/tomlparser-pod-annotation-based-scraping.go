@@ -6,12 +6,11 @@ import (
 	"regexp"
 
 	"github.com/pelletier/go-toml"
+	"github.com/prometheus-collector/shared/settings"
 )
 
 const (
-	LOGGING_PREFIX                    = "pod-annotation-based-scraping"
-	envVariableTemplateName           = "AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX"
-	envVariableAnnotationsEnabledName = "AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED"
+	LOGGING_PREFIX = "pod-annotation-based-scraping"
 )
 
 func parseConfigMapForPodAnnotations() (map[string]interface{}, error) {
@@ -38,37 +37,9 @@ func isValidRegex(str string) bool {
 	return err == nil
 }
 
-func writeConfigToFile(podannotationNamespaceRegex string) error {
-	fmt.Printf("Writing configuration to file: %s\n", podAnnotationEnvVarPath)
-	file, err := os.Create(podAnnotationEnvVarPath)
-	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	if podannotationNamespaceRegex != "" {
-		linuxPrefix := ""
-		//if os.Getenv("OS_TYPE") != "" && strings.ToLower(os.Getenv("OS_TYPE")) == "linux" {
-		//	linuxPrefix = "export "
-		//}
-		// Writes the variable to the file in the format: AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX='value'
-		envVarString := fmt.Sprintf("%s%s='%s'\n", linuxPrefix, envVariableTemplateName, podannotationNamespaceRegex)
-		envVarAnnotationsEnabled := fmt.Sprintf("%s%s=%s\n", linuxPrefix, envVariableAnnotationsEnabledName, "true")
-		fmt.Printf("Writing to file: %s%s", envVarString, envVarAnnotationsEnabled)
-
-		if _, err := file.WriteString(envVarString); err != nil {
-			return fmt.Errorf("error writing to file: %v", err)
-		}
-		if _, err := file.WriteString(envVarAnnotationsEnabled); err != nil {
-			return fmt.Errorf("error writing to file: %v", err)
-		}
-
-		fmt.Println("Configuration written to file successfully.")
-	}
-	return nil
-}
-
-func configurePodAnnotationSettings() error {
+// configurePodAnnotationSettings enables the pod annotation based scraping in d when the configmap gives a
+// namespace regex
+func configurePodAnnotationSettings(d *settings.DefaultScrapeSettings) error {
 	parsedConfig, err := parseConfigMapForPodAnnotations()
 	if err != nil || parsedConfig == nil {
 		return err
@@ -77,8 +48,10 @@ func configurePodAnnotationSettings() error {
 	if err != nil {
 		return err
 	}
-	if err := writeConfigToFile(podannotationNamespaceRegex); err != nil {
-		return err
+	if podannotationNamespaceRegex != "" {
+		d.PodAnnotations = true
+		d.PodAnnotationNamespacesRegex = podannotationNamespaceRegex
+		fmt.Printf("Pod annotation based scraping enabled for the namespaces matching: %s\n", podannotationNamespaceRegex)
 	}
 	return nil
 }
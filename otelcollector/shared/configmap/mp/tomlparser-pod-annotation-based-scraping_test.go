@@ -2,12 +2,12 @@ package configmapsettings
 
 import (
 	"bufio"
-	"fmt"
 	"os"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus-collector/shared/settings"
 )
 
 var _ = Describe("ConfigMapSettings", func() {
@@ -29,7 +29,6 @@ var _ = Describe("ConfigMapSettings", func() {
 
 				// Set the configMapMountPathForPodAnnotation to the temporary file path
 				configMapMountPathForPodAnnotation = file.Name()
-				podAnnotationEnvVarPath = fmt.Sprintf("%s_out", configMapMountPathForPodAnnotation)
 
 				setEnvVars(map[string]string {
 					"AZMON_OPERATOR_ENABLED": "true",
@@ -49,20 +48,19 @@ var _ = Describe("ConfigMapSettings", func() {
 
 			It("should print the configmap namespace regex", func() {
 				capturedOutput := captureOutput(func() {
-					err := configurePodAnnotationSettings()
+					err := configurePodAnnotationSettings(&settings.DefaultScrapeSettings{})
 					Expect(err).NotTo(HaveOccurred())
 				})
 
 				Expect(capturedOutput).To(ContainSubstring("Using configmap namespace regex for podannotations: ^namespace-regex|namespace-regex-2$"))
 			})
 
-			It("should write the config to the output file", func() {
-				err := configurePodAnnotationSettings()
+			It("should enable pod annotation based scraping for the namespaces", func() {
+				d := &settings.DefaultScrapeSettings{}
+				err := configurePodAnnotationSettings(d)
 				Expect(err).NotTo(HaveOccurred())
-
-				content, err := os.ReadFile(podAnnotationEnvVarPath)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(Equal("AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX='^namespace-regex|namespace-regex-2$'\nAZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED=true\n"))
+				Expect(d.PodAnnotations).To(BeTrue())
+				Expect(d.PodAnnotationNamespacesRegex).To(Equal("^namespace-regex|namespace-regex-2$"))
 			})
 		})
 
@@ -82,46 +80,12 @@ var _ = Describe("ConfigMapSettings", func() {
 			})
 
 			It("should return an error", func() {
-				err := configurePodAnnotationSettings()
+				err := configurePodAnnotationSettings(&settings.DefaultScrapeSettings{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("configmap section not mounted, using defaults"))
 			})
 		})
 
-		Context("when the out file does not exist", func() {
-			BeforeEach(func() {
-				// Create a temporary file with the desired content
-				fileContent := `podannotationnamespaceregex = "^namespace-regex|namespace-regex-2$"`
-				file, err := os.CreateTemp("", "configmap")
-				Expect(err).NotTo(HaveOccurred())
-				defer file.Close()
-
-				_, err = file.WriteString(fileContent)
-				Expect(err).NotTo(HaveOccurred())
-
-				// Set the configMapMountPathForPodAnnotation to the temporary file path
-				configMapMountPathForPodAnnotation = file.Name()
-				podAnnotationEnvVarPath = "/path/to/nonexistent/file"
-
-				setEnvVars(map[string]string {
-					"AZMON_OPERATOR_ENABLED": "true",
-					"CONTAINER_TYPE": "ConfigReaderSidecar",
-					"CONTROLLER_TYPE": "ReplicaSet",
-					"OS_TYPE": "linux",
-					"MODE": "advanced",
-					"KUBE_STATE_NAME": "ama-metrics-ksm",
-					"POD_NAMESPACE": "kube-system",
-					"MAC": "true",
-				})
-			})
-
-			It("should return an error", func() {
-				err := configurePodAnnotationSettings()
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("error opening file"))
-			})
-		})
-
 		Context("when the config map file contains an invalid namespace regex", func() {
 			BeforeEach(func() {
 				// Create a temporary file with an invalid regex
@@ -153,7 +117,7 @@ var _ = Describe("ConfigMapSettings", func() {
 			})
 
 			It("should return an error", func() {
-				err := configurePodAnnotationSettings()
+				err := configurePodAnnotationSettings(&settings.DefaultScrapeSettings{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Invalid namespace regex for podannotations"))
 			})
@@ -5,6 +5,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/prometheus-collector/shared/settings"
 )
 
 func (fcl *FilesystemConfigLoader) ParseConfigMap() (map[string]string, error) {
@@ -49,6 +51,16 @@ func (cp *ConfigProcessor) PopulateSettingValuesFromConfigMap(parsedConfig map[s
 		}
 	}
 
+	if value, ok := parsedConfig["partial_config_acceptance"]; ok {
+		cp.PartialConfigAcceptance = strings.ToLower(value) == "true"
+		fmt.Printf("Configmap setting for partial_config_acceptance: %t\n", cp.PartialConfigAcceptance)
+	}
+
+	if value, ok := parsedConfig["suppressed_lint_rules"]; ok {
+		cp.SuppressedLintRules = strings.Trim(value, `"`)
+		fmt.Printf("Configmap setting for suppressed_lint_rules: %s\n", cp.SuppressedLintRules)
+	}
+
 	if operatorEnabled := os.Getenv("AZMON_OPERATOR_ENABLED"); operatorEnabled != "" && strings.ToLower(operatorEnabled) == "true" {
 		cp.IsOperatorEnabledChartSetting = true
 		if value, ok := parsedConfig["operator_enabled"]; ok {
@@ -64,26 +76,23 @@ func (cp *ConfigProcessor) PopulateSettingValuesFromConfigMap(parsedConfig map[s
 	}
 }
 
-func (fcw *FileConfigWriter) WriteConfigToFile(filename string, configParser *ConfigProcessor) error {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("exception while opening file for writing prometheus-collector config environment variables: %s", err)
+// setCollectorSettings records the prometheus collector settings in s
+func (cp *ConfigProcessor) setCollectorSettings(s *settings.Settings) {
+	s.Collector = settings.CollectorSettings{
+		DefaultMetricAccountName:    cp.DefaultMetricAccountName,
+		ClusterLabel:                cp.ClusterLabel,
+		ClusterAlias:                cp.ClusterAlias,
+		OperatorEnabledChartSetting: cp.IsOperatorEnabledChartSetting,
+		OperatorEnabled:             cp.IsOperatorEnabled,
+		PartialConfigAcceptance:     cp.PartialConfigAcceptance,
+		SuppressedLintRules:         cp.SuppressedLintRules,
 	}
-	defer file.Close()
-	file.WriteString(fmt.Sprintf("AZMON_DEFAULT_METRIC_ACCOUNT_NAME=%s\n", configParser.DefaultMetricAccountName))
-	file.WriteString(fmt.Sprintf("AZMON_CLUSTER_LABEL=%s\n", configParser.ClusterLabel))
-	file.WriteString(fmt.Sprintf("AZMON_CLUSTER_ALIAS=%s\n", configParser.ClusterAlias))
-	file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED_CHART_SETTING=%t\n", configParser.IsOperatorEnabledChartSetting))
-	if configParser.IsOperatorEnabled {
-		file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED=%t\n", configParser.IsOperatorEnabled))
-		file.WriteString(fmt.Sprintf("AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING=%t\n", configParser.IsOperatorEnabled))
-	}
-	return nil
 }
+
 func (c *Configurator) Configure() {
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+	configSchemaVersion := c.Settings.ConfigSchemaVersion
 
-	fmt.Printf("Configure:Print the value of AZMON_AGENT_CFG_SCHEMA_VERSION: %s\n", os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION"))
+	fmt.Printf("Configure:Print the value of AZMON_AGENT_CFG_SCHEMA_VERSION: %s\n", configSchemaVersion)
 
 	if configSchemaVersion != "" && strings.TrimSpace(configSchemaVersion) == "v1" {
 		configMapSettings, err := c.ConfigLoader.ParseConfigMap()
@@ -111,20 +120,14 @@ func (c *Configurator) Configure() {
 	fmt.Printf("AZMON_CLUSTER_ALIAS: '%s'\n", c.ConfigParser.ClusterAlias)
 	fmt.Printf("AZMON_CLUSTER_LABEL: %s\n", c.ConfigParser.ClusterLabel)
 
-	err := c.ConfigWriter.WriteConfigToFile(c.ConfigFilePath, c.ConfigParser)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		return
-	}
-
+	c.ConfigParser.setCollectorSettings(c.Settings)
 }
 
-func parseConfigAndSetEnvInFile() {
+func parseCollectorSettings(s *settings.Settings) {
 	configurator := &Configurator{
-		ConfigLoader:   &FilesystemConfigLoader{ConfigMapMountPath: collectorSettingsMountPath},
-		ConfigParser:   &ConfigProcessor{},
-		ConfigWriter:   &FileConfigWriter{ConfigProcessor: &ConfigProcessor{}},
-		ConfigFilePath: collectorSettingsEnvVarPath,
+		ConfigLoader: &FilesystemConfigLoader{ConfigMapMountPath: collectorSettingsMountPath},
+		ConfigParser: &ConfigProcessor{},
+		Settings:     s,
 	}
 
 	configurator.Configure()
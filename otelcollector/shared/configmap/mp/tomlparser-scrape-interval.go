@@ -10,6 +10,7 @@ import (
 
 	"github.com/pelletier/go-toml"
 	"github.com/prometheus-collector/shared"
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -52,8 +53,8 @@ func getConfigStringValue(configMapSettings *toml.Tree, key string) string {
 	return value
 }
 
-func processConfigMap() map[string]string {
-	configSchemaVersion := os.Getenv("AZMON_AGENT_CFG_SCHEMA_VERSION")
+func processConfigMap(s *settings.Settings) map[string]string {
+	configSchemaVersion := s.ConfigSchemaVersion
 
 	intervalHash := make(map[string]string)
 
@@ -119,9 +120,9 @@ func writeIntervalHashToFile(intervalHash map[string]string, filePath string) er
 	return nil
 }
 
-func tomlparserScrapeInterval() {
+func tomlparserScrapeInterval(s *settings.Settings) {
 	shared.EchoSectionDivider("Start Processing - tomlparserScrapeInterval")
-	intervalHash := processConfigMap()
+	intervalHash := processConfigMap(s)
 	err := writeIntervalHashToFile(intervalHash, scrapeIntervalEnvVarPath)
 	if err != nil {
 		fmt.Printf("Error writing to file: %v\n", err)
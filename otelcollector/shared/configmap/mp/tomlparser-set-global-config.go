@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/prometheus-collector/shared/settings"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -36,10 +37,8 @@ type OtelConfig struct {
 	} `yaml:"service"`
 }
 
-func SetGlobalSettingsInCollectorConfig() {
-	azmonSetGlobalSettings := os.Getenv("AZMON_SET_GLOBAL_SETTINGS")
-
-	if azmonSetGlobalSettings == "true" {
+func SetGlobalSettingsInCollectorConfig(s *settings.Settings) {
+	if s.SetGlobalSettings {
 		mergedCollectorConfigPath := "/opt/microsoft/otelcollector/collector-config.yml"
 		mergedCollectorConfigFileContents, err := os.ReadFile(mergedCollectorConfigPath)
 		if err != nil {
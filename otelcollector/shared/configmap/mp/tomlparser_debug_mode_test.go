@@ -8,6 +8,7 @@ import (
 
 	ginkgo "github.com/onsi/ginkgo/v2"
 	gomega "github.com/onsi/gomega"
+	"github.com/prometheus-collector/shared/settings"
 	"gopkg.in/yaml.v2"
 )
 
@@ -24,17 +25,10 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 			os.Setenv("CONTROLLER_TYPE", "ReplicaSet")
 			os.Setenv("OS_TYPE", "linux")
 
-			err := ConfigureDebugModeSettings()
+			s := &settings.Settings{}
+			err := ConfigureDebugModeSettings(s)
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify that the environment variable file is created
-			_, err = os.Stat(debugModeEnvVarPath)
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify the content of the environment variable file
-			content, err := os.ReadFile(debugModeEnvVarPath)
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-			gomega.Expect(string(content)).To(gomega.Equal("DEBUG_MODE_ENABLED=true\n"))
+			gomega.Expect(s.DebugModeEnabled).To(gomega.BeTrue())
 	
 			// Verify the modification of the YAML configuration file
 			config, err := parseYAMLConfigFile(replicaSetCollectorConfig)
@@ -47,17 +41,10 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 			os.Setenv("CONTROLLER_TYPE", "DaemonSet")
 			os.Setenv("OS_TYPE", "linux")
 
-			err := ConfigureDebugModeSettings()
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify that the environment variable file is created
-			_, err = os.Stat(debugModeEnvVarPath)
+			s := &settings.Settings{}
+			err := ConfigureDebugModeSettings(s)
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify the content of the environment variable file
-			content, err := os.ReadFile(debugModeEnvVarPath)
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-			gomega.Expect(string(content)).To(gomega.Equal("DEBUG_MODE_ENABLED=true\n"))
+			gomega.Expect(s.DebugModeEnabled).To(gomega.BeTrue())
 	
 			// Verify the modification of the YAML configuration file
 			config, err := parseYAMLConfigFile(replicaSetCollectorConfig)
@@ -70,17 +57,10 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 			os.Setenv("CONTROLLER_TYPE", "DaemonSet")
 			os.Setenv("OS_TYPE", "windows")
 
-			err := ConfigureDebugModeSettings()
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify that the environment variable file is created
-			_, err = os.Stat(debugModeEnvVarPath)
-			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-	
-			// Verify the content of the environment variable file
-			content, err := os.ReadFile(debugModeEnvVarPath)
+			s := &settings.Settings{}
+			err := ConfigureDebugModeSettings(s)
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
-			gomega.Expect(string(content)).To(gomega.Equal("DEBUG_MODE_ENABLED=true\n"))
+			gomega.Expect(s.DebugModeEnabled).To(gomega.BeTrue())
 	
 			// Verify the modification of the YAML configuration file
 			config, err := parseYAMLConfigFile(replicaSetCollectorConfig)
@@ -93,7 +73,6 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 			os.Unsetenv("CONTROLLER_TYPE")
 			os.Unsetenv("OS_TYPE")
 			configMapDebugMountPath = ""
-			debugModeEnvVarPath = ""
 			replicaSetCollectorConfig = ""
 			fmt.Println("Cleaning up temp files")
 			cleanupTempFiles()
@@ -111,7 +90,7 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 		err = os.Remove(fmt.Sprintf("temp/debug-mode-%s", suffix))
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
-		err = ConfigureDebugModeSettings()
+		err = ConfigureDebugModeSettings(&settings.Settings{})
 		gomega.Expect(err).To(gomega.HaveOccurred())
 		gomega.Expect(err.Error()).To(gomega.ContainSubstring("configmap section not mounted, using defaults"))
 	})
@@ -125,26 +104,11 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 		err := createTempFiles(suffix, `		[ invalid_key = true		`)
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
-		err = ConfigureDebugModeSettings()
+		err = ConfigureDebugModeSettings(&settings.Settings{})
 		gomega.Expect(err).To(gomega.HaveOccurred())
 		gomega.Expect(err.Error()).To(gomega.ContainSubstring("exception while parsing config map for debug mode"))
 	})
 
-	ginkgo.It("should handle an error while opening environment variable file", func() {
-		os.Setenv("CONTROLLER_TYPE", "ReplicaSet")
-		suffix := createRandomString(5)
-		ginkgo.DeferCleanup(func() {
-			cleanupTempFiles()
-		})
-		err := createTempFiles(suffix, `enabled = true`)
-		gomega.Expect(err).NotTo(gomega.HaveOccurred())
-		debugModeEnvVarPath = "/nonexistant-path/envvarpath"
-
-		err = ConfigureDebugModeSettings()
-		gomega.Expect(err).To(gomega.HaveOccurred())
-		gomega.Expect(err.Error()).To(gomega.ContainSubstring("Exception while opening file for writing prometheus-collector config environment variables"))
-	})
-
 	ginkgo.It("should handle an error while reading the replicaset collector config file", func() {
 		os.Setenv("CONTROLLER_TYPE", "ReplicaSet")
 		suffix := createRandomString(5)
@@ -155,7 +119,7 @@ var _ = ginkgo.Describe("When parsing debug mode settings", func() {
 		gomega.Expect(err).NotTo(gomega.HaveOccurred())
 		replicaSetCollectorConfig = "/nonexistant-path/replicasetconfig"
 
-		err = ConfigureDebugModeSettings()
+		err = ConfigureDebugModeSettings(&settings.Settings{})
 		gomega.Expect(err).To(gomega.HaveOccurred())
 		gomega.Expect(err.Error()).To(gomega.ContainSubstring("Exception while setting otlp in the exporter metrics for service pipeline when debug mode is enabled"))
 	})
@@ -183,7 +147,6 @@ func createTempFiles(suffix string, debugModeString string) (error) {
 	}
 
 	configMapDebugMountPath = fmt.Sprintf("temp/debug-mode-%s", suffix)
-	debugModeEnvVarPath = fmt.Sprintf("temp/config_debug_mode_env_var-%s", suffix)
 	replicaSetCollectorConfig = fmt.Sprintf("temp/collector-config-replicaset-%s.yml", suffix)
   sourceFile := "testdata/collector-config-replicaset.yml"
 
@@ -1,7 +1,6 @@
 package shared
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -9,8 +8,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"os/user"
-	"path/filepath"
 	"strings"
 )
 
@@ -101,73 +98,6 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func SetEnvVarsFromFile(filename string) error {
-	// Check if the file exists
-	_, e := os.Stat(filename)
-	if os.IsNotExist(e) {
-		return fmt.Errorf("File does not exist: %s", filename)
-	}
-	// Open the file for reading
-	file, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "=")
-		if len(parts) != 2 {
-			fmt.Printf("Skipping invalid line: %s\n", line)
-			continue
-		}
-
-		key := parts[0]
-		value := parts[1]
-
-		SetEnvAndSourceBashrcOrPowershell(key, value, false)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func Inotify(outputFile string, location1 string, location2 string) error {
-	// Start inotify to watch for changes
-	fmt.Println("Starting inotify for watching config map update")
-
-	_, err := os.Create(outputFile)
-	if err != nil {
-		log.Fatalf("Error creating output file: %v\n", err)
-	}
-
-	// Define the command to start inotify
-	inotifyCommand := exec.Command(
-		"inotifywait",
-		location1,
-		location2,
-		"--daemon",
-		"--recursive",
-		"--outfile", outputFile,
-		"--event", "create,delete",
-		"--format", "%e : %T",
-		"--timefmt", "+%s",
-	)
-
-	// Start the inotify process
-	err = inotifyCommand.Start()
-	if err != nil {
-		log.Fatalf("Error starting inotify process: %v\n", err)
-	}
-
-	return nil
-}
-
 func HasConfigChanged(filePath string) bool {
 	if _, err := os.Stat(filePath); err == nil {
 		fileInfo, err := os.Stat(filePath)
@@ -187,62 +117,6 @@ func WriteTerminationLog(message string) {
 	}
 }
 
-func AddLineToBashrc(line string) error {
-	// Get the home directory of the current user
-	currentUser, err := user.Current()
-	if err != nil {
-		return err
-	}
-	homeDir := currentUser.HomeDir
-
-	// Find the .bashrc file path
-	bashrcPath := filepath.Join(homeDir, ".bashrc")
-
-	// Check if the line already exists in .bashrc
-	if exists, err := lineExistsInFile(bashrcPath, line); err != nil {
-		return err
-	} else if exists {
-		return nil // Line already exists, no need to add it again
-	}
-
-	// Open .bashrc file in append mode
-	file, err := os.OpenFile(bashrcPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Add the line to .bashrc
-	_, err = file.WriteString(line + "\n")
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// Function to check if a line exists in a file
-func lineExistsInFile(filePath, line string) (bool, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return false, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if strings.TrimSpace(scanner.Text()) == line {
-			return true, nil
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return false, err
-	}
-
-	return false, nil
-}
-
 func ModifyConfigFile(configFile string, pid int, placeholder string) error {
 	// Read the contents of the config file
 	content, err := os.ReadFile(configFile)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +19,34 @@ func GetEnv(key, defaultValue string) string {
 	return value
 }
 
+// SetEnv sets a key-value pair as an environment variable of the current process, which the processes
+// started from it inherit. If echo is true, it calls EchoVar
+func SetEnv(key, value string, echo bool) error {
+	if err := os.Setenv(key, value); err != nil {
+		return fmt.Errorf("failed to set environment variable: %v", err)
+	}
+
+	if echo {
+		EchoVar(key, value)
+	}
+	return nil
+}
+
+// DefaultPrometheusCollectorHealthPort is the port fluent-bit serves the prometheus collector health metrics on
+// when PROMETHEUS_COLLECTOR_HEALTH_PORT is not set
+const DefaultPrometheusCollectorHealthPort = "2234"
+
+// PrometheusCollectorHealthPort returns the port fluent-bit serves the prometheus collector health metrics on,
+// from PROMETHEUS_COLLECTOR_HEALTH_PORT
+func PrometheusCollectorHealthPort() string {
+	port := GetEnv("PROMETHEUS_COLLECTOR_HEALTH_PORT", DefaultPrometheusCollectorHealthPort)
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		fmt.Printf("Invalid PROMETHEUS_COLLECTOR_HEALTH_PORT %q, using the default of %s\n", port, DefaultPrometheusCollectorHealthPort)
+		return DefaultPrometheusCollectorHealthPort
+	}
+	return port
+}
+
 func GetControllerType() string {
 	// Get CONTROLLER_TYPE environment variable
 	controllerType := os.Getenv("CONTROLLER_TYPE")
@@ -192,7 +221,7 @@ func SetEnvVariablesForWindows() {
 
 	// Set environment variables for process and machine
 	os.Setenv("windowsVersion", windowsVersion)
-	SetEnvAndSourceBashrcOrPowershell("windowsVersion", windowsVersion, true)
+	SetEnv("windowsVersion", windowsVersion, true)
 
 	// Resource ID override
 	mac := os.Getenv("MAC")
@@ -202,16 +231,16 @@ func SetEnvVariablesForWindows() {
 		if cluster == "" {
 			fmt.Printf("CLUSTER is empty or not set. Using %s as CLUSTER\n", nodeName)
 			os.Setenv("customResourceId", nodeName)
-			SetEnvAndSourceBashrcOrPowershell("customResourceId", nodeName, true)
+			SetEnv("customResourceId", nodeName, true)
 		} else {
 			os.Setenv("customResourceId", cluster)
-			SetEnvAndSourceBashrcOrPowershell("customResourceId", cluster, true)
+			SetEnv("customResourceId", cluster, true)
 		}
 	} else {
-		SetEnvAndSourceBashrcOrPowershell("customResourceId", cluster, true)
+		SetEnv("customResourceId", cluster, true)
 
 		aksRegion := os.Getenv("AKSREGION")
-		SetEnvAndSourceBashrcOrPowershell("customRegion", aksRegion, true)
+		SetEnv("customRegion", aksRegion, true)
 
 		// Set variables for Telegraf
 		SetTelegrafVariables(aksRegion, cluster)
@@ -225,30 +254,30 @@ func SetEnvVariablesForWindows() {
 	mcsEndpoint, mcsGlobalEndpoint := GetMcsEndpoints(customEnvironment)
 
 	// Set MCS endpoint environment variables
-	SetEnvAndSourceBashrcOrPowershell("MCS_AZURE_RESOURCE_ENDPOINT", mcsEndpoint, true)
-	SetEnvAndSourceBashrcOrPowershell("MCS_GLOBAL_ENDPOINT", mcsGlobalEndpoint, true)
+	SetEnv("MCS_AZURE_RESOURCE_ENDPOINT", mcsEndpoint, true)
+	SetEnv("MCS_GLOBAL_ENDPOINT", mcsGlobalEndpoint, true)
 }
 
 func SetTelegrafVariables(aksRegion, cluster string) {
-	SetEnvAndSourceBashrcOrPowershell("AKSREGION", aksRegion, true)
-	SetEnvAndSourceBashrcOrPowershell("CLUSTER", cluster, true)
+	SetEnv("AKSREGION", aksRegion, true)
+	SetEnv("CLUSTER", cluster, true)
 	azmonClusterAlias := os.Getenv("AZMON_CLUSTER_ALIAS")
-	SetEnvAndSourceBashrcOrPowershell("AZMON_CLUSTER_ALIAS", azmonClusterAlias, true)
+	SetEnv("AZMON_CLUSTER_ALIAS", azmonClusterAlias, true)
 }
 
 func SetMonitoringVariables() {
-	SetEnvAndSourceBashrcOrPowershell("MONITORING_ROLE_INSTANCE", "cloudAgentRoleInstanceIdentity", true)
-	SetEnvAndSourceBashrcOrPowershell("MA_RoleEnvironment_OsType", "Windows", true)
-	SetEnvAndSourceBashrcOrPowershell("MONITORING_VERSION", "2.0", true)
-	SetEnvAndSourceBashrcOrPowershell("MONITORING_ROLE", "cloudAgentRoleIdentity", true)
-	SetEnvAndSourceBashrcOrPowershell("MONITORING_IDENTITY", "use_ip_address", true)
-	SetEnvAndSourceBashrcOrPowershell("MONITORING_USE_GENEVA_CONFIG_SERVICE", "false", true)
-	SetEnvAndSourceBashrcOrPowershell("SKIP_IMDS_LOOKUP_FOR_LEGACY_AUTH", "true", true)
-	SetEnvAndSourceBashrcOrPowershell("ENABLE_MCS", "true", true)
-	SetEnvAndSourceBashrcOrPowershell("MDSD_USE_LOCAL_PERSISTENCY", "false", true)
-	SetEnvAndSourceBashrcOrPowershell("MA_RoleEnvironment_Location", os.Getenv("AKSREGION"), true)
-	SetEnvAndSourceBashrcOrPowershell("MA_RoleEnvironment_ResourceId", os.Getenv("CLUSTER"), true)
-	SetEnvAndSourceBashrcOrPowershell("MCS_CUSTOM_RESOURCE_ID", os.Getenv("CLUSTER"), true)
+	SetEnv("MONITORING_ROLE_INSTANCE", "cloudAgentRoleInstanceIdentity", true)
+	SetEnv("MA_RoleEnvironment_OsType", "Windows", true)
+	SetEnv("MONITORING_VERSION", "2.0", true)
+	SetEnv("MONITORING_ROLE", "cloudAgentRoleIdentity", true)
+	SetEnv("MONITORING_IDENTITY", "use_ip_address", true)
+	SetEnv("MONITORING_USE_GENEVA_CONFIG_SERVICE", "false", true)
+	SetEnv("SKIP_IMDS_LOOKUP_FOR_LEGACY_AUTH", "true", true)
+	SetEnv("ENABLE_MCS", "true", true)
+	SetEnv("MDSD_USE_LOCAL_PERSISTENCY", "false", true)
+	SetEnv("MA_RoleEnvironment_Location", os.Getenv("AKSREGION"), true)
+	SetEnv("MA_RoleEnvironment_ResourceId", os.Getenv("CLUSTER"), true)
+	SetEnv("MCS_CUSTOM_RESOURCE_ID", os.Getenv("CLUSTER"), true)
 }
 
 func GetMcsEndpoints(customEnvironment string) (string, string) {
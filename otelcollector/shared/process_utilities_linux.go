@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -43,66 +42,6 @@ func IsProcessRunning(processName string) bool {
 	return false
 }
 
-// SetEnvAndSourceBashrcOrPowershell sets a key-value pair as an environment variable in the .bashrc file
-// and sources the file to apply changes immediately. If echo is true, it calls EchoVar
-func SetEnvAndSourceBashrcOrPowershell(key, value string, echo bool) error {
-
-	// Set the environment variable
-	err := os.Setenv(key, value)
-	if err != nil {
-		return fmt.Errorf("failed to set environment variable: %v", err)
-	}
-
-	// Conditionally call EchoVar
-	if echo {
-		EchoVar(key, value)
-	}
-
-	if GetEnv("CCP_METRICS_ENABLED", "false") == "true" {
-		// return if in ccp mode as no bash shell present here
-		return nil
-	}
-
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get user's home directory: %v", err)
-	}
-
-	// Construct the path to .bashrc
-	bashrcPath := filepath.Join(homeDir, ".bashrc")
-
-	// Check if .bashrc exists, if not, create it
-	if _, err := os.Stat(bashrcPath); os.IsNotExist(err) {
-		file, err := os.Create(bashrcPath)
-		if err != nil {
-			return fmt.Errorf("failed to create .bashrc file: %v", err)
-		}
-		defer file.Close()
-	}
-
-	// Open the .bashrc file for appending
-	file, err := os.OpenFile(bashrcPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open .bashrc file: %v", err)
-	}
-	defer file.Close()
-
-	_, err = fmt.Fprintf(file, "export %s=%s\n", key, value)
-
-	if err != nil {
-		return fmt.Errorf("failed to write to .bashrc file: %v", err)
-	}
-
-	// Source the .bashrc file
-	cmd := exec.Command("bash", "-c", "source "+bashrcPath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to source .bashrc: %v", err)
-	}
-
-	return nil
-}
-
 func StartCommandWithOutputFile(command string, args []string, outputFile string) (int, error) {
 	cmd := exec.Command(command, args...)
 
@@ -101,39 +101,6 @@ func isProcessRunningWindows(processName string) bool {
 	return false
 }
 
-// SetEnvAndSourceBashrcOrPowershell sets a key-value pair as an environment variable.
-// If OS_TYPE is 'linux', it sets the variable in the .bashrc file and sources it.
-// If OS_TYPE is 'windows', it sets the variable in the system environment.
-func SetEnvAndSourceBashrcOrPowershell(key, value string, echo bool) error {
-	// Get the OS_TYPE from environment variables
-	osType := os.Getenv("OS_TYPE")
-
-	if osType == "linux" {
-		fmt.Println(("Should never reach here as this is the windows file"))
-	} else if osType == "windows" {
-		// On Windows, set the environment variable for the machine (persistent across sessions)
-		cmd := exec.Command("setx", key, value, "/M") // "/M" flag sets the variable for the machine
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to set environment variable on Windows (Machine scope): %v", err)
-		}
-
-		// Set the environment variable for the current process
-		err := os.Setenv(key, value)
-		if err != nil {
-			return fmt.Errorf("failed to set environment variable for current session: %v", err)
-		}
-	} else {
-		return fmt.Errorf("unsupported OS_TYPE: %s", osType)
-	}
-
-	// Conditionally call EchoVar
-	if echo {
-		EchoVar(key, value)
-	}
-
-	return nil
-}
-
 func StartCommandWithOutputFile(command string, args []string, outputFile string) (int, error) {
 	cmd := exec.Command(command, args...)
 
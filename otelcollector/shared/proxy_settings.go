@@ -51,8 +51,8 @@ func addNoProxy(target string) {
 	noProxy := os.Getenv("NO_PROXY")
 	noProxy = strings.TrimSpace(noProxy)
 	noProxy += "," + target
-	SetEnvAndSourceBashrcOrPowershell("NO_PROXY", noProxy, true)
-	SetEnvAndSourceBashrcOrPowershell("no_proxy", noProxy, true)
+	SetEnv("NO_PROXY", noProxy, true)
+	SetEnv("no_proxy", noProxy, true)
 }
 
 func setHTTPProxyEnabled() {
@@ -60,7 +60,7 @@ func setHTTPProxyEnabled() {
 	if os.Getenv("HTTP_PROXY") != "" {
 		httpProxyEnabled = "true"
 	}
-	SetEnvAndSourceBashrcOrPowershell("HTTP_PROXY_ENABLED", httpProxyEnabled, true)
+	SetEnv("HTTP_PROXY_ENABLED", httpProxyEnabled, true)
 }
 
 func ConfigureEnvironment() error {
@@ -69,7 +69,7 @@ func ConfigureEnvironment() error {
 	// Remove trailing '/' character from HTTP_PROXY and HTTPS_PROXY
 	proxyVariables := []string{"http_proxy", "HTTP_PROXY", "https_proxy", "HTTPS_PROXY"}
 	for _, v := range proxyVariables {
-		SetEnvAndSourceBashrcOrPowershell(v, removeTrailingSlash(os.Getenv(v)), true)
+		SetEnv(v, removeTrailingSlash(os.Getenv(v)), true)
 	}
 
 	addNoProxy("ama-metrics-operator-targets.kube-system.svc.cluster.local")
@@ -92,11 +92,11 @@ func ConfigureEnvironment() error {
 		password := base64.StdEncoding.EncodeToString([]byte(strings.SplitN(urlParts[0], ":", 2)[1]))
 		os.WriteFile("/opt/microsoft/proxy_password", []byte(password), 0644)
 
-		SetEnvAndSourceBashrcOrPowershell("MDSD_PROXY_MODE", "application", true)
-		SetEnvAndSourceBashrcOrPowershell("MDSD_PROXY_ADDRESS", os.Getenv("HTTPS_PROXY"), true)
+		SetEnv("MDSD_PROXY_MODE", "application", true)
+		SetEnv("MDSD_PROXY_ADDRESS", os.Getenv("HTTPS_PROXY"), true)
 		if user := strings.SplitN(urlParts[0], ":", 2)[0]; user != "" {
-			SetEnvAndSourceBashrcOrPowershell("MDSD_PROXY_USERNAME", user, true)
-			SetEnvAndSourceBashrcOrPowershell("MDSD_PROXY_PASSWORD_FILE", "/opt/microsoft/proxy_password", true)
+			SetEnv("MDSD_PROXY_USERNAME", user, true)
+			SetEnv("MDSD_PROXY_PASSWORD_FILE", "/opt/microsoft/proxy_password", true)
 		}
 	}
 
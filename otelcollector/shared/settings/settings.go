@@ -0,0 +1,240 @@
+// Package settings is the runtime settings store of the prometheus-collector container. The configmap
+// parser fills it in once at startup and saves it as a single JSON file, which the entrypoint and the
+// configuration reader then read instead of passing values through shell rc files and env var files.
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// DefaultPath is where the settings are saved in the container.
+const DefaultPath = "/opt/microsoft/configmapparser/settings.json"
+
+// ValidatorResultsPath is where the prom config validator records its results when it is run by the agent.
+const ValidatorResultsPath = "/opt/microsoft/configmapparser/prom-config-validator-results.json"
+
+// Settings holds the values computed at startup from the configmaps. They are also available as
+// environment variables under their historical names through Environ, for the external processes that
+// read them from there.
+type Settings struct {
+	ConfigSchemaVersion string `json:"configSchemaVersion,omitempty"`
+	ConfigFileVersion   string `json:"configFileVersion,omitempty"`
+	DebugModeEnabled    bool   `json:"debugModeEnabled"`
+	// Collector are the prometheus-collector-settings section of the settings configmap.
+	Collector CollectorSettings `json:"collector"`
+	// DefaultScrape are the default scrape targets of the agent, set by the configmap parser of the agent.
+	DefaultScrape *DefaultScrapeSettings `json:"defaultScrape,omitempty"`
+	// ControlPlaneScrape are the default scrape targets of the control plane, set by the configmap parser
+	// of the control plane.
+	ControlPlaneScrape *ControlPlaneScrapeSettings `json:"controlPlaneScrape,omitempty"`
+	// NoDefaultScrapingEnabled is set when none of the default scrape targets are enabled.
+	NoDefaultScrapingEnabled bool `json:"noDefaultScrapingEnabled"`
+	// UseDefaultPrometheusConfig is set when the custom prometheus config is missing or invalid and only
+	// the default scrape configs are used.
+	UseDefaultPrometheusConfig    bool `json:"useDefaultPrometheusConfig"`
+	InvalidCustomPrometheusConfig bool `json:"invalidCustomPrometheusConfig"`
+	SetGlobalSettings             bool `json:"setGlobalSettings"`
+	ValidatorResults
+}
+
+// CollectorSettings are the prometheus collector settings read from the settings configmap.
+type CollectorSettings struct {
+	DefaultMetricAccountName string `json:"defaultMetricAccountName,omitempty"`
+	ClusterLabel             string `json:"clusterLabel,omitempty"`
+	ClusterAlias             string `json:"clusterAlias,omitempty"`
+	// OperatorEnabledChartSetting is set when the chart enables the target allocator, and OperatorEnabled
+	// when the configmap does not turn it off.
+	OperatorEnabledChartSetting bool `json:"operatorEnabledChartSetting"`
+	OperatorEnabled             bool `json:"operatorEnabled"`
+	PartialConfigAcceptance     bool `json:"partialConfigAcceptance"`
+	// SuppressedLintRules are the comma separated IDs of the lint rules the custom config is not linted with
+	SuppressedLintRules string `json:"suppressedLintRules,omitempty"`
+}
+
+// DefaultScrapeSettings say which default scrape targets of the agent are enabled.
+type DefaultScrapeSettings struct {
+	Kubelet                    bool `json:"kubelet"`
+	CoreDNS                    bool `json:"coredns"`
+	Cadvisor                   bool `json:"cadvisor"`
+	KubeProxy                  bool `json:"kubeproxy"`
+	APIServer                  bool `json:"apiserver"`
+	KubeState                  bool `json:"kubestate"`
+	NodeExporter               bool `json:"nodeexporter"`
+	CollectorHealth            bool `json:"prometheuscollectorhealth"`
+	WindowsExporter            bool `json:"windowsexporter"`
+	WindowsKubeProxy           bool `json:"windowskubeproxy"`
+	KappieBasic                bool `json:"kappiebasic"`
+	NetworkObservabilityRetina bool `json:"networkobservabilityRetina"`
+	NetworkObservabilityHubble bool `json:"networkobservabilityHubble"`
+	NetworkObservabilityCilium bool `json:"networkobservabilityCilium"`
+	AcstorCapacityProvisioner  bool `json:"acstorCapacityProvisioner"`
+	AcstorMetricsExporter      bool `json:"acstorMetricsExporter"`
+	// PodAnnotations is set when the pod annotation based scraping configmap gives a namespace regex.
+	PodAnnotations               bool   `json:"podannotations"`
+	PodAnnotationNamespacesRegex string `json:"podAnnotationNamespacesRegex,omitempty"`
+}
+
+// ControlPlaneScrapeSettings say which default scrape targets of the control plane are enabled.
+type ControlPlaneScrapeSettings struct {
+	KubeControllerManager bool `json:"kubeControllerManager"`
+	KubeScheduler         bool `json:"kubeScheduler"`
+	APIServer             bool `json:"apiserver"`
+	ClusterAutoscaler     bool `json:"clusterAutoscaler"`
+	Etcd                  bool `json:"etcd"`
+}
+
+// ValidatorResults are recorded by the prom config validator when it is run by the agent.
+type ValidatorResults struct {
+	GlobalSettingsConfigured bool   `json:"globalSettingsConfigured"`
+	InvalidConfigFatalError  string `json:"invalidConfigFatalError,omitempty"`
+	// RejectedScrapeJobs is a JSON object of the error of each scrape job left out of the custom config in
+	// partial acceptance mode, keyed by job name.
+	RejectedScrapeJobs string `json:"rejectedScrapeJobs,omitempty"`
+}
+
+// Load reads the settings saved at path.
+func Load(path string) (*Settings, error) {
+	s := &Settings{}
+	if err := load(path, s); err != nil {
+		return &Settings{}, err
+	}
+	return s, nil
+}
+
+// LoadValidatorResults reads the results the prom config validator recorded at path.
+func LoadValidatorResults(path string) (ValidatorResults, error) {
+	var r ValidatorResults
+	if err := load(path, &r); err != nil {
+		return ValidatorResults{}, err
+	}
+	return r, nil
+}
+
+// Save writes the settings to path.
+func (s *Settings) Save(path string) error {
+	return save(path, s)
+}
+
+// Save writes the validator results to path.
+func (r ValidatorResults) Save(path string) error {
+	return save(path, r)
+}
+
+func load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error parsing settings file %s: %w", path, err)
+	}
+	return nil
+}
+
+// save writes v to path as JSON. The file is replaced atomically, so readers never see a partial file.
+func save(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding settings: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating settings directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing settings file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error replacing settings file: %w", err)
+	}
+	return nil
+}
+
+// Environ returns the settings keyed by their environment variable name. The default scrape targets are
+// only included for the configmap parser that set them, and the optional strings only when they are set.
+func (s *Settings) Environ() map[string]string {
+	env := map[string]string{}
+	setString := func(key, value string) {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	setBool := func(key string, value bool) {
+		env[key] = strconv.FormatBool(value)
+	}
+
+	setString("AZMON_AGENT_CFG_SCHEMA_VERSION", s.ConfigSchemaVersion)
+	setString("AZMON_AGENT_CFG_FILE_VERSION", s.ConfigFileVersion)
+	setBool("DEBUG_MODE_ENABLED", s.DebugModeEnabled)
+
+	setString("AZMON_DEFAULT_METRIC_ACCOUNT_NAME", s.Collector.DefaultMetricAccountName)
+	setString("AZMON_CLUSTER_LABEL", s.Collector.ClusterLabel)
+	setString("AZMON_CLUSTER_ALIAS", s.Collector.ClusterAlias)
+	setBool("AZMON_OPERATOR_ENABLED_CHART_SETTING", s.Collector.OperatorEnabledChartSetting)
+	setBool("AZMON_PARTIAL_CONFIG_ACCEPTANCE", s.Collector.PartialConfigAcceptance)
+	// The chart sets AZMON_OPERATOR_ENABLED, the configmap can only confirm it
+	if s.Collector.OperatorEnabled {
+		setBool("AZMON_OPERATOR_ENABLED", true)
+		setBool("AZMON_OPERATOR_ENABLED_CFG_MAP_SETTING", true)
+	}
+
+	if d := s.DefaultScrape; d != nil {
+		setBool("AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED", d.Kubelet)
+		setBool("AZMON_PROMETHEUS_COREDNS_SCRAPING_ENABLED", d.CoreDNS)
+		setBool("AZMON_PROMETHEUS_CADVISOR_SCRAPING_ENABLED", d.Cadvisor)
+		setBool("AZMON_PROMETHEUS_KUBEPROXY_SCRAPING_ENABLED", d.KubeProxy)
+		setBool("AZMON_PROMETHEUS_APISERVER_SCRAPING_ENABLED", d.APIServer)
+		setBool("AZMON_PROMETHEUS_KUBESTATE_SCRAPING_ENABLED", d.KubeState)
+		setBool("AZMON_PROMETHEUS_NODEEXPORTER_SCRAPING_ENABLED", d.NodeExporter)
+		setBool("AZMON_PROMETHEUS_COLLECTOR_HEALTH_SCRAPING_ENABLED", d.CollectorHealth)
+		setBool("AZMON_PROMETHEUS_WINDOWSEXPORTER_SCRAPING_ENABLED", d.WindowsExporter)
+		setBool("AZMON_PROMETHEUS_WINDOWSKUBEPROXY_SCRAPING_ENABLED", d.WindowsKubeProxy)
+		setBool("AZMON_PROMETHEUS_KAPPIEBASIC_SCRAPING_ENABLED", d.KappieBasic)
+		setBool("AZMON_PROMETHEUS_NETWORKOBSERVABILITYRETINA_SCRAPING_ENABLED", d.NetworkObservabilityRetina)
+		setBool("AZMON_PROMETHEUS_NETWORKOBSERVABILITYHUBBLE_SCRAPING_ENABLED", d.NetworkObservabilityHubble)
+		setBool("AZMON_PROMETHEUS_NETWORKOBSERVABILITYCILIUM_SCRAPING_ENABLED", d.NetworkObservabilityCilium)
+		setBool("AZMON_PROMETHEUS_ACSTORCAPACITYPROVISIONER_SCRAPING_ENABLED", d.AcstorCapacityProvisioner)
+		setBool("AZMON_PROMETHEUS_ACSTORMETRICSEXPORTER_SCRAPING_ENABLED", d.AcstorMetricsExporter)
+		setBool("AZMON_PROMETHEUS_POD_ANNOTATION_SCRAPING_ENABLED", d.PodAnnotations)
+		setString("AZMON_PROMETHEUS_POD_ANNOTATION_NAMESPACES_REGEX", d.PodAnnotationNamespacesRegex)
+	}
+	if c := s.ControlPlaneScrape; c != nil {
+		setBool("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_CONTROLLER_MANAGER_ENABLED", c.KubeControllerManager)
+		setBool("AZMON_PROMETHEUS_CONTROLPLANE_KUBE_SCHEDULER_ENABLED", c.KubeScheduler)
+		setBool("AZMON_PROMETHEUS_CONTROLPLANE_APISERVER_ENABLED", c.APIServer)
+		setBool("AZMON_PROMETHEUS_CONTROLPLANE_CLUSTER_AUTOSCALER_ENABLED", c.ClusterAutoscaler)
+		setBool("AZMON_PROMETHEUS_CONTROLPLANE_ETCD_ENABLED", c.Etcd)
+	}
+	setBool("AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED", s.NoDefaultScrapingEnabled)
+
+	setBool("AZMON_USE_DEFAULT_PROMETHEUS_CONFIG", s.UseDefaultPrometheusConfig)
+	setBool("AZMON_INVALID_CUSTOM_PROMETHEUS_CONFIG", s.InvalidCustomPrometheusConfig)
+	setBool("AZMON_SET_GLOBAL_SETTINGS", s.SetGlobalSettings)
+	setBool("AZMON_GLOBAL_SETTINGS_CONFIGURED", s.GlobalSettingsConfigured)
+	setString("INVALID_CONFIG_FATAL_ERROR", s.InvalidConfigFatalError)
+	setString("INVALID_CONFIG_REJECTED_JOBS", s.RejectedScrapeJobs)
+	return env
+}
+
+// Export sets the settings as environment variables of the current process, so that the external
+// processes started from it inherit them.
+func (s *Settings) Export() error {
+	env := s.Environ()
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := os.Setenv(k, env[k]); err != nil {
+			return fmt.Errorf("error exporting %s: %w", k, err)
+		}
+	}
+	return nil
+}
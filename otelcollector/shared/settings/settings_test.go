@@ -0,0 +1,97 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configmapparser", "settings.json")
+	saved := &Settings{
+		ConfigSchemaVersion: "v1",
+		ConfigFileVersion:   "ver1",
+		DebugModeEnabled:    true,
+		Collector: CollectorSettings{
+			ClusterLabel:            "alias",
+			ClusterAlias:            "alias",
+			PartialConfigAcceptance: true,
+		},
+		DefaultScrape: &DefaultScrapeSettings{
+			Kubelet:                      true,
+			PodAnnotations:               true,
+			PodAnnotationNamespacesRegex: ".*|value",
+		},
+		UseDefaultPrometheusConfig: true,
+		ValidatorResults: ValidatorResults{
+			GlobalSettingsConfigured: true,
+			RejectedScrapeJobs:       `{"job":"error"}`,
+		},
+	}
+	require.NoError(t, saved.Save(path))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, saved, loaded)
+	assert.Equal(t, saved.Environ(), loaded.Environ())
+
+	// The file is replaced, not appended to, and no temporary file is left behind
+	saved.DefaultScrape = nil
+	require.NoError(t, saved.Save(path))
+	loaded, err = Load(path)
+	require.NoError(t, err)
+	assert.Nil(t, loaded.DefaultScrape)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	loaded, err := Load(filepath.Join(t.TempDir(), "settings.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, &Settings{}, loaded)
+
+	_, err = LoadValidatorResults(filepath.Join(t.TempDir(), "results.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"debugModeEnabled": tru`), 0644))
+
+	loaded, err := Load(path)
+	assert.ErrorContains(t, err, "error parsing settings file "+path)
+	assert.Equal(t, &Settings{}, loaded)
+}
+
+func TestValidatorResultsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	saved := ValidatorResults{InvalidConfigFatalError: "1 problem(s) found in the prometheus config"}
+	require.NoError(t, saved.Save(path))
+
+	loaded, err := LoadValidatorResults(path)
+	require.NoError(t, err)
+	assert.Equal(t, saved, loaded)
+}
+
+func TestEnviron(t *testing.T) {
+	s := &Settings{
+		Collector:                CollectorSettings{ClusterLabel: "cluster"},
+		ControlPlaneScrape:       &ControlPlaneScrapeSettings{Etcd: true},
+		NoDefaultScrapingEnabled: true,
+	}
+	env := s.Environ()
+	assert.Equal(t, "cluster", env["AZMON_CLUSTER_LABEL"])
+	assert.Equal(t, "true", env["AZMON_PROMETHEUS_CONTROLPLANE_ETCD_ENABLED"])
+	assert.Equal(t, "false", env["AZMON_PROMETHEUS_CONTROLPLANE_APISERVER_ENABLED"])
+	assert.Equal(t, "true", env["AZMON_PROMETHEUS_NO_DEFAULT_SCRAPING_ENABLED"])
+	assert.Equal(t, "false", env["DEBUG_MODE_ENABLED"])
+
+	// Unset strings, the operator confirmation and the default scrape targets of the agent are left out
+	for _, key := range []string{"AZMON_CLUSTER_ALIAS", "AZMON_OPERATOR_ENABLED", "AZMON_PROMETHEUS_KUBELET_SCRAPING_ENABLED", "INVALID_CONFIG_FATAL_ERROR"} {
+		assert.NotContains(t, env, key)
+	}
+}
@@ -0,0 +1,99 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultGateInterval = time.Second
+
+// Gate is a readiness condition that is waited for before a process is started.
+type Gate struct {
+	// Name identifies the gate in logs and telemetry.
+	Name string
+	// Check returns nil once the condition is met.
+	Check func(ctx context.Context) error
+	// Timeout is how long to wait for the condition. The process is started anyway once it expires.
+	// 0 means no timeout, the gate is waited for until its condition is met or the supervisor stops.
+	Timeout time.Duration
+	// Interval between checks. Defaults to 1s.
+	Interval time.Duration
+}
+
+// GateResult is reported for every gate a process waited for.
+type GateResult struct {
+	Process string
+	Gate    string
+	Waited  time.Duration
+	// Err is the last check error if the gate timed out, nil if it was met.
+	Err error
+}
+
+// Wait polls the gate until its check succeeds, it times out or ctx is done. It returns how long it
+// waited, and the last check error if the condition was not met.
+func (g Gate) Wait(ctx context.Context) (time.Duration, error) {
+	interval := g.Interval
+	if interval == 0 {
+		interval = defaultGateInterval
+	}
+	start := time.Now()
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := g.Check(ctx)
+		if err == nil {
+			return time.Since(start), nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				err = fmt.Errorf("%w, last check error: %v", ctx.Err(), err)
+			}
+			return time.Since(start), err
+		}
+	}
+}
+
+// FileExists returns a check that succeeds once path exists and is not empty.
+func FileExists(path string) func(context.Context) error {
+	return func(context.Context) error {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("%s is empty", path)
+		}
+		return nil
+	}
+}
+
+// HTTPOK returns a check that succeeds once a GET request to url returns 200.
+func HTTPOK(url string) func(context.Context) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGateInterval = 10 * time.Millisecond
+
+func TestGateWaitSucceeds(t *testing.T) {
+	var checks atomic.Int32
+	g := Gate{
+		Name: "third check",
+		Check: func(context.Context) error {
+			if checks.Add(1) < 3 {
+				return errors.New("not ready")
+			}
+			return nil
+		},
+		Timeout:  time.Second,
+		Interval: testGateInterval,
+	}
+	waited, err := g.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), checks.Load())
+	assert.GreaterOrEqual(t, waited, 2*testGateInterval)
+}
+
+func TestGateWaitTimesOut(t *testing.T) {
+	g := Gate{
+		Name:     "never ready",
+		Check:    func(context.Context) error { return errors.New("not ready") },
+		Timeout:  50 * time.Millisecond,
+		Interval: testGateInterval,
+	}
+	waited, err := g.Wait(context.Background())
+	assert.EqualError(t, err, "not ready")
+	assert.GreaterOrEqual(t, waited, g.Timeout)
+	assert.Less(t, waited, time.Second)
+}
+
+func TestGateWaitWithoutTimeout(t *testing.T) {
+	var checks atomic.Int32
+	g := Gate{
+		Name: "ready after the default timeout would have expired",
+		Check: func(ctx context.Context) error {
+			// The check context has no deadline
+			if _, ok := ctx.Deadline(); ok {
+				return errors.New("unexpected deadline")
+			}
+			if checks.Add(1) < 5 {
+				return errors.New("not ready")
+			}
+			return nil
+		},
+		Interval: testGateInterval,
+	}
+	_, err := g.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), checks.Load())
+}
+
+func TestGateWaitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := Gate{
+		Name:     "canceled",
+		Check:    func(context.Context) error { return errors.New("not ready") },
+		Interval: testGateInterval,
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	waited, err := g.Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "not ready")
+	assert.Less(t, waited, time.Second)
+}
+
+func TestFileExists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	check := FileExists(path)
+	assert.ErrorIs(t, check(context.Background()), os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	assert.ErrorContains(t, check(context.Background()), "is empty")
+
+	require.NoError(t, os.WriteFile(path, []byte("token"), 0600))
+	assert.NoError(t, check(context.Background()))
+}
+
+func TestHTTPOK(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	check := HTTPOK(server.URL)
+	assert.EqualError(t, check(context.Background()), server.URL+" returned 503")
+	status.Store(http.StatusOK)
+	assert.NoError(t, check(context.Background()))
+
+	server.Close()
+	assert.Error(t, check(context.Background()))
+}
@@ -0,0 +1,109 @@
+package supervisor
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reapInterval is how often the zombie children are looked for.
+var reapInterval = 5 * time.Second
+
+// prSetChildSubreaper is PR_SET_CHILD_SUBREAPER from linux/prctl.h.
+const prSetChildSubreaper = 36
+
+// sysProcAttr starts every child in its own process group, so that SIGTERM reaches the
+// processes it forks as well.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminate(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGTERM); err != nil {
+		return p.Signal(syscall.SIGTERM)
+	}
+	return nil
+}
+
+func kill(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+// startReaper reaps zombie processes left behind by daemonizing or orphaned grandchildren.
+// Outside of PID 1 the supervisor registers itself as child subreaper so that orphans are
+// re-parented to it. A zombie is only reaped once it has been seen in two consecutive scans,
+// so exit statuses that another goroutine is about to collect through exec.Cmd.Wait are left alone.
+func startReaper(ctx context.Context, isSupervised func(pid int) bool) {
+	if os.Getpid() != 1 {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+			log.Printf("supervisor: failed to become child subreaper, zombies will not be reaped: %v", errno)
+			return
+		}
+	}
+	interval := reapInterval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		seen := map[int]bool{}
+		for {
+			select {
+			case <-ticker.C:
+				zombies := zombieChildren()
+				for pid := range zombies {
+					if !seen[pid] || isSupervised(pid) {
+						continue
+					}
+					var ws syscall.WaitStatus
+					if reaped, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil); err == nil && reaped == pid {
+						log.Printf("supervisor: reaped zombie process %d (exit status %d)", pid, ws.ExitStatus())
+						delete(zombies, pid)
+					}
+				}
+				seen = zombies
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// zombieChildren returns the pids of the zombie children of this process.
+func zombieChildren() map[int]bool {
+	zombies := map[int]bool{}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return zombies
+	}
+	self := os.Getpid()
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+		// The command name in parentheses may contain spaces, the fields after it are fixed:
+		// state, ppid, ...
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil && ppid == self {
+			zombies[pid] = true
+		}
+	}
+	return zombies
+}
@@ -0,0 +1,273 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	waitFor = 5 * time.Second
+	tick    = 10 * time.Millisecond
+)
+
+func newTestSupervisor(t *testing.T) *Supervisor {
+	t.Helper()
+	s := New()
+	s.InitialBackoff = 20 * time.Millisecond
+	s.MaxBackoff = 80 * time.Millisecond
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func shell(name, script string) Process {
+	return Process{Name: name, Command: "/bin/sh", Args: []string{"-c", script}}
+}
+
+func waitForState(t *testing.T, s *Supervisor, name string, state State) Status {
+	t.Helper()
+	var status Status
+	require.Eventually(t, func() bool {
+		status, _ = s.ProcessStatus(name)
+		return status.State == state
+	}, waitFor, tick, "%s did not reach %s", name, state)
+	return status
+}
+
+func TestRestartWithBackoffUntilMaxRestarts(t *testing.T) {
+	s := newTestSupervisor(t)
+	output := filepath.Join(t.TempDir(), "output")
+	crashing := shell("crashing", "echo started; exit 3")
+	crashing.MaxRestarts = 3
+	crashing.OutputFile = output
+	require.NoError(t, s.Add(crashing))
+
+	start := time.Now()
+	require.NoError(t, s.Start(context.Background()))
+	status := waitForState(t, s, "crashing", StateFailed)
+
+	// The restarts waited 20ms, 40ms and 80ms
+	assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond)
+	assert.Equal(t, 3, status.Restarts)
+	assert.Equal(t, 4, status.Crashes)
+	assert.Equal(t, "exit status 3", status.LastError)
+	assert.Zero(t, status.PID)
+
+	// The output of the restarts is appended to the output of the first start
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "started\nstarted\nstarted\nstarted\n", string(content))
+}
+
+func TestRestartResetsCrashesOfStableProcess(t *testing.T) {
+	s := newTestSupervisor(t)
+	s.StableAfter = 0
+	flapping := shell("flapping", "exit 1")
+	flapping.MaxRestarts = 1
+	require.NoError(t, s.Add(flapping))
+	require.NoError(t, s.Start(context.Background()))
+
+	// Every run counts as stable, so the crash counter never exceeds MaxRestarts
+	require.Eventually(t, func() bool {
+		status, _ := s.ProcessStatus("flapping")
+		return status.Restarts >= 3
+	}, waitFor, tick)
+	status, _ := s.ProcessStatus("flapping")
+	assert.NotEqual(t, StateFailed, status.State)
+	assert.LessOrEqual(t, status.Crashes, 1)
+}
+
+func TestRestartPolicies(t *testing.T) {
+	s := newTestSupervisor(t)
+	clean := shell("clean", "exit 0")
+	clean.Restart = RestartOnFailure
+	never := shell("never", "exit 1")
+	never.Restart = RestartNever
+	require.NoError(t, s.Add(clean))
+	require.NoError(t, s.Add(never))
+	require.NoError(t, s.Start(context.Background()))
+
+	for _, name := range []string{"clean", "never"} {
+		status := waitForState(t, s, name, StateExited)
+		assert.Zero(t, status.Restarts, name)
+	}
+}
+
+func TestStartFailureIsRetried(t *testing.T) {
+	s := newTestSupervisor(t)
+	require.NoError(t, s.Add(Process{Name: "missing", Command: "/nonexistent/command", MaxRestarts: 2}))
+	require.NoError(t, s.Start(context.Background()))
+
+	status := waitForState(t, s, "missing", StateFailed)
+	assert.Equal(t, 2, status.Restarts)
+	assert.Contains(t, status.LastError, "no such file or directory")
+}
+
+func TestStartFailsWhenDependencyGivesUp(t *testing.T) {
+	s := newTestSupervisor(t)
+	require.NoError(t, s.Add(Process{Name: "missing", Command: "/nonexistent/command", MaxRestarts: 1}))
+	dependent := shell("dependent", "sleep 10")
+	dependent.DependsOn = []string{"missing"}
+	require.NoError(t, s.Add(dependent))
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "supervisor: dependent: dependency missing is failed without having started")
+	case <-time.After(waitFor):
+		t.Fatal("Start did not return after the dependency gave up")
+	}
+	status, _ := s.ProcessStatus("dependent")
+	assert.Equal(t, StatePending, status.State)
+}
+
+func TestStartDependencyOrder(t *testing.T) {
+	s := newTestSupervisor(t)
+	var mu sync.Mutex
+	var started []string
+	preStart := func(name string, dependency string) func(context.Context) error {
+		return func(context.Context) error {
+			if dependency != "" {
+				status, _ := s.ProcessStatus(dependency)
+				assert.Equal(t, StateRunning, status.State, "%s started before %s", name, dependency)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, name)
+			return nil
+		}
+	}
+	telegraf := shell("telegraf", "sleep 10")
+	telegraf.DependsOn = []string{"otelcollector"}
+	telegraf.PreStart = preStart("telegraf", "otelcollector")
+	otelcollector := shell("otelcollector", "sleep 10")
+	otelcollector.PreStart = preStart("otelcollector", "")
+	require.NoError(t, s.Add(telegraf))
+	require.NoError(t, s.Add(otelcollector))
+
+	require.NoError(t, s.Start(context.Background()))
+	assert.Equal(t, []string{"otelcollector", "telegraf"}, started)
+	for _, status := range s.Status() {
+		assert.Equal(t, StateRunning, status.State, status.Name)
+		assert.NotZero(t, status.PID, status.Name)
+	}
+
+	s.Stop()
+	for _, status := range s.Status() {
+		assert.Equal(t, StateStopped, status.State, status.Name)
+	}
+}
+
+func TestStartPreStartFailure(t *testing.T) {
+	s := newTestSupervisor(t)
+	failing := shell("failing", "sleep 10")
+	failing.PreStart = func(context.Context) error { return os.ErrPermission }
+	require.NoError(t, s.Add(failing))
+	assert.ErrorIs(t, s.Start(context.Background()), os.ErrPermission)
+}
+
+func TestStopProcessDrains(t *testing.T) {
+	s := newTestSupervisor(t)
+	require.NoError(t, s.Add(shell("draining", `trap "exit 0" TERM; while true; do sleep 0.05; done`)))
+	require.NoError(t, s.Start(context.Background()))
+	// Let the shell install the trap
+	time.Sleep(100 * time.Millisecond)
+
+	result := s.StopProcess("draining")
+	assert.True(t, result.Running)
+	assert.False(t, result.Killed)
+	assert.Less(t, result.Took, defaultDrainTimeout)
+
+	// The stopped process is not restarted, and stopping it again is a no-op
+	time.Sleep(3 * s.InitialBackoff)
+	status, _ := s.ProcessStatus("draining")
+	assert.Equal(t, StateStopped, status.State)
+	assert.Zero(t, status.Restarts)
+	assert.Equal(t, StopResult{Process: "draining"}, s.StopProcess("draining"))
+	assert.Equal(t, StopResult{Process: "missing"}, s.StopProcess("missing"))
+}
+
+func TestStopProcessKillsAfterDrainTimeout(t *testing.T) {
+	s := newTestSupervisor(t)
+	stubborn := shell("stubborn", `trap "" TERM; while true; do sleep 0.05; done`)
+	stubborn.DrainTimeout = 200 * time.Millisecond
+	require.NoError(t, s.Add(stubborn))
+	require.NoError(t, s.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+
+	result := s.StopProcess("stubborn")
+	assert.True(t, result.Running)
+	assert.True(t, result.Killed)
+	assert.GreaterOrEqual(t, result.Took, stubborn.DrainTimeout)
+}
+
+func TestStopBeforeBoundsTheDrainTimeouts(t *testing.T) {
+	s := newTestSupervisor(t)
+	for _, name := range []string{"first", "second"} {
+		stubborn := shell(name, `trap "" TERM; while true; do sleep 0.05; done`)
+		stubborn.DrainTimeout = time.Second
+		require.NoError(t, s.Add(stubborn))
+	}
+	require.NoError(t, s.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+
+	// Each drain timeout would take 1s, both processes are killed at the deadline instead
+	start := time.Now()
+	s.StopBefore(start.Add(300 * time.Millisecond))
+	assert.Less(t, time.Since(start), time.Second)
+	for _, status := range s.Status() {
+		assert.Equal(t, StateStopped, status.State, status.Name)
+	}
+}
+
+func TestStopProcessBeforeKeepsTheShorterDrainTimeout(t *testing.T) {
+	s := newTestSupervisor(t)
+	stubborn := shell("stubborn", `trap "" TERM; while true; do sleep 0.05; done`)
+	stubborn.DrainTimeout = 200 * time.Millisecond
+	require.NoError(t, s.Add(stubborn))
+	require.NoError(t, s.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+
+	result := s.StopProcessBefore("stubborn", time.Now().Add(time.Hour))
+	assert.True(t, result.Killed)
+	assert.Less(t, result.Took, time.Second)
+}
+
+// startZombie starts a child that exits right away and is not waited for, which leaves a zombie
+func startZombie(t *testing.T) int {
+	t.Helper()
+	pid, err := syscall.ForkExec("/bin/true", []string{"true"}, &syscall.ProcAttr{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return zombieChildren()[pid] }, waitFor, tick)
+	return pid
+}
+
+func TestReaper(t *testing.T) {
+	defaultInterval := reapInterval
+	reapInterval = 20 * time.Millisecond
+	t.Cleanup(func() { reapInterval = defaultInterval })
+
+	orphan := startZombie(t)
+	supervised := startZombie(t)
+	t.Cleanup(func() {
+		var ws syscall.WaitStatus
+		_, _ = syscall.Wait4(supervised, &ws, 0, nil)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startReaper(ctx, func(pid int) bool { return pid == supervised })
+
+	require.Eventually(t, func() bool { return !zombieChildren()[orphan] }, waitFor, tick, "the zombie was not reaped")
+	// The exit status of a supervised process is left for its own Wait
+	time.Sleep(5 * reapInterval)
+	assert.True(t, zombieChildren()[supervised])
+}
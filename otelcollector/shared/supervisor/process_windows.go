@@ -0,0 +1,23 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"syscall"
+)
+
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// terminate kills the process, Windows has no SIGTERM.
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+func kill(p *os.Process) error {
+	return p.Kill()
+}
+
+// startReaper is a no-op, Windows has no zombie processes.
+func startReaper(ctx context.Context, isSupervised func(pid int) bool) {}
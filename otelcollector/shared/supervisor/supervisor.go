@@ -0,0 +1,557 @@
+// Package supervisor owns the child processes started by the prometheus-collector container entrypoint.
+// It starts them in dependency order, restarts them with backoff when they exit, keeps per-process
+// crash counters and propagates SIGTERM to them on shutdown.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// RestartPolicy decides whether a process is started again after it exits.
+type RestartPolicy int
+
+const (
+	// RestartAlways restarts the process whenever it exits.
+	RestartAlways RestartPolicy = iota
+	// RestartOnFailure restarts the process only when it exits with an error.
+	RestartOnFailure
+	// RestartNever leaves the process stopped after it exits.
+	RestartNever
+)
+
+// State is the lifecycle state of a supervised process.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateBackoff  State = "backoff"
+	// StateExited is the final state of a process that exited and is not restarted by its restart policy.
+	StateExited State = "exited"
+	// StateFailed is the final state of a process that crashed more than MaxRestarts times in a row.
+	StateFailed  State = "failed"
+	StateStopped State = "stopped"
+)
+
+const (
+	defaultDrainTimeout   = 10 * time.Second
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultStableAfter    = time.Minute
+)
+
+// Process describes a child process owned by the supervisor.
+type Process struct {
+	// Name identifies the process in logs, status and DependsOn.
+	Name    string
+	Command string
+	Args    []string
+	// Env is the environment of the process. Defaults to the environment of the supervisor.
+	Env []string
+	// Stdout and Stderr default to the supervisor's own stdout and stderr. Use io.Discard to drop the output.
+	Stdout io.Writer
+	Stderr io.Writer
+	// OutputFile, when set, receives both stdout and stderr instead of Stdout and Stderr.
+	// It is truncated on the first start and appended to on restarts, so crash output is kept.
+	OutputFile string
+	// DependsOn lists the processes that must be running before this one is started.
+	DependsOn []string
+	// PreStart is called before the process is first started, e.g. to create directories.
+	PreStart func(ctx context.Context) error
+	// Gates are waited for, in order, after PreStart and before the process is first started.
+	Gates   []Gate
+	Restart RestartPolicy
+	// MaxRestarts is the number of consecutive crashes after which the process is marked failed
+	// and not restarted again. 0 means the process is restarted forever.
+	MaxRestarts int
+	// DrainTimeout is how long the process gets to exit after SIGTERM before it is killed. Defaults to 10s.
+	DrainTimeout time.Duration
+}
+
+// Status is a snapshot of the state of a supervised process.
+type Status struct {
+	Name  string
+	State State
+	PID   int
+	// Restarts is the total number of restarts, Crashes the number of consecutive crashes since the
+	// process last ran for longer than the stable period.
+	Restarts  int
+	Crashes   int
+	StartedAt time.Time
+	ExitedAt  time.Time
+	LastError string
+}
+
+type process struct {
+	spec    Process
+	started chan struct{} // closed once the process is running for the first time
+	done    chan struct{} // closed once the process is not restarted anymore
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	exited   chan struct{} // closed when the current cmd exits
+	exitErr  error         // the result of waiting for the current cmd, set before exited is closed
+	stopping bool          // set by StopProcess, the process is not restarted anymore
+	status   Status
+}
+
+// Supervisor starts and restarts a set of processes.
+type Supervisor struct {
+	processes []*process
+	byName    map[string]*process
+
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// StableAfter is how long a process has to run before its crash counter is reset.
+	StableAfter time.Duration
+	// OnGate, when set, is called with the result of every gate that was waited for.
+	OnGate func(GateResult)
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// New returns a supervisor with the default backoff settings.
+func New() *Supervisor {
+	return &Supervisor{
+		byName:         map[string]*process{},
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		StableAfter:    defaultStableAfter,
+	}
+}
+
+// Add registers a process. Processes must be added before Start.
+func (s *Supervisor) Add(p Process) error {
+	if p.Name == "" || p.Command == "" {
+		return fmt.Errorf("supervisor: process name and command must be set")
+	}
+	if _, ok := s.byName[p.Name]; ok {
+		return fmt.Errorf("supervisor: duplicate process %q", p.Name)
+	}
+	if p.DrainTimeout == 0 {
+		p.DrainTimeout = defaultDrainTimeout
+	}
+	proc := &process{
+		spec:    p,
+		started: make(chan struct{}),
+		done:    make(chan struct{}),
+		status:  Status{Name: p.Name, State: StatePending},
+	}
+	s.processes = append(s.processes, proc)
+	s.byName[p.Name] = proc
+	return nil
+}
+
+// Start starts all processes in dependency order and returns once they have been started for the
+// first time. Processes are kept running in the background until Stop is called.
+// Start fails when a dependency exits or gives up before it was running, e.g. because its binary is
+// missing, as the processes that depend on it would never be started. The processes started before
+// the failure keep running until Stop is called.
+func (s *Supervisor) Start(ctx context.Context) error {
+	order, err := s.startOrder()
+	if err != nil {
+		return err
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	startReaper(s.ctx, s.isSupervised)
+
+	for _, p := range order {
+		for _, dep := range p.spec.DependsOn {
+			if err := s.waitForStart(s.byName[dep]); err != nil {
+				return fmt.Errorf("supervisor: %s: %w", p.spec.Name, err)
+			}
+		}
+		if p.spec.PreStart != nil {
+			if err := p.spec.PreStart(s.ctx); err != nil {
+				return fmt.Errorf("supervisor: %s: pre-start failed: %w", p.spec.Name, err)
+			}
+		}
+		s.waitForGates(p)
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		if err := s.startProcess(p, false); err != nil {
+			// A process that cannot be started at all is retried like one that crashed.
+			log.Printf("supervisor: failed to start %s: %v", p.spec.Name, err)
+		}
+		s.wg.Add(1)
+		go s.supervise(p)
+	}
+	return nil
+}
+
+// waitForStart waits until the dependency p is running for the first time.
+func (s *Supervisor) waitForStart(p *process) error {
+	select {
+	case <-p.started:
+		return nil
+	case <-p.done:
+		// started and done may both be closed if the process ran before it stopped being restarted
+		select {
+		case <-p.started:
+			return nil
+		default:
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return fmt.Errorf("dependency %s is %s without having started: %s", p.spec.Name, p.status.State, p.status.LastError)
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// waitForGates waits for the gates of a process. A gate that times out is logged and reported,
+// but does not keep the process from starting.
+func (s *Supervisor) waitForGates(p *process) {
+	for _, g := range p.spec.Gates {
+		if g.Timeout > 0 {
+			log.Printf("supervisor: %s: waiting up to %s for %s", p.spec.Name, g.Timeout, g.Name)
+		} else {
+			log.Printf("supervisor: %s: waiting for %s without timeout", p.spec.Name, g.Name)
+		}
+		waited, err := g.Wait(s.ctx)
+		if err != nil {
+			log.Printf("supervisor: %s: %s not ready after %s, starting anyway: %v", p.spec.Name, g.Name, waited.Round(time.Millisecond), err)
+		} else {
+			log.Printf("supervisor: %s: %s ready after %s", p.spec.Name, g.Name, waited.Round(time.Millisecond))
+		}
+		if s.OnGate != nil {
+			s.OnGate(GateResult{Process: p.spec.Name, Gate: g.Name, Waited: waited, Err: err})
+		}
+	}
+}
+
+// Stop stops all processes in reverse dependency order. Each process is sent SIGTERM and killed if it
+// has not exited within its drain timeout. Processes already stopped with StopProcess are skipped.
+func (s *Supervisor) Stop() {
+	s.StopBefore(time.Time{})
+}
+
+// StopBefore is Stop, except that the processes still running at deadline are killed even if their drain
+// timeout has not expired, so that stopping them one after the other does not take longer than the time
+// the caller was given. A zero deadline leaves the drain timeouts as they are.
+func (s *Supervisor) StopBefore(deadline time.Time) {
+	s.stopOnce.Do(func() {
+		if s.cancel == nil {
+			return
+		}
+		s.cancel()
+		order, _ := s.startOrder()
+		for i := len(order) - 1; i >= 0; i-- {
+			s.StopProcessBefore(order[i].spec.Name, deadline)
+		}
+		s.wg.Wait()
+	})
+}
+
+// StopResult describes how a process was stopped.
+type StopResult struct {
+	Process string
+	// Running is false when the process had already exited, or was never started.
+	Running bool
+	// Took is how long the process took to exit after SIGTERM.
+	Took time.Duration
+	// Killed is set when the process did not exit within its drain timeout.
+	Killed bool
+}
+
+// StopProcess sends SIGTERM to a single process and waits up to its drain timeout for it to exit,
+// killing it afterwards. The process is not restarted.
+func (s *Supervisor) StopProcess(name string) StopResult {
+	return s.StopProcessBefore(name, time.Time{})
+}
+
+// StopProcessBefore is StopProcess, except that the process is killed at deadline if its drain timeout
+// expires later. A zero deadline leaves the drain timeout as it is.
+func (s *Supervisor) StopProcessBefore(name string, deadline time.Time) StopResult {
+	result := StopResult{Process: name}
+	p, ok := s.byName[name]
+	if !ok {
+		return result
+	}
+	p.mu.Lock()
+	cmd, exited := p.cmd, p.exited
+	p.stopping = true
+	if p.status.State != StateFailed {
+		p.status.State = StateStopped
+	}
+	p.mu.Unlock()
+	if cmd == nil || exited == nil {
+		return result
+	}
+	select {
+	case <-exited:
+		return result
+	default:
+	}
+
+	result.Running = true
+	start := time.Now()
+	drainTimeout := p.spec.DrainTimeout
+	if !deadline.IsZero() {
+		drainTimeout = min(drainTimeout, max(deadline.Sub(start), 0))
+	}
+	log.Printf("supervisor: stopping %s (pid %d), waiting up to %s", name, cmd.Process.Pid, drainTimeout)
+	if err := terminate(cmd.Process); err != nil {
+		log.Printf("supervisor: failed to send SIGTERM to %s: %v", name, err)
+	}
+	select {
+	case <-exited:
+		log.Printf("supervisor: %s stopped", name)
+	case <-time.After(drainTimeout):
+		log.Printf("supervisor: %s did not stop within %s, killing it", name, drainTimeout)
+		_ = kill(cmd.Process)
+		<-exited
+		result.Killed = true
+	}
+	result.Took = time.Since(start)
+	return result
+}
+
+// Status returns the status of every process, in the order they were added.
+func (s *Supervisor) Status() []Status {
+	statuses := make([]Status, 0, len(s.processes))
+	for _, p := range s.processes {
+		p.mu.Lock()
+		statuses = append(statuses, p.status)
+		p.mu.Unlock()
+	}
+	return statuses
+}
+
+// ProcessStatus returns the status of the named process.
+func (s *Supervisor) ProcessStatus(name string) (Status, bool) {
+	p, ok := s.byName[name]
+	if !ok {
+		return Status{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status, true
+}
+
+// startOrder sorts the processes so that every process comes after its dependencies.
+func (s *Supervisor) startOrder() ([]*process, error) {
+	order := make([]*process, 0, len(s.processes))
+	visited := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(p *process) error
+	visit = func(p *process) error {
+		name := p.spec.Name
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("supervisor: dependency cycle at %q", name)
+		}
+		visiting[name] = true
+		for _, dep := range p.spec.DependsOn {
+			d, ok := s.byName[dep]
+			if !ok {
+				return fmt.Errorf("supervisor: %q depends on unknown process %q", name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		order = append(order, p)
+		return nil
+	}
+	for _, p := range s.processes {
+		if err := visit(p); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (s *Supervisor) startProcess(p *process, restart bool) error {
+	cmd := exec.Command(p.spec.Command, p.spec.Args...)
+	cmd.Env = p.spec.Env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.SysProcAttr = sysProcAttr()
+	cmd.Stdout, cmd.Stderr = p.spec.Stdout, p.spec.Stderr
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	var outputFile *os.File
+	if p.spec.OutputFile != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if restart {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(p.spec.OutputFile, flags, 0644)
+		if err != nil {
+			s.recordError(p, err)
+			return fmt.Errorf("error opening output file: %v", err)
+		}
+		cmd.Stdout, cmd.Stderr = f, f
+		outputFile = f
+	}
+
+	p.mu.Lock()
+	p.status.State = StateStarting
+	p.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		if outputFile != nil {
+			outputFile.Close()
+		}
+		s.recordError(p, err)
+		return err
+	}
+
+	exited := make(chan struct{})
+	p.mu.Lock()
+	p.cmd = cmd
+	p.exited = exited
+	p.status.State = StateRunning
+	p.status.PID = cmd.Process.Pid
+	p.status.StartedAt = time.Now()
+	p.mu.Unlock()
+	select {
+	case <-p.started:
+	default:
+		close(p.started)
+	}
+	log.Printf("supervisor: started %s (pid %d)", p.spec.Name, cmd.Process.Pid)
+
+	go func() {
+		err := cmd.Wait()
+		if outputFile != nil {
+			outputFile.Close()
+		}
+		p.mu.Lock()
+		p.exitErr = err
+		p.status.ExitedAt = time.Now()
+		p.status.PID = 0
+		if err != nil {
+			p.status.LastError = err.Error()
+		}
+		p.mu.Unlock()
+		close(exited)
+	}()
+	return nil
+}
+
+func (s *Supervisor) recordError(p *process, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.LastError = err.Error()
+	p.status.ExitedAt = time.Now()
+}
+
+// supervise waits for the process to exit and restarts it according to its restart policy.
+func (s *Supervisor) supervise(p *process) {
+	defer s.wg.Done()
+	defer close(p.done)
+	for {
+		p.mu.Lock()
+		exited := p.exited
+		p.mu.Unlock()
+
+		// exited is nil when the process could not be started, which counts as a crash.
+		startFailed := exited == nil
+		if !startFailed {
+			select {
+			case <-exited:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		p.mu.Lock()
+		if s.ctx.Err() != nil || p.stopping {
+			p.mu.Unlock()
+			return
+		}
+		exitErr := p.exitErr
+		if !startFailed && p.status.ExitedAt.Sub(p.status.StartedAt) >= s.StableAfter {
+			p.status.Crashes = 0
+		}
+		if exitErr != nil || startFailed {
+			p.status.Crashes++
+		}
+		crashes := p.status.Crashes
+		p.mu.Unlock()
+
+		if p.spec.Restart == RestartNever || (p.spec.Restart == RestartOnFailure && exitErr == nil && !startFailed) {
+			p.mu.Lock()
+			p.status.State = StateExited
+			p.mu.Unlock()
+			log.Printf("supervisor: %s exited (%v), not restarting", p.spec.Name, exitErr)
+			return
+		}
+		if p.spec.MaxRestarts > 0 && crashes > p.spec.MaxRestarts {
+			p.mu.Lock()
+			p.status.State = StateFailed
+			p.mu.Unlock()
+			log.Printf("supervisor: %s crashed %d times in a row, giving up", p.spec.Name, crashes)
+			return
+		}
+
+		delay := s.backoff(crashes)
+		p.mu.Lock()
+		p.status.State = StateBackoff
+		p.mu.Unlock()
+		log.Printf("supervisor: %s exited (%v), consecutive crashes: %d, restarting in %s", p.spec.Name, exitErr, crashes, delay)
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return
+		}
+
+		p.mu.Lock()
+		p.exited = nil
+		p.exitErr = nil
+		p.status.Restarts++
+		p.mu.Unlock()
+		if err := s.startProcess(p, true); err != nil {
+			log.Printf("supervisor: failed to restart %s: %v", p.spec.Name, err)
+		}
+	}
+}
+
+// backoff returns the delay before the next restart, doubling with every consecutive crash.
+func (s *Supervisor) backoff(crashes int) time.Duration {
+	delay := s.InitialBackoff
+	for i := 1; i < crashes && delay < s.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.MaxBackoff {
+		delay = s.MaxBackoff
+	}
+	return delay
+}
+
+// isSupervised reports whether pid belongs to a process owned by the supervisor, whose exit status is
+// collected by its own goroutine and must not be reaped.
+func (s *Supervisor) isSupervised(pid int) bool {
+	for _, p := range s.processes {
+		p.mu.Lock()
+		cmd := p.cmd
+		p.mu.Unlock()
+		if cmd != nil && cmd.Process != nil && cmd.Process.Pid == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func names(order []*process) []string {
+	result := make([]string, 0, len(order))
+	for _, p := range order {
+		result = append(result, p.spec.Name)
+	}
+	return result
+}
+
+func TestStartOrder(t *testing.T) {
+	s := New()
+	require.NoError(t, s.Add(Process{Name: "telegraf", Command: "telegraf", DependsOn: []string{"otelcollector"}}))
+	require.NoError(t, s.Add(Process{Name: "fluent-bit", Command: "fluent-bit", DependsOn: []string{"otelcollector", "mdsd"}}))
+	require.NoError(t, s.Add(Process{Name: "otelcollector", Command: "otelcollector"}))
+	require.NoError(t, s.Add(Process{Name: "mdsd", Command: "mdsd"}))
+
+	order, err := s.startOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"otelcollector", "telegraf", "mdsd", "fluent-bit"}, names(order))
+}
+
+func TestStartOrderErrors(t *testing.T) {
+	s := New()
+	require.NoError(t, s.Add(Process{Name: "a", Command: "a", DependsOn: []string{"b"}}))
+	require.NoError(t, s.Add(Process{Name: "b", Command: "b", DependsOn: []string{"c"}}))
+	require.NoError(t, s.Add(Process{Name: "c", Command: "c", DependsOn: []string{"a"}}))
+	_, err := s.startOrder()
+	assert.ErrorContains(t, err, "dependency cycle")
+
+	s = New()
+	require.NoError(t, s.Add(Process{Name: "a", Command: "a", DependsOn: []string{"missing"}}))
+	_, err = s.startOrder()
+	assert.ErrorContains(t, err, `"a" depends on unknown process "missing"`)
+}
+
+func TestAdd(t *testing.T) {
+	s := New()
+	assert.Error(t, s.Add(Process{Name: "a"}))
+	assert.Error(t, s.Add(Process{Command: "a"}))
+	require.NoError(t, s.Add(Process{Name: "a", Command: "a"}))
+	assert.ErrorContains(t, s.Add(Process{Name: "a", Command: "a"}), "duplicate")
+	assert.Equal(t, defaultDrainTimeout, s.byName["a"].spec.DrainTimeout)
+
+	status, ok := s.ProcessStatus("a")
+	require.True(t, ok)
+	assert.Equal(t, StatePending, status.State)
+	_, ok = s.ProcessStatus("missing")
+	assert.False(t, ok)
+}
+
+func TestBackoff(t *testing.T) {
+	s := New()
+	s.InitialBackoff = time.Second
+	s.MaxBackoff = 5 * time.Second
+	var delays []time.Duration
+	for crashes := 1; crashes <= 5; crashes++ {
+		delays = append(delays, s.backoff(crashes))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+}
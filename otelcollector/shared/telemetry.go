@@ -45,14 +45,14 @@ func SetupTelemetry(customEnvironment string) {
 	}
 
 	// Export APPLICATIONINSIGHTS_AUTH
-	err := SetEnvAndSourceBashrcOrPowershell("APPLICATIONINSIGHTS_AUTH", encodedAIKey, false)
+	err := SetEnv("APPLICATIONINSIGHTS_AUTH", encodedAIKey, false)
 	if err != nil {
 		fmt.Println("Error setting APPLICATIONINSIGHTS_AUTH environment variable:", err)
 		return
 	}
 
 	// Export APPLICATIONINSIGHTS_ENDPOINT
-	err = SetEnvAndSourceBashrcOrPowershell("APPLICATIONINSIGHTS_ENDPOINT", aiEndpoint , false)
+	err = SetEnv("APPLICATIONINSIGHTS_ENDPOINT", aiEndpoint , false)
 	if err != nil {
 		fmt.Println("Error setting APPLICATIONINSIGHTS_ENDPOINT environment variable:", err)
 		return
@@ -66,7 +66,7 @@ func SetupTelemetry(customEnvironment string) {
 	}
 	aiKey = string(aiKeyBytes)
 
-	err = SetEnvAndSourceBashrcOrPowershell("TELEMETRY_APPLICATIONINSIGHTS_KEY", aiKey, false)
+	err = SetEnv("TELEMETRY_APPLICATIONINSIGHTS_KEY", aiKey, false)
 	if err != nil {
 		fmt.Println("Error setting TELEMETRY_APPLICATIONINSIGHTS_KEY environment variable:", err)
 		return
@@ -0,0 +1,355 @@
+// Package watcher notifies about changes to mounted configuration, such as ConfigMaps, Secrets and the
+// token config written by mdsd. Bursts of filesystem events are debounced, and a change is only reported
+// when the content of a watched file changed, so the symlink swap the kubelet does when it refreshes a
+// volume without changing its data is ignored.
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	defaultDebounce      = time.Second
+	defaultRetryInterval = 10 * time.Second
+)
+
+// Event describes a change to the content of a watched path.
+type Event struct {
+	// Path is the watched path the change was detected under.
+	Path string
+	// Added, Modified and Removed list the files whose content changed.
+	Added    []string
+	Modified []string
+	Removed  []string
+	Time     time.Time
+}
+
+func (e Event) String() string {
+	var parts []string
+	for _, files := range []struct {
+		op    string
+		files []string
+	}{{"added", e.Added}, {"modified", e.Modified}, {"removed", e.Removed}} {
+		if len(files.files) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", files.op, strings.Join(files.files, ", ")))
+		}
+	}
+	return fmt.Sprintf("%s changed: %s", e.Path, strings.Join(parts, "; "))
+}
+
+// Watcher watches files and directories, recursively, for content changes.
+type Watcher struct {
+	// Debounce is how long the watcher waits for events to settle before comparing content. Defaults to 1s.
+	Debounce time.Duration
+	// RetryInterval is how often paths that do not exist yet are checked for. Defaults to 10s.
+	RetryInterval time.Duration
+
+	paths []string
+	fsw   *fsnotify.Watcher
+
+	mu        sync.Mutex
+	callbacks []func(Event)
+	snapshots map[string]map[string]string
+	dirs      map[string]bool
+	pending   map[string]bool
+	dirty     map[string]bool
+	changes   []Event
+}
+
+// New returns a watcher for paths. A path that does not exist yet is watched once it is created; its
+// content at that point is not reported as a change.
+func New(paths ...string) *Watcher {
+	cleaned := make([]string, len(paths))
+	for i, p := range paths {
+		cleaned[i] = filepath.Clean(p)
+	}
+	return &Watcher{
+		paths:     cleaned,
+		snapshots: map[string]map[string]string{},
+		dirs:      map[string]bool{},
+		pending:   map[string]bool{},
+		dirty:     map[string]bool{},
+	}
+}
+
+// OnChange registers fn to be called for every change. It must be called before Start.
+func (w *Watcher) OnChange(fn func(Event)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Start begins watching. The watcher stops when ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	w.fsw = fsw
+	if w.Debounce == 0 {
+		w.Debounce = defaultDebounce
+	}
+	if w.RetryInterval == 0 {
+		w.RetryInterval = defaultRetryInterval
+	}
+
+	w.mu.Lock()
+	for _, p := range w.paths {
+		if err := w.add(p); err != nil {
+			w.pending[p] = true
+		}
+	}
+	w.mu.Unlock()
+
+	go w.run(ctx)
+	return nil
+}
+
+// Changed reports whether any watched content changed since the watcher started.
+func (w *Watcher) Changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.changes) > 0
+}
+
+// Changes returns every change since the watcher started, oldest first.
+func (w *Watcher) Changes() []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Event(nil), w.changes...)
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer w.fsw.Close()
+
+	debounce := time.NewTimer(w.Debounce)
+	debounce.Stop()
+	retry := time.NewTicker(w.RetryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+			debounce.Reset(w.Debounce)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher error: %v\n", err)
+		case <-debounce.C:
+			w.compare()
+		case <-retry.C:
+			w.addPending()
+		}
+	}
+}
+
+// handle marks the paths an event belongs to as dirty, and watches new directories.
+func (w *Watcher) handle(ev fsnotify.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range w.paths {
+		if w.pending[p] || !w.covers(p, ev.Name) {
+			continue
+		}
+		w.dirty[p] = true
+		if ev.Has(fsnotify.Create) && !hidden(ev.Name) {
+			if info, err := os.Stat(ev.Name); err == nil && info.IsDir() && ev.Name != p {
+				if err := w.addDirs(ev.Name); err != nil {
+					log.Printf("Error watching %s: %v\n", ev.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// covers reports whether an event for name may change the content under the watched path p. Files are
+// watched through their parent directory, so that atomic replaces and symlink swaps are seen.
+func (w *Watcher) covers(p, name string) bool {
+	if w.dirs[p] {
+		return name == p || strings.HasPrefix(name, p+string(filepath.Separator))
+	}
+	return filepath.Dir(name) == filepath.Dir(p)
+}
+
+// add starts watching p and takes the snapshot changes are compared to.
+func (w *Watcher) add(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = w.addDirs(p)
+	} else {
+		err = w.fsw.Add(filepath.Dir(p))
+	}
+	if err != nil {
+		return err
+	}
+	snapshot, err := snapshot(p)
+	if err != nil {
+		return err
+	}
+	w.snapshots[p] = snapshot
+	w.dirs[p] = info.IsDir()
+	return nil
+}
+
+// addDirs watches dir and its subdirectories. The timestamped data directories of ConfigMap and
+// Secret volumes are skipped, their changes show up as the swap of the ..data symlink.
+func (w *Watcher) addDirs(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && hidden(path) {
+			return filepath.SkipDir
+		}
+		return w.fsw.Add(path)
+	})
+}
+
+func (w *Watcher) addPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for p := range w.pending {
+		if err := w.add(p); err == nil {
+			delete(w.pending, p)
+		}
+	}
+}
+
+// compare hashes the content of the dirty paths and reports the ones that changed.
+func (w *Watcher) compare() {
+	w.mu.Lock()
+	var events []Event
+	for p := range w.dirty {
+		current, err := snapshot(p)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %v\n", p, err)
+			continue
+		}
+		if ev, changed := diff(p, w.snapshots[p], current); changed {
+			events = append(events, ev)
+		}
+		w.snapshots[p] = current
+	}
+	w.dirty = map[string]bool{}
+	w.changes = append(w.changes, events...)
+	callbacks := w.callbacks
+	w.mu.Unlock()
+
+	for _, ev := range events {
+		for _, fn := range callbacks {
+			fn(ev)
+		}
+	}
+}
+
+func diff(p string, before, after map[string]string) (Event, bool) {
+	ev := Event{Path: p, Time: time.Now()}
+	for file, hash := range after {
+		prev, ok := before[file]
+		switch {
+		case !ok:
+			ev.Added = append(ev.Added, file)
+		case prev != hash:
+			ev.Modified = append(ev.Modified, file)
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			ev.Removed = append(ev.Removed, file)
+		}
+	}
+	sort.Strings(ev.Added)
+	sort.Strings(ev.Modified)
+	sort.Strings(ev.Removed)
+	return ev, len(ev.Added)+len(ev.Modified)+len(ev.Removed) > 0
+}
+
+// snapshot returns the hash of every file under p, or of p itself if it is a file. Symlinks to files are
+// followed, and hidden volume data directories are skipped as they are reached through those symlinks.
+func snapshot(p string) (map[string]string, error) {
+	files := map[string]string{}
+	info, err := os.Stat(p)
+	if err != nil {
+		return files, err
+	}
+	if !info.IsDir() {
+		hash, err := hashFile(p)
+		if err != nil {
+			return files, err
+		}
+		files[p] = hash
+		return files, nil
+	}
+	err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != p && hidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			// dangling symlink, or one to a directory
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return nil
+		}
+		files[path] = hash
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hidden reports whether path is one of the ..data entries of a ConfigMap or Secret volume.
+func hidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "..")
+}
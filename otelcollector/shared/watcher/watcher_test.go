@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDebounce = 50 * time.Millisecond
+	waitFor      = 2 * time.Second
+	tick         = 10 * time.Millisecond
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) record(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *recorder) get() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event{}, r.events...)
+}
+
+func startWatcher(t *testing.T, paths ...string) (*Watcher, *recorder) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := New(paths...)
+	w.Debounce = testDebounce
+	w.RetryInterval = testDebounce
+	r := &recorder{}
+	w.OnChange(r.record)
+	require.NoError(t, w.Start(ctx))
+	return w, r
+}
+
+// writeVolume lays out dir like the kubelet does for a ConfigMap volume: the data in a timestamped
+// directory, a ..data symlink to it, and a symlink per key to ..data/key.
+func writeVolume(t *testing.T, dir, version string, data map[string]string) {
+	t.Helper()
+	dataDir := filepath.Join(dir, "..data_"+version)
+	require.NoError(t, os.MkdirAll(dataDir, 0755))
+	for k, v := range data {
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, k), []byte(v), 0644))
+		link := filepath.Join(dir, k)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			require.NoError(t, os.Symlink(filepath.Join("..data", k), link))
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(filepath.Base(dataDir), tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+}
+
+func TestWatcherDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("1"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	w, r := startWatcher(t, dir)
+	assert.False(t, w.Changed())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("2"), 0644))
+	require.Eventually(t, func() bool { return len(r.get()) == 1 }, waitFor, tick)
+	ev := r.get()[0]
+	assert.Equal(t, dir, ev.Path)
+	assert.Equal(t, []string{filepath.Join(dir, "a")}, ev.Modified)
+	assert.True(t, w.Changed())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("1"), 0644))
+	require.Eventually(t, func() bool { return len(r.get()) == 2 }, waitFor, tick)
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "b")}, r.get()[1].Added)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "a")))
+	require.Eventually(t, func() bool { return len(r.get()) == 3 }, waitFor, tick)
+	assert.Equal(t, []string{filepath.Join(dir, "a")}, r.get()[2].Removed)
+	assert.Len(t, w.Changes(), 3)
+}
+
+func TestWatcherIgnoresUnchangedContent(t *testing.T) {
+	dir := t.TempDir()
+	writeVolume(t, dir, "1", map[string]string{"key": "value"})
+
+	w, r := startWatcher(t, dir)
+
+	// the kubelet refreshing the volume with the same data
+	writeVolume(t, dir, "2", map[string]string{"key": "value"})
+	// a file created and removed within the debounce window
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transient"), nil, 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "transient")))
+	time.Sleep(5 * testDebounce)
+	assert.Empty(t, r.get())
+	assert.False(t, w.Changed())
+
+	writeVolume(t, dir, "3", map[string]string{"key": "new value"})
+	require.Eventually(t, func() bool { return len(r.get()) == 1 }, waitFor, tick)
+	assert.Equal(t, []string{filepath.Join(dir, "key")}, r.get()[0].Modified)
+}
+
+func TestWatcherFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "TokenConfig.json")
+	require.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), []byte("1"), 0644))
+
+	_, r := startWatcher(t, file)
+
+	// rewriting the same content and changing a sibling are not changes of the file
+	require.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), []byte("2"), 0644))
+	time.Sleep(5 * testDebounce)
+	assert.Empty(t, r.get())
+
+	// atomic replace
+	tmp := filepath.Join(dir, "TokenConfig.json.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(`{"a":1}`), 0644))
+	require.NoError(t, os.Rename(tmp, file))
+	require.Eventually(t, func() bool { return len(r.get()) == 1 }, waitFor, tick)
+	assert.Equal(t, []string{file}, r.get()[0].Modified)
+}
+
+func TestWatcherPathCreatedLater(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	w, r := startWatcher(t, dir)
+
+	staged := dir + ".staged"
+	require.NoError(t, os.Mkdir(staged, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(staged, "ca.crt"), []byte("1"), 0644))
+	require.NoError(t, os.Rename(staged, dir))
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return !w.pending[dir]
+	}, waitFor, tick)
+	time.Sleep(5 * testDebounce)
+	assert.Empty(t, r.get(), "the content found when the path appears is the baseline")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("2"), 0644))
+	require.Eventually(t, func() bool { return len(r.get()) == 1 }, waitFor, tick)
+}
+
+func TestEventString(t *testing.T) {
+	ev := Event{Path: "/etc/config/settings", Added: []string{"a"}, Modified: []string{"b", "c"}}
+	assert.Equal(t, "/etc/config/settings changed: added a; modified b, c", ev.String())
+}
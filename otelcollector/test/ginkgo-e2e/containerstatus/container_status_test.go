@@ -69,8 +69,6 @@ var _ = DescribeTable("All processes are running",
 			"otelcollector",
 			"mdsd -a -A -e",
 			"MetricsExtension",
-			"inotifywait /etc/config/settings",
-			"inotifywait /etc/mdsd.d",
 			"crond",
 		},
 	),
@@ -80,8 +78,6 @@ var _ = DescribeTable("All processes are running",
 			"otelcollector",
 			"mdsd -a -A -e",
 			"MetricsExtension",
-			"inotifywait /etc/config/settings",
-			"inotifywait /etc/mdsd.d",
 			"crond",
 		},
 	),
@@ -40,7 +40,7 @@ var _ = Describe("When replicaset prometheus-collector container liveness probe
     err := utils.GetAndUpdateConfigMap(K8sClient, "ama-metrics-prometheus-config", "kube-system")
     Expect(err).NotTo(HaveOccurred())
     err = utils.WatchForPodRestart(K8sClient, "kube-system", "rsName", "ama-metrics", 120, "prometheus-collector",
-      "inotifyoutput.txt has been updated - config changed",
+      "config changed - /etc/config/settings changed",
     )
     Expect(err).NotTo(HaveOccurred())
   })
@@ -71,7 +71,7 @@ var _ = Describe("When the daemonset prometheus-collector container liveness pro
     err := utils.GetAndUpdateConfigMap(K8sClient, "ama-metrics-prometheus-config-node", "kube-system")
     Expect(err).NotTo(HaveOccurred())
     err = utils.WatchForPodRestart(K8sClient, "kube-system", "dsName", "ama-metrics-node", 120, "prometheus-collector",
-      "inotifyoutput.txt has been updated - config changed",
+      "config changed - /etc/config/settings changed",
     )
     Expect(err).NotTo(HaveOccurred())
   })